@@ -0,0 +1,49 @@
+package coercion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type closeOptions struct {
+	immediate bool
+}
+
+// CloseOption is an optional argument for Workstream.Close().
+type CloseOption func(*closeOptions) error
+
+// WithCloseImmediate closes the Workstream without waiting for running Actions to finish. Running Plans are
+// left as if the process crashed, so recovery handles them like any other crash and WithMaxLastUpdate() applies.
+func WithCloseImmediate() CloseOption {
+	return func(o *closeOptions) error {
+		o.immediate = true
+		return nil
+	}
+}
+
+// Close shuts down the Workstream and then closes the storage.Vault. No more Plans are started, including
+// scheduled and queued Plans. By default, Close drains running Plans: Actions that are running are allowed to
+// finish, no new Actions, Sequences or Blocks are started and the state of each Plan is written to storage.
+// Each drained Plan is released so that another Workstream sharing the storage.Vault, or this process after a
+// restart, resumes it from where it left off. A drained Plan is resumed no matter how long ago it was last updated.
+// If the Context is cancelled before the running Actions finish, the remaining Plans are left as if the process
+// crashed. Running Plans do not finish in this process, so Wait() and Stop() return an error once Close is called.
+// The Workstream cannot be used after Close.
+func (w *Workstream) Close(ctx context.Context, options ...CloseOption) error {
+	opts := closeOptions{}
+	for _, o := range options {
+		if err := o(&opts); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if cErr := w.exec.Close(ctx, !opts.immediate); cErr != nil {
+		err = fmt.Errorf("failed to stop running plans: %w", cErr)
+	}
+	if cErr := w.store.Close(context.WithoutCancel(ctx)); cErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to close storage: %w", cErr))
+	}
+	return err
+}
@@ -11,14 +11,18 @@ package coercion
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/element-of-surprise/coercion/internal/execute"
+	"github.com/element-of-surprise/coercion/internal/execute/sm"
 	"github.com/element-of-surprise/coercion/plugins/registry"
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/element-of-surprise/coercion/workflow/storage"
 	"github.com/element-of-surprise/coercion/workflow/utils/walk"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // This makes UUID generation much faster.
@@ -67,7 +71,65 @@ func WithMaxSubmit(d time.Duration) Option {
 	}
 }
 
-// New creates a new Workstream.
+// WithMaxRunning sets the maximum number of Plans that run at the same time. Plans that are started beyond this
+// wait in a queue and start as running Plans finish. Plans with a higher Plan.Priority start first, Plans with the
+// same priority start in the order they were started. The queue is written to storage so that it survives a
+// restart. A queued Plan is not considered stale by WithMaxSubmit(). Queued Plans can be found with the storage.Vault
+// Search() or List() methods, storage.CompareQueued() orders them by their position in the queue. If this is not set,
+// the default is 0, which is no limit.
+func WithMaxRunning(n int) Option {
+	return func(w *Workstream) error {
+		w.execOptions = append(w.execOptions, execute.WithMaxRunning(n))
+		return nil
+	}
+}
+
+// WithOwner sets the ID that this process uses to lease Plans in storage. Several processes can share
+// one storage.Vault, each Plan is run by the process that holds its lease. Every process sharing a
+// storage.Vault must have a different owner. If this is not set, the default is the hostname followed by
+// a random ID, which is different on each start.
+func WithOwner(owner string) Option {
+	return func(w *Workstream) error {
+		w.execOptions = append(w.execOptions, execute.WithOwner(owner))
+		return nil
+	}
+}
+
+// WithLeaseTTL sets how long a lease on a Plan lasts without being renewed. A process renews the leases on its
+// running Plans three times per TTL. If a process stops, another process sharing the storage.Vault takes over
+// its Plans within about twice the TTL. If this is not set, the default is 30 seconds.
+func WithLeaseTTL(d time.Duration) Option {
+	return func(w *Workstream) error {
+		w.execOptions = append(w.execOptions, execute.WithLeaseTTL(d))
+		return nil
+	}
+}
+
+// WithAttemptLogs sets which of the records that a plugin logs with context.Log() are kept on each
+// workflow.Attempt, where they are stored with the Plan. Records are still written to the process logger.
+// Records below level are not kept. Once an Attempt has kept maxRecords records or maxBytes of messages and
+// attributes, further records are only counted in Attempt.LogsDropped. A maxRecords of 0 turns this off and a
+// maxBytes of 0 has no size limit. If this is not set, the default is slog.LevelInfo, 100 records and 32 KiB.
+func WithAttemptLogs(level slog.Level, maxRecords, maxBytes int) Option {
+	return func(w *Workstream) error {
+		w.execOptions = append(w.execOptions, execute.WithAttemptLogs(level, maxRecords, maxBytes))
+		return nil
+	}
+}
+
+// WithTracerProvider sets the trace.TracerProvider used to create the spans of each Plan's execution. A Plan,
+// its Checks, Blocks, Sequences, Actions and each Attempt have a span. The Attempt's span is in the Context
+// passed to Plugin.Execute(), so calls the plugin makes can join the trace. If this is not set,
+// otel.GetTracerProvider() is used.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(w *Workstream) error {
+		w.execOptions = append(w.execOptions, execute.WithTracerProvider(tp))
+		return nil
+	}
+}
+
+// New creates a new Workstream. The execution of each Plan is recorded in metrics with the Meter from
+// context.Meter(ctx).
 func New(ctx context.Context, reg *registry.Register, store storage.Vault, options ...Option) (*Workstream, error) {
 	if store == nil {
 		return nil, fmt.Errorf("storage is required")
@@ -89,6 +151,7 @@ func New(ctx context.Context, reg *registry.Register, store storage.Vault, optio
 			return nil, err
 		}
 	}
+	ws.execOptions = append(ws.execOptions, execute.WithPreparer(ws.prepare))
 
 	exec, err := execute.New(ctx, store, reg, ws.execOptions...)
 	if err != nil {
@@ -108,27 +171,71 @@ type defaulter interface {
 // Start() to begin execution. Using the Plan object after submitting it results in undefined behavior.
 // To get the status of the plan, use the Status method.
 func (w *Workstream) Submit(ctx context.Context, plan *workflow.Plan) (uuid.UUID, error) {
-	if err := w.populateRegistry(ctx, plan); err != nil {
+	if err := w.prepare(ctx, plan); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := w.exec.Submit(ctx, plan); err != nil {
 		return uuid.Nil, err
 	}
+
+	return plan.ID, nil
+}
+
+// DryRun validates a workflow.Plan, applies defaults and walks it through execution without executing any
+// plugins or writing to storage. Plugins that implement plugins.DryRunner report what they would do, all
+// others are recorded as would execute. Each Action gets a single Attempt with DryRun set. Delays are
+// skipped and Approvals are not waited on. The returned Plan shows the order Blocks and Sequences would
+// run in and which bypasses would trigger. It can be rendered with the reports package. Using the Plan
+// object after passing it to DryRun results in undefined behavior.
+func (w *Workstream) DryRun(ctx context.Context, plan *workflow.Plan) (*workflow.Plan, error) {
+	if err := w.prepare(ctx, plan); err != nil {
+		return nil, err
+	}
+
+	if err := w.exec.DryRun(ctx, plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// prepare validates the plan and sets it up for execution. This includes the SubPlans of its Blocks, which
+// have their CallerID set to the ID of the Plan that runs them.
+func (w *Workstream) prepare(ctx context.Context, plan *workflow.Plan) error {
+	if err := w.populateRegistry(ctx, plan); err != nil {
+		return err
+	}
 	w.requestDefaults(ctx, plan)
 
 	if err := workflow.Validate(plan); err != nil {
-		return uuid.Nil, fmt.Errorf("Plan did not validate: %s", err)
+		return fmt.Errorf("Plan did not validate: %s", err)
 	}
 
-	for item := range walk.Plan(context.WithoutCancel(ctx), plan) {
+	now := w.now()
+	for item := range walk.Plan(context.WithoutCancel(ctx), plan, walk.WithSubPlans()) {
 		if def, ok := item.Value.(defaulter); ok {
 			def.Defaults()
 		}
+		if item.Value.Type() == workflow.OTPlan {
+			p := item.Plan()
+			p.SubmitTime = now
+			if len(item.Chain) > 0 {
+				p.CallerID = owningPlan(item).ID
+			}
+		}
 	}
-	plan.SubmitTime = w.now()
+	return nil
+}
 
-	if err := w.store.Create(ctx, plan); err != nil {
-		return uuid.Nil, fmt.Errorf("Failed to write plan to storage: %w", err)
+// owningPlan returns the closest Plan in the Chain of item. For an object this is the Plan it belongs to and
+// for a SubPlan this is the Plan that runs it.
+func owningPlan(item walk.Item) *workflow.Plan {
+	for i := len(item.Chain) - 1; i >= 0; i-- {
+		if item.Chain[i].Type() == workflow.OTPlan {
+			return item.Chain[i].(*workflow.Plan)
+		}
 	}
-
-	return plan.ID, nil
+	return nil
 }
 
 type setPlanIDer interface {
@@ -137,9 +244,9 @@ type setPlanIDer interface {
 
 // requestDefaults finds all request objects in the plan and calls their Defaults() method.
 func (w *Workstream) requestDefaults(ctx context.Context, plan *workflow.Plan) {
-	for item := range walk.Plan(ctx, plan) {
+	for item := range walk.Plan(ctx, plan, walk.WithSubPlans()) {
 		if item.Value.Type() != workflow.OTPlan {
-			item.Value.(setPlanIDer).SetPlanID(plan.ID)
+			item.Value.(setPlanIDer).SetPlanID(owningPlan(item).ID)
 		}
 		switch item.Value.Type() {
 		case workflow.OTAction:
@@ -152,7 +259,7 @@ func (w *Workstream) requestDefaults(ctx context.Context, plan *workflow.Plan) {
 }
 
 func (w *Workstream) populateRegistry(ctx context.Context, plan *workflow.Plan) error {
-	for item := range walk.Plan(ctx, plan) {
+	for item := range walk.Plan(ctx, plan, walk.WithSubPlans()) {
 		if item.Value.Type() == workflow.OTAction {
 			a := item.Action()
 			if a.HasRegister() {
@@ -165,6 +272,8 @@ func (w *Workstream) populateRegistry(ctx context.Context, plan *workflow.Plan)
 }
 
 // Start begins execution of a plan with the given id. The plan must have been submitted to the workstream.
+// If WithMaxRunning() plans are running, the plan is queued and starts when a running plan finishes.
+// Starting a plan that is already queued returns an error.
 func (w *Workstream) Start(ctx context.Context, id uuid.UUID) error {
 	return w.exec.Start(ctx, id)
 }
@@ -190,6 +299,78 @@ func (w *Workstream) Wait(ctx context.Context, id uuid.UUID) (*workflow.Plan, er
 	return w.store.Read(ctx, id)
 }
 
+// Stop stops the running plan with the given id and returns the Plan's final state. Actions that are
+// already executing are allowed to finish or time out, but nothing new is started. DeferredChecks are
+// still run. Objects that did not start are marked Stopped and the Plan will have Status Stopped with the
+// Reason FRStopped. If the plan is not running, an error is returned. If the context is canceled, the error
+// will be context.Canceled, but the plan will still stop.
+func (w *Workstream) Stop(ctx context.Context, id uuid.UUID) (*workflow.Plan, error) {
+	err := w.exec.Stop(ctx, id)
+	if err != nil {
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return nil, context.Canceled
+		}
+		if err == execute.ErrNotFound {
+			return nil, fmt.Errorf("plan(%s) is not running", id)
+		}
+		return nil, err
+	}
+	return w.store.Read(ctx, id)
+}
+
+// Pause pauses the running plan with the given id. A paused plan does not start any new Blocks or Sequences,
+// but Sequences that are already running are allowed to complete and ContChecks continue to run. The pause
+// is recorded in the Plan's Paused field before this returns, which survives a restart, and takes effect at
+// the next Block or Sequence boundary. If the plan is not running or is already paused, an error is returned.
+func (w *Workstream) Pause(ctx context.Context, id uuid.UUID) error {
+	if err := w.exec.Pause(ctx, id); err != nil {
+		if err == execute.ErrNotFound {
+			return fmt.Errorf("plan(%s) is not running", id)
+		}
+		return err
+	}
+	return nil
+}
+
+// Resume resumes the paused plan with the given id. The Plan's Paused field is cleared before this returns.
+// If the plan is not running or is not paused, an error is returned.
+func (w *Workstream) Resume(ctx context.Context, id uuid.UUID) error {
+	if err := w.exec.Resume(ctx, id); err != nil {
+		if err == execute.ErrNotFound {
+			return fmt.Errorf("plan(%s) is not running", id)
+		}
+		return err
+	}
+	return nil
+}
+
+// Approve approves an Approval that the running plan with planID is waiting on. id is the ID of the Block
+// that has the Approval, or planID for the Plan's Approval. The approver and comment are recorded on the
+// Approval. If the plan is not running or is not waiting on that Approval, an error is returned.
+func (w *Workstream) Approve(ctx context.Context, planID, id uuid.UUID, approver, comment string) error {
+	return w.decide(ctx, planID, id, sm.Decision{Approved: true, Approver: approver, Comment: comment})
+}
+
+// Reject rejects an Approval that the running plan with planID is waiting on. This causes the plan to
+// fail with the Reason FRApproval. id is the ID of the Block that has the Approval, or planID for the
+// Plan's Approval. If the plan is not running or is not waiting on that Approval, an error is returned.
+func (w *Workstream) Reject(ctx context.Context, planID, id uuid.UUID, approver, comment string) error {
+	return w.decide(ctx, planID, id, sm.Decision{Approved: false, Approver: approver, Comment: comment})
+}
+
+func (w *Workstream) decide(ctx context.Context, planID, id uuid.UUID, d sm.Decision) error {
+	if strings.TrimSpace(d.Approver) == "" {
+		return fmt.Errorf("approver must be provided")
+	}
+	if err := w.exec.Decide(ctx, planID, id, d); err != nil {
+		if err == execute.ErrNotFound {
+			return fmt.Errorf("plan(%s) is not running", planID)
+		}
+		return err
+	}
+	return nil
+}
+
 // Status returns a channel that will receive updates on the status of the plan with the given id. The interval
 // is the time between updates. The channel will be closed when the plan is complete or an error occurs.
 // If the Context is canceled, the channel will be closed and the final Result will have Err set. Otherwise, regardless
@@ -223,6 +404,43 @@ func (w *Workstream) Status(ctx context.Context, id uuid.UUID, interval time.Dur
 	return ch
 }
 
+// Events returns a channel that receives an event each time an object in the running plan with the given id
+// changes Status or an Action makes an Attempt. The last 1000 events of the plan are replayed first, so subscribing
+// late only loses history for a plan with more events than that. The channel will be closed once the plan is
+// complete and all events are delivered. If the plan is not running, the only Result will have Err set. If the Context is canceled,
+// the channel will be closed and the final Result will have Err set.
+func (w *Workstream) Events(ctx context.Context, id uuid.UUID) chan Result[workflow.Event] {
+	ch := make(chan Result[workflow.Event], 1)
+
+	events, err := w.exec.Events(ctx, id)
+	if err != nil {
+		if err == execute.ErrNotFound {
+			err = fmt.Errorf("plan(%s) is not running", id)
+		}
+		ch <- Result[workflow.Event]{Err: err}
+		close(ch)
+		return ch
+	}
+
+	go func() {
+		defer close(ch)
+
+		for ev := range events {
+			ch <- Result[workflow.Event]{Data: ev}
+		}
+		if ctx.Err() != nil {
+			ch <- Result[workflow.Event]{Err: ctx.Err()}
+		}
+	}()
+	return ch
+}
+
+// Locks returns the locks that are currently held by running Plans, Blocks and Sequences, sorted by name.
+// The Owner of each lock is the ID of the object holding it.
+func (w *Workstream) Locks(ctx context.Context) ([]storage.Lock, error) {
+	return w.store.Locks(ctx)
+}
+
 func (w *Workstream) now() time.Time {
 	return time.Now().UTC()
 }
@@ -0,0 +1,72 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{name: "Every minute", spec: "* * * * *"},
+		{name: "Lists, ranges and steps", spec: "0,30 1-5 */2 1-12/3 1-5"},
+		{name: "Descriptor", spec: "@daily"},
+		{name: "Sunday as 7", spec: "0 0 * * 7"},
+		{name: "Too few fields", spec: "* * * *", wantErr: true},
+		{name: "Minute out of range", spec: "60 * * * *", wantErr: true},
+		{name: "Month zero", spec: "* * * 0 *", wantErr: true},
+		{name: "Bad step", spec: "*/0 * * * *", wantErr: true},
+		{name: "Backwards range", spec: "* 5-1 * * *", wantErr: true},
+		{name: "Not a number", spec: "a * * * *", wantErr: true},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.spec)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestParse(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestParse(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	t.Parallel()
+
+	// A Wednesday.
+	from := time.Date(2024, 1, 10, 10, 17, 30, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		spec string
+		want time.Time
+	}{
+		{name: "Every minute", spec: "* * * * *", want: time.Date(2024, 1, 10, 10, 18, 0, 0, time.UTC)},
+		{name: "Every 15 minutes", spec: "*/15 * * * *", want: time.Date(2024, 1, 10, 10, 30, 0, 0, time.UTC)},
+		{name: "Hourly", spec: "@hourly", want: time.Date(2024, 1, 10, 11, 0, 0, 0, time.UTC)},
+		{name: "Daily at 2am", spec: "0 2 * * *", want: time.Date(2024, 1, 11, 2, 0, 0, 0, time.UTC)},
+		{name: "Weekly on Sunday", spec: "@weekly", want: time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+		{name: "Sunday as 7", spec: "30 1 * * 7", want: time.Date(2024, 1, 14, 1, 30, 0, 0, time.UTC)},
+		{name: "Next month", spec: "0 0 1 * *", want: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "Leap day", spec: "0 0 29 2 *", want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "Day of month or day of week", spec: "0 0 20 * 5", want: time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)},
+		{name: "Never", spec: "0 0 30 2 *", want: time.Time{}},
+	}
+
+	for _, test := range tests {
+		s, err := Parse(test.spec)
+		if err != nil {
+			t.Errorf("TestNext(%s): Parse() error: %s", test.name, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(test.want) {
+			t.Errorf("TestNext(%s): got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
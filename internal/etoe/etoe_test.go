@@ -1,17 +1,20 @@
 package etoe
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	workstream "github.com/element-of-surprise/coercion"
+	pluglib "github.com/element-of-surprise/coercion/plugins"
 	"github.com/element-of-surprise/coercion/plugins/registry"
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/element-of-surprise/coercion/workflow/builder"
@@ -22,7 +25,13 @@ import (
 	"github.com/element-of-surprise/coercion/workflow/utils/clone"
 	"github.com/element-of-surprise/coercion/workflow/utils/walk"
 	"github.com/google/uuid"
+	"github.com/gostdlib/base/retry/exponential"
+	"github.com/gostdlib/base/telemetry/otel/metrics"
 	"github.com/kylelemons/godebug/pretty"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	"github.com/element-of-surprise/coercion/internal/execute/sm/testing/plugins"
 	testplugin "github.com/element-of-surprise/coercion/internal/execute/sm/testing/plugins"
@@ -667,39 +676,2535 @@ func TestBypassBlock(t *testing.T) {
 	}
 }
 
+func TestStop(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	plugCheck := &testplugin.Plugin{
+		AlwaysRespond: true,
+		IsCheckPlugin: true,
+		PlugName:      "check",
+	}
+
+	plugAction := &testplugin.Plugin{
+		AlwaysRespond: true,
+	}
+
+	reg := registry.New()
+	reg.Register(plugCheck)
+	reg.Register(plugAction)
+
+	checks := &workflow.Checks{
+		Actions: []*workflow.Action{
+			{Name: "check", Descr: "check", Plugin: "check", Req: testplugin.Req{}},
+		},
+	}
+
+	seqs := &workflow.Sequence{
+		Name:  "seq",
+		Descr: "seq",
+		Actions: []*workflow.Action{
+			{Name: "action0", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{Sleep: 2 * time.Second}},
+			{Name: "action1", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{}},
+		},
+	}
+
+	build, err := builder.New("stop test", "tests that a plan can be stopped")
+	if err != nil {
+		panic(err)
+	}
+
+	build.AddChecks(builder.PostChecks, clone.Checks(ctx, checks, cloneOpts...)).Up()
+	build.AddChecks(builder.DeferredChecks, clone.Checks(ctx, checks, cloneOpts...)).Up()
+	build.AddBlock(
+		builder.BlockArgs{
+			Name:        "block0",
+			Descr:       "block0",
+			Concurrency: 1,
+		},
+	)
+	build.AddChecks(builder.DeferredChecks, clone.Checks(ctx, checks, cloneOpts...)).Up()
+	build.AddSequence(clone.Sequence(ctx, seqs, cloneOpts...)).Up()
+	build.AddSequence(clone.Sequence(ctx, seqs, cloneOpts...)).Up().Up()
+
+	build.AddBlock(
+		builder.BlockArgs{
+			Name:        "block1",
+			Descr:       "block1",
+			Concurrency: 1,
+		},
+	)
+	build.AddSequence(clone.Sequence(ctx, seqs, cloneOpts...)).Up()
+
+	if build.Err() != nil {
+		panic("problem building plan: " + build.Err().Error())
+	}
+
+	plan, err := build.Plan()
+	if err != nil {
+		panic(err)
+	}
+
+	var vault storage.Vault
+	switch *vaultType {
+	case "sqlite":
+		vault, err = sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	case "cosmosdb":
+		cred, err := msiCred(*msi)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info(fmt.Sprintf("TestStop: Using cosmosdb: %s, %s", *db, *container))
+		vault, err = cosmosdb.New(ctx, *swarm, *db, *container, cred, reg)
+	default:
+		panic(fmt.Errorf("TestStop: unknown storage vault type: %s", *vaultType))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+
+	id, err := ws.Submit(ctx, plan)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := ws.Start(ctx, id); err != nil {
+		panic(err)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	result, err := ws.Stop(ctx, id)
+	if err != nil {
+		t.Fatalf("TestStop: Stop() returned error: %v", err)
+	}
+
+	if _, err := ws.Stop(ctx, id); err == nil {
+		t.Errorf("TestStop: Stop() on a stopped plan: got err == nil, want err != nil")
+	}
+
+	if result.State.Status != workflow.Stopped {
+		t.Fatalf("TestStop: expected Plan in Stopped, got %s", result.State.Status)
+	}
+	if result.Reason != workflow.FRStopped {
+		t.Errorf("TestStop: expected Plan Reason FRStopped, got %s", result.Reason)
+	}
+	if result.PostChecks.State.Status != workflow.Stopped {
+		t.Errorf("TestStop: expected Plan PostChecks in Stopped, got %s", result.PostChecks.State.Status)
+	}
+	if result.DeferredChecks.State.Status != workflow.Completed {
+		t.Errorf("TestStop: expected Plan DeferredChecks in Completed, got %s", result.DeferredChecks.State.Status)
+	}
+
+	// Block 0 was running when we stopped, the in-flight action is allowed to finish.
+	b0 := result.Blocks[0]
+	if b0.State.Status != workflow.Stopped {
+		t.Errorf("TestStop: expected block 0 in Stopped, got %s", b0.State.Status)
+	}
+	if b0.DeferredChecks.State.Status != workflow.Completed {
+		t.Errorf("TestStop: expected block 0 DeferredChecks in Completed, got %s", b0.DeferredChecks.State.Status)
+	}
+	if b0.Sequences[0].State.Status != workflow.Stopped {
+		t.Errorf("TestStop: expected block 0 sequence 0 in Stopped, got %s", b0.Sequences[0].State.Status)
+	}
+	if b0.Sequences[0].Actions[0].State.Status != workflow.Completed {
+		t.Errorf("TestStop: expected block 0 sequence 0 action 0 in Completed, got %s", b0.Sequences[0].Actions[0].State.Status)
+	}
+	if b0.Sequences[0].Actions[1].State.Status != workflow.Stopped {
+		t.Errorf("TestStop: expected block 0 sequence 0 action 1 in Stopped, got %s", b0.Sequences[0].Actions[1].State.Status)
+	}
+	if b0.Sequences[1].State.Status != workflow.Stopped {
+		t.Errorf("TestStop: expected block 0 sequence 1 in Stopped, got %s", b0.Sequences[1].State.Status)
+	}
+
+	// Block 1 never started.
+	b1 := result.Blocks[1]
+	if b1.State.Status != workflow.Stopped {
+		t.Errorf("TestStop: expected block 1 in Stopped, got %s", b1.State.Status)
+	}
+	for _, a := range b1.Sequences[0].Actions {
+		if a.State.Status != workflow.Stopped {
+			t.Errorf("TestStop: expected block 1 actions in Stopped, got %s", a.State.Status)
+		}
+	}
+}
+
+func TestPause(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	plugAction := &testplugin.Plugin{
+		AlwaysRespond: true,
+	}
+
+	reg := registry.New()
+	reg.Register(plugAction)
+
+	seqs := &workflow.Sequence{
+		Name:  "seq",
+		Descr: "seq",
+		Actions: []*workflow.Action{
+			{Name: "action0", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{Sleep: 1 * time.Second}},
+		},
+	}
+
+	build, err := builder.New("pause test", "tests that a plan can be paused and resumed")
+	if err != nil {
+		panic(err)
+	}
+
+	build.AddBlock(
+		builder.BlockArgs{
+			Name:        "block0",
+			Descr:       "block0",
+			Concurrency: 1,
+		},
+	)
+	build.AddSequence(clone.Sequence(ctx, seqs, cloneOpts...)).Up()
+	build.AddSequence(clone.Sequence(ctx, seqs, cloneOpts...)).Up().Up()
+
+	if build.Err() != nil {
+		panic("problem building plan: " + build.Err().Error())
+	}
+
+	plan, err := build.Plan()
+	if err != nil {
+		panic(err)
+	}
+
+	var vault storage.Vault
+	switch *vaultType {
+	case "sqlite":
+		vault, err = sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	case "cosmosdb":
+		cred, err := msiCred(*msi)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info(fmt.Sprintf("TestPause: Using cosmosdb: %s, %s", *db, *container))
+		vault, err = cosmosdb.New(ctx, *swarm, *db, *container, cred, reg)
+	default:
+		panic(fmt.Errorf("TestPause: unknown storage vault type: %s", *vaultType))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+
+	id, err := ws.Submit(ctx, plan)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := ws.Start(ctx, id); err != nil {
+		panic(err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	if err := ws.Pause(ctx, id); err != nil {
+		t.Fatalf("TestPause: Pause() returned error: %v", err)
+	}
+	if err := ws.Pause(ctx, id); err == nil {
+		t.Errorf("TestPause: Pause() on a paused plan: got err == nil, want err != nil")
+	}
+
+	// Give the running sequence time to finish, the second sequence must not start.
+	time.Sleep(2 * time.Second)
+
+	paused, err := ws.Plan(ctx, id)
+	if err != nil {
+		t.Fatalf("TestPause: Plan() returned error: %v", err)
+	}
+	if !paused.Paused {
+		t.Errorf("TestPause: expected Plan.Paused to be true while paused")
+	}
+	if paused.State.Status != workflow.Running {
+		t.Errorf("TestPause: expected Plan in Running while paused, got %s", paused.State.Status)
+	}
+	if paused.Blocks[0].Sequences[0].State.Status != workflow.Completed {
+		t.Errorf("TestPause: expected sequence 0 in Completed while paused, got %s", paused.Blocks[0].Sequences[0].State.Status)
+	}
+	if paused.Blocks[0].Sequences[1].State.Status != workflow.NotStarted {
+		t.Errorf("TestPause: expected sequence 1 in NotStarted while paused, got %s", paused.Blocks[0].Sequences[1].State.Status)
+	}
+
+	if err := ws.Resume(ctx, id); err != nil {
+		t.Fatalf("TestPause: Resume() returned error: %v", err)
+	}
+
+	result, err := ws.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("TestPause: Wait() returned error: %v", err)
+	}
+	if result.State.Status != workflow.Completed {
+		t.Errorf("TestPause: expected Plan in Completed, got %s", result.State.Status)
+	}
+	if result.Paused {
+		t.Errorf("TestPause: expected Plan.Paused to be false after resume")
+	}
+	if err := ws.Resume(ctx, id); err == nil {
+		t.Errorf("TestPause: Resume() on a completed plan: got err == nil, want err != nil")
+	}
+}
+
+func TestApproval(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	plugAction := &testplugin.Plugin{
+		AlwaysRespond: true,
+	}
+
+	reg := registry.New()
+	reg.Register(plugAction)
+
+	seqs := &workflow.Sequence{
+		Name:  "seq",
+		Descr: "seq",
+		Actions: []*workflow.Action{
+			{Name: "action0", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{Sleep: 10 * time.Millisecond}},
+		},
+	}
+
+	build, err := builder.New("approval test", "tests that a plan waits on approvals")
+	if err != nil {
+		panic(err)
+	}
+
+	build.AddBlock(
+		builder.BlockArgs{
+			Name:        "block0",
+			Descr:       "block0",
+			Concurrency: 1,
+			Approval:    &workflow.Approval{},
+		},
+	)
+	build.AddSequence(clone.Sequence(ctx, seqs, cloneOpts...)).Up().Up()
+
+	build.AddBlock(
+		builder.BlockArgs{
+			Name:        "block1",
+			Descr:       "block1",
+			Concurrency: 1,
+			Approval:    &workflow.Approval{},
+		},
+	)
+	build.AddSequence(clone.Sequence(ctx, seqs, cloneOpts...)).Up()
+
+	if build.Err() != nil {
+		panic("problem building plan: " + build.Err().Error())
+	}
+
+	plan, err := build.Plan()
+	if err != nil {
+		panic(err)
+	}
+	plan.Approval = &workflow.Approval{}
+
+	var vault storage.Vault
+	switch *vaultType {
+	case "sqlite":
+		vault, err = sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	case "cosmosdb":
+		cred, err := msiCred(*msi)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info(fmt.Sprintf("TestApproval: Using cosmosdb: %s, %s", *db, *container))
+		vault, err = cosmosdb.New(ctx, *swarm, *db, *container, cred, reg)
+	default:
+		panic(fmt.Errorf("TestApproval: unknown storage vault type: %s", *vaultType))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+
+	id, err := ws.Submit(ctx, plan)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := ws.Start(ctx, id); err != nil {
+		panic(err)
+	}
+
+	// waitFor waits until the Approval returned by get is waiting on a decision.
+	waitFor := func(get func(p *workflow.Plan) *workflow.Approval) {
+		for i := 0; i < 100; i++ {
+			p, err := ws.Plan(ctx, id)
+			if err != nil {
+				t.Fatalf("TestApproval: Plan() returned error: %v", err)
+			}
+			if get(p).Status == workflow.ASWaiting {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatalf("TestApproval: approval never reached ASWaiting")
+	}
+
+	if err := ws.Approve(ctx, id, id, "", ""); err == nil {
+		t.Errorf("TestApproval: Approve() without an approver: got err == nil, want err != nil")
+	}
+
+	waitFor(func(p *workflow.Plan) *workflow.Approval { return p.Approval })
+	if err := ws.Approve(ctx, id, id, "approver", "plan is good"); err != nil {
+		t.Fatalf("TestApproval: Approve(plan) returned error: %v", err)
+	}
+
+	waitFor(func(p *workflow.Plan) *workflow.Approval { return p.Blocks[0].Approval })
+	if err := ws.Approve(ctx, id, plan.Blocks[0].ID, "approver", "block0 is good"); err != nil {
+		t.Fatalf("TestApproval: Approve(block0) returned error: %v", err)
+	}
+
+	waitFor(func(p *workflow.Plan) *workflow.Approval { return p.Blocks[1].Approval })
+	if err := ws.Reject(ctx, id, plan.Blocks[1].ID, "approver", "block1 is bad"); err != nil {
+		t.Fatalf("TestApproval: Reject(block1) returned error: %v", err)
+	}
+
+	result, err := ws.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("TestApproval: Wait() returned error: %v", err)
+	}
+	if result.State.Status != workflow.Failed {
+		t.Errorf("TestApproval: expected Plan in Failed, got %s", result.State.Status)
+	}
+	if result.Reason != workflow.FRApproval {
+		t.Errorf("TestApproval: expected Plan Reason FRApproval, got %s", result.Reason)
+	}
+	if result.Approval.Status != workflow.ASApproved {
+		t.Errorf("TestApproval: expected Plan Approval in ASApproved, got %s", result.Approval.Status)
+	}
+
+	b0 := result.Blocks[0]
+	if b0.State.Status != workflow.Completed {
+		t.Errorf("TestApproval: expected block 0 in Completed, got %s", b0.State.Status)
+	}
+	if b0.Approval.Status != workflow.ASApproved || b0.Approval.Approver != "approver" || b0.Approval.Comment != "block0 is good" {
+		t.Errorf("TestApproval: block 0 Approval not recorded correctly: %+v", b0.Approval)
+	}
+
+	b1 := result.Blocks[1]
+	if b1.State.Status != workflow.Failed {
+		t.Errorf("TestApproval: expected block 1 in Failed, got %s", b1.State.Status)
+	}
+	if b1.Approval.Status != workflow.ASRejected {
+		t.Errorf("TestApproval: expected block 1 Approval in ASRejected, got %s", b1.Approval.Status)
+	}
+	if b1.Sequences[0].State.Status != workflow.NotStarted {
+		t.Errorf("TestApproval: expected block 1 sequence 0 in NotStarted, got %s", b1.Sequences[0].State.Status)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	ctx := context.Background()
+
+	plugCheck := &testplugin.DryRunPlugin{
+		Plugin: &testplugin.Plugin{
+			AlwaysRespond: true,
+			IsCheckPlugin: true,
+			PlugName:      "check",
+		},
+	}
+	plugAction := &testplugin.Plugin{
+		AlwaysRespond: true,
+	}
+
+	reg := registry.New()
+	reg.Register(plugCheck)
+	reg.Register(plugAction)
+
+	seqs := &workflow.Sequence{
+		Name:  "seq",
+		Descr: "seq",
+		Actions: []*workflow.Action{
+			{Name: "action0", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{Sleep: 1 * time.Hour}},
+		},
+	}
+
+	build, err := builder.New("dry run test", "tests that a plan can be dry run")
+	if err != nil {
+		panic(err)
+	}
+
+	build.AddChecks(
+		builder.PreChecks,
+		&workflow.Checks{
+			Actions: []*workflow.Action{
+				{Name: "check", Descr: "check", Plugin: "check", Req: testplugin.Req{Arg: "check"}},
+			},
+		},
+	).Up()
+	build.AddChecks(
+		builder.ContChecks,
+		&workflow.Checks{
+			Delay: 1 * time.Hour,
+			Actions: []*workflow.Action{
+				{Name: "check", Descr: "check", Plugin: "check", Req: testplugin.Req{Arg: "check"}},
+			},
+		},
+	).Up()
+	build.AddBlock(
+		builder.BlockArgs{
+			Name:          "block0",
+			Descr:         "block0",
+			EntranceDelay: 1 * time.Hour,
+			Concurrency:   1,
+			Approval:      &workflow.Approval{},
+		},
+	)
+	build.AddSequence(clone.Sequence(ctx, seqs, cloneOpts...)).Up()
+	build.AddSequence(clone.Sequence(ctx, seqs, cloneOpts...)).Up()
+
+	if build.Err() != nil {
+		panic("problem building plan: " + build.Err().Error())
+	}
+
+	plan, err := build.Plan()
+	if err != nil {
+		panic(err)
+	}
+
+	vault, err := sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	result, err := ws.DryRun(ctx, plan)
+	if err != nil {
+		t.Fatalf("TestDryRun: DryRun() returned error: %v", err)
+	}
+
+	if result.State.Status != workflow.Completed {
+		t.Fatalf("TestDryRun: expected Plan in Completed, got %s", result.State.Status)
+	}
+	if plugAction.Calls.Load() != 0 || plugCheck.Calls.Load() != 0 {
+		t.Errorf("TestDryRun: plugins had Execute() called during a dry run")
+	}
+	if plugCheck.DryRuns.Load() != 2 {
+		t.Errorf("TestDryRun: expected DryRun() to be called twice, got %d", plugCheck.DryRuns.Load())
+	}
+	if ok, _ := vault.Exists(ctx, result.ID); ok {
+		t.Errorf("TestDryRun: dry run Plan was written to storage")
+	}
+
+	check := result.PreChecks.Actions[0]
+	if len(check.Attempts) != 1 || !check.Attempts[0].DryRun {
+		t.Fatalf("TestDryRun: expected a single dry run Attempt on the PreCheck, got %+v", check.Attempts)
+	}
+	if resp := check.Attempts[0].Resp.(testplugin.Resp); resp.Arg != "would check" {
+		t.Errorf("TestDryRun: got PreCheck Resp %q, want %q", resp.Arg, "would check")
+	}
+
+	b0 := result.Blocks[0]
+	if b0.Approval.Status != workflow.ASNotRequested {
+		t.Errorf("TestDryRun: expected block 0 Approval to be untouched, got %s", b0.Approval.Status)
+	}
+	for i, seq := range b0.Sequences {
+		a := seq.Actions[0]
+		if a.State.Status != workflow.Completed {
+			t.Errorf("TestDryRun: expected sequence %d action in Completed, got %s", i, a.State.Status)
+		}
+		if len(a.Attempts) != 1 || !a.Attempts[0].DryRun || a.Attempts[0].Resp != nil {
+			t.Errorf("TestDryRun: expected sequence %d action to have a would execute Attempt, got %+v", i, a.Attempts)
+		}
+	}
+	if b0.Sequences[0].State.End.After(b0.Sequences[1].State.Start) {
+		t.Errorf("TestDryRun: sequences ran concurrently with Concurrency 1")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	plugCheck := &testplugin.Plugin{
+		AlwaysRespond: true,
+		IsCheckPlugin: true,
+		PlugName:      "check",
+	}
+
+	plugAction := &testplugin.Plugin{
+		AlwaysRespond: true,
+	}
+
+	reg := registry.New()
+	reg.Register(plugCheck)
+	reg.Register(plugAction)
+
+	seq := func(name, arg string) *workflow.Sequence {
+		return &workflow.Sequence{
+			Name:  name,
+			Descr: name,
+			Actions: []*workflow.Action{
+				{Name: "action", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{Arg: arg}},
+			},
+		}
+	}
+
+	build, err := builder.New("retry test", "tests that a failed plan can be retried")
+	if err != nil {
+		panic(err)
+	}
+
+	build.AddChecks(
+		builder.PreChecks,
+		&workflow.Checks{
+			Actions: []*workflow.Action{
+				{Name: "check0", Descr: "check0", Plugin: "check", Req: testplugin.Req{Arg: "ok"}},
+				{Name: "check1", Descr: "check1", Plugin: "check", Req: testplugin.Req{Arg: "ok"}},
+			},
+		},
+	).Up()
+
+	build.AddBlock(
+		builder.BlockArgs{
+			Name:        "block0",
+			Descr:       "block0",
+			Concurrency: 1,
+		},
+	)
+	build.AddSequence(seq("ok", "ok")).Up()
+	build.AddSequence(seq("fail", "error")).Up().Up()
+
+	build.AddBlock(
+		builder.BlockArgs{
+			Name:        "block1",
+			Descr:       "block1",
+			Concurrency: 1,
+		},
+	)
+	build.AddSequence(seq("never", "ok")).Up()
+
+	if build.Err() != nil {
+		panic("problem building plan: " + build.Err().Error())
+	}
+
+	plan, err := build.Plan()
+	if err != nil {
+		panic(err)
+	}
+
+	var vault storage.Vault
+	switch *vaultType {
+	case "sqlite":
+		vault, err = sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	case "cosmosdb":
+		cred, err := msiCred(*msi)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info(fmt.Sprintf("TestRetry: Using cosmosdb: %s, %s", *db, *container))
+		vault, err = cosmosdb.New(ctx, *swarm, *db, *container, cred, reg)
+	default:
+		panic(fmt.Errorf("TestRetry: unknown storage vault type: %s", *vaultType))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+
+	id, err := ws.Submit(ctx, plan)
+	if err != nil {
+		panic(err)
+	}
+	if err := ws.Start(ctx, id); err != nil {
+		panic(err)
+	}
+	failed, err := ws.Wait(ctx, id)
+	if err != nil {
+		panic(err)
+	}
+	if failed.State.Status != workflow.Failed {
+		t.Fatalf("TestRetry: expected Plan in Failed, got %s", failed.State.Status)
+	}
+
+	// seqNames returns the names of the Sequences in each Block.
+	seqNames := func(p *workflow.Plan) [][]string {
+		var names [][]string
+		for _, b := range p.Blocks {
+			var bn []string
+			for _, seq := range b.Sequences {
+				bn = append(bn, seq.Name)
+			}
+			names = append(names, bn)
+		}
+		return names
+	}
+
+	tests := []struct {
+		name    string
+		options []workstream.RetryOption
+		want    [][]string
+		// skippedPreChecks indicates the Completed PreChecks are not in the retry Plan.
+		skippedPreChecks bool
+	}{
+		{
+			name: "Default",
+			want: [][]string{{"fail"}, {"never"}},
+		},
+		{
+			name:    "WithOnlyFailedSequences",
+			options: []workstream.RetryOption{workstream.WithOnlyFailedSequences()},
+			want:    [][]string{{"fail"}},
+		},
+		{
+			name:             "WithSkipCompletedPreChecks",
+			options:          []workstream.RetryOption{workstream.WithSkipCompletedPreChecks()},
+			want:             [][]string{{"fail"}, {"never"}},
+			skippedPreChecks: true,
+		},
+	}
+
+	for _, test := range tests {
+		retryID, err := ws.Retry(ctx, id, test.options...)
+		if err != nil {
+			t.Errorf("TestRetry(%s): Retry() returned error: %v", test.name, err)
+			continue
+		}
+		retry, err := ws.Plan(ctx, retryID)
+		if err != nil {
+			t.Errorf("TestRetry(%s): Plan() returned error: %v", test.name, err)
+			continue
+		}
+		if retry.ParentID != id {
+			t.Errorf("TestRetry(%s): got ParentID %s, want %s", test.name, retry.ParentID, id)
+		}
+		if retry.State.Status != workflow.NotStarted {
+			t.Errorf("TestRetry(%s): expected retry Plan in NotStarted, got %s", test.name, retry.State.Status)
+		}
+		if diff := pretty.Compare(test.want, seqNames(retry)); diff != "" {
+			t.Errorf("TestRetry(%s): Sequences: -want/+got:\n%s", test.name, diff)
+		}
+		if got := retry.PreChecks == nil; got != test.skippedPreChecks {
+			t.Errorf("TestRetry(%s): got PreChecks skipped == %v, want %v", test.name, got, test.skippedPreChecks)
+		}
+
+		if _, err := ws.Retry(ctx, retryID); err == nil {
+			t.Errorf("TestRetry(%s): Retry() on a NotStarted plan: got err == nil, want err != nil", test.name)
+		}
+	}
+
+	retryID, err := ws.Retry(ctx, id, workstream.WithOnlyFailedSequences(), workstream.WithRetryStart())
+	if err != nil {
+		t.Fatalf("TestRetry(WithRetryStart): Retry() returned error: %v", err)
+	}
+	result, err := ws.Wait(ctx, retryID)
+	if err != nil {
+		t.Fatalf("TestRetry(WithRetryStart): Wait() returned error: %v", err)
+	}
+	// The Sequence still fails, but it must have been run.
+	if result.State.Status != workflow.Failed {
+		t.Errorf("TestRetry(WithRetryStart): expected Plan in Failed, got %s", result.State.Status)
+	}
+	if len(result.Blocks[0].Sequences[0].Actions[0].Attempts) == 0 {
+		t.Errorf("TestRetry(WithRetryStart): retried Sequence was not run")
+	}
+}
+
+func TestEvents(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	plugAction := &testplugin.Plugin{
+		AlwaysRespond: true,
+	}
+
+	reg := registry.New()
+	reg.Register(plugAction)
+
+	build, err := builder.New("events test", "tests that events are streamed for a running plan")
+	if err != nil {
+		panic(err)
+	}
+
+	build.AddBlock(
+		builder.BlockArgs{
+			Name:        "block0",
+			Descr:       "block0",
+			Concurrency: 1,
+		},
+	)
+	build.AddSequence(&workflow.Sequence{Name: "seq0", Descr: "seq0"})
+	build.AddAction(&workflow.Action{Name: "action", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{Arg: "ok"}})
+	build.Up().Up()
+
+	if build.Err() != nil {
+		panic("problem building plan: " + build.Err().Error())
+	}
+
+	plan, err := build.Plan()
+	if err != nil {
+		panic(err)
+	}
+
+	var vault storage.Vault
+	switch *vaultType {
+	case "sqlite":
+		vault, err = sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	case "cosmosdb":
+		cred, err := msiCred(*msi)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info(fmt.Sprintf("TestEvents: Using cosmosdb: %s, %s", *db, *container))
+		vault, err = cosmosdb.New(ctx, *swarm, *db, *container, cred, reg)
+	default:
+		panic(fmt.Errorf("TestEvents: unknown storage vault type: %s", *vaultType))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+
+	id, err := ws.Submit(ctx, plan)
+	if err != nil {
+		panic(err)
+	}
+
+	for result := range ws.Events(ctx, id) {
+		if result.Err == nil {
+			t.Errorf("TestEvents: Events() on a plan that is not running: got err == nil, want err != nil")
+		}
+	}
+
+	if err := ws.Start(ctx, id); err != nil {
+		panic(err)
+	}
+
+	var events []workflow.Event
+	for result := range ws.Events(ctx, id) {
+		if result.Err != nil {
+			t.Fatalf("TestEvents: Events() returned error: %v", result.Err)
+		}
+		events = append(events, result.Data)
+	}
+
+	result, err := ws.Wait(ctx, id)
+	if err != nil {
+		panic(err)
+	}
+	if result.State.Status != workflow.Completed {
+		t.Fatalf("TestEvents: expected Plan in Completed, got %s", result.State.Status)
+	}
+
+	if len(events) == 0 {
+		t.Fatalf("TestEvents: got no events")
+	}
+	first, last := events[0], events[len(events)-1]
+	if first.Type != workflow.OTPlan || first.OldStatus != workflow.NotStarted || first.NewStatus != workflow.Running {
+		t.Errorf("TestEvents: first event: got %s %s -> %s, want Plan NotStarted -> Running", first.Type, first.OldStatus, first.NewStatus)
+	}
+	if last.Type != workflow.OTPlan || last.OldStatus != workflow.Running || last.NewStatus != workflow.Completed {
+		t.Errorf("TestEvents: last event: got %s %s -> %s, want Plan Running -> Completed", last.Type, last.OldStatus, last.NewStatus)
+	}
+
+	// Every object must move through a continuous chain of states and end where the Plan says it did.
+	statuses := map[uuid.UUID]workflow.Status{}
+	for _, ev := range events {
+		if ev.PlanID != id {
+			t.Errorf("TestEvents: event for %s(%s) has PlanID %s, want %s", ev.Type, ev.ID, ev.PlanID, id)
+		}
+		old, ok := statuses[ev.ID]
+		if !ok {
+			old = workflow.NotStarted
+		}
+		if ev.OldStatus != old {
+			t.Errorf("TestEvents: event for %s(%s): got OldStatus %s, want %s", ev.Type, ev.ID, ev.OldStatus, old)
+		}
+		statuses[ev.ID] = ev.NewStatus
+	}
+
+	action := result.Blocks[0].Sequences[0].Actions[0]
+	for item := range walk.Plan(ctx, result) {
+		obj := item.Value.(interface {
+			GetID() uuid.UUID
+			GetState() *workflow.State
+		})
+		if got, want := statuses[obj.GetID()], obj.GetState().Status; got != want {
+			t.Errorf("TestEvents: %s(%s): got final event status %s, want %s", item.Value.Type(), obj.GetID(), got, want)
+		}
+	}
+
+	var attempt int
+	for _, ev := range events {
+		if ev.ID == action.ID {
+			attempt = ev.Attempt
+		}
+	}
+	if attempt != len(action.Attempts) {
+		t.Errorf("TestEvents: action's last event had Attempt %d, want %d", attempt, len(action.Attempts))
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	ctx := context.Background()
+
+	plugAction := &testplugin.Plugin{
+		AlwaysRespond: true,
+	}
+
+	reg := registry.New()
+	reg.Register(plugAction)
+
+	groupID := workflow.NewV7()
+	factoryCalls := 0
+	factory := func() *workflow.Plan {
+		factoryCalls++
+
+		build, err := builder.New("schedule test", "tests that plans can be scheduled")
+		if err != nil {
+			panic(err)
+		}
+		build.AddBlock(builder.BlockArgs{Name: "block0", Descr: "block0", Concurrency: 1})
+		build.AddSequence(&workflow.Sequence{Name: "seq0", Descr: "seq0"})
+		build.AddAction(&workflow.Action{Name: "action", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{Arg: "ok"}})
+		build.Up().Up()
+
+		plan, err := build.Plan()
+		if err != nil {
+			panic(err)
+		}
+		plan.GroupID = groupID
+		return plan
+	}
+
+	vault, err := sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+
+	// waitStatus waits for the plan to reach the status.
+	waitStatus := func(ws *workstream.Workstream, id uuid.UUID, status workflow.Status) *workflow.Plan {
+		for i := 0; i < 200; i++ {
+			plan, err := ws.Plan(ctx, id)
+			if err != nil {
+				panic(err)
+			}
+			if plan.State.Status == status {
+				return plan
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatalf("TestSchedule: plan(%s) did not reach %s", id, status)
+		return nil
+	}
+
+	// Schedule() starts the plan at the scheduled time.
+	id, err := ws.Submit(ctx, factory())
+	if err != nil {
+		panic(err)
+	}
+	if err := ws.Schedule(ctx, id, time.Now().Add(-time.Second)); err == nil {
+		t.Errorf("TestSchedule: Schedule() in the past: got err == nil, want err != nil")
+	}
+	at := time.Now().Add(500 * time.Millisecond)
+	if err := ws.Schedule(ctx, id, at); err != nil {
+		t.Fatalf("TestSchedule: Schedule() returned error: %v", err)
+	}
+	plan := waitStatus(ws, id, workflow.Completed)
+	if !plan.StartAt.Equal(at.UTC()) {
+		t.Errorf("TestSchedule: got StartAt %s, want %s", plan.StartAt, at.UTC())
+	}
+	if plan.State.Start.Before(at) {
+		t.Errorf("TestSchedule: plan started at %s, before it was scheduled at %s", plan.State.Start, at)
+	}
+
+	// Unschedule() keeps the plan from starting.
+	id, err = ws.Submit(ctx, factory())
+	if err != nil {
+		panic(err)
+	}
+	if err := ws.Unschedule(ctx, id); err == nil {
+		t.Errorf("TestSchedule: Unschedule() on an unscheduled plan: got err == nil, want err != nil")
+	}
+	if err := ws.Schedule(ctx, id, time.Now().Add(500*time.Millisecond)); err != nil {
+		t.Fatalf("TestSchedule: Schedule() returned error: %v", err)
+	}
+	if err := ws.Unschedule(ctx, id); err != nil {
+		t.Fatalf("TestSchedule: Unschedule() returned error: %v", err)
+	}
+	time.Sleep(time.Second)
+	plan, err = ws.Plan(ctx, id)
+	if err != nil {
+		panic(err)
+	}
+	if plan.State.Status != workflow.NotStarted || !plan.StartAt.IsZero() {
+		t.Errorf("TestSchedule: unscheduled plan: got Status %s, StartAt %s, want NotStarted and zero StartAt", plan.State.Status, plan.StartAt)
+	}
+
+	// A schedule in storage is picked up by a new Workstream, as it would be after a restart.
+	plan.StartAt = time.Now().Add(500 * time.Millisecond).UTC()
+	if err := vault.UpdatePlan(ctx, plan); err != nil {
+		panic(err)
+	}
+	restarted, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+	waitStatus(restarted, id, workflow.Completed)
+
+	// SubmitRecurring() schedules the first plan and starting it submits the next.
+	if _, err := ws.SubmitRecurring(ctx, factory, "not a spec"); err == nil {
+		t.Errorf("TestSchedule: SubmitRecurring() with a bad spec: got err == nil, want err != nil")
+	}
+	factoryCalls = 0
+	id, err = ws.SubmitRecurring(ctx, factory, "@daily")
+	if err != nil {
+		t.Fatalf("TestSchedule: SubmitRecurring() returned error: %v", err)
+	}
+	first, err := ws.Plan(ctx, id)
+	if err != nil {
+		panic(err)
+	}
+	tomorrow := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	if first.Recurring != "@daily" || !first.StartAt.Equal(tomorrow) {
+		t.Errorf("TestSchedule: recurring plan: got Recurring %q, StartAt %s, want @daily, %s", first.Recurring, first.StartAt, tomorrow)
+	}
+
+	if err := ws.Start(ctx, id); err != nil {
+		t.Fatalf("TestSchedule: Start() on recurring plan returned error: %v", err)
+	}
+	waitStatus(ws, id, workflow.Completed)
+	if factoryCalls != 2 {
+		t.Errorf("TestSchedule: got %d factory calls, want 2", factoryCalls)
+	}
+
+	results, err := vault.Search(ctx, storage.Filters{ByGroupIDs: []uuid.UUID{groupID}, ByStatus: []workflow.Status{workflow.NotStarted}})
+	if err != nil {
+		panic(err)
+	}
+	var next *workflow.Plan
+	for result := range results {
+		if result.Err != nil {
+			panic(result.Err)
+		}
+		p, err := ws.Plan(ctx, result.Result.ID)
+		if err != nil {
+			panic(err)
+		}
+		if p.Recurring != "" {
+			if next != nil {
+				t.Fatalf("TestSchedule: found more than one next recurring plan")
+			}
+			next = p
+		}
+	}
+	if next == nil {
+		t.Fatalf("TestSchedule: did not find the next recurring plan")
+	}
+	if !next.StartAt.Equal(tomorrow.Add(24 * time.Hour)) {
+		t.Errorf("TestSchedule: next recurring plan: got StartAt %s, want %s", next.StartAt, tomorrow.Add(24*time.Hour))
+	}
+	if err := ws.Unschedule(ctx, next.ID); err != nil {
+		t.Errorf("TestSchedule: Unschedule() on next recurring plan returned error: %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	plugAction := &testplugin.Plugin{
+		AlwaysRespond: true,
+	}
+
+	reg := registry.New()
+	reg.Register(plugAction)
+
+	seq := func(timeout time.Duration) *workflow.Sequence {
+		return &workflow.Sequence{
+			Name:    "seq",
+			Descr:   "seq",
+			Timeout: timeout,
+			Actions: []*workflow.Action{
+				{Name: "action0", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{Sleep: 2 * time.Second}},
+				{Name: "action1", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{}},
+			},
+		}
+	}
+
+	// build builds a Plan with two Blocks, the first has the Block and Sequence Timeouts.
+	build := func(planTimeout, blockTimeout, seqTimeout time.Duration) *workflow.Plan {
+		build, err := builder.New("timeout test", "tests that timeouts fail a plan", builder.WithTimeout(planTimeout))
+		if err != nil {
+			panic(err)
+		}
+		build.AddBlock(
+			builder.BlockArgs{
+				Name:        "block0",
+				Descr:       "block0",
+				Concurrency: 1,
+				Timeout:     blockTimeout,
+			},
+		)
+		build.AddSequence(seq(seqTimeout)).Up().Up()
+		build.AddBlock(
+			builder.BlockArgs{
+				Name:        "block1",
+				Descr:       "block1",
+				Concurrency: 1,
+			},
+		)
+		build.AddSequence(seq(0)).Up()
+
+		plan, err := build.Plan()
+		if err != nil {
+			panic(err)
+		}
+		return plan
+	}
+
+	var vault storage.Vault
+	var err error
+	switch *vaultType {
+	case "sqlite":
+		vault, err = sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	case "cosmosdb":
+		cred, err := msiCred(*msi)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info(fmt.Sprintf("TestTimeout: Using cosmosdb: %s, %s", *db, *container))
+		vault, err = cosmosdb.New(ctx, *swarm, *db, *container, cred, reg)
+	default:
+		panic(fmt.Errorf("TestTimeout: unknown storage vault type: %s", *vaultType))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+
+	tests := []struct {
+		name       string
+		plan       *workflow.Plan
+		wantBlock0 workflow.Status
+		wantSeq    workflow.Status
+		wantBlock1 workflow.Status
+	}{
+		{
+			name:       "plan timeout",
+			plan:       build(time.Second, 0, 0),
+			wantBlock0: workflow.Stopped,
+			wantSeq:    workflow.Stopped,
+			wantBlock1: workflow.Stopped,
+		},
+		{
+			name:       "block timeout",
+			plan:       build(0, time.Second, 0),
+			wantBlock0: workflow.Failed,
+			wantSeq:    workflow.Stopped,
+			wantBlock1: workflow.NotStarted,
+		},
+		{
+			name:       "sequence timeout",
+			plan:       build(0, 0, time.Second),
+			wantBlock0: workflow.Failed,
+			wantSeq:    workflow.Failed,
+			wantBlock1: workflow.NotStarted,
+		},
+	}
+
+	for _, test := range tests {
+		id, err := ws.Submit(ctx, test.plan)
+		if err != nil {
+			panic(err)
+		}
+		if err := ws.Start(ctx, id); err != nil {
+			panic(err)
+		}
+
+		result, err := ws.Wait(ctx, id)
+		if err != nil {
+			t.Fatalf("TestTimeout(%s): Wait() returned error: %v", test.name, err)
+		}
+
+		if result.State.Status != workflow.Failed {
+			t.Errorf("TestTimeout(%s): expected Plan in Failed, got %s", test.name, result.State.Status)
+		}
+		if result.Reason != workflow.FRTimeout {
+			t.Errorf("TestTimeout(%s): expected Plan Reason FRTimeout, got %s", test.name, result.Reason)
+		}
+		b0 := result.Blocks[0]
+		if b0.State.Status != test.wantBlock0 {
+			t.Errorf("TestTimeout(%s): expected block 0 in %s, got %s", test.name, test.wantBlock0, b0.State.Status)
+		}
+		// The in-flight action is cancelled, the next one is never started.
+		if b0.Sequences[0].State.Status != test.wantSeq {
+			t.Errorf("TestTimeout(%s): expected block 0 sequence in %s, got %s", test.name, test.wantSeq, b0.Sequences[0].State.Status)
+		}
+		action0 := b0.Sequences[0].Actions[0]
+		if action0.State.Status != workflow.Failed {
+			t.Errorf("TestTimeout(%s): expected block 0 action 0 in Failed, got %s", test.name, action0.State.Status)
+		}
+		if n := len(action0.Attempts); n != 1 || action0.Attempts[0].Err == nil || !strings.Contains(action0.Attempts[0].Err.Error(), "exceeded its timeout") {
+			t.Errorf("TestTimeout(%s): expected block 0 action 0 to have one Attempt cancelled by the timeout, got %d Attempts", test.name, n)
+		}
+		if b0.Sequences[0].Actions[1].State.Status == workflow.Completed {
+			t.Errorf("TestTimeout(%s): expected block 0 action 1 to not run", test.name)
+		}
+		if result.Blocks[1].State.Status != test.wantBlock1 {
+			t.Errorf("TestTimeout(%s): expected block 1 in %s, got %s", test.name, test.wantBlock1, result.Blocks[1].State.Status)
+		}
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	plug := &testplugin.LimitedPlugin{Plugin: &testplugin.Plugin{AlwaysRespond: true}, Max: 2}
+
+	reg := registry.New()
+	reg.Register(plug)
+
+	// Each Plan would run 3 actions at a time on its own, the plugin allows 2 across all Plans.
+	build := func() *workflow.Plan {
+		build, err := builder.New("concurrency limit test", "tests that plugin limits apply across plans")
+		if err != nil {
+			panic(err)
+		}
+		build.AddBlock(
+			builder.BlockArgs{
+				Name:        "block0",
+				Descr:       "block0",
+				Concurrency: 3,
+			},
+		)
+		for i := 0; i < 3; i++ {
+			build.AddSequence(
+				&workflow.Sequence{
+					Name:  "seq",
+					Descr: "seq",
+					Actions: []*workflow.Action{
+						{Name: "action", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{Sleep: 200 * time.Millisecond}},
+					},
+				},
+			).Up()
+		}
+		plan, err := build.Plan()
+		if err != nil {
+			panic(err)
+		}
+		return plan
+	}
+
+	var vault storage.Vault
+	var err error
+	switch *vaultType {
+	case "sqlite":
+		vault, err = sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	case "cosmosdb":
+		cred, err := msiCred(*msi)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info(fmt.Sprintf("TestConcurrencyLimit: Using cosmosdb: %s, %s", *db, *container))
+		vault, err = cosmosdb.New(ctx, *swarm, *db, *container, cred, reg)
+	default:
+		panic(fmt.Errorf("TestConcurrencyLimit: unknown storage vault type: %s", *vaultType))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+
+	var ids []uuid.UUID
+	for i := 0; i < 3; i++ {
+		id, err := ws.Submit(ctx, build())
+		if err != nil {
+			panic(err)
+		}
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		if err := ws.Start(ctx, id); err != nil {
+			panic(err)
+		}
+	}
+
+	var waited time.Duration
+	for _, id := range ids {
+		result, err := ws.Wait(ctx, id)
+		if err != nil {
+			t.Fatalf("TestConcurrencyLimit: Wait() returned error: %v", err)
+		}
+		if result.State.Status != workflow.Completed {
+			t.Errorf("TestConcurrencyLimit: expected Plan in Completed, got %s", result.State.Status)
+		}
+		for item := range walk.Plan(ctx, result) {
+			if item.Value.Type() != workflow.OTAction {
+				continue
+			}
+			for _, a := range item.Action().Attempts {
+				waited += a.SlotWait
+			}
+		}
+	}
+
+	if got := plug.MaxCount.Load(); got > int64(plug.Max) {
+		t.Errorf("TestConcurrencyLimit: got %d concurrent executions, want <= %d", got, plug.Max)
+	}
+	if plug.Calls.Load() != 9 {
+		t.Errorf("TestConcurrencyLimit: got %d executions, want 9", plug.Calls.Load())
+	}
+	if waited == 0 {
+		t.Errorf("TestConcurrencyLimit: expected Attempts to record time waiting for a slot")
+	}
+}
+
+func TestLocks(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	plugAction := &testplugin.Plugin{
+		AlwaysRespond: true,
+	}
+
+	reg := registry.New()
+	reg.Register(plugAction)
+
+	const lockName = "cluster/east-1"
+
+	build := func(policy workflow.LockPolicy) *workflow.Plan {
+		build, err := builder.New(
+			"locks test",
+			"tests that plans holding the same lock do not run at once",
+			builder.WithLocks(&workflow.Locks{Names: []string{lockName}, Policy: policy}),
+		)
+		if err != nil {
+			panic(err)
+		}
+		build.AddBlock(
+			builder.BlockArgs{
+				Name:        "block0",
+				Descr:       "block0",
+				Concurrency: 1,
+			},
+		)
+		build.AddSequence(
+			&workflow.Sequence{
+				Name:  "seq",
+				Descr: "seq",
+				Actions: []*workflow.Action{
+					{Name: "action", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{Sleep: 1 * time.Second}},
+				},
+			},
+		).Up()
+		plan, err := build.Plan()
+		if err != nil {
+			panic(err)
+		}
+		return plan
+	}
+
+	var vault storage.Vault
+	var err error
+	switch *vaultType {
+	case "sqlite":
+		vault, err = sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	case "cosmosdb":
+		cred, err := msiCred(*msi)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info(fmt.Sprintf("TestLocks: Using cosmosdb: %s, %s", *db, *container))
+		vault, err = cosmosdb.New(ctx, *swarm, *db, *container, cred, reg)
+	default:
+		panic(fmt.Errorf("TestLocks: unknown storage vault type: %s", *vaultType))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+
+	submit := func(plan *workflow.Plan) uuid.UUID {
+		id, err := ws.Submit(ctx, plan)
+		if err != nil {
+			panic(err)
+		}
+		if err := ws.Start(ctx, id); err != nil {
+			panic(err)
+		}
+		return id
+	}
+
+	holder := submit(build(workflow.LPWait))
+
+	// Wait for the first Plan to hold the lock before starting the others.
+	for {
+		locks, err := ws.Locks(ctx)
+		if err != nil {
+			t.Fatalf("TestLocks: Locks() returned error: %v", err)
+		}
+		if len(locks) == 1 && locks[0].Name == lockName && locks[0].Owner == holder {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	failer := submit(build(workflow.LPFail))
+	waiter := submit(build(workflow.LPWait))
+
+	result, err := ws.Wait(ctx, failer)
+	if err != nil {
+		t.Fatalf("TestLocks: Wait() returned error: %v", err)
+	}
+	if result.State.Status != workflow.Failed || result.Reason != workflow.FRLocked {
+		t.Errorf("TestLocks: expected LPFail Plan in Failed with FRLocked, got %s with %s", result.State.Status, result.Reason)
+	}
+
+	held, err := ws.Wait(ctx, holder)
+	if err != nil {
+		t.Fatalf("TestLocks: Wait() returned error: %v", err)
+	}
+	waited, err := ws.Wait(ctx, waiter)
+	if err != nil {
+		t.Fatalf("TestLocks: Wait() returned error: %v", err)
+	}
+	for _, p := range []*workflow.Plan{held, waited} {
+		if p.State.Status != workflow.Completed {
+			t.Errorf("TestLocks: expected Plan(%s) in Completed, got %s", p.ID, p.State.Status)
+		}
+	}
+	// The waiting Plan can only run its Blocks once the holder has released the lock.
+	if waited.Blocks[0].State.Start.Before(held.Blocks[0].State.End) {
+		t.Errorf("TestLocks: waiting Plan started its Block at %v, before the holder finished at %v", waited.Blocks[0].State.Start, held.Blocks[0].State.End)
+	}
+
+	locks, err := ws.Locks(ctx)
+	if err != nil {
+		t.Fatalf("TestLocks: Locks() returned error: %v", err)
+	}
+	if len(locks) != 0 {
+		t.Errorf("TestLocks: expected no locks to be held, got %v", locks)
+	}
+}
+
+func TestLeases(t *testing.T) {
+	if *vaultType != "sqlite" {
+		t.Skip("TestLeases: shares a sqlite database between two Vaults")
+	}
+
+	ctx := context.Background()
+
+	plugAction := &testplugin.Plugin{
+		AlwaysRespond: true,
+	}
+
+	reg := registry.New()
+	reg.Register(plugAction)
+
+	build := func() *workflow.Plan {
+		build, err := builder.New("leases test", "tests that plans are taken over from a process that stopped")
+		if err != nil {
+			panic(err)
+		}
+		build.AddBlock(
+			builder.BlockArgs{
+				Name:        "block0",
+				Descr:       "block0",
+				Concurrency: 1,
+			},
+		)
+		build.AddSequence(
+			&workflow.Sequence{
+				Name:  "seq",
+				Descr: "seq",
+				Actions: []*workflow.Action{
+					{Name: "action", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{}},
+				},
+			},
+		).Up()
+		plan, err := build.Plan()
+		if err != nil {
+			panic(err)
+		}
+		return plan
+	}
+
+	// Each Vault on the shared database acts as a separate process.
+	root := t.TempDir()
+	vault1, err := sqlite.New(ctx, root, reg)
+	if err != nil {
+		panic(err)
+	}
+	vault2, err := sqlite.New(ctx, root, reg)
+	if err != nil {
+		panic(err)
+	}
+
+	// The first process submits the Plans. Its lease TTL is long so that it never runs recovery during the test.
+	ws1, err := workstream.New(ctx, reg, vault1, workstream.WithOwner("process1"), workstream.WithLeaseTTL(time.Hour))
+	if err != nil {
+		panic(err)
+	}
+
+	// running submits a Plan and makes it look like it is running in a process with owner, whose lease expires after ttl.
+	running := func(owner string, ttl time.Duration) uuid.UUID {
+		id, err := ws1.Submit(ctx, build())
+		if err != nil {
+			panic(err)
+		}
+		if err := vault1.Claim(ctx, id, owner, ttl); err != nil {
+			panic(err)
+		}
+		plan, err := vault1.Read(ctx, id)
+		if err != nil {
+			panic(err)
+		}
+		plan.State.Status = workflow.Running
+		plan.State.Start = time.Now()
+		if err := vault1.UpdatePlan(ctx, plan); err != nil {
+			panic(err)
+		}
+		return id
+	}
+
+	dead := running("dead", 500*time.Millisecond)
+	alive := running("alive", time.Hour)
+
+	// A Plan that another process has claimed cannot be started.
+	claimed, err := ws1.Submit(ctx, build())
+	if err != nil {
+		panic(err)
+	}
+	if err := vault1.Claim(ctx, claimed, "alive", time.Hour); err != nil {
+		panic(err)
+	}
+	if err := ws1.Start(ctx, claimed); !errors.Is(err, storage.ErrLeased) {
+		t.Errorf("TestLeases: Start() of a claimed Plan: got err == %v, want err == storage.ErrLeased", err)
+	}
+
+	// The second process starts while the dead process's lease is still valid, so it must wait for it to expire.
+	ws2, err := workstream.New(ctx, reg, vault2, workstream.WithOwner("process2"), workstream.WithLeaseTTL(200*time.Millisecond))
+	if err != nil {
+		panic(err)
+	}
+	plan, err := ws2.Plan(ctx, dead)
+	if err != nil {
+		panic(err)
+	}
+	if plan.State.Status != workflow.Running || plugAction.Calls.Load() != 0 {
+		t.Fatalf("TestLeases: Plan was recovered before its lease expired")
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		plan, err = ws2.Plan(ctx, dead)
+		if err != nil {
+			panic(err)
+		}
+		if plan.State.Status != workflow.Running || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if plan.State.Status != workflow.Completed {
+		t.Errorf("TestLeases: expected the dead process's Plan to be taken over and Completed, got %s", plan.State.Status)
+	}
+
+	// The Plan with a live lease is not touched by the second process.
+	plan, err = ws2.Plan(ctx, alive)
+	if err != nil {
+		panic(err)
+	}
+	if plan.State.Status != workflow.Running {
+		t.Errorf("TestLeases: expected the live process's Plan to still be Running, got %s", plan.State.Status)
+	}
+	if got := plugAction.Calls.Load(); got != 1 {
+		t.Errorf("TestLeases: got %d executions, want 1", got)
+	}
+
+	leases, err := vault2.Leases(ctx)
+	if err != nil {
+		panic(err)
+	}
+	owners := map[uuid.UUID]string{}
+	for _, l := range leases {
+		owners[l.PlanID] = l.Owner
+	}
+	if _, ok := owners[dead]; ok {
+		t.Errorf("TestLeases: expected the lease on the completed Plan to be released")
+	}
+	if owners[alive] != "alive" || owners[claimed] != "alive" {
+		t.Errorf("TestLeases: expected the live process to keep its leases, got %v", owners)
+	}
+}
+
 func validateFlags() error {
 	if *vaultType == "cosmosdb" {
 		if *db == "" {
 			return fmt.Errorf("missing db name")
 		}
-		if *container == "" {
-			return fmt.Errorf("missing container name")
+		if *container == "" {
+			return fmt.Errorf("missing container name")
+		}
+	}
+	return nil
+}
+
+// msiCred returns a managed identity credential.
+func msiCred(msi string) (azcore.TokenCredential, error) {
+	if msi != "" {
+		msiResc := azidentity.ResourceID(msi)
+		msiOpts := azidentity.ManagedIdentityCredentialOptions{ID: msiResc}
+		cred, err := azidentity.NewManagedIdentityCredential(&msiOpts)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("Authentication is using identity token.")
+		return cred, nil
+	}
+	// Otherwise, allow authentication via az login
+	// Need following roles comosdb sql roles:
+	// https://learn.microsoft.com/en-us/azure/cosmos-db/nosql/security/how-to-grant-data-plane-role-based-access?tabs=built-in-definition%2Ccsharp&pivots=azure-interface-cli
+	azOptions := &azidentity.AzureCLICredentialOptions{}
+	azCred, err := azidentity.NewAzureCLICredential(azOptions)
+	if err != nil {
+		return nil, fmt.Errorf("creating az cli credential: %s", err)
+	}
+
+	log.Println("Authentication is using az cli token.")
+	return azCred, nil
+}
+
+func TestRefs(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	plugAction := &testplugin.Plugin{
+		AlwaysRespond: true,
+	}
+
+	reg := registry.New()
+	reg.Register(plugAction)
+
+	createKey := workflow.NewV7()
+
+	build, err := builder.New("refs test", "tests that actions can use the responses of earlier actions")
+	if err != nil {
+		panic(err)
+	}
+	build.AddBlock(
+		builder.BlockArgs{
+			Name:        "block0",
+			Descr:       "block0",
+			Concurrency: 1,
+		},
+	)
+	build.AddSequence(
+		&workflow.Sequence{
+			Name:  "seq",
+			Descr: "seq",
+			Actions: []*workflow.Action{
+				// The response is "echo:resolved", which the next Action echoes as "resolved".
+				{Key: createKey, Name: "create", Descr: "create", Plugin: testplugin.Name, Req: testplugin.Req{Arg: "echo:echo:resolved"}},
+			},
+		},
+	).Up().Up()
+	build.AddBlock(
+		builder.BlockArgs{
+			Name:        "block1",
+			Descr:       "block1",
+			Concurrency: 1,
+		},
+	)
+	build.AddSequence(
+		&workflow.Sequence{
+			Name:  "seq",
+			Descr: "seq",
+			Actions: []*workflow.Action{
+				{
+					Name:   "use",
+					Descr:  "use",
+					Plugin: testplugin.Name,
+					Req:    testplugin.Req{},
+					Refs:   []workflow.Ref{{Key: createKey, Path: "Arg", Field: "Arg"}},
+				},
+			},
+		},
+	).Up()
+	plan, err := build.Plan()
+	if err != nil {
+		panic(err)
+	}
+
+	var vault storage.Vault
+	switch *vaultType {
+	case "sqlite":
+		vault, err = sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	case "cosmosdb":
+		cred, err := msiCred(*msi)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info(fmt.Sprintf("TestRefs: Using cosmosdb: %s, %s", *db, *container))
+		vault, err = cosmosdb.New(ctx, *swarm, *db, *container, cred, reg)
+	default:
+		panic(fmt.Errorf("TestRefs: unknown storage vault type: %s", *vaultType))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+
+	// A ref to an Action that does not run before it is rejected at Submit.
+	bad := clone.Plan(ctx, plan)
+	bad.Blocks[0].Sequences[0].Actions[0].Refs = []workflow.Ref{{Key: createKey, Path: "Arg", Field: "Arg"}}
+	if _, err := ws.Submit(ctx, bad); err == nil {
+		t.Fatalf("TestRefs: Submit() with a ref to itself: got err == nil, want err != nil")
+	}
+
+	id, err := ws.Submit(ctx, plan)
+	if err != nil {
+		panic(err)
+	}
+	if err := ws.Start(ctx, id); err != nil {
+		panic(err)
+	}
+
+	result, err := ws.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("TestRefs: Wait() returned error: %v", err)
+	}
+	if result.State.Status != workflow.Completed {
+		t.Fatalf("TestRefs: expected Plan in Completed, got %s", result.State.Status)
+	}
+
+	use := result.Blocks[1].Sequences[0].Actions[0]
+	resp, ok := use.FinalAttempt().Resp.(testplugin.Resp)
+	if !ok {
+		t.Fatalf("TestRefs: got response type %T, want testplugin.Resp", use.FinalAttempt().Resp)
+	}
+	if resp.Arg != "resolved" {
+		t.Errorf("TestRefs: got response Arg %q, want %q", resp.Arg, "resolved")
+	}
+}
+
+const genPluginName = "github.com/element-of-surprise/coercion/internal/etoe.Generator"
+
+type genReq struct {
+	Hosts []string
+}
+
+// genResp implements workflow.Generated with a Sequence for each host.
+type genResp struct {
+	Hosts []string
+}
+
+func (g genResp) Sequences() []*workflow.Sequence {
+	seqs := make([]*workflow.Sequence, 0, len(g.Hosts))
+	for _, host := range g.Hosts {
+		seqs = append(
+			seqs,
+			&workflow.Sequence{
+				Name:  host,
+				Descr: "generated for " + host,
+				Actions: []*workflow.Action{
+					{Name: "action", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{Arg: "echo:" + host}},
+				},
+			},
+		)
+	}
+	return seqs
+}
+
+// genPlugin is a generator plugin that responds with the hosts it was given.
+type genPlugin struct{}
+
+func (genPlugin) Name() string {
+	return genPluginName
+}
+
+func (genPlugin) Execute(ctx context.Context, req any) (any, *pluglib.Error) {
+	return genResp{Hosts: req.(genReq).Hosts}, nil
+}
+
+func (genPlugin) ValidateReq(req any) error {
+	if _, ok := req.(genReq); !ok {
+		return fmt.Errorf("req is not a genReq, was %T", req)
+	}
+	return nil
+}
+
+func (genPlugin) Request() any {
+	return genReq{}
+}
+
+func (genPlugin) Response() any {
+	return genResp{}
+}
+
+func (genPlugin) IsCheck() bool {
+	return false
+}
+
+func (genPlugin) RetryPolicy() exponential.Policy {
+	return pluglib.FastRetryPolicy()
+}
+
+func (genPlugin) Init() error {
+	return nil
+}
+
+func TestGenerator(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	reg := registry.New()
+	reg.Register(&testplugin.Plugin{AlwaysRespond: true})
+	reg.Register(genPlugin{})
+
+	hosts := []string{"host0", "host1", "host2"}
+
+	build, err := builder.New("generator test", "tests that a generator adds sequences when a block runs")
+	if err != nil {
+		panic(err)
+	}
+	build.AddBlock(
+		builder.BlockArgs{
+			Name:        "block",
+			Descr:       "block",
+			Concurrency: 2,
+			Generator:   &workflow.Action{Name: "generator", Descr: "generator", Plugin: genPluginName, Req: genReq{Hosts: hosts}},
+		},
+	).Up()
+	plan, err := build.Plan()
+	if err != nil {
+		panic(err)
+	}
+
+	var vault storage.Vault
+	switch *vaultType {
+	case "sqlite":
+		vault, err = sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	case "cosmosdb":
+		cred, err := msiCred(*msi)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info(fmt.Sprintf("TestGenerator: Using cosmosdb: %s, %s", *db, *container))
+		vault, err = cosmosdb.New(ctx, *swarm, *db, *container, cred, reg)
+	default:
+		panic(fmt.Errorf("TestGenerator: unknown storage vault type: %s", *vaultType))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+
+	id, err := ws.Submit(ctx, plan)
+	if err != nil {
+		panic(err)
+	}
+	if err := ws.Start(ctx, id); err != nil {
+		panic(err)
+	}
+
+	result, err := ws.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("TestGenerator: Wait() returned error: %v", err)
+	}
+	if result.State.Status != workflow.Completed {
+		t.Fatalf("TestGenerator: expected Plan in Completed, got %s", result.State.Status)
+	}
+
+	// The result is read from the vault, so this checks that the generated Sequences were stored.
+	b := result.Blocks[0]
+	if b.Generator.State.Status != workflow.Completed {
+		t.Errorf("TestGenerator: expected Generator in Completed, got %s", b.Generator.State.Status)
+	}
+	if len(b.Sequences) != len(hosts) {
+		t.Fatalf("TestGenerator: got %d Sequences, want %d", len(b.Sequences), len(hosts))
+	}
+	for i, seq := range b.Sequences {
+		if seq.Name != hosts[i] {
+			t.Errorf("TestGenerator: Sequence(%d): got name %q, want %q", i, seq.Name, hosts[i])
+		}
+		if seq.State.Status != workflow.Completed {
+			t.Errorf("TestGenerator: Sequence(%s): expected Completed, got %s", seq.Name, seq.State.Status)
+		}
+		resp, ok := seq.Actions[0].FinalAttempt().Resp.(testplugin.Resp)
+		if !ok || resp.Arg != hosts[i] {
+			t.Errorf("TestGenerator: Sequence(%s): got response %v, want Arg %q", seq.Name, seq.Actions[0].FinalAttempt().Resp, hosts[i])
+		}
+	}
+}
+
+func TestRollback(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	reg := registry.New()
+	reg.Register(&testplugin.Plugin{AlwaysRespond: true})
+
+	createKey := workflow.NewV7()
+	action := func(name, arg string) *workflow.Action {
+		return &workflow.Action{Name: name, Descr: name, Plugin: testplugin.Name, Req: testplugin.Req{Arg: arg}}
+	}
+
+	build, err := builder.New("rollback test", "tests that rollbacks run when sequences and blocks fail")
+	if err != nil {
+		panic(err)
+	}
+	build.AddBlock(
+		builder.BlockArgs{
+			Name:        "block",
+			Descr:       "block",
+			Concurrency: 1,
+			Rollback:    []*workflow.Action{action("block-undo", "ok")},
+		},
+	)
+	build.AddSequence(
+		&workflow.Sequence{
+			Name:  "completes",
+			Descr: "completes",
+			Actions: []*workflow.Action{
+				{Key: createKey, Name: "create", Descr: "create", Plugin: testplugin.Name, Req: testplugin.Req{Arg: "echo:echo:vm"}},
+			},
+			Rollback: []*workflow.Action{
+				{
+					Name:   "delete",
+					Descr:  "delete",
+					Plugin: testplugin.Name,
+					Req:    testplugin.Req{},
+					Refs:   []workflow.Ref{{Key: createKey, Path: "Arg", Field: "Arg"}},
+				},
+			},
+		},
+	).Up()
+	build.AddSequence(
+		&workflow.Sequence{
+			Name:     "fails",
+			Descr:    "fails",
+			Actions:  []*workflow.Action{action("a0", "ok"), action("a1", "error"), action("a2", "ok")},
+			Rollback: []*workflow.Action{action("r0", "ok"), action("r1", "error"), action("r2", "ok")},
+		},
+	).Up()
+	plan, err := build.Plan()
+	if err != nil {
+		panic(err)
+	}
+
+	var vault storage.Vault
+	switch *vaultType {
+	case "sqlite":
+		vault, err = sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	case "cosmosdb":
+		cred, err := msiCred(*msi)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info(fmt.Sprintf("TestRollback: Using cosmosdb: %s, %s", *db, *container))
+		vault, err = cosmosdb.New(ctx, *swarm, *db, *container, cred, reg)
+	default:
+		panic(fmt.Errorf("TestRollback: unknown storage vault type: %s", *vaultType))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+
+	id, err := ws.Submit(ctx, plan)
+	if err != nil {
+		panic(err)
+	}
+	if err := ws.Start(ctx, id); err != nil {
+		panic(err)
+	}
+
+	result, err := ws.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("TestRollback: Wait() returned error: %v", err)
+	}
+	if result.State.Status != workflow.Failed {
+		t.Fatalf("TestRollback: expected Plan in Failed, got %s", result.State.Status)
+	}
+
+	statuses := func(actions []*workflow.Action) []workflow.Status {
+		s := make([]workflow.Status, 0, len(actions))
+		for _, a := range actions {
+			s = append(s, a.State.Status)
+		}
+		return s
+	}
+
+	// The result is read from the vault, so this checks that the rollbacks were stored.
+	b := result.Blocks[0]
+	completes, fails := b.Sequences[0], b.Sequences[1]
+	if diff := pretty.Compare([]workflow.Status{workflow.Completed, workflow.Failed, workflow.NotStarted}, statuses(fails.Rollback)); diff != "" {
+		t.Errorf("TestRollback: failed Sequence Rollback: -want/+got:\n%s", diff)
+	}
+	if fails.Rollback[1].FinalAttempt().Err == nil {
+		t.Errorf("TestRollback: failed Rollback Action has no error")
+	}
+	if diff := pretty.Compare([]workflow.Status{workflow.Completed}, statuses(completes.Rollback)); diff != "" {
+		t.Errorf("TestRollback: completed Sequence Rollback: -want/+got:\n%s", diff)
+	}
+	if resp, ok := completes.Rollback[0].FinalAttempt().Resp.(testplugin.Resp); !ok || resp.Arg != "vm" {
+		t.Errorf("TestRollback: Rollback with a Ref: got response %v, want Arg %q", completes.Rollback[0].FinalAttempt().Resp, "vm")
+	}
+	if diff := pretty.Compare([]workflow.Status{workflow.Completed}, statuses(b.Rollback)); diff != "" {
+		t.Errorf("TestRollback: Block Rollback: -want/+got:\n%s", diff)
+	}
+}
+
+func TestSubPlan(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	reg := registry.New()
+	reg.Register(&testplugin.Plugin{AlwaysRespond: true})
+
+	var vault storage.Vault
+	var err error
+	switch *vaultType {
+	case "sqlite":
+		vault, err = sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	case "cosmosdb":
+		cred, err := msiCred(*msi)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info(fmt.Sprintf("TestSubPlan: Using cosmosdb: %s, %s", *db, *container))
+		vault, err = cosmosdb.New(ctx, *swarm, *db, *container, cred, reg)
+	default:
+		panic(fmt.Errorf("TestSubPlan: unknown storage vault type: %s", *vaultType))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+
+	newPlan := func(name, arg string) *workflow.Plan {
+		build, err := builder.New(name, name)
+		if err != nil {
+			panic(err)
+		}
+		build.AddBlock(builder.BlockArgs{Name: "block", Descr: "block", Concurrency: 1})
+		build.AddSequence(&workflow.Sequence{Name: "seq", Descr: "seq"})
+		build.AddAction(&workflow.Action{Name: "action", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{Arg: arg}})
+		p, err := build.Plan()
+		if err != nil {
+			panic(err)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name      string
+		arg       string
+		want      workflow.Status
+		wantAfter workflow.Status
+	}{
+		{name: "SubPlan completes", arg: "ok", want: workflow.Completed, wantAfter: workflow.Completed},
+		{name: "SubPlan fails", arg: "error", want: workflow.Failed, wantAfter: workflow.NotStarted},
+	}
+
+	for _, test := range tests {
+		sub := newPlan("sub-plan", test.arg)
+		build, err := builder.New("sub-plan test", "tests that a block runs a sub-plan")
+		if err != nil {
+			panic(err)
+		}
+		build.AddBlock(builder.BlockArgs{Name: "sub-plan", Descr: "runs the sub-plan", SubPlan: sub}).Up()
+		build.AddBlock(builder.BlockArgs{Name: "after", Descr: "runs after the sub-plan", Concurrency: 1})
+		build.AddSequence(&workflow.Sequence{Name: "seq", Descr: "seq"})
+		build.AddAction(&workflow.Action{Name: "action", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{Arg: "ok"}})
+		plan, err := build.Plan()
+		if err != nil {
+			panic(err)
+		}
+
+		id, err := ws.Submit(ctx, plan)
+		if err != nil {
+			t.Fatalf("TestSubPlan(%s): Submit() returned error: %v", test.name, err)
+		}
+		if sub.ID == uuid.Nil || sub.CallerID != id {
+			t.Fatalf("TestSubPlan(%s): got sub-plan ID %s with CallerID %s, want an ID with CallerID %s", test.name, sub.ID, sub.CallerID, id)
+		}
+		if err := ws.Start(ctx, sub.ID); err == nil {
+			t.Errorf("TestSubPlan(%s): Start() on a sub-plan: got err == nil, want err != nil", test.name)
+		}
+
+		results, err := vault.Search(ctx, storage.Filters{ByCallerIDs: []uuid.UUID{id}})
+		if err != nil {
+			t.Fatalf("TestSubPlan(%s): Search() returned error: %v", test.name, err)
+		}
+		var found []uuid.UUID
+		for r := range results {
+			if r.Err != nil {
+				t.Fatalf("TestSubPlan(%s): Search() stream error: %v", test.name, r.Err)
+			}
+			found = append(found, r.Result.ID)
+		}
+		if diff := pretty.Compare([]uuid.UUID{sub.ID}, found); diff != "" {
+			t.Errorf("TestSubPlan(%s): Search(ByCallerIDs): -want/+got:\n%s", test.name, diff)
+		}
+
+		if err := ws.Start(ctx, id); err != nil {
+			t.Fatalf("TestSubPlan(%s): Start() returned error: %v", test.name, err)
+		}
+		result, err := ws.Wait(ctx, id)
+		if err != nil {
+			t.Fatalf("TestSubPlan(%s): Wait() returned error: %v", test.name, err)
+		}
+		if result.State.Status != test.want {
+			t.Errorf("TestSubPlan(%s): got Plan status %s, want %s", test.name, result.State.Status, test.want)
+		}
+
+		// The result is read from the vault, so this checks that the SubPlan's state was stored.
+		b := result.Blocks[0]
+		if b.SubPlan == nil || b.SubPlan.ID != sub.ID {
+			t.Fatalf("TestSubPlan(%s): Block does not have the sub-plan", test.name)
+		}
+		if b.State.Status != test.want || b.SubPlan.State.Status != test.want {
+			t.Errorf("TestSubPlan(%s): got Block status %s and sub-plan status %s, want %s", test.name, b.State.Status, b.SubPlan.State.Status, test.want)
+		}
+		if s := result.Blocks[1].State.Status; s != test.wantAfter {
+			t.Errorf("TestSubPlan(%s): got status %s for the Block after the sub-plan, want %s", test.name, s, test.wantAfter)
 		}
 	}
-	return nil
 }
 
-// msiCred returns a managed identity credential.
-func msiCred(msi string) (azcore.TokenCredential, error) {
-	if msi != "" {
-		msiResc := azidentity.ResourceID(msi)
-		msiOpts := azidentity.ManagedIdentityCredentialOptions{ID: msiResc}
-		cred, err := azidentity.NewManagedIdentityCredential(&msiOpts)
+func TestBlockDependsOn(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	reg := registry.New()
+	reg.Register(&testplugin.Plugin{AlwaysRespond: true})
+
+	var vault storage.Vault
+	var err error
+	switch *vaultType {
+	case "sqlite":
+		vault, err = sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	case "cosmosdb":
+		cred, err := msiCred(*msi)
 		if err != nil {
-			return nil, err
+			panic(err)
 		}
-		log.Println("Authentication is using identity token.")
-		return cred, nil
+		logger.Info(fmt.Sprintf("TestBlockDependsOn: Using cosmosdb: %s, %s", *db, *container))
+		vault, err = cosmosdb.New(ctx, *swarm, *db, *container, cred, reg)
+	default:
+		panic(fmt.Errorf("TestBlockDependsOn: unknown storage vault type: %s", *vaultType))
 	}
-	// Otherwise, allow authentication via az login
-	// Need following roles comosdb sql roles:
-	// https://learn.microsoft.com/en-us/azure/cosmos-db/nosql/security/how-to-grant-data-plane-role-based-access?tabs=built-in-definition%2Ccsharp&pivots=azure-interface-cli
-	azOptions := &azidentity.AzureCLICredentialOptions{}
-	azCred, err := azidentity.NewAzureCLICredential(azOptions)
 	if err != nil {
-		return nil, fmt.Errorf("creating az cli credential: %s", err)
+		panic(err)
 	}
 
-	log.Println("Authentication is using az cli token.")
-	return azCred, nil
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		panic(err)
+	}
+
+	tests := []struct {
+		name     string
+		argB     string
+		want     workflow.Status
+		wantLast workflow.Status
+	}{
+		{name: "dependencies complete", argB: "ok", want: workflow.Completed, wantLast: workflow.Completed},
+		{name: "dependency fails", argB: "error", want: workflow.Failed, wantLast: workflow.NotStarted},
+	}
+
+	for _, test := range tests {
+		keyA, keyB := workflow.NewV7(), workflow.NewV7()
+		build, err := builder.New("depends on test", "tests that blocks run as a DAG", builder.WithBlockConcurrency(2))
+		if err != nil {
+			panic(err)
+		}
+		addBlock := func(args builder.BlockArgs, arg string) {
+			args.Descr = args.Name
+			args.Concurrency = 1
+			build.AddBlock(args)
+			build.AddSequence(&workflow.Sequence{Name: "seq", Descr: "seq"})
+			build.AddAction(&workflow.Action{Name: "action", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{Arg: arg, Sleep: 500 * time.Millisecond}})
+			build.Up().Up()
+		}
+		addBlock(builder.BlockArgs{Name: "last", DependsOn: []uuid.UUID{keyA, keyB}}, "ok")
+		addBlock(builder.BlockArgs{Name: "a", Key: keyA}, "ok")
+		addBlock(builder.BlockArgs{Name: "b", Key: keyB}, test.argB)
+		plan, err := build.Plan()
+		if err != nil {
+			panic(err)
+		}
+
+		id, err := ws.Submit(ctx, plan)
+		if err != nil {
+			t.Fatalf("TestBlockDependsOn(%s): Submit() returned error: %v", test.name, err)
+		}
+		if err := ws.Start(ctx, id); err != nil {
+			t.Fatalf("TestBlockDependsOn(%s): Start() returned error: %v", test.name, err)
+		}
+		result, err := ws.Wait(ctx, id)
+		if err != nil {
+			t.Fatalf("TestBlockDependsOn(%s): Wait() returned error: %v", test.name, err)
+		}
+		if result.State.Status != test.want {
+			t.Errorf("TestBlockDependsOn(%s): got Plan status %s, want %s", test.name, result.State.Status, test.want)
+		}
+
+		last, a, b := result.Blocks[0], result.Blocks[1], result.Blocks[2]
+		if last.State.Status != test.wantLast {
+			t.Errorf("TestBlockDependsOn(%s): got status %s for the dependent Block, want %s", test.name, last.State.Status, test.wantLast)
+		}
+		if a.State.Status != workflow.Completed {
+			t.Errorf("TestBlockDependsOn(%s): got status %s for Block a, want %s", test.name, a.State.Status, workflow.Completed)
+		}
+		// The independent Blocks run at the same time.
+		if !a.State.Start.Before(b.State.End) || !b.State.Start.Before(a.State.End) {
+			t.Errorf("TestBlockDependsOn(%s): Blocks a and b did not run at the same time", test.name)
+		}
+		if test.wantLast == workflow.Completed {
+			if last.State.Start.Before(a.State.End) || last.State.Start.Before(b.State.End) {
+				t.Errorf("TestBlockDependsOn(%s): dependent Block started before its dependencies ended", test.name)
+			}
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	if *vaultType != "sqlite" {
+		t.Skip("TestClose: reopens a sqlite database after the Workstream is closed")
+	}
+
+	ctx := context.Background()
+
+	plugAction := &testplugin.Plugin{
+		AlwaysRespond: true,
+	}
+
+	reg := registry.New()
+	reg.Register(plugAction)
+
+	build, err := builder.New("close test", "tests that a drained plan is resumed by the next process")
+	if err != nil {
+		panic(err)
+	}
+	build.AddBlock(
+		builder.BlockArgs{
+			Name:        "block0",
+			Descr:       "block0",
+			Concurrency: 1,
+		},
+	)
+	seq := &workflow.Sequence{Name: "seq", Descr: "seq"}
+	for i := 0; i < 3; i++ {
+		seq.Actions = append(seq.Actions, &workflow.Action{
+			Name:   fmt.Sprintf("action%d", i),
+			Descr:  "action",
+			Plugin: testplugin.Name,
+			Req:    testplugin.Req{Sleep: 500 * time.Millisecond},
+		})
+	}
+	build.AddSequence(seq).Up()
+	plan, err := build.Plan()
+	if err != nil {
+		panic(err)
+	}
+
+	root := t.TempDir()
+	vault1, err := sqlite.New(ctx, root, reg)
+	if err != nil {
+		panic(err)
+	}
+	// The first Workstream records its metrics in reader, which must not show the drained Plan as failed.
+	// context.Background() attaches the default MeterProvider, which the Workstream records with.
+	reader := sdkmetric.NewManualReader()
+	defaultMP := metrics.Default()
+	metrics.Set(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	ws1, err := workstream.New(context.Background(), reg, vault1, workstream.WithOwner("process1"))
+	metrics.Set(defaultMP)
+	if err != nil {
+		panic(err)
+	}
+	id, err := ws1.Submit(ctx, plan)
+	if err != nil {
+		panic(err)
+	}
+	if err := ws1.Start(ctx, id); err != nil {
+		panic(err)
+	}
+
+	for plugAction.Calls.Load() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The first Action is running, so Close waits for it to finish and does not start the next one.
+	if err := ws1.Close(ctx); err != nil {
+		t.Fatalf("TestClose: Close(): got err == %v, want err == nil", err)
+	}
+	if err := ws1.Start(ctx, id); err == nil {
+		t.Errorf("TestClose: Start() after Close(): got err == nil, want err != nil")
+	}
+
+	// Close does not wait for the statemachine of the drained Plan to end.
+	var rm metricdata.ResourceMetrics
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		rm = metricdata.ResourceMetrics{}
+		if err := reader.Collect(ctx, &rm); err != nil {
+			panic(err)
+		}
+		running := metricPoints(rm, "coercion.plans.running")
+		if len(running) == 1 && running[0].Value == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("TestClose: got running Plans %+v after Close(), want 0", running)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if points := metricPoints(rm, "coercion.plans"); len(points) != 0 {
+		t.Errorf("TestClose: drained Plan was recorded as ended: %+v", points)
+	}
+	for _, name := range []string{"coercion.blocks", "coercion.sequences", "coercion.actions"} {
+		for _, p := range metricPoints(rm, name) {
+			if v, _ := p.Attributes.Value("coercion.status"); v.AsString() != workflow.Completed.String() {
+				t.Errorf("TestClose: got %s recorded with status %s for the drained Plan", name, v.AsString())
+			}
+		}
+	}
+
+	vault2, err := sqlite.New(ctx, root, reg)
+	if err != nil {
+		panic(err)
+	}
+	drained, err := vault2.Read(ctx, id)
+	if err != nil {
+		panic(err)
+	}
+	if drained.State.Status != workflow.Running || !drained.Drained {
+		t.Fatalf("TestClose: got Status %s, Drained %v, want Running, true", drained.State.Status, drained.Drained)
+	}
+	actions := drained.Blocks[0].Sequences[0].Actions
+	if actions[0].State.Status != workflow.Completed || actions[1].State.Status != workflow.NotStarted {
+		t.Errorf("TestClose: got action statuses %s, %s, want Completed, NotStarted", actions[0].State.Status, actions[1].State.Status)
+	}
+	leases, err := vault2.Leases(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if len(leases) != 0 {
+		t.Errorf("TestClose: expected the lease on the drained Plan to be released, got %v", leases)
+	}
+
+	// A drained Plan is resumed even though it is older than WithMaxLastUpdate().
+	time.Sleep(10 * time.Millisecond)
+	ws2, err := workstream.New(ctx, reg, vault2, workstream.WithOwner("process2"), workstream.WithMaxLastUpdate(time.Millisecond))
+	if err != nil {
+		panic(err)
+	}
+	defer ws2.Close(ctx)
+
+	result, err := ws2.Wait(ctx, id)
+	if err != nil {
+		panic(err)
+	}
+	if result.State.Status != workflow.Completed {
+		t.Errorf("TestClose: expected the drained Plan to be resumed and Completed, got %s", result.State.Status)
+	}
+	if result.Drained {
+		t.Errorf("TestClose: expected Plan.Drained to be cleared when the Plan is resumed")
+	}
+	if got := plugAction.Calls.Load(); got != 3 {
+		t.Errorf("TestClose: got %d executions, want 3", got)
+	}
+}
+
+// metricPoints returns the data points of the int64 sum named name in rm.
+func metricPoints(rm metricdata.ResourceMetrics, name string) []metricdata.DataPoint[int64] {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m.Data.(metricdata.Sum[int64]).DataPoints
+			}
+		}
+	}
+	return nil
+}
+
+func TestTracing(t *testing.T) {
+	ctx := context.Background()
+
+	plugCheck := &testplugin.Plugin{
+		AlwaysRespond: true,
+		IsCheckPlugin: true,
+		PlugName:      "check",
+	}
+	plugAction := &testplugin.Plugin{
+		AlwaysRespond: true,
+	}
+
+	reg := registry.New()
+	reg.Register(plugCheck)
+	reg.Register(plugAction)
+
+	build, err := builder.New("tracing test", "tests that the execution of a plan is traced")
+	if err != nil {
+		panic(err)
+	}
+	build.AddChecks(
+		builder.PostChecks,
+		&workflow.Checks{
+			Actions: []*workflow.Action{
+				{Name: "check", Descr: "check", Plugin: "check", Req: testplugin.Req{}},
+			},
+		},
+	).Up()
+	build.AddBlock(
+		builder.BlockArgs{
+			Name:        "block0",
+			Descr:       "block0",
+			Concurrency: 1,
+		},
+	)
+	build.AddSequence(
+		&workflow.Sequence{
+			Name:  "seq",
+			Descr: "seq",
+			Actions: []*workflow.Action{
+				{Name: "action", Descr: "action", Plugin: testplugin.Name, Req: testplugin.Req{Arg: "spanid"}},
+			},
+		},
+	).Up()
+	plan, err := build.Plan()
+	if err != nil {
+		panic(err)
+	}
+
+	vault, err := sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	if err != nil {
+		panic(err)
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	ws, err := workstream.New(ctx, reg, vault, workstream.WithTracerProvider(tp))
+	if err != nil {
+		panic(err)
+	}
+
+	id, err := ws.Submit(ctx, plan)
+	if err != nil {
+		panic(err)
+	}
+	if err := ws.Start(ctx, id); err != nil {
+		panic(err)
+	}
+	result, err := ws.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("TestTracing: Wait() returned error: %v", err)
+	}
+	if result.State.Status != workflow.Completed {
+		t.Fatalf("TestTracing: expected Plan in Completed, got %s", result.State.Status)
+	}
+
+	byName := map[string][]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		byName[span.Name] = append(byName[span.Name], span)
+	}
+	for name, want := range map[string]int{"Plan": 1, "Checks": 1, "Block": 1, "Sequence": 1, "Action": 2, "Attempt": 2} {
+		if got := len(byName[name]); got != want {
+			t.Fatalf("TestTracing: got %d %s spans, want %d", got, name, want)
+		}
+	}
+
+	planSpan := byName["Plan"][0]
+	if result.TraceID != planSpan.SpanContext.TraceID().String() {
+		t.Errorf("TestTracing: got Plan.TraceID %q, want %q", result.TraceID, planSpan.SpanContext.TraceID())
+	}
+
+	// parentOf returns the single span named name whose parent is parent.
+	parentOf := func(name string, parent tracetest.SpanStub) tracetest.SpanStub {
+		for _, span := range byName[name] {
+			if span.Parent.SpanID() == parent.SpanContext.SpanID() {
+				return span
+			}
+		}
+		t.Fatalf("TestTracing: no %s span is a child of the %s span", name, parent.Name)
+		return tracetest.SpanStub{}
+	}
+	parentOf("Checks", planSpan)
+	blockSpan := parentOf("Block", planSpan)
+	seqSpan := parentOf("Sequence", blockSpan)
+	actionSpan := parentOf("Action", seqSpan)
+	attemptSpan := parentOf("Attempt", actionSpan)
+
+	for _, span := range exporter.GetSpans() {
+		if span.SpanContext.TraceID() != planSpan.SpanContext.TraceID() {
+			t.Errorf("TestTracing: span %s is not in the Plan's trace", span.Name)
+		}
+	}
+
+	// The plugin returns the ID of the span in the Context it was executed with.
+	action := result.Blocks[0].Sequences[0].Actions[0]
+	if got := action.FinalAttempt().Resp.(testplugin.Resp).Arg; got != attemptSpan.SpanContext.SpanID().String() {
+		t.Errorf("TestTracing: plugin was executed with span %s, want the Attempt's span %s", got, attemptSpan.SpanContext.SpanID())
+	}
 }
@@ -0,0 +1,78 @@
+package execute
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+	"github.com/google/uuid"
+
+	"github.com/gostdlib/base/telemetry/log"
+)
+
+// ErrClosed is returned when the Plans has been closed.
+var ErrClosed = errors.New("closed")
+
+// Close stops Plans from starting and stops writing the state of running Plans to storage. If drain is set,
+// running Actions are allowed to finish and no new Actions, Sequences or Blocks start. Each running Plan is
+// then marked workflow.Plan.Drained and its lease is released so that another process, or this one after a
+// restart, resumes it. If drain is not set, or ctx is done before the running Actions finish, the running
+// Plans are left as if the process crashed. Close does not close the storage.Vault.
+func (e *Plans) Close(ctx context.Context, drain bool) error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return ErrClosed
+	}
+	e.closed = true
+	for id, t := range e.schedules {
+		t.Stop()
+		delete(e.schedules, id)
+	}
+	var running []uuid.UUID
+	for id := range e.stoppers {
+		running = append(running, id)
+	}
+	close(e.closing)
+	e.mu.Unlock()
+
+	// Leases are no longer renewed, a drained Plan's lease is released below and the lease on any other
+	// Plan expires.
+	e.renewing.Wait(context.WithoutCancel(ctx))
+
+	if !drain {
+		return e.states.Halt(ctx)
+	}
+	if err := e.states.Drain(ctx); err != nil {
+		return err
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	var err error
+	for _, id := range running {
+		if hErr := e.handoff(ctx, id); hErr != nil {
+			err = errors.Join(err, hErr)
+		}
+	}
+	return err
+}
+
+// handoff marks a running Plan that was drained and releases its lease so that it can be recovered.
+func (e *Plans) handoff(ctx context.Context, id uuid.UUID) error {
+	plan, err := e.store.Read(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to read drained plan(%s): %w", id, err)
+	}
+	// The Plan finished before the drain and released its own lease.
+	if plan.State.Status != workflow.Running {
+		return nil
+	}
+	plan.Drained = true
+	if err := e.store.UpdatePlan(ctx, plan); err != nil {
+		return fmt.Errorf("failed to write drained plan(%s): %w", id, err)
+	}
+	e.release(ctx, id)
+	log.Default().Info("drained plan", "id", id)
+	return nil
+}
@@ -0,0 +1,59 @@
+package execute
+
+import (
+	"fmt"
+
+	"github.com/element-of-surprise/coercion/internal/execute/sm"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+
+	"github.com/gostdlib/base/statemachine"
+)
+
+// discardVault is a storage.Vault that discards all updates. This is used for dry runs so that
+// a Plan can be walked through the statemachine without touching storage. Only the Updater
+// methods may be called, anything else will panic.
+type discardVault struct {
+	storage.Vault
+}
+
+func (discardVault) UpdatePlan(context.Context, *workflow.Plan) error         { return nil }
+func (discardVault) UpdateBlock(context.Context, *workflow.Block) error       { return nil }
+func (discardVault) UpdateChecks(context.Context, *workflow.Checks) error     { return nil }
+func (discardVault) UpdateSequence(context.Context, *workflow.Sequence) error { return nil }
+func (discardVault) UpdateAction(context.Context, *workflow.Action) error     { return nil }
+func (discardVault) AddSequences(context.Context, *workflow.Block, []*workflow.Sequence) error {
+	return nil
+}
+
+// DryRun walks a Plan through the statemachine without executing any plugins and without writing
+// to storage. Plugins that implement plugins.DryRunner report what they would do, all others are
+// recorded as would execute. Delays and Approvals are not waited on. The Plan must have been validated
+// and had its defaults applied. This blocks until the dry run is complete and the results are recorded
+// in the Plan. Cancelling the Context stops the dry run.
+func (e *Plans) DryRun(ctx context.Context, plan *workflow.Plan) error {
+	if err := e.validateStartState(ctx, plan); err != nil {
+		return fmt.Errorf("invalid plan state: %w", err)
+	}
+
+	states, err := sm.New(discardVault{}, e.registry)
+	if err != nil {
+		return err
+	}
+
+	// Cancelling ctx stops the dry run like Stop() stops a Plan.
+	runCtx, stop := sm.WithStop(context.WithoutCancel(ctx))
+	defer stop()
+	defer context.AfterFunc(ctx, stop)()
+
+	req := statemachine.Request[sm.Data]{
+		Ctx:  context.SetDryRun(runCtx),
+		Data: sm.Data{Plan: plan},
+		Next: states.Start,
+	}
+
+	// NOTE: Like runPlan, all errors are encapsulated in the Plan's state.
+	e.runner(plan.Name, req)
+	return nil
+}
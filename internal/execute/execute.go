@@ -5,9 +5,11 @@ package execute
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/element-of-surprise/coercion/internal/execute/sm"
+	"github.com/element-of-surprise/coercion/internal/execute/sm/actions"
 	"github.com/element-of-surprise/coercion/plugins/registry"
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/element-of-surprise/coercion/workflow/context"
@@ -18,6 +20,7 @@ import (
 	"github.com/gostdlib/base/concurrency/sync"
 	"github.com/gostdlib/base/statemachine"
 	"github.com/gostdlib/base/telemetry/log"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -41,9 +44,21 @@ type Plans struct {
 	// states is the statemachine that runs the Plans.
 	states *sm.States
 
-	mu       sync.Mutex // protects stoppers
-	waiters  map[uuid.UUID]chan struct{}
-	stoppers map[uuid.UUID]context.CancelFunc
+	mu        sync.Mutex // protects stoppers, waiters, pausers, approvals, events, schedules, factories and queue
+	waiters   map[uuid.UUID]chan struct{}
+	stoppers  map[uuid.UUID]context.CancelFunc
+	pausers   map[uuid.UUID]*sm.Pauser
+	approvals map[uuid.UUID]*sm.Approvals
+	events    map[uuid.UUID]*sm.Events
+	// schedules holds the timers that start scheduled Plans.
+	schedules map[uuid.UUID]*time.Timer
+	// factories holds the Factory for the next Plan of a recurring schedule, keyed by the Plan that is scheduled.
+	factories map[uuid.UUID]Factory
+	// queue holds the Plans waiting to start, in the order they start.
+	queue []storage.ListResult
+
+	// preparer is used to submit the next Plan of a recurring schedule.
+	preparer Preparer
 
 	// runner is the function that runs the statemachine.
 	// In production this is the statemachine.Run function.
@@ -57,6 +72,24 @@ type Plans struct {
 	maxLastUpdate time.Duration
 	// maxSubmitTime is the maximum amount of time that can pass between submission and start of a Plan.
 	maxSubmit time.Duration
+
+	// owner is the ID this process uses to lease Plans in storage.
+	owner string
+	// leaseTTL is how long a lease on a Plan lasts without being renewed.
+	leaseTTL time.Duration
+	// maxRunning is the maximum number of Plans that run at the same time. 0 is no limit.
+	maxRunning int
+	// logCapture is how the records plugins log during each Attempt are captured.
+	logCapture actions.LogCapture
+	// tracerProvider creates the spans of each Plan's execution. If nil, otel.GetTracerProvider() is used.
+	tracerProvider trace.TracerProvider
+
+	// closed is set by Close(). Protected by mu.
+	closed bool
+	// closing is closed by Close() to stop lease renewals, recovery and waiters.
+	closing chan struct{}
+	// renewing runs the goroutines that renew leases.
+	renewing sync.Group
 }
 
 // Option is an option for configuring a Plans via New.
@@ -82,6 +115,33 @@ func WithMaxSubmit(d time.Duration) Option {
 	}
 }
 
+// WithAttemptLogs sets which of the records that a plugin logs with context.Log() are captured on each
+// workflow.Attempt. Records below level are not captured. Once an Attempt has captured maxRecords records or
+// maxBytes of messages and attributes, further records are counted in Attempt.LogsDropped. A maxRecords of 0
+// turns off capture. A maxBytes of 0 has no size limit. If this is not set, the default is slog.LevelInfo,
+// 100 records and 32 KiB.
+func WithAttemptLogs(level slog.Level, maxRecords, maxBytes int) Option {
+	return func(p *Plans) error {
+		if maxRecords < 0 || maxBytes < 0 {
+			return fmt.Errorf("attempt log limits cannot be negative")
+		}
+		p.logCapture = actions.LogCapture{Level: level, MaxRecords: maxRecords, MaxBytes: maxBytes}
+		return nil
+	}
+}
+
+// WithTracerProvider sets the trace.TracerProvider used to create the spans of each Plan's execution.
+// If this is not set, otel.GetTracerProvider() is used.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(p *Plans) error {
+		if tp == nil {
+			return fmt.Errorf("tracer provider cannot be nil")
+		}
+		p.tracerProvider = tp
+		return nil
+	}
+}
+
 // New creates a new Executor. This should only be created once.
 func New(ctx context.Context, store storage.Vault, reg *registry.Register, options ...Option) (*Plans, error) {
 	e := &Plans{
@@ -89,9 +149,18 @@ func New(ctx context.Context, store storage.Vault, reg *registry.Register, optio
 		store:         store,
 		waiters:       map[uuid.UUID]chan struct{}{},
 		stoppers:      map[uuid.UUID]context.CancelFunc{},
+		pausers:       map[uuid.UUID]*sm.Pauser{},
+		approvals:     map[uuid.UUID]*sm.Approvals{},
+		events:        map[uuid.UUID]*sm.Events{},
+		schedules:     map[uuid.UUID]*time.Timer{},
+		factories:     map[uuid.UUID]Factory{},
 		runner:        statemachine.Run[sm.Data],
 		maxLastUpdate: 30 * time.Minute,
 		maxSubmit:     30 * time.Minute,
+		owner:         defaultOwner(),
+		leaseTTL:      30 * time.Second,
+		closing:       make(chan struct{}),
+		logCapture:    actions.LogCapture{Level: slog.LevelInfo, MaxRecords: 100, MaxBytes: 32 << 10},
 	}
 
 	for _, o := range options {
@@ -104,15 +173,30 @@ func New(ctx context.Context, store storage.Vault, reg *registry.Register, optio
 		return nil, fmt.Errorf("failed to initialize plugins: %w", err)
 	}
 
+	// A dry run uses its own States without a Meter, so it is not recorded with the Plans that ran.
+	smOpts := []sm.Option{sm.WithLogCapture(e.logCapture), sm.WithMeter(context.Meter(ctx))}
+	if e.tracerProvider != nil {
+		smOpts = append(smOpts, sm.WithTracerProvider(e.tracerProvider))
+	}
 	var err error
-	e.states, err = sm.New(store, e.registry)
+	e.states, err = sm.New(store, e.registry, smOpts...)
 	if err != nil {
 		return nil, err
 	}
 
 	e.addValidators()
 
-	e.recover(ctx)
+	if err := e.recover(ctx); err != nil {
+		log.Default().Error(err.Error())
+	}
+	go e.recoverLoop(ctx)
+
+	if err := e.recoverSchedules(ctx); err != nil {
+		return nil, err
+	}
+	if err := e.recoverQueue(ctx); err != nil {
+		return nil, err
+	}
 
 	return e, nil
 }
@@ -145,28 +229,90 @@ func (e *Plans) initPlugins(ctx context.Context) error {
 }
 
 // Start starts a previously Submitted Plan by its ID. Cancelling the Context will not Stop execution.
-// Please use Stop to stop execution of a Plan.
+// Please use Stop to stop execution of a Plan. If the Plan is recurring, the next Plan is submitted and scheduled.
+// If another process sharing storage has claimed the Plan, this returns an error wrapping storage.ErrLeased.
+// If WithMaxRunning() Plans are running, the Plan is queued and starts when it reaches the front of the queue.
+// Starting a Plan that is already queued returns an error.
 func (e *Plans) Start(ctx context.Context, id uuid.UUID) error {
+	e.mu.Lock()
+	closed := e.closed
+	e.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+
+	// The lease is claimed before the Plan is read so that another process cannot start the Plan
+	// between our read and our claim.
+	if err := e.store.Claim(ctx, id, e.owner, e.leaseTTL); err != nil {
+		return fmt.Errorf("could not claim plan(%s): %w", id, err)
+	}
+
 	plan, err := e.store.Read(ctx, id)
 	if err != nil {
+		e.release(ctx, id)
 		return err
 	}
 
 	if err := e.validateStartState(ctx, plan); err != nil {
+		e.release(ctx, id)
 		return fmt.Errorf("invalid plan state: %w", err)
 	}
+	// A queued Plan keeps its place in the queue, it starts when it reaches the front.
+	if !plan.QueuedAt.IsZero() {
+		e.release(ctx, id)
+		return fmt.Errorf("plan(%s) is already queued", id)
+	}
+
+	// A scheduled Plan that is started early does not start again when its time comes.
+	e.disarm(id)
+	if plan.Recurring != "" {
+		if err := e.scheduleNext(ctx, plan); err != nil {
+			log.Default().Error(fmt.Sprintf("failed to schedule the plan after plan(%s): %s", id, err))
+		}
+	}
+
+	e.mu.Lock()
+	queued := len(e.queue) > 0
+	e.mu.Unlock()
 
-	e.runPlan(ctx, plan)
+	// Plans that are already queued start first.
+	if queued || !e.runPlan(ctx, plan, true) {
+		if err := e.enqueue(ctx, plan); err != nil {
+			e.release(ctx, id)
+			return err
+		}
+	}
 
 	return nil
 }
 
+// Submit writes a Plan that has been validated and had its defaults applied to storage. The SubPlans of its
+// Blocks are written first, as a Plan is always read with its SubPlans.
+func (e *Plans) Submit(ctx context.Context, plan *workflow.Plan) error {
+	for _, b := range plan.Blocks {
+		if b.SubPlan == nil {
+			continue
+		}
+		if err := e.Submit(ctx, b.SubPlan); err != nil {
+			return err
+		}
+	}
+	if err := e.store.Create(ctx, plan); err != nil {
+		return fmt.Errorf("failed to write plan(%s) to storage: %w", plan.ID, err)
+	}
+	return nil
+}
+
 // recover recovers a Plan that is in a Running state in storage and restarts it from where it left off.
-// This is used when the Executor starts up.
+// This is used when the Executor starts up and periodically afterwards to take over the Plans of other
+// processes whose leases have expired.
 func (e *Plans) recover(ctx context.Context) error {
 	recovery := recover{
-		maxAge: e.maxLastUpdate,
-		store:  e.store,
+		maxAge:  e.maxLastUpdate,
+		store:   e.store,
+		owner:   e.owner,
+		ttl:     e.leaseTTL,
+		running: e.running,
 	}
 	req := statemachine.Request[recoverData]{Ctx: ctx, Next: recovery.start}
 	var err error
@@ -175,40 +321,91 @@ func (e *Plans) recover(ctx context.Context) error {
 		return fmt.Errorf("failed to recover: %w", err)
 	}
 
-	if len(req.Data.plans) == 0 {
-		log.Println("no plans to recover")
-	}
 	for _, plan := range req.Data.plans {
-		log.Default().Info("recovered plan", "id", plan.ID, "status", plan.State.Status)
-		e.runPlan(ctx, plan)
+		log.Default().Info("recovered plan", "id", plan.ID, "status", plan.State.Status, "drained", plan.Drained)
+		// A drained Plan has been handed off to this process, this is written when the Plan starts running.
+		plan.Drained = false
+		e.runPlan(ctx, plan, false)
 	}
 
 	return nil
 }
 
-// runPlan runs a Plan through the statemachine. This is a non-blocking call.
-func (e *Plans) runPlan(ctx context.Context, plan *workflow.Plan) {
-	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+// release releases the lease on the Plan with id.
+func (e *Plans) release(ctx context.Context, id uuid.UUID) {
+	if err := e.store.Release(ctx, id, e.owner); err != nil {
+		log.Default().Error(fmt.Sprintf("failed to release lease on plan(%s): %s", id, err))
+	}
+}
+
+// runPlan runs a Plan through the statemachine. This is a non-blocking call. The lease on the Plan must
+// already be claimed, it is renewed while the Plan runs and released when it finishes. If limit is set
+// and WithMaxRunning() Plans are running, the Plan is not run and this returns false.
+func (e *Plans) runPlan(ctx context.Context, plan *workflow.Plan, limit bool) bool {
+	// A recovered Plan that was paused stays paused until it is resumed.
+	pauser := e.states.NewPauser(plan)
+	approvals := sm.NewApprovals()
+	events := sm.NewEvents(plan)
+	runCtx, cancel := sm.WithStop(context.WithoutCancel(ctx))
 
 	e.mu.Lock()
+	if limit && !e.admit() {
+		e.mu.Unlock()
+		cancel()
+		events.Close()
+		return false
+	}
 	e.stoppers[plan.ID] = cancel
-	e.waiters[plan.ID] = make(chan struct{})
+	// A Plan that was queued already has a waiter.
+	if _, ok := e.waiters[plan.ID]; !ok {
+		e.waiters[plan.ID] = make(chan struct{})
+	}
+	e.pausers[plan.ID] = pauser
+	e.approvals[plan.ID] = approvals
+	e.events[plan.ID] = events
 	e.mu.Unlock()
 
+	leaseDone, renewed := make(chan struct{}), make(chan struct{})
+	e.renewing.Go(context.WithoutCancel(ctx), func(context.Context) error {
+		defer close(renewed)
+		e.renew(ctx, plan.ID, leaseDone)
+		return nil
+	})
+
 	go func() {
 		defer func() {
 			cancel()
+			close(leaseDone)
+			<-renewed
+			// After Close(), the statemachine unwinds without writing the Plan's state. The lease is released
+			// by Close() for a drained Plan, otherwise it expires.
+			e.mu.Lock()
+			closed := e.closed
+			e.mu.Unlock()
+			if !closed {
+				e.release(ctx, plan.ID)
+			}
 			e.mu.Lock()
 			delete(e.stoppers, plan.ID)
+			delete(e.pausers, plan.ID)
+			delete(e.approvals, plan.ID)
+			delete(e.events, plan.ID)
+			events.Close()
 			close(e.waiters[plan.ID])
 			delete(e.waiters, plan.ID)
 			e.mu.Unlock()
+
+			// The finished Plan frees a slot for a queued Plan.
+			e.dequeue(ctx)
 		}()
 
 		req := statemachine.Request[sm.Data]{
 			Ctx: runCtx,
 			Data: sm.Data{
-				Plan: plan,
+				Plan:      plan,
+				Pauser:    pauser,
+				Approvals: approvals,
+				Events:    events,
 			},
 			Next: e.states.Start,
 		}
@@ -217,6 +414,7 @@ func (e *Plans) runPlan(ctx context.Context, plan *workflow.Plan) {
 		// and doesn't actually matter. All errors are encapsulated in the Plan's state.
 		e.runner(plan.Name, req)
 	}()
+	return true
 }
 
 func (e *Plans) now() time.Time {
@@ -224,7 +422,8 @@ func (e *Plans) now() time.Time {
 }
 
 // Wait waits for a Plan to finish execution. Cancelling the Context will stop waiting and
-// return context.Canceled. If the Plan is not found, this will return ErrNotFound.
+// return context.Canceled. If the Plan is not found, this will return ErrNotFound. If Close() is
+// called before the Plan finishes, this will return ErrClosed.
 func (e *Plans) Wait(ctx context.Context, id uuid.UUID) error {
 	e.mu.Lock()
 	waiter, ok := e.waiters[id]
@@ -237,11 +436,112 @@ func (e *Plans) Wait(ctx context.Context, id uuid.UUID) error {
 	select {
 	case <-ctx.Done():
 		return context.Canceled
+	case <-e.closing:
+		return ErrClosed
 	case <-waiter:
 		return nil
 	}
 }
 
+// Stop stops a running Plan. Actions that are already executing are allowed to finish or time out,
+// but no new Actions, Sequences or Blocks are started. DeferredChecks are still run. Objects that did
+// not start are marked workflow.Stopped and the Plan records workflow.FRStopped as the reason.
+// This blocks until the Plan has stopped or the Context is cancelled. If the Plan is not running,
+// this will return ErrNotFound.
+func (e *Plans) Stop(ctx context.Context, id uuid.UUID) error {
+	e.mu.Lock()
+	stopper, ok := e.stoppers[id]
+	waiter := e.waiters[id]
+	e.mu.Unlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+	stopper()
+
+	select {
+	case <-ctx.Done():
+		return context.Canceled
+	case <-e.closing:
+		return ErrClosed
+	case <-waiter:
+		return nil
+	}
+}
+
+// Pause pauses a running Plan. The Plan will not start any new Blocks or Sequences until it is resumed.
+// Sequences that are already running are allowed to complete and ContChecks continue to run. The pause
+// is written to storage before this returns and takes effect at the next Block or Sequence boundary.
+// If the Plan is not running, this will return ErrNotFound.
+func (e *Plans) Pause(ctx context.Context, id uuid.UUID) error {
+	e.mu.Lock()
+	pauser, ok := e.pausers[id]
+	e.mu.Unlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+	ok, err := pauser.Pause(ctx)
+	if err != nil {
+		return fmt.Errorf("could not pause plan(%s): %w", id, err)
+	}
+	if !ok {
+		return fmt.Errorf("plan(%s) is already paused", id)
+	}
+	return nil
+}
+
+// Resume resumes a paused Plan. The resume is written to storage before this returns. If the Plan is
+// not running, this will return ErrNotFound.
+func (e *Plans) Resume(ctx context.Context, id uuid.UUID) error {
+	e.mu.Lock()
+	pauser, ok := e.pausers[id]
+	e.mu.Unlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+	ok, err := pauser.Resume(ctx)
+	if err != nil {
+		return fmt.Errorf("could not resume plan(%s): %w", id, err)
+	}
+	if !ok {
+		return fmt.Errorf("plan(%s) is not paused", id)
+	}
+	return nil
+}
+
+// Decide delivers a Decision on an Approval that a running Plan is waiting on. id is the ID of the
+// object that holds the Approval, which is the Plan's ID for the Plan's Approval or a Block's ID for
+// a Block's Approval. If the Plan is not running, this will return ErrNotFound.
+func (e *Plans) Decide(ctx context.Context, planID, id uuid.UUID, d sm.Decision) error {
+	e.mu.Lock()
+	approvals, ok := e.approvals[planID]
+	e.mu.Unlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+	if err := approvals.Decide(id, d); err != nil {
+		return fmt.Errorf("plan(%s): %w", planID, err)
+	}
+	return nil
+}
+
+// Events returns a channel that receives the events of a running Plan. The events that the Plan's sm.Events
+// keeps are replayed first. The channel is closed once the Plan has finished and all events have been
+// delivered, or when the Context is cancelled. If the Plan is not running, this will return ErrNotFound.
+func (e *Plans) Events(ctx context.Context, id uuid.UUID) (<-chan workflow.Event, error) {
+	e.mu.Lock()
+	events, ok := e.events[id]
+	e.mu.Unlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return events.Subscribe(ctx), nil
+}
+
 // getStater provides an interface for grabbing the State struct from workflow objects.
 // This is used to validate that the starting state of the plan is correct before starting it.
 type getStater interface {
@@ -264,12 +564,21 @@ func (p *Plans) validateStartState(ctx context.Context, plan *workflow.Plan) err
 	if plan.SubmitTime.IsZero() {
 		return fmt.Errorf("Plan.SubmitTime is zero")
 	}
+	if plan.CallerID != uuid.Nil {
+		return fmt.Errorf("plan is a sub-plan and can only be run by plan(%s)", plan.CallerID)
+	}
 
-	if plan.SubmitTime.Add(p.maxSubmit).Before(time.Now()) {
+	// A scheduled Plan is not stale until maxSubmit after the time it is scheduled for. A queued Plan was
+	// started in time and is not stale while it waits in the queue.
+	submitted := plan.SubmitTime
+	if plan.StartAt.After(submitted) {
+		submitted = plan.StartAt
+	}
+	if plan.QueuedAt.IsZero() && submitted.Add(p.maxSubmit).Before(time.Now()) {
 		return fmt.Errorf("plan is stale, submit time is too old")
 	}
 
-	for item := range walk.Plan(context.WithoutCancel(ctx), plan) {
+	for item := range walk.Plan(context.WithoutCancel(ctx), plan, walk.WithSubPlans()) {
 		for _, v := range p.validators {
 			if err := v(item); err != nil {
 				return err
@@ -291,6 +600,9 @@ func (p *Plans) validatePlan(i walk.Item) error {
 	if plan.Reason != workflow.FRUnknown {
 		return fmt.Errorf("Plan.Reason is not FRUnknown")
 	}
+	if plan.Paused {
+		return fmt.Errorf("Plan.Paused is true")
+	}
 	return nil
 }
 
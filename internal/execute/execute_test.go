@@ -2,10 +2,12 @@ package execute
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -123,6 +125,33 @@ type fakeStore struct {
 	storage.Vault
 
 	m map[uuid.UUID]*workflow.Plan
+
+	mu     sync.Mutex
+	leases map[uuid.UUID]string
+}
+
+func (f *fakeStore) Claim(ctx context.Context, id uuid.UUID, owner string, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.leases == nil {
+		f.leases = map[uuid.UUID]string{}
+	}
+	if held, ok := f.leases[id]; ok && held != owner {
+		return storage.ErrLeased
+	}
+	f.leases[id] = owner
+	return nil
+}
+
+func (f *fakeStore) Release(ctx context.Context, id uuid.UUID, owner string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.leases[id] == owner {
+		delete(f.leases, id)
+	}
+	return nil
 }
 
 func (f *fakeStore) Read(ctx context.Context, id uuid.UUID) (*workflow.Plan, error) {
@@ -133,6 +162,10 @@ func (f *fakeStore) Read(ctx context.Context, id uuid.UUID) (*workflow.Plan, err
 	return p, nil
 }
 
+func (f *fakeStore) UpdatePlan(ctx context.Context, plan *workflow.Plan) error {
+	return nil
+}
+
 type fakeRunner struct {
 	called bool
 	req    statemachine.Request[sm.Data]
@@ -164,6 +197,7 @@ func TestStart(t *testing.T) {
 		name    string
 		id      uuid.UUID
 		plan    *workflow.Plan
+		leased  bool
 		wantErr bool
 	}{
 		{
@@ -177,6 +211,19 @@ func TestStart(t *testing.T) {
 			plan:    &workflow.Plan{SubmitTime: time.Now()}, //  plan is invalid, has no ID
 			wantErr: true,
 		},
+		{
+			name: "plan is leased by another process",
+			id:   storedID,
+			plan: &workflow.Plan{
+				ID: storedID,
+				State: &workflow.State{
+					Status: workflow.NotStarted,
+				},
+				SubmitTime: time.Now(),
+			},
+			leased:  true,
+			wantErr: true,
+		},
 		{
 			name: "plan starts execution",
 			id:   storedID,
@@ -196,6 +243,9 @@ func TestStart(t *testing.T) {
 				storedID: test.plan,
 			},
 		}
+		if test.leased {
+			fakeStore.Claim(context.Background(), storedID, "other", time.Minute)
+		}
 		fr := &fakeRunner{ran: make(chan struct{})}
 
 		p := &Plans{
@@ -203,8 +253,13 @@ func TestStart(t *testing.T) {
 			runner:    fr.Run,
 			states:    &sm.States{},
 			stoppers:  map[uuid.UUID]context.CancelFunc{},
+			pausers:   map[uuid.UUID]*sm.Pauser{},
+			approvals: map[uuid.UUID]*sm.Approvals{},
+			events:    map[uuid.UUID]*sm.Events{},
 			waiters:   map[uuid.UUID]chan struct{}{},
 			maxSubmit: 30 * time.Minute,
+			owner:     "test",
+			leaseTTL:  time.Minute,
 		}
 		p.addValidators()
 
@@ -232,6 +287,13 @@ func TestStart(t *testing.T) {
 		if methodName(fr.req.Next) != methodName(p.states.Start) {
 			t.Errorf("TestStart(%s): Next method in Request is not the expected Start method", test.name)
 		}
+		p.Wait(context.Background(), test.id)
+		fakeStore.mu.Lock()
+		leases := len(fakeStore.leases)
+		fakeStore.mu.Unlock()
+		if leases > 0 {
+			t.Errorf("TestStart(%s): did not release the lease", test.name)
+		}
 		p.mu.Lock()
 		stopperLen := len(p.stoppers)
 		p.mu.Unlock()
@@ -241,6 +303,162 @@ func TestStart(t *testing.T) {
 	}
 }
 
+// blockingRunner runs each Plan until it is finished with finish.
+type blockingRunner struct {
+	started chan uuid.UUID
+
+	mu       sync.Mutex
+	finished map[uuid.UUID]chan struct{}
+}
+
+func (r *blockingRunner) done(id uuid.UUID) chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.finished[id] == nil {
+		r.finished[id] = make(chan struct{})
+	}
+	return r.finished[id]
+}
+
+func (r *blockingRunner) finish(id uuid.UUID) {
+	close(r.done(id))
+}
+
+func (r *blockingRunner) Run(name string, req statemachine.Request[sm.Data], options ...statemachine.Option[sm.Data]) (statemachine.Request[sm.Data], error) {
+	r.started <- req.Data.Plan.ID
+	<-r.done(req.Data.Plan.ID)
+	return req, nil
+}
+
+func TestStartQueued(t *testing.T) {
+	t.Parallel()
+
+	newPlan := func(priority int) *workflow.Plan {
+		return &workflow.Plan{
+			ID:         NewV7(),
+			State:      &workflow.State{Status: workflow.NotStarted},
+			SubmitTime: time.Now(),
+			Priority:   priority,
+		}
+	}
+	running, low, high := newPlan(0), newPlan(0), newPlan(1)
+
+	fakeStore := &fakeStore{
+		m: map[uuid.UUID]*workflow.Plan{running.ID: running, low.ID: low, high.ID: high},
+	}
+	br := &blockingRunner{started: make(chan uuid.UUID, 3), finished: map[uuid.UUID]chan struct{}{}}
+
+	p := &Plans{
+		store:      fakeStore,
+		runner:     br.Run,
+		states:     &sm.States{},
+		stoppers:   map[uuid.UUID]context.CancelFunc{},
+		pausers:    map[uuid.UUID]*sm.Pauser{},
+		approvals:  map[uuid.UUID]*sm.Approvals{},
+		events:     map[uuid.UUID]*sm.Events{},
+		waiters:    map[uuid.UUID]chan struct{}{},
+		maxSubmit:  30 * time.Minute,
+		owner:      "test",
+		leaseTTL:   time.Minute,
+		maxRunning: 1,
+	}
+	p.addValidators()
+
+	wantStarted := func(want *workflow.Plan) {
+		t.Helper()
+		select {
+		case got := <-br.started:
+			if got != want.ID {
+				t.Fatalf("TestStartQueued: got plan(%s) started, want plan(%s)", got, want.ID)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("TestStartQueued: plan(%s) did not start", want.ID)
+		}
+	}
+
+	for _, plan := range []*workflow.Plan{running, low, high} {
+		if err := p.Start(context.Background(), plan.ID); err != nil {
+			t.Fatalf("TestStartQueued: Start(%s): got err == %v, want err == nil", plan.ID, err)
+		}
+	}
+	wantStarted(running)
+
+	p.mu.Lock()
+	queue := p.queue
+	p.mu.Unlock()
+	if len(queue) != 2 || queue[0].ID != high.ID || queue[1].ID != low.ID {
+		t.Fatalf("TestStartQueued: got queue %v, want [%s %s]", queue, high.ID, low.ID)
+	}
+	if low.QueuedAt.IsZero() {
+		t.Errorf("TestStartQueued: queued plan did not have QueuedAt set")
+	}
+
+	// Starting a queued Plan again does not move it in the queue.
+	queuedAt := low.QueuedAt
+	if err := p.Start(context.Background(), low.ID); err == nil {
+		t.Errorf("TestStartQueued: Start() of a queued plan: got err == nil, want err != nil")
+	}
+	if !low.QueuedAt.Equal(queuedAt) {
+		t.Errorf("TestStartQueued: Start() of a queued plan changed QueuedAt from %v to %v", queuedAt, low.QueuedAt)
+	}
+	p.mu.Lock()
+	queue = p.queue
+	p.mu.Unlock()
+	if len(queue) != 2 || queue[0].ID != high.ID || queue[1].ID != low.ID {
+		t.Fatalf("TestStartQueued: got queue %v after starting a queued plan, want [%s %s]", queue, high.ID, low.ID)
+	}
+	fakeStore.mu.Lock()
+	leases := len(fakeStore.leases)
+	fakeStore.mu.Unlock()
+	if leases != 1 {
+		t.Errorf("TestStartQueued: got %d leases, want 1 for the running plan", leases)
+	}
+
+	// The higher priority Plan starts first, even though it was queued last.
+	br.finish(running.ID)
+	wantStarted(high)
+	br.finish(high.ID)
+	wantStarted(low)
+	br.finish(low.ID)
+
+	if err := p.Wait(context.Background(), low.ID); err != nil && !errors.Is(err, ErrNotFound) {
+		t.Fatalf("TestStartQueued: Wait(): got err == %v", err)
+	}
+	if !low.QueuedAt.IsZero() {
+		t.Errorf("TestStartQueued: plan that left the queue still had QueuedAt set")
+	}
+}
+
+func TestStop(t *testing.T) {
+	t.Parallel()
+
+	runningID := NewV7()
+	ctx, cancel := context.WithCancel(context.Background())
+	waiter := make(chan struct{})
+
+	p := &Plans{
+		stoppers: map[uuid.UUID]context.CancelFunc{runningID: cancel},
+		waiters:  map[uuid.UUID]chan struct{}{runningID: waiter},
+	}
+
+	if err := p.Stop(context.Background(), NewV7()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("TestStop(plan not running): got err == %v, want err == %v", err, ErrNotFound)
+	}
+
+	go func() {
+		<-ctx.Done()
+		close(waiter)
+	}()
+
+	if err := p.Stop(context.Background(), runningID); err != nil {
+		t.Errorf("TestStop(plan running): got err == %v, want err == nil", err)
+	}
+	if ctx.Err() == nil {
+		t.Errorf("TestStop(plan running): Plan Context was not cancelled")
+	}
+}
+
 func TestValidateStartState(t *testing.T) {
 	t.Parallel()
 
@@ -262,6 +480,19 @@ func TestValidateStartState(t *testing.T) {
 			name: "Success",
 			plan: &workflow.Plan{ID: NewV7(), SubmitTime: time.Now()},
 		},
+		{
+			name: "Success: scheduled Plan is not stale",
+			plan: &workflow.Plan{ID: NewV7(), SubmitTime: time.Now().Add(-time.Hour), StartAt: time.Now().Add(-time.Minute)},
+		},
+		{
+			name: "Success: queued Plan is not stale",
+			plan: &workflow.Plan{ID: NewV7(), SubmitTime: time.Now().Add(-time.Hour), QueuedAt: time.Now().Add(-time.Minute)},
+		},
+		{
+			name:    "Scheduled Plan is too old",
+			plan:    &workflow.Plan{ID: NewV7(), SubmitTime: time.Now().Add(-2 * time.Hour), StartAt: time.Now().Add(-time.Hour)},
+			wantErr: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -308,6 +539,16 @@ func TestValidatePlan(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "plan is paused",
+			item: walk.Item{
+				Value: &workflow.Plan{
+					SubmitTime: time.Now(),
+					Paused:     true,
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "Success",
 			item: walk.Item{
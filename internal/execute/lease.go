@@ -0,0 +1,106 @@
+package execute
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/google/uuid"
+
+	"github.com/gostdlib/base/telemetry/log"
+)
+
+// WithOwner sets the ID that this process uses to lease Plans in storage. Every process sharing a
+// storage.Vault must have a different owner. If this is not set, the default is the hostname followed by a
+// random ID, which is different on each start.
+func WithOwner(owner string) Option {
+	return func(p *Plans) error {
+		if owner == "" {
+			return fmt.Errorf("owner cannot be empty")
+		}
+		p.owner = owner
+		return nil
+	}
+}
+
+// WithLeaseTTL sets how long a lease on a Plan lasts without being renewed. A running Plan's lease is renewed
+// three times per TTL and Plans are recovered from processes that have stopped renewing once per TTL.
+// If this is not set, the default is 30 seconds.
+func WithLeaseTTL(d time.Duration) Option {
+	return func(p *Plans) error {
+		if d <= 0 {
+			return fmt.Errorf("lease TTL must be positive")
+		}
+		p.leaseTTL = d
+		return nil
+	}
+}
+
+// defaultOwner returns the owner used when WithOwner() is not set.
+func defaultOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return host + "/" + workflow.NewV7().String()
+}
+
+// running returns true if the Plan with id is running in this process.
+func (e *Plans) running(id uuid.UUID) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, ok := e.stoppers[id]
+	return ok
+}
+
+// renew renews the lease on the Plan with id until done is closed. If another process has taken
+// the lease, the Plan is being run by two processes and this one cannot continue.
+func (e *Plans) renew(ctx context.Context, id uuid.UUID, done chan struct{}) {
+	ctx = context.WithoutCancel(ctx)
+
+	t := time.NewTicker(e.leaseTTL / 3)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-e.closing:
+			return
+		case <-t.C:
+			err := e.store.Claim(ctx, id, e.owner, e.leaseTTL)
+			switch {
+			case err == nil:
+			case errors.Is(err, storage.ErrLeased):
+				log.Fatalf("lease on plan(%s) was taken by another process: %v", id, err)
+			default:
+				log.Default().Error(fmt.Sprintf("failed to renew lease on plan(%s): %s", id, err))
+			}
+		}
+	}
+}
+
+// recoverLoop runs recovery once per lease TTL to take over the Plans of processes that have
+// stopped renewing their leases. It stops when ctx is cancelled.
+func (e *Plans) recoverLoop(ctx context.Context) {
+	t := time.NewTicker(e.leaseTTL)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.closing:
+			return
+		case <-t.C:
+			if err := e.recover(ctx); err != nil {
+				log.Default().Error(err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package execute
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/google/uuid"
+
+	"github.com/gostdlib/base/telemetry/log"
+)
+
+// WithMaxRunning sets the maximum number of Plans that run at the same time. Plans that are started
+// beyond this wait in a queue ordered by Plan.Priority and start as running Plans finish. The queue is
+// written to storage so that it survives a restart. If this is not set, the default is 0, which is no limit.
+func WithMaxRunning(n int) Option {
+	return func(p *Plans) error {
+		if n < 0 {
+			return fmt.Errorf("max running cannot be negative")
+		}
+		p.maxRunning = n
+		return nil
+	}
+}
+
+// admit returns true if another Plan can run. Must be called with e.mu held.
+func (e *Plans) admit() bool {
+	return e.maxRunning == 0 || len(e.stoppers) < e.maxRunning
+}
+
+// enqueue queues a Plan that cannot run because the maximum number of Plans are running. The queue
+// position is written to storage and the lease on the Plan is released until it leaves the queue.
+func (e *Plans) enqueue(ctx context.Context, plan *workflow.Plan) error {
+	plan.QueuedAt = e.now()
+	if err := e.store.UpdatePlan(ctx, plan); err != nil {
+		plan.QueuedAt = time.Time{}
+		return fmt.Errorf("failed to write plan(%s) to the queue: %w", plan.ID, err)
+	}
+	e.push(plan.ID, plan.Priority, plan.QueuedAt)
+	e.release(ctx, plan.ID)
+
+	// A Plan may have finished between the Plan failing to be admitted and being queued.
+	e.dequeue(ctx)
+	return nil
+}
+
+// push adds a Plan to the in-memory queue and creates its waiter so that Wait() works while it is queued.
+func (e *Plans) push(id uuid.UUID, priority int, queuedAt time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if slices.ContainsFunc(e.queue, func(q storage.ListResult) bool { return q.ID == id }) {
+		return
+	}
+	e.queue = append(e.queue, storage.ListResult{ID: id, Priority: priority, QueuedAt: queuedAt})
+	slices.SortFunc(e.queue, storage.CompareQueued)
+	if _, ok := e.waiters[id]; !ok {
+		e.waiters[id] = make(chan struct{})
+	}
+}
+
+// pop removes the first Plan in the queue if another Plan can run. It returns false if the queue is
+// empty or the maximum number of Plans are running.
+func (e *Plans) pop() (storage.ListResult, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed || len(e.queue) == 0 || !e.admit() {
+		return storage.ListResult{}, false
+	}
+	q := e.queue[0]
+	e.queue = e.queue[1:]
+	return q, true
+}
+
+// drop removes the waiter of a Plan that left the queue without running.
+func (e *Plans) drop(id uuid.UUID) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.stoppers[id]; ok {
+		return
+	}
+	if w, ok := e.waiters[id]; ok {
+		close(w)
+		delete(e.waiters, id)
+	}
+}
+
+// dequeue starts queued Plans until the queue is empty or the maximum number of Plans are running.
+func (e *Plans) dequeue(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+
+	for {
+		q, ok := e.pop()
+		if !ok {
+			return
+		}
+
+		err := e.startQueued(ctx, q.ID)
+		switch {
+		case err == nil:
+		case errors.Is(err, errNotAdmitted):
+			// Another Plan took the slot first, the Plan keeps its place in the queue.
+			e.push(q.ID, q.Priority, q.QueuedAt)
+			return
+		case errors.Is(err, storage.ErrLeased):
+			// Another process sharing storage started the Plan.
+			e.drop(q.ID)
+		default:
+			log.Default().Error(fmt.Sprintf("queued plan(%s) failed to start: %s", q.ID, err))
+			e.drop(q.ID)
+		}
+	}
+}
+
+// errNotAdmitted is returned by startQueued when the maximum number of Plans are running.
+var errNotAdmitted = errors.New("maximum number of plans are running")
+
+// startQueued starts a Plan that is leaving the queue.
+func (e *Plans) startQueued(ctx context.Context, id uuid.UUID) error {
+	if err := e.store.Claim(ctx, id, e.owner, e.leaseTTL); err != nil {
+		return fmt.Errorf("could not claim plan(%s): %w", id, err)
+	}
+
+	plan, err := e.store.Read(ctx, id)
+	if err != nil {
+		e.release(ctx, id)
+		return err
+	}
+	if plan.QueuedAt.IsZero() {
+		e.release(ctx, id)
+		return fmt.Errorf("plan(%s) is not queued", id)
+	}
+	if err := e.validateStartState(ctx, plan); err != nil {
+		e.release(ctx, id)
+		return fmt.Errorf("invalid plan state: %w", err)
+	}
+
+	queuedAt := plan.QueuedAt
+	plan.QueuedAt = time.Time{}
+	if err := e.store.UpdatePlan(ctx, plan); err != nil {
+		e.release(ctx, id)
+		return fmt.Errorf("failed to remove plan(%s) from the queue: %w", id, err)
+	}
+	if !e.runPlan(ctx, plan, true) {
+		plan.QueuedAt = queuedAt
+		if err := e.store.UpdatePlan(ctx, plan); err != nil {
+			log.Default().Error(fmt.Sprintf("failed to write plan(%s) back to the queue: %s", id, err))
+		}
+		e.release(ctx, id)
+		return errNotAdmitted
+	}
+	return nil
+}
+
+// recoverQueue queues the Plans in storage that were waiting to start and starts as many as can run.
+// This is used when the Executor starts up.
+func (e *Plans) recoverQueue(ctx context.Context) error {
+	results, err := e.store.Search(ctx, storage.Filters{ByStatus: []workflow.Status{workflow.NotStarted}})
+	if err != nil {
+		return fmt.Errorf("failed to search for queued plans: %w", err)
+	}
+
+	for result := range results {
+		if result.Err != nil {
+			return fmt.Errorf("failed mid search for queued plans: %w", result.Err)
+		}
+		// SubPlans are started by the Plan that runs them.
+		if result.Result.CallerID != uuid.Nil || !result.Result.Queued() {
+			continue
+		}
+		log.Default().Info("recovered queued plan", "id", result.Result.ID, "priority", result.Result.Priority)
+		e.push(result.Result.ID, result.Result.Priority, result.Result.QueuedAt)
+	}
+
+	e.dequeue(ctx)
+	return nil
+}
@@ -1,6 +1,7 @@
 package execute
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/element-of-surprise/coercion/workflow/context"
 	"github.com/element-of-surprise/coercion/workflow/storage"
 	"github.com/element-of-surprise/coercion/workflow/utils/walk"
+	"github.com/google/uuid"
 	"github.com/gostdlib/base/statemachine"
 )
 
@@ -17,10 +19,18 @@ type recoverData struct {
 	agedOut       []*workflow.Plan
 }
 
-// recover is a state machine that recovers plans after a crash.
+// recover is a state machine that recovers plans after a crash. Processes sharing storage each run
+// recovery, a Plan is only recovered by the process that claims its lease.
 type recover struct {
 	maxAge time.Duration
 	store  storage.Vault
+
+	// owner is the ID this process uses to lease Plans.
+	owner string
+	// ttl is how long a lease lasts without being renewed.
+	ttl time.Duration
+	// running returns true if the Plan is already running in this process.
+	running func(uuid.UUID) bool
 }
 
 // start starts the recovery process. It searches for running plans in the data store.
@@ -40,20 +50,52 @@ func (r *recover) start(req statemachine.Request[recoverData]) statemachine.Requ
 		req.Data.searchResults = append(req.Data.searchResults, result)
 	}
 
+	req.Next = r.claim
+	return req
+}
+
+// claim claims the lease on each running Plan. Plans that are running in this process or that another
+// process holds an unexpired lease on are not recovered. SubPlans are not recovered, they are recovered
+// by the Plan that runs them.
+func (r *recover) claim(req statemachine.Request[recoverData]) statemachine.Request[recoverData] {
+	claimed := []storage.Stream[storage.ListResult]{}
+	for _, result := range req.Data.searchResults {
+		id := result.Result.ID
+		if r.running(id) || result.Result.CallerID != uuid.Nil {
+			continue
+		}
+		if err := r.store.Claim(req.Ctx, id, r.owner, r.ttl); err != nil {
+			if errors.Is(err, storage.ErrLeased) {
+				continue
+			}
+			req.Err = fmt.Errorf("failed to claim Plan(%s): %w", id, err)
+			return req
+		}
+		claimed = append(claimed, result)
+	}
+	req.Data.searchResults = claimed
 	req.Next = r.fetchPlans
 	return req
 }
 
 // fetchPlans fetches the plans that are running from the data store in parallel.
+// A Plan that finished after the search is not recovered and its lease is released.
 func (r *recover) fetchPlans(req statemachine.Request[recoverData]) statemachine.Request[recoverData] {
-	recovered := make([]*workflow.Plan, len(req.Data.searchResults))
-	for i, result := range req.Data.searchResults {
+	recovered := make([]*workflow.Plan, 0, len(req.Data.searchResults))
+	for _, result := range req.Data.searchResults {
 		plan, err := r.store.Read(req.Ctx, result.Result.ID)
 		if err != nil {
 			req.Err = fmt.Errorf("failed to read Plan(%s): %w", result.Result.ID, err)
 			return req
 		}
-		recovered[i] = plan
+		if plan.State.Status != workflow.Running {
+			if err := r.store.Release(req.Ctx, plan.ID, r.owner); err != nil {
+				req.Err = fmt.Errorf("failed to release Plan(%s): %w", plan.ID, err)
+				return req
+			}
+			continue
+		}
+		recovered = append(recovered, plan)
 	}
 	req.Data.plans = recovered
 	req.Next = r.filterPlans
@@ -117,6 +159,11 @@ func (r *recover) filterPlans(req statemachine.Request[recoverData]) statemachin
 	now := time.Now()
 
 	for i, plan := range req.Data.plans {
+		// A paused Plan or a Plan waiting on an Approval is not updated while it waits,
+		// so it cannot age out. A drained Plan was handed off cleanly, so its state is current.
+		if plan.Paused || plan.Drained || waitingApproval(plan) {
+			continue
+		}
 		if lastUpdate(req.Ctx, plan).Add(r.maxAge).Before(now) {
 			req.Data.agedOut = append(req.Data.agedOut, plan)
 			req.Data.plans[i] = nil
@@ -145,7 +192,62 @@ func (r *recover) agedOut(req statemachine.Request[recoverData]) statemachine.Re
 			req.Err = fmt.Errorf("failed to update Plan(%s): %w", plan.ID, err)
 			return req
 		}
+		if err := r.store.Release(req.Ctx, plan.ID, r.owner); err != nil {
+			req.Err = fmt.Errorf("failed to release Plan(%s): %w", plan.ID, err)
+			return req
+		}
+	}
+	req.Next = r.staleLocks
+	return req
+}
+
+// staleLocks removes locks left behind by a crash. A lock is stale if it is not held by a running
+// object in a Plan that is being recovered, unless its Plan is leased and running in some process.
+// Running objects that are recovered acquire their locks again.
+func (r *recover) staleLocks(req statemachine.Request[recoverData]) statemachine.Request[recoverData] {
+	locks, err := r.store.Locks(req.Ctx)
+	if err != nil {
+		req.Err = fmt.Errorf("failed to list locks: %w", err)
+		return req
+	}
+	leases, err := r.store.Leases(req.Ctx)
+	if err != nil {
+		req.Err = fmt.Errorf("failed to list leases: %w", err)
+		return req
+	}
+
+	recovered := map[uuid.UUID]bool{}
+	running := map[uuid.UUID]bool{}
+	for _, plan := range req.Data.plans {
+		recovered[plan.ID] = true
+		for item := range walk.Plan(req.Ctx, plan, walk.WithSubPlans()) {
+			if item.Value.(getStater).GetState().Status == workflow.Running {
+				running[item.Value.(ider).GetID()] = true
+			}
+		}
+	}
+	now := time.Now()
+	leased := map[uuid.UUID]bool{}
+	for _, l := range leases {
+		if l.Expires.After(now) {
+			leased[l.PlanID] = true
+		}
+	}
+
+	for _, l := range locks {
+		if running[l.Owner] {
+			continue
+		}
+		if leased[l.PlanID] && !recovered[l.PlanID] {
+			continue
+		}
+		if err := r.store.Unlock(req.Ctx, l.Owner, []string{l.Name}); err != nil {
+			req.Err = fmt.Errorf("failed to remove stale lock(%s): %w", l.Name, err)
+			return req
+		}
+		context.Log(req.Ctx).Info("removed stale lock", "name", l.Name, "owner", l.Owner, "plan", l.PlanID)
 	}
+
 	req.Next = r.done
 	return req
 }
@@ -158,7 +260,7 @@ func (r *recover) done(req statemachine.Request[recoverData]) statemachine.Reque
 // runningToFailed marks all objects in running states in the plan as failed.
 func runningToFailed(ctx context.Context, p *workflow.Plan) {
 	p.State.End = time.Now()
-	for item := range walk.Plan(ctx, p) {
+	for item := range walk.Plan(ctx, p, walk.WithSubPlans()) {
 		state := item.Value.(getStater).GetState()
 		if state.Status == workflow.Running {
 			state.Status = workflow.Failed
@@ -171,6 +273,19 @@ type stater interface {
 	GetState() *workflow.State
 }
 
+// waitingApproval returns true if the Plan is waiting on an Approval for the Plan or one of its Blocks.
+func waitingApproval(p *workflow.Plan) bool {
+	if p.Approval != nil && p.Approval.Status == workflow.ASWaiting {
+		return true
+	}
+	for _, b := range p.Blocks {
+		if b.Approval != nil && b.Approval.Status == workflow.ASWaiting {
+			return true
+		}
+	}
+	return false
+}
+
 // lastUpdate returns the last time the object was updated. If it has not been updated, this will return
 // the zero time. Only an object that has been started will have a LastUpdate time.
 func lastUpdate(ctx context.Context, p *workflow.Plan) time.Time {
@@ -180,7 +295,7 @@ func lastUpdate(ctx context.Context, p *workflow.Plan) time.Time {
 
 	last := time.Time{}
 
-	for item := range walk.Plan(ctx, p) {
+	for item := range walk.Plan(ctx, p, walk.WithSubPlans()) {
 		state := item.Value.(stater).GetState()
 		if state.Start.After(last) {
 			last = state.Start
@@ -0,0 +1,269 @@
+package execute
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/element-of-surprise/coercion/internal/cron"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/element-of-surprise/coercion/workflow/utils/clone"
+	"github.com/google/uuid"
+
+	"github.com/gostdlib/base/telemetry/log"
+)
+
+// Factory builds a new Plan for each start of a recurring schedule.
+type Factory func() *workflow.Plan
+
+// Preparer validates a Plan and applies defaults so that it can be written to storage.
+// This is used to submit the next Plan of a recurring schedule.
+type Preparer func(ctx context.Context, plan *workflow.Plan) error
+
+// WithPreparer sets the Preparer used to submit the next Plan of a recurring schedule.
+// Without a Preparer, recurring Plans do not recur.
+func WithPreparer(p Preparer) Option {
+	return func(e *Plans) error {
+		e.preparer = p
+		return nil
+	}
+}
+
+// Schedule schedules a previously Submitted Plan to start at a time. The schedule is written to storage so
+// that it survives a restart. A scheduled Plan is not considered stale until WithMaxSubmit() after the time
+// it is scheduled for.
+func (e *Plans) Schedule(ctx context.Context, id uuid.UUID, at time.Time) error {
+	if !at.After(e.now()) {
+		return fmt.Errorf("start time(%s) must be in the future", at)
+	}
+
+	plan, err := e.store.Read(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := e.validateStartState(ctx, plan); err != nil {
+		return fmt.Errorf("invalid plan state: %w", err)
+	}
+
+	plan.StartAt = at.UTC()
+	if err := e.store.UpdatePlan(ctx, plan); err != nil {
+		return fmt.Errorf("failed to write plan schedule: %w", err)
+	}
+	e.arm(ctx, plan)
+	return nil
+}
+
+// ScheduleRecurring schedules a previously Submitted Plan to start at the next time in the cron spec.
+// When the Plan starts, the next Plan is built with factory, submitted and scheduled for the following time
+// in the spec. The schedule is written to storage so that it survives a restart. As factory cannot be
+// written to storage, after a restart the next Plan is a clone of the Plan that is starting. Fields marked
+// `coerce:"secure"` are not in storage and are not in the clone.
+func (e *Plans) ScheduleRecurring(ctx context.Context, id uuid.UUID, spec string, factory Factory) error {
+	sched, err := cron.Parse(spec)
+	if err != nil {
+		return err
+	}
+	at := sched.Next(e.now())
+	if at.IsZero() {
+		return fmt.Errorf("cron spec(%s) never fires", spec)
+	}
+
+	plan, err := e.store.Read(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := e.validateStartState(ctx, plan); err != nil {
+		return fmt.Errorf("invalid plan state: %w", err)
+	}
+
+	plan.StartAt = at
+	plan.Recurring = spec
+	if err := e.store.UpdatePlan(ctx, plan); err != nil {
+		return fmt.Errorf("failed to write plan schedule: %w", err)
+	}
+
+	if factory != nil {
+		e.mu.Lock()
+		e.factories[id] = factory
+		e.mu.Unlock()
+	}
+	e.arm(ctx, plan)
+	return nil
+}
+
+// Unschedule removes the schedule from a Plan that has not started. The Plan stays in storage and can still be
+// started with Start(). For a recurring Plan, this ends the recurrence. If the Plan is not scheduled, this will
+// return ErrNotFound.
+func (e *Plans) Unschedule(ctx context.Context, id uuid.UUID) error {
+	plan, err := e.store.Read(ctx, id)
+	if err != nil {
+		return err
+	}
+	if plan.StartAt.IsZero() || plan.State.Status != workflow.NotStarted {
+		return ErrNotFound
+	}
+
+	e.disarm(id)
+	e.mu.Lock()
+	delete(e.factories, id)
+	e.mu.Unlock()
+
+	plan.StartAt = time.Time{}
+	plan.Recurring = ""
+	if err := e.store.UpdatePlan(ctx, plan); err != nil {
+		return fmt.Errorf("failed to write plan schedule: %w", err)
+	}
+	return nil
+}
+
+// arm sets a timer that starts the Plan at Plan.StartAt. Any previous timer for the Plan is stopped.
+func (e *Plans) arm(ctx context.Context, plan *workflow.Plan) {
+	id := plan.ID
+	ctx = context.WithoutCancel(ctx)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if t, ok := e.schedules[id]; ok {
+		t.Stop()
+	}
+	e.schedules[id] = time.AfterFunc(plan.StartAt.Sub(e.now()), func() { e.startScheduled(ctx, id) })
+}
+
+// disarm stops the timer for the Plan, if there is one.
+func (e *Plans) disarm(id uuid.UUID) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if t, ok := e.schedules[id]; ok {
+		t.Stop()
+		delete(e.schedules, id)
+	}
+}
+
+// startScheduled starts a Plan when its timer fires. If the Plan cannot be started, its schedule
+// is removed so that it is not retried on every restart. A recurring Plan still schedules the next Plan.
+func (e *Plans) startScheduled(ctx context.Context, id uuid.UUID) {
+	e.mu.Lock()
+	delete(e.schedules, id)
+	e.mu.Unlock()
+
+	err := e.Start(ctx, id)
+	if err == nil {
+		return
+	}
+	// Another process sharing storage started the Plan and handles its schedule.
+	if errors.Is(err, storage.ErrLeased) {
+		return
+	}
+	log.Default().Error(fmt.Sprintf("scheduled plan(%s) failed to start: %s", id, err))
+
+	plan, err := e.store.Read(ctx, id)
+	if err != nil {
+		log.Default().Error(fmt.Sprintf("failed to read scheduled plan(%s): %s", id, err))
+		return
+	}
+	if plan.State.Status != workflow.NotStarted || plan.StartAt.IsZero() {
+		return
+	}
+	if plan.Recurring != "" {
+		if err := e.scheduleNext(ctx, plan); err != nil {
+			log.Default().Error(fmt.Sprintf("failed to schedule the plan after plan(%s): %s", id, err))
+		}
+	}
+	plan.StartAt = time.Time{}
+	plan.Recurring = ""
+	if err := e.store.UpdatePlan(ctx, plan); err != nil {
+		log.Default().Error(fmt.Sprintf("failed to remove schedule from plan(%s): %s", id, err))
+	}
+}
+
+// scheduleNext submits and schedules the Plan that follows prev in its recurring schedule.
+func (e *Plans) scheduleNext(ctx context.Context, prev *workflow.Plan) error {
+	if e.preparer == nil {
+		return fmt.Errorf("no Preparer is set, recurring plans cannot be submitted")
+	}
+
+	sched, err := cron.Parse(prev.Recurring)
+	if err != nil {
+		return err
+	}
+	from := e.now()
+	if prev.StartAt.After(from) {
+		from = prev.StartAt
+	}
+	at := sched.Next(from)
+	if at.IsZero() {
+		return fmt.Errorf("cron spec(%s) never fires again", prev.Recurring)
+	}
+
+	e.mu.Lock()
+	factory := e.factories[prev.ID]
+	delete(e.factories, prev.ID)
+	e.mu.Unlock()
+
+	var next *workflow.Plan
+	if factory != nil {
+		next = factory()
+	} else {
+		next = clone.Plan(ctx, prev, clone.WithKeepSecrets())
+	}
+	if next == nil {
+		return fmt.Errorf("recurring plan factory returned nil")
+	}
+
+	if err := e.preparer(ctx, next); err != nil {
+		return err
+	}
+	next.StartAt = at
+	next.Recurring = prev.Recurring
+	if err := e.Submit(ctx, next); err != nil {
+		return err
+	}
+
+	if factory != nil {
+		e.mu.Lock()
+		e.factories[next.ID] = factory
+		e.mu.Unlock()
+	}
+	e.arm(ctx, next)
+	return nil
+}
+
+// recoverSchedules sets timers for all scheduled Plans in storage that have not started.
+// This is used when the Executor starts up.
+func (e *Plans) recoverSchedules(ctx context.Context) error {
+	results, err := e.store.Search(ctx, storage.Filters{ByStatus: []workflow.Status{workflow.NotStarted}})
+	if err != nil {
+		return fmt.Errorf("failed to search for scheduled plans: %w", err)
+	}
+
+	// Like recovery, we read the results before reading the Plans as the sqlite store cannot
+	// handle concurrent reads and writes.
+	var ids []uuid.UUID
+	for result := range results {
+		if result.Err != nil {
+			return fmt.Errorf("failed mid search for scheduled plans: %w", result.Err)
+		}
+		// SubPlans are started by the Plan that runs them.
+		if result.Result.CallerID != uuid.Nil {
+			continue
+		}
+		ids = append(ids, result.Result.ID)
+	}
+
+	for _, id := range ids {
+		plan, err := e.store.Read(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to read Plan(%s): %w", id, err)
+		}
+		if plan.StartAt.IsZero() {
+			continue
+		}
+		log.Default().Info("recovered scheduled plan", "id", plan.ID, "startAt", plan.StartAt)
+		e.arm(ctx, plan)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package actions
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"time"
@@ -11,9 +12,12 @@ import (
 	"github.com/element-of-surprise/coercion/workflow/context"
 	"github.com/element-of-surprise/coercion/workflow/storage"
 
+	"github.com/google/uuid"
 	"github.com/gostdlib/base/retry/exponential"
 	"github.com/gostdlib/base/statemachine"
 	"github.com/gostdlib/base/telemetry/log"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Data is the data passed to the state machine.
@@ -24,6 +28,8 @@ type Data struct {
 	Updater storage.ActionUpdater
 	// Registry is the registry to get the plugin from.
 	Registry *registry.Register
+	// Lookup returns the Action in the Plan with a Key. This is used to resolve the Action's Refs.
+	Lookup func(key uuid.UUID) *workflow.Action
 
 	// plugin is the plugin to run. This is set by the GetPlugin state.
 	plugin plugins.Plugin
@@ -36,6 +42,16 @@ type nower func() time.Time
 
 // Runner is a state machine that runs a workflow.Action.
 type Runner struct {
+	// Logs configures the capture of the records a plugin logs during each Attempt.
+	Logs LogCapture
+	// Tracer creates the span of each Attempt. If nil, a tracer from otel.GetTracerProvider() is used.
+	Tracer trace.Tracer
+	// AttemptDuration records how long each Attempt ran. If nil, it is not recorded.
+	AttemptDuration metric.Float64Histogram
+	// Transition is called with the Action each time its state changes, including when the running Attempt
+	// reports progress, whether or not the change is written to storage. If nil, it is not called.
+	Transition func(ctx context.Context, action *workflow.Action)
+
 	nower nower
 }
 
@@ -46,6 +62,11 @@ func (r Runner) Start(req statemachine.Request[Data]) statemachine.Request[Data]
 
 	action.State.Start = r.now()
 	action.State.Status = workflow.Running
+	// An Attempt that was running when the service stopped did not finish, so it is made again.
+	if n := len(action.Attempts); n > 0 && action.Attempts[n-1].Running() {
+		action.Attempts = action.Attempts[:n-1]
+	}
+	r.transition(req.Ctx, action)
 
 	if err := updater.UpdateAction(req.Ctx, action); err != nil {
 		log.Fatalf("failed to write Action: %v", err)
@@ -74,35 +95,97 @@ func (r Runner) GetPlugin(req statemachine.Request[Data]) statemachine.Request[D
 	}
 
 	req.Data.plugin = p
+	req.Next = r.Resolve
+	return req
+}
+
+// Resolve sets the fields in the Action's Req from the responses of the Actions its Refs reference, then has the
+// plugin validate the Req. If this fails, the Action fails with a permanent error recorded as its only Attempt.
+func (r Runner) Resolve(req statemachine.Request[Data]) statemachine.Request[Data] {
+	action := req.Data.Action
+
+	if len(action.Refs) == 0 {
+		req.Next = r.Execute
+		return req
+	}
+
+	var err error
+	if req.Data.Lookup == nil {
+		err = fmt.Errorf("action(%s) has refs, but there is no Lookup", action.Name)
+	} else {
+		err = action.ResolveRefs(req.Data.Lookup)
+	}
+	if err == nil {
+		err = req.Data.plugin.ValidateReq(action.Req)
+	}
+
+	// In a dry run, referenced Actions may not have a response because they were not executed.
+	if err != nil && !context.DryRun(req.Ctx) {
+		now := r.now()
+		action.Attempts = append(action.Attempts, &workflow.Attempt{Start: now, End: now, Err: &plugins.Error{Message: err.Error(), Permanent: true}})
+		req.Data.err = err
+		req.Next = r.End
+		return req
+	}
+
 	req.Next = r.Execute
 	return req
 }
 
 // Execute runs the action using the plugin and writes the result to the store. This
-// function will retry the action based on the plugin's retry policy.
+// function will retry the action based on the Action's RetryPolicy or, if that is not set,
+// the plugin's retry policy. If the Action has a MaxElapsed, no attempt starts after it has passed.
 func (r Runner) Execute(req statemachine.Request[Data]) statemachine.Request[Data] {
 	action := req.Data.Action
 	plugin := req.Data.plugin
 	writer := req.Data.Updater
 
+	// A dry run makes a single attempt, as nothing was actually done that could be retried.
+	if context.DryRun(req.Ctx) {
+		req.Data.err = r.exec(req.Ctx, action, plugin, req.Data.Registry, writer)
+		req.Next = r.End
+		return req
+	}
+
+	policy := plugin.RetryPolicy()
+	if action.RetryPolicy != nil {
+		policy = *action.RetryPolicy
+	}
 	backoff, err := exponential.New(
-		exponential.WithPolicy(req.Data.plugin.RetryPolicy()),
+		exponential.WithPolicy(policy),
 	)
 	// This should be protected by upper level code. If it fails, we should panic.
 	if err != nil {
 		log.Fatalf("failed to create backoff policy: %v", err)
 	}
 
+	// The deadline only stops the backoff from waiting past MaxElapsed. Attempts use req.Ctx, so that
+	// writing the Action is not cancelled by it.
+	retryCtx := req.Ctx
+	if action.MaxElapsed > 0 {
+		var cancel context.CancelFunc
+		retryCtx, cancel = context.WithDeadline(req.Ctx, action.State.Start.Add(action.MaxElapsed))
+		defer cancel()
+	}
+
 	req.Data.err = backoff.Retry(
-		req.Ctx,
+		retryCtx,
 		func(ctx context.Context, record exponential.Record) error {
-			return r.exec(ctx, action, plugin, writer)
+			return r.exec(req.Ctx, action, plugin, req.Data.Registry, writer)
 		},
 	)
+	if errors.Is(req.Data.err, exponential.ErrRetryCanceled) && req.Ctx.Err() == nil {
+		req.Data.err = maxElapsedErr(action)
+	}
 	req.Next = r.End
 	return req
 }
 
+// maxElapsedErr returns the error for an Action that could not make another attempt before its MaxElapsed.
+func maxElapsedErr(action *workflow.Action) error {
+	return fmt.Errorf("action(%s) exceeded its max elapsed time(%v): %w", action.Name, action.MaxElapsed, exponential.ErrPermanent)
+}
+
 // End marks the end of the action and handles writing the final state to the store.
 // If any error was recorded in the Data object, it will be promoted as the error of the Request.
 func (r Runner) End(req statemachine.Request[Data]) statemachine.Request[Data] {
@@ -115,6 +198,7 @@ func (r Runner) End(req statemachine.Request[Data]) statemachine.Request[Data] {
 	}
 
 	action.State.End = r.now()
+	r.transition(req.Ctx, action)
 
 	if err := updater.UpdateAction(req.Ctx, action); err != nil {
 		log.Fatalf("failed to write Action: %v", err)
@@ -134,30 +218,87 @@ func unexpectedTypeMsg(plugin plugins.Plugin, got, want any) string {
 }
 
 // exec runs the action once using the plugin and writes the result to the store, unless the action
-// has exceeded the maximum number of retries. In that case, it returns a permanent error.
-func (r Runner) exec(ctx context.Context, action *workflow.Action, plugin plugins.Plugin, updater storage.ActionUpdater) error {
+// has exceeded the maximum number of retries or its MaxElapsed. In that case, it returns a permanent error. If the plugin
+// has a concurrency limit in reg, this waits for a slot before running the plugin.
+func (r Runner) exec(ctx context.Context, action *workflow.Action, plugin plugins.Plugin, reg *registry.Register, updater storage.ActionUpdater) error {
 	if len(action.Attempts) > action.Retries {
 		return exponential.ErrPermanent
 	}
+	if action.MaxElapsed > 0 && r.now().Sub(action.State.Start) > action.MaxElapsed {
+		return maxElapsedErr(action)
+	}
 
 	defer func() {
+		r.transition(ctx, action)
 		if err := updater.UpdateAction(ctx, action); err != nil {
 			log.Fatalf("failed to write Action: %v", err)
 		}
 	}()
 
 	attempt := &workflow.Attempt{
-		Start: r.now(),
+		Start:  r.now(),
+		DryRun: context.DryRun(ctx),
 	}
 	defer func() {
 		action.Attempts = append(action.Attempts, attempt)
 	}()
 
+	// The plugin is run with the Attempt's span, so that calls it makes are part of the Plan's trace.
+	ctx, span := r.startSpan(ctx, action, len(action.Attempts)+1)
+	defer endSpan(span, attempt)
+	defer r.recordAttempt(ctx, action, attempt)
+
+	if _, ok := plugin.(plugins.DryRunner); attempt.DryRun && !ok {
+		// The plugin can't tell us what it would do, so we only record that it would execute.
+		attempt.End = r.now()
+		return nil
+	}
+
+	// A dry run does not execute the plugin, so it does not need a slot.
+	release := func() {}
+	if !attempt.DryRun && reg.MaxConcurrent(plugin.Name()) > 0 {
+		var err error
+		release, err = reg.Acquire(ctx, plugin.Name())
+		attempt.SlotWait = r.now().Sub(attempt.Start)
+		if err != nil {
+			attempt.End = r.now()
+			attempt.Err = &plugins.Error{Message: err.Error(), Permanent: true}
+			return errPermanent(attempt.Err)
+		}
+		attempt.Start = attempt.Start.Add(attempt.SlotWait)
+	}
+
+	prog := r.newProgress(ctx, action, attempt, updater)
 	runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), action.Timeout)
-	plugResp := run(runCtx, plugin, action.Req)
+	// A Stop lets the running Attempt finish, but a Plan, Block or Sequence that exceeds its Timeout cancels
+	// the Attempt with the cause of its deadline.
+	runCtx, cancelCause := context.WithCancelCause(runCtx)
+	stopDeadline := context.AfterFunc(ctx, func() {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			cancelCause(context.Cause(ctx))
+		}
+	})
+	runCtx = context.SetProgress(runCtx, prog)
+	logger, logs := newAttemptLog(ctx, r.Logs)
+	if logger != nil {
+		runCtx = context.SetLog(runCtx, logger)
+	}
+	plugResp := run(runCtx, plugin, action.Req, release)
+	cause := context.Cause(runCtx)
+	stopDeadline()
+	cancelCause(nil)
 	cancel()
+	prog.stop()
+	if logs != nil {
+		attempt.Logs, attempt.LogsDropped = logs.stop()
+	}
 	attempt.End = r.now()
 
+	// There is no time left to retry an Attempt that was cancelled by the deadline of its Plan, Block or Sequence.
+	if plugResp.timeout && cause != nil && !errors.Is(cause, context.DeadlineExceeded) {
+		attempt.Err = &plugins.Error{Message: cause.Error(), Permanent: true}
+		return errPermanent(attempt.Err)
+	}
 	if plugResp.timeout {
 		attempt.Err = &plugins.Error{
 			Message:   pluginTimeoutMsg,
@@ -195,6 +336,13 @@ func errPermanent(err *plugins.Error) error {
 	return fmt.Errorf("%w: %w", exponential.ErrPermanent, err)
 }
 
+// transition calls Transition with action, if it is set.
+func (r Runner) transition(ctx context.Context, action *workflow.Action) {
+	if r.Transition != nil {
+		r.Transition(ctx, action)
+	}
+}
+
 func (r Runner) now() time.Time {
 	if r.nower == nil {
 		return time.Now().UTC()
@@ -209,13 +357,20 @@ type plugResp struct {
 }
 
 // run executes the plugin in a goroutine and returns the response or an error if the context is done.
-func run(ctx context.Context, plugin plugins.Plugin, req any) plugResp {
+// If this is a dry run and the plugin implements plugins.DryRunner, DryRun is called instead of Execute.
+// release is called when the plugin returns, which may be after run has returned because of a timeout.
+func run(ctx context.Context, plugin plugins.Plugin, req any, release func()) plugResp {
 	ch := make(chan plugResp, 1)
 	go func() {
 		defer close(ch)
+		defer release()
 
 		plugResp := plugResp{}
-		plugResp.Resp, plugResp.Err = plugin.Execute(ctx, req)
+		if dr, ok := plugin.(plugins.DryRunner); ok && context.DryRun(ctx) {
+			plugResp.Resp, plugResp.Err = dr.DryRun(ctx, req)
+		} else {
+			plugResp.Resp, plugResp.Err = plugin.Execute(ctx, req)
+		}
 		ch <- plugResp
 	}()
 
@@ -2,6 +2,7 @@ package actions
 
 import (
 	"errors"
+	"log/slog"
 	"reflect"
 	"runtime"
 	"strings"
@@ -16,9 +17,16 @@ import (
 	"github.com/element-of-surprise/coercion/workflow/context"
 	"github.com/element-of-surprise/coercion/workflow/storage/sqlite"
 
+	"github.com/google/uuid"
 	"github.com/gostdlib/base/retry/exponential"
 	"github.com/gostdlib/base/statemachine"
 	"github.com/kylelemons/godebug/pretty"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 type fakeUpdater struct {
@@ -58,9 +66,12 @@ func TestStart(t *testing.T) {
 		return now
 	}
 
+	// The Attempt that was running when the service stopped is dropped.
+	finished := &workflow.Attempt{Start: now, End: now}
 	data := Data{
 		Action: &workflow.Action{
-			State: &workflow.State{},
+			State:    &workflow.State{},
+			Attempts: []*workflow.Attempt{finished, {Start: now, Progress: &workflow.Progress{Percent: 10}}},
 		},
 		Updater: newFakeUpdater(),
 	}
@@ -73,6 +84,7 @@ func TestStart(t *testing.T) {
 			Start:  now,
 			Status: workflow.Running,
 		},
+		Attempts: []*workflow.Attempt{finished},
 	}
 
 	if diff := pretty.Compare(wantAction, req.Data.Action); diff != "" {
@@ -140,7 +152,7 @@ func TestGetPlugin(t *testing.T) {
 				},
 				plugin: reg.Plugin(testplugin.Name),
 			},
-			wantNext: methodName(sm.Execute),
+			wantNext: methodName(sm.Resolve),
 		},
 	}
 	for _, test := range tests {
@@ -156,6 +168,114 @@ func TestGetPlugin(t *testing.T) {
 	}
 }
 
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	nower := func() time.Time {
+		return now
+	}
+
+	sm := Runner{nower: nower}
+
+	reg := registry.New()
+	reg.Register(&testplugin.Plugin{})
+	plug := reg.Plugin(testplugin.Name)
+
+	key := workflow.NewV7()
+	completed := &workflow.Action{
+		Key:      key,
+		Name:     "completed",
+		State:    &workflow.State{Status: workflow.Completed},
+		Attempts: []*workflow.Attempt{{Resp: testplugin.Resp{Arg: "resolved"}}},
+	}
+	lookup := func(k uuid.UUID) *workflow.Action {
+		if k == key {
+			return completed
+		}
+		return nil
+	}
+	refs := []workflow.Ref{{Key: key, Path: "Arg", Field: "Arg"}}
+	missing := []workflow.Ref{{Key: workflow.NewV7(), Field: "Arg"}}
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		action     *workflow.Action
+		wantAction *workflow.Action
+		wantErr    bool
+		wantNext   string
+	}{
+		{
+			name:       "No refs",
+			ctx:        context.Background(),
+			action:     &workflow.Action{Req: testplugin.Req{Arg: "arg"}},
+			wantAction: &workflow.Action{Req: testplugin.Req{Arg: "arg"}},
+			wantNext:   methodName(sm.Execute),
+		},
+		{
+			name:       "Refs resolved",
+			ctx:        context.Background(),
+			action:     &workflow.Action{Req: testplugin.Req{}, Refs: refs},
+			wantAction: &workflow.Action{Req: testplugin.Req{Arg: "resolved"}, Refs: refs},
+			wantNext:   methodName(sm.Execute),
+		},
+		{
+			name:   "Ref not found",
+			ctx:    context.Background(),
+			action: &workflow.Action{Req: testplugin.Req{}, Refs: missing},
+			wantAction: &workflow.Action{
+				Req:      testplugin.Req{},
+				Refs:     missing,
+				Attempts: []*workflow.Attempt{{Start: now, End: now, Err: &plugins.Error{Permanent: true}}},
+			},
+			wantErr:  true,
+			wantNext: methodName(sm.End),
+		},
+		{
+			name:   "Resolved Req fails validation",
+			ctx:    context.Background(),
+			action: &workflow.Action{Req: testplugin.Req{FailValidation: true}, Refs: refs},
+			wantAction: &workflow.Action{
+				Req:      testplugin.Req{Arg: "resolved", FailValidation: true},
+				Refs:     refs,
+				Attempts: []*workflow.Attempt{{Start: now, End: now, Err: &plugins.Error{Permanent: true}}},
+			},
+			wantErr:  true,
+			wantNext: methodName(sm.End),
+		},
+		{
+			name:       "Ref not found in a dry run",
+			ctx:        context.SetDryRun(context.Background()),
+			action:     &workflow.Action{Req: testplugin.Req{}, Refs: missing},
+			wantAction: &workflow.Action{Req: testplugin.Req{}, Refs: missing},
+			wantNext:   methodName(sm.Execute),
+		},
+	}
+
+	for _, test := range tests {
+		data := Data{Action: test.action, Lookup: lookup, plugin: plug}
+		req := sm.Resolve(statemachine.Request[Data]{Ctx: test.ctx, Data: data, Next: sm.Resolve})
+
+		// Error messages are not compared.
+		for _, a := range req.Data.Action.Attempts {
+			if a.Err != nil {
+				a.Err.Message = ""
+			}
+		}
+
+		if diff := pretty.Compare(test.wantAction, req.Data.Action); diff != "" {
+			t.Errorf("TestResolve(%s): Action: -want/+got:\n%s", test.name, diff)
+		}
+		if gotErr := req.Data.err != nil; gotErr != test.wantErr {
+			t.Errorf("TestResolve(%s): got err == %v, want err != nil == %v", test.name, req.Data.err, test.wantErr)
+		}
+		if methodName(req.Next) != test.wantNext {
+			t.Errorf("TestResolve(%s): got Request.Next %s, want Request.Next == %s", test.name, methodName(req.Next), test.wantNext)
+		}
+	}
+}
+
 func TestExecute(t *testing.T) {
 	t.Parallel()
 
@@ -167,6 +287,8 @@ func TestExecute(t *testing.T) {
 	pluginErr := &plugins.Error{
 		Message: "plugin error",
 	}
+	// slowPolicy waits longer than the MaxElapsed of the Action that uses it before retrying.
+	slowPolicy := exponential.Policy{InitialInterval: time.Hour, Multiplier: 2, MaxInterval: time.Hour}
 
 	tests := []struct {
 		name     string
@@ -210,6 +332,42 @@ func TestExecute(t *testing.T) {
 				err: exponential.ErrPermanent,
 			},
 		},
+		{
+			name: "RetryPolicy overrides the plugin",
+			data: Data{
+				Action: &workflow.Action{
+					State:       &workflow.State{Start: now},
+					Plugin:      testplugin.Name,
+					Timeout:     1 * time.Second,
+					Retries:     1,
+					RetryPolicy: &slowPolicy,
+					MaxElapsed:  100 * time.Millisecond,
+					Req:         testplugin.Req{},
+				},
+				plugin: &testplugin.Plugin{
+					Responses: []any{pluginErr, testplugin.Resp{Arg: "ok"}},
+				},
+			},
+			wantData: Data{
+				Action: &workflow.Action{
+					State:       &workflow.State{Start: now},
+					Plugin:      testplugin.Name,
+					Timeout:     1 * time.Second,
+					Retries:     1,
+					RetryPolicy: &slowPolicy,
+					MaxElapsed:  100 * time.Millisecond,
+					Req:         testplugin.Req{},
+					Attempts: []*workflow.Attempt{
+						{
+							Err:   &plugins.Error{Message: pluginErr.Error()},
+							Start: now,
+							End:   now,
+						},
+					},
+				},
+				err: maxElapsedErr(&workflow.Action{MaxElapsed: 100 * time.Millisecond}),
+			},
+		},
 		{
 			name: "Success after retry",
 			data: Data{
@@ -370,6 +528,22 @@ func TestExec(t *testing.T) {
 			errPermanent: true,
 			wantAttempts: []*workflow.Attempt{{}, {}},
 		},
+		{
+			name: "MaxElapsed exceeded",
+			ctx:  context.Background(),
+			plugin: &testplugin.Plugin{
+				AlwaysRespond: true,
+			},
+			action: &workflow.Action{
+				Attempts:   []*workflow.Attempt{{}},
+				Retries:    5,
+				MaxElapsed: time.Minute,
+				State:      &workflow.State{Start: now.Add(-2 * time.Minute)},
+			},
+			wantErr:      true,
+			errPermanent: true,
+			wantAttempts: []*workflow.Attempt{{}},
+		},
 		{
 			name: "Timeout",
 			ctx:  context.Background(),
@@ -441,6 +615,51 @@ func TestExec(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Dry run without a DryRunner",
+			ctx:  context.SetDryRun(context.Background()),
+			plugin: &testplugin.Plugin{
+				Responses: []any{
+					&plugins.Error{Message: "should not be executed"},
+				},
+			},
+			action: &workflow.Action{
+				Req:     testplugin.Req{Arg: "ok"},
+				Timeout: 100 * time.Millisecond,
+				State:   &workflow.State{},
+			},
+			wantAttempts: []*workflow.Attempt{
+				{
+					Start:  now,
+					End:    now,
+					DryRun: true,
+				},
+			},
+		},
+		{
+			name: "Dry run with a DryRunner",
+			ctx:  context.SetDryRun(context.Background()),
+			plugin: &testplugin.DryRunPlugin{
+				Plugin: &testplugin.Plugin{
+					Responses: []any{
+						&plugins.Error{Message: "should not be executed"},
+					},
+				},
+			},
+			action: &workflow.Action{
+				Req:     testplugin.Req{Arg: "ok"},
+				Timeout: 100 * time.Millisecond,
+				State:   &workflow.State{},
+			},
+			wantAttempts: []*workflow.Attempt{
+				{
+					Resp:   &testplugin.Resp{Arg: "would ok"},
+					Start:  now,
+					End:    now,
+					DryRun: true,
+				},
+			},
+		},
 	}
 
 	sm := Runner{nower: nower}
@@ -451,7 +670,7 @@ func TestExec(t *testing.T) {
 		}
 		defer rw.Close(context.Background())
 
-		err = sm.exec(test.ctx, test.action, test.plugin, rw)
+		err = sm.exec(test.ctx, test.action, test.plugin, nil, rw)
 
 		switch {
 		case err == nil && test.wantErr:
@@ -472,6 +691,288 @@ func TestExec(t *testing.T) {
 	}
 }
 
+func TestExecConcurrencyLimit(t *testing.T) {
+	t.Parallel()
+
+	plug := &testplugin.Plugin{AlwaysRespond: true}
+	reg := registry.New(registry.WithMaxConcurrent(testplugin.Name, 1))
+	reg.Register(plug)
+
+	// Take the only slot so that exec must wait for it.
+	release, err := reg.Acquire(context.Background(), testplugin.Name)
+	if err != nil {
+		t.Fatalf("TestExecConcurrencyLimit: Acquire() returned error: %v", err)
+	}
+	const wait = 50 * time.Millisecond
+	time.AfterFunc(wait, release)
+
+	action := &workflow.Action{Name: "action", Plugin: testplugin.Name, Req: testplugin.Req{Arg: "ok"}, Timeout: 5 * time.Second, Retries: 1}
+	sm := Runner{}
+	if err := sm.exec(context.Background(), action, plug, reg, (&fakeUpdater{}).SetRetErrOn(-1)); err != nil {
+		t.Fatalf("TestExecConcurrencyLimit: exec() returned error: %v", err)
+	}
+	if plug.Calls.Load() != 1 {
+		t.Errorf("TestExecConcurrencyLimit: got %d plugin calls, want 1", plug.Calls.Load())
+	}
+	if len(action.Attempts) != 1 {
+		t.Fatalf("TestExecConcurrencyLimit: got %d attempts, want 1", len(action.Attempts))
+	}
+	if action.Attempts[0].SlotWait < wait {
+		t.Errorf("TestExecConcurrencyLimit: got SlotWait == %v, want >= %v", action.Attempts[0].SlotWait, wait)
+	}
+
+	// The slot was released when the plugin returned.
+	release, err = reg.Acquire(context.Background(), testplugin.Name)
+	if err != nil {
+		t.Fatalf("TestExecConcurrencyLimit: Acquire() after exec() returned error: %v", err)
+	}
+
+	// A stopped Plan does not wait for a slot forever.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	action = &workflow.Action{Name: "action", Plugin: testplugin.Name, Req: testplugin.Req{Arg: "ok"}, Timeout: 5 * time.Second, Retries: 1}
+	err = sm.exec(ctx, action, plug, reg, (&fakeUpdater{}).SetRetErrOn(-1))
+	if !errors.Is(err, exponential.ErrPermanent) {
+		t.Errorf("TestExecConcurrencyLimit: got err == %v, want permanent error", err)
+	}
+	if plug.Calls.Load() != 1 {
+		t.Errorf("TestExecConcurrencyLimit: plugin was called while waiting for a slot")
+	}
+	release()
+}
+
+func TestExecParentDeadline(t *testing.T) {
+	t.Parallel()
+
+	plug := &testplugin.Plugin{AlwaysRespond: true}
+	newAction := func() *workflow.Action {
+		return &workflow.Action{Name: "action", Plugin: testplugin.Name, Req: testplugin.Req{Arg: "ok", Sleep: 200 * time.Millisecond}, Timeout: 5 * time.Second}
+	}
+	sm := Runner{}
+
+	// The deadline of a Plan, Block or Sequence cancels the running Attempt with its cause.
+	cause := errors.New("block(block) exceeded its timeout(50ms)")
+	ctx, cancel := context.WithDeadlineCause(context.Background(), time.Now().Add(50*time.Millisecond), cause)
+	defer cancel()
+	action := newAction()
+	err := sm.exec(ctx, action, plug, nil, newFakeUpdater())
+	if !errors.Is(err, exponential.ErrPermanent) {
+		t.Errorf("TestExecParentDeadline: got err == %v, want permanent error", err)
+	}
+	if len(action.Attempts) != 1 || action.Attempts[0].Err == nil || action.Attempts[0].Err.Message != cause.Error() {
+		t.Fatalf("TestExecParentDeadline: got Attempts %+v, want one Attempt that failed with %q", action.Attempts, cause)
+	}
+	if d := action.Attempts[0].End.Sub(action.Attempts[0].Start); d >= 200*time.Millisecond {
+		t.Errorf("TestExecParentDeadline: Attempt ran for %v, want it cancelled at the deadline", d)
+	}
+
+	// A Stop cancels without a deadline, which lets the running Attempt finish.
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	action = newAction()
+	if err := sm.exec(ctx, action, plug, nil, newFakeUpdater()); err != nil {
+		t.Errorf("TestExecParentDeadline: exec() after a cancel: got err == %v, want err == nil", err)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	finished := &workflow.Attempt{Start: now, End: now}
+	action := &workflow.Action{Name: "action", State: &workflow.State{Status: workflow.Running}, Attempts: []*workflow.Attempt{finished}}
+	attempt := &workflow.Attempt{Start: now}
+	updater := newFakeUpdater()
+	transitions := 0
+
+	r := Runner{
+		Transition: func(ctx context.Context, a *workflow.Action) { transitions++ },
+		nower:      func() time.Time { return now },
+	}
+	p := r.newProgress(context.Background(), action, attempt, updater)
+
+	p.Report(150, "starting")
+	// This is within progressInterval of the last write, so it is only set on the Attempt.
+	now = now.Add(time.Second)
+	p.Report(40, "working")
+	now = now.Add(progressInterval)
+	p.Report(80, "finishing")
+	p.stop()
+	// Reports after the Attempt finished are ignored.
+	p.Report(90, "late")
+
+	if len(updater.updates) != 2 {
+		t.Fatalf("TestProgress: got %d writes, want 2", len(updater.updates))
+	}
+	// Every report is a transition, including the one that was not written.
+	if transitions != 3 {
+		t.Errorf("TestProgress: got %d transitions, want 3", transitions)
+	}
+	first := updater.updates[0]
+	if len(first.Attempts) != 2 || first.Attempts[1] != attempt {
+		t.Fatalf("TestProgress: expected the running Attempt to be written after the finished one, got %d attempts", len(first.Attempts))
+	}
+	if len(action.Attempts) != 1 {
+		t.Errorf("TestProgress: the running Attempt was added to the executing Action")
+	}
+	want := &workflow.Progress{Percent: 80, Msg: "finishing", Time: now}
+	if diff := pretty.Compare(want, attempt.Progress); diff != "" {
+		t.Errorf("TestProgress: Attempt.Progress: -want/+got:\n%s", diff)
+	}
+	if !attempt.Running() {
+		t.Errorf("TestProgress: Attempt.Running(): got false, want true")
+	}
+}
+
+func TestExecLogs(t *testing.T) {
+	t.Parallel()
+
+	plug := &testplugin.Plugin{AlwaysRespond: true}
+	action := &workflow.Action{Name: "action", Plugin: testplugin.Name, Req: testplugin.Req{Arg: "ok", Log: "hello"}, Timeout: 5 * time.Second}
+	sm := Runner{Logs: LogCapture{Level: slog.LevelInfo, MaxRecords: 10}}
+
+	ctx := context.SetLog(context.Background(), slog.New(slog.DiscardHandler))
+	if err := sm.exec(ctx, action, plug, nil, newFakeUpdater()); err != nil {
+		t.Fatalf("TestExecLogs: exec() returned error: %v", err)
+	}
+	logs := action.Attempts[0].Logs
+	if len(logs) != 1 || logs[0].Msg != "hello" {
+		t.Fatalf("TestExecLogs: got Attempt.Logs %+v, want one record with Msg hello", logs)
+	}
+}
+
+func TestExecSpan(t *testing.T) {
+	t.Parallel()
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	sm := Runner{Tracer: tp.Tracer("test")}
+
+	plug := &testplugin.Plugin{AlwaysRespond: true}
+	action := &workflow.Action{ID: uuid.New(), Name: "action", Plugin: testplugin.Name, Req: testplugin.Req{Arg: "spanid"}, Timeout: 5 * time.Second}
+	if err := sm.exec(context.Background(), action, plug, nil, newFakeUpdater()); err != nil {
+		t.Fatalf("TestExecSpan: exec() returned error: %v", err)
+	}
+
+	failPlug := &testplugin.Plugin{Responses: []any{&plugins.Error{Code: 7, Message: "failed", Permanent: true}}}
+	failed := &workflow.Action{ID: uuid.New(), Name: "failed", Plugin: testplugin.Name, Req: testplugin.Req{}, Timeout: 5 * time.Second}
+	if err := sm.exec(context.Background(), failed, failPlug, nil, newFakeUpdater()); err == nil {
+		t.Fatalf("TestExecSpan: exec() with failing plugin: got err == nil, want err != nil")
+	}
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("TestExecSpan: got %d spans, want 2", len(spans))
+	}
+
+	// The plugin was executed with the Attempt's span.
+	if got := action.Attempts[0].Resp.(testplugin.Resp).Arg; got != spans[0].SpanContext().SpanID().String() {
+		t.Errorf("TestExecSpan: plugin saw span %s, want the Attempt span %s", got, spans[0].SpanContext().SpanID())
+	}
+	if spans[0].Name() != "Attempt" || spans[0].Status().Code != codes.Ok {
+		t.Errorf("TestExecSpan: got span %q with status %v, want Attempt with status Ok", spans[0].Name(), spans[0].Status().Code)
+	}
+
+	if spans[1].Status().Code != codes.Error || spans[1].Status().Description != "failed" {
+		t.Errorf("TestExecSpan: got failed Attempt span status %+v, want Error with description failed", spans[1].Status())
+	}
+	attrs := attribute.NewSet(spans[1].Attributes()...)
+	if v, _ := attrs.Value(attrErrCode); v.AsInt64() != 7 {
+		t.Errorf("TestExecSpan: got error code attribute %v, want 7", v.AsInt64())
+	}
+	if v, _ := attrs.Value(attrID); v.AsString() != failed.ID.String() {
+		t.Errorf("TestExecSpan: got id attribute %q, want %q", v.AsString(), failed.ID)
+	}
+}
+
+func TestExecMetrics(t *testing.T) {
+	t.Parallel()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	hist, err := mp.Meter("test").Float64Histogram("attempts")
+	if err != nil {
+		t.Fatalf("TestExecMetrics: Float64Histogram() returned error: %v", err)
+	}
+	sm := Runner{AttemptDuration: hist}
+
+	plug := &testplugin.Plugin{Responses: []any{&plugins.Error{Message: "failed"}, testplugin.Resp{Arg: "ok"}}}
+	action := &workflow.Action{ID: uuid.New(), Name: "action", Plugin: testplugin.Name, Req: testplugin.Req{}, Timeout: 5 * time.Second, Retries: 1}
+	if err := sm.exec(context.Background(), action, plug, nil, newFakeUpdater()); err == nil {
+		t.Fatalf("TestExecMetrics: exec() first attempt: got err == nil, want err != nil")
+	}
+	if err := sm.exec(context.Background(), action, plug, nil, newFakeUpdater()); err != nil {
+		t.Fatalf("TestExecMetrics: exec() second attempt returned error: %v", err)
+	}
+
+	rm := metricdata.ResourceMetrics{}
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("TestExecMetrics: Collect() returned error: %v", err)
+	}
+	points := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64]).DataPoints
+
+	got := map[string]uint64{}
+	for _, p := range points {
+		if v, _ := p.Attributes.Value(attrPlugin); v.AsString() != testplugin.Name {
+			t.Errorf("TestExecMetrics: got plugin attribute %q, want %q", v.AsString(), testplugin.Name)
+		}
+		v, _ := p.Attributes.Value(attrStatus)
+		got[v.AsString()] += p.Count
+	}
+	want := map[string]uint64{workflow.Failed.String(): 1, workflow.Completed.String(): 1}
+	if diff := pretty.Compare(want, got); diff != "" {
+		t.Errorf("TestExecMetrics: Attempts recorded by status: -want/+got:\n%s", diff)
+	}
+}
+
+func TestAttemptLog(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.SetLog(context.Background(), slog.New(slog.DiscardHandler))
+
+	if logger, buf := newAttemptLog(ctx, LogCapture{}); logger != nil || buf != nil {
+		t.Errorf("TestAttemptLog: expected no capture when MaxRecords == 0")
+	}
+
+	logger, buf := newAttemptLog(ctx, LogCapture{Level: slog.LevelInfo, MaxRecords: 2, MaxBytes: 1024})
+	logger.Debug("below level")
+	logger.With("plan", "p").WithGroup("req").Info("working", "step", 1, slog.Group("disk", "free", "1G"))
+	logger.Warn("slow")
+	logger.Error("over limit")
+	records, dropped := buf.stop()
+	// Records after the Attempt finished are not captured.
+	logger.Error("late")
+
+	want := []workflow.LogRecord{
+		{
+			Level: slog.LevelInfo,
+			Msg:   "working",
+			Attrs: []workflow.LogAttr{{Key: "plan", Value: "p"}, {Key: "req.step", Value: "1"}, {Key: "req.disk.free", Value: "1G"}},
+		},
+		{Level: slog.LevelWarn, Msg: "slow"},
+	}
+	for i := range records {
+		if records[i].Time.IsZero() {
+			t.Errorf("TestAttemptLog: record %d has no Time", i)
+		}
+		records[i].Time = time.Time{}
+	}
+	if diff := pretty.Compare(want, records); diff != "" {
+		t.Errorf("TestAttemptLog: records: -want/+got:\n%s", diff)
+	}
+	if dropped != 1 {
+		t.Errorf("TestAttemptLog: got %d dropped records, want 1", dropped)
+	}
+
+	logger, buf = newAttemptLog(ctx, LogCapture{Level: slog.LevelInfo, MaxRecords: 10, MaxBytes: 10})
+	logger.Info("0123456789")
+	logger.Info("x")
+	records, dropped = buf.stop()
+	if len(records) != 1 || dropped != 1 {
+		t.Errorf("TestAttemptLog(MaxBytes): got %d records and %d dropped, want 1 and 1", len(records), dropped)
+	}
+}
+
 func TestRun(t *testing.T) {
 	t.Parallel()
 
@@ -511,7 +1012,7 @@ func TestRun(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), test.timeout)
 		defer cancel()
 
-		resp := run(ctx, &testplugin.Plugin{AlwaysRespond: true}, test.req)
+		resp := run(ctx, &testplugin.Plugin{AlwaysRespond: true}, test.req, func() {})
 		switch {
 		case test.wantErr && resp.Err == nil:
 			t.Errorf("TestRun(%s): got err == nil, want error != nil", test.name)
@@ -0,0 +1,151 @@
+package actions
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+)
+
+// LogCapture configures the capture of the log records a plugin emits with context.Log() during an Attempt.
+// The zero value does not capture records.
+type LogCapture struct {
+	// Level is the minimum level of a record that is captured.
+	Level slog.Level
+	// MaxRecords is the maximum number of records captured for an Attempt. If this is 0, no records are captured.
+	MaxRecords int
+	// MaxBytes is the maximum size of the messages and attributes captured for an Attempt. If this is 0,
+	// there is no limit.
+	MaxBytes int
+}
+
+// logBuffer holds the records captured during an Attempt. A logBuffer is safe for concurrent use.
+type logBuffer struct {
+	mu sync.Mutex
+
+	capture LogCapture
+	records []workflow.LogRecord
+	size    int
+	dropped int
+	// stopped is set once the Attempt has finished. A plugin that timed out may still log.
+	stopped bool
+}
+
+// add captures a record, unless it exceeds the limits.
+func (b *logBuffer) add(rec workflow.LogRecord) {
+	size := len(rec.Msg)
+	for _, a := range rec.Attrs {
+		size += len(a.Key) + len(a.Value)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		return
+	}
+	if len(b.records) >= b.capture.MaxRecords || (b.capture.MaxBytes > 0 && b.size+size > b.capture.MaxBytes) {
+		b.dropped++
+		return
+	}
+	b.records = append(b.records, rec)
+	b.size += size
+}
+
+// stop stops capturing records and returns the records that were captured and the number that were dropped.
+func (b *logBuffer) stop() ([]workflow.LogRecord, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stopped = true
+	return b.records, b.dropped
+}
+
+// logHandler is a slog.Handler that captures records in a logBuffer and passes them to the process logger.
+type logHandler struct {
+	buf  *logBuffer
+	next slog.Handler
+
+	// prefix is prepended to attribute keys for the groups opened with WithGroup().
+	prefix string
+	// attrs are the attributes added with WithAttrs().
+	attrs []workflow.LogAttr
+}
+
+// newAttemptLog returns a logger for a plugin's Attempt that captures records in a logBuffer. Records are also
+// passed to the logger attached to ctx. If capture does not capture records, this returns a nil logBuffer.
+func newAttemptLog(ctx context.Context, capture LogCapture) (*slog.Logger, *logBuffer) {
+	if capture.MaxRecords <= 0 {
+		return nil, nil
+	}
+	buf := &logBuffer{capture: capture}
+	return slog.New(&logHandler{buf: buf, next: context.Log(ctx).Handler()}), buf
+}
+
+// Enabled implements slog.Handler.Enabled().
+func (h *logHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.buf.capture.Level || h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.Handle().
+func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= h.buf.capture.Level {
+		rec := workflow.LogRecord{
+			Time:  r.Time,
+			Level: r.Level,
+			Msg:   r.Message,
+			Attrs: slices.Clone(h.attrs),
+		}
+		r.Attrs(func(a slog.Attr) bool {
+			rec.Attrs = appendAttr(rec.Attrs, h.prefix, a)
+			return true
+		})
+		h.buf.add(rec)
+	}
+	if h.next.Enabled(ctx, r.Level) {
+		return h.next.Handle(ctx, r)
+	}
+	return nil
+}
+
+// WithAttrs implements slog.Handler.WithAttrs().
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	n := *h
+	n.next = h.next.WithAttrs(attrs)
+	n.attrs = slices.Clip(h.attrs)
+	for _, a := range attrs {
+		n.attrs = appendAttr(n.attrs, h.prefix, a)
+	}
+	return &n
+}
+
+// WithGroup implements slog.Handler.WithGroup().
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	n := *h
+	n.next = h.next.WithGroup(name)
+	n.prefix = h.prefix + name + "."
+	return &n
+}
+
+// appendAttr appends an attribute to attrs with prefix added to its key. Groups are flattened.
+func appendAttr(attrs []workflow.LogAttr, prefix string, a slog.Attr) []workflow.LogAttr {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			attrs = appendAttr(attrs, prefix, ga)
+		}
+		return attrs
+	}
+	if a.Key == "" {
+		return attrs
+	}
+	return append(attrs, workflow.LogAttr{Key: prefix + a.Key, Value: v.String()})
+}
@@ -0,0 +1,28 @@
+package actions
+
+import (
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// recordAttempt records the duration of attempt in AttemptDuration, if it is set.
+func (r Runner) recordAttempt(ctx context.Context, action *workflow.Action, attempt *workflow.Attempt) {
+	if r.AttemptDuration == nil || attempt.End.IsZero() {
+		return
+	}
+	status := workflow.Completed
+	if attempt.Err != nil {
+		status = workflow.Failed
+	}
+	r.AttemptDuration.Record(
+		context.WithoutCancel(ctx),
+		attempt.End.Sub(attempt.Start).Seconds(),
+		metric.WithAttributes(
+			attribute.String(attrPlugin, action.Plugin),
+			attribute.String(attrStatus, status.String()),
+		),
+	)
+}
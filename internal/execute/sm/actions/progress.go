@@ -0,0 +1,88 @@
+package actions
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+
+	"github.com/gostdlib/base/telemetry/log"
+)
+
+// progressInterval is the minimum time between writes of progress to storage. Progress that is reported
+// more often is kept on the Attempt and written with the next write.
+const progressInterval = 5 * time.Second
+
+// progress is a context.Reporter for a running Attempt. Each report is set on the Attempt and passed to
+// Runner.Transition, and the Action is written with the Attempt added to its Attempts, so the Attempt can
+// be seen while it runs.
+// A progress is safe for concurrent use.
+type progress struct {
+	mu sync.Mutex
+
+	// ctx is the Context used to write the Action.
+	ctx     context.Context
+	action  *workflow.Action
+	attempt *workflow.Attempt
+	updater storage.ActionUpdater
+	// transition is Runner.transition.
+	transition func(ctx context.Context, action *workflow.Action)
+
+	// written is when progress was last written to storage.
+	written time.Time
+	// stopped is set once the Attempt has finished. A plugin that timed out may still report.
+	stopped bool
+
+	nower nower
+}
+
+// newProgress creates a progress for attempt, which is not yet in action.Attempts.
+func (r Runner) newProgress(ctx context.Context, action *workflow.Action, attempt *workflow.Attempt, updater storage.ActionUpdater) *progress {
+	return &progress{
+		ctx:        ctx,
+		action:     action,
+		attempt:    attempt,
+		updater:    updater,
+		transition: r.transition,
+		nower:      r.now,
+	}
+}
+
+// Report implements context.Reporter.
+func (p *progress) Report(percent int, msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped {
+		return
+	}
+
+	now := p.nower()
+	p.attempt.Progress = &workflow.Progress{Percent: min(max(percent, 0), 100), Msg: msg, Time: now}
+
+	// The Action is copied so that the running Attempt is not added to the Action that is executing.
+	a := *p.action
+	a.Attempts = append(slices.Clip(p.action.Attempts), p.attempt)
+	p.transition(p.ctx, &a)
+
+	if !p.written.IsZero() && now.Sub(p.written) < progressInterval {
+		return
+	}
+	p.written = now
+
+	if err := p.updater.UpdateAction(p.ctx, &a); err != nil {
+		log.Default().Error(fmt.Sprintf("failed to write progress of Action(%s): %s", a.ID, err))
+	}
+}
+
+// stop stops progress from being reported. Once this returns, the Attempt is no longer changed.
+func (p *progress) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stopped = true
+}
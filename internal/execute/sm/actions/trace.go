@@ -0,0 +1,57 @@
+package actions
+
+import (
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the tracer used when Runner.Tracer is not set.
+const tracerName = "github.com/element-of-surprise/coercion"
+
+// Attribute keys that are set on the span and metrics of an Attempt. These match the keys used by the sm package.
+const (
+	attrPlanID  = "coercion.plan.id"
+	attrID      = "coercion.id"
+	attrPlugin  = "coercion.plugin"
+	attrAttempt = "coercion.attempt"
+	attrStatus  = "coercion.status"
+	attrErrCode = "coercion.error.code"
+)
+
+// startSpan starts the span for an Attempt of action. n is the number of the Attempt, starting at 1.
+func (r Runner) startSpan(ctx context.Context, action *workflow.Action, n int) (context.Context, trace.Span) {
+	tracer := r.Tracer
+	if tracer == nil {
+		tracer = otel.GetTracerProvider().Tracer(tracerName)
+	}
+	return tracer.Start(
+		ctx,
+		"Attempt",
+		trace.WithAttributes(
+			attribute.String(attrPlanID, context.PlanID(ctx).String()),
+			attribute.String(attrID, action.ID.String()),
+			attribute.String(attrPlugin, action.Plugin),
+			attribute.Int(attrAttempt, n),
+		),
+	)
+}
+
+// endSpan ends the span of attempt with the Attempt's result.
+func endSpan(span trace.Span, attempt *workflow.Attempt) {
+	if attempt.Err == nil {
+		span.SetAttributes(attribute.String(attrStatus, workflow.Completed.String()))
+		span.SetStatus(codes.Ok, "")
+	} else {
+		span.SetAttributes(
+			attribute.String(attrStatus, workflow.Failed.String()),
+			attribute.Int64(attrErrCode, int64(attempt.Err.Code)),
+		)
+		span.SetStatus(codes.Error, attempt.Err.Message)
+	}
+	span.End()
+}
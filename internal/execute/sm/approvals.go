@@ -0,0 +1,226 @@
+package sm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+
+	"github.com/google/uuid"
+	"github.com/gostdlib/base/statemachine"
+	"github.com/gostdlib/base/telemetry/log"
+)
+
+// Decision is a decision made by a human on a workflow.Approval.
+type Decision struct {
+	// Approved is true if the Approval was approved, false if it was rejected.
+	Approved bool
+	// Approver is who made the decision.
+	Approver string
+	// Comment is a comment the approver gave with the decision.
+	Comment string
+}
+
+// Approvals delivers Decisions to a Plan that is waiting on a workflow.Approval.
+// An Approvals is safe for concurrent use.
+type Approvals struct {
+	mu      sync.Mutex
+	waiting map[uuid.UUID]chan Decision
+}
+
+// NewApprovals creates a new Approvals.
+func NewApprovals() *Approvals {
+	return &Approvals{waiting: map[uuid.UUID]chan Decision{}}
+}
+
+// Decide delivers a Decision for the Approval on the object with the ID. For the Approval on a Plan
+// this is the Plan's ID, for an Approval on a Block this is the Block's ID. If the Plan is not
+// currently waiting on that Approval, an error is returned.
+func (a *Approvals) Decide(id uuid.UUID, d Decision) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ch, ok := a.waiting[id]
+	if !ok {
+		return fmt.Errorf("not waiting on an approval for object(%s)", id)
+	}
+	delete(a.waiting, id)
+	// ch is buffered and only receives once, so this does not block. Sending under the lock
+	// lets expire() see the Decision.
+	ch <- d
+	return nil
+}
+
+// wait registers that we are waiting on the Approval for the object with the ID. The returned channel
+// receives the Decision. If a is nil, this returns a nil channel.
+func (a *Approvals) wait(id uuid.UUID) chan Decision {
+	if a == nil {
+		return nil
+	}
+
+	ch := make(chan Decision, 1)
+	a.mu.Lock()
+	a.waiting[id] = ch
+	a.mu.Unlock()
+	return ch
+}
+
+// done removes the registration for the object with the ID.
+func (a *Approvals) done(id uuid.UUID) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	delete(a.waiting, id)
+	a.mu.Unlock()
+}
+
+// expire removes the registration for the object with the ID when its Approval times out. If a
+// Decision was delivered on ch before that, it is returned so it can be honoured. Once this returns,
+// Decide() for the object returns an error.
+func (a *Approvals) expire(id uuid.UUID, ch chan Decision) (Decision, bool) {
+	if a == nil {
+		return Decision{}, false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.waiting, id)
+	select {
+	case d := <-ch:
+		return d, true
+	default:
+		return Decision{}, false
+	}
+}
+
+// PlanApproval waits on the Plan's Approval, if it has one, before any Block is executed.
+func (s *States) PlanApproval(req statemachine.Request[Data]) statemachine.Request[Data] {
+	req.Next = s.BlockApproval
+	if concurrentBlocks(req.Data.Plan) {
+		req.Next = s.ExecuteBlocks
+	}
+
+	plan := req.Data.Plan
+	write := func() {
+		eventsFrom(req.Ctx).plan(plan)
+		if err := s.updatePlan(context.WithoutCancel(req.Ctx), plan); err != nil {
+			log.Fatalf("failed to write Plan: %v", err)
+		}
+	}
+
+	if err := s.waitApproval(req, plan.ID, plan.Approval, write); err != nil {
+		// A stopped or timed out Plan is handled in ExecuteBlock.
+		if halted(req.Ctx) != nil {
+			return req
+		}
+		req.Data.err = err
+		req.Next = s.PlanDeferredChecks
+	}
+	return req
+}
+
+// BlockApproval waits on the Approval of the next Block, if it has one, before the Block is executed.
+// If the Approval is rejected or expires, the Block fails.
+func (s *States) BlockApproval(req statemachine.Request[Data]) statemachine.Request[Data] {
+	req.Next = s.ExecuteBlock
+
+	if len(req.Data.blocks) == 0 {
+		return req
+	}
+	h := req.Data.blocks[0]
+	if skipBlock(h) {
+		return req
+	}
+
+	write := func() {
+		eventsFrom(req.Ctx).block(h.block)
+		if err := s.store.UpdateBlock(context.WithoutCancel(req.Ctx), h.block); err != nil {
+			log.Fatalf("failed to write Block: %v", err)
+		}
+	}
+
+	if err := s.waitApproval(req, h.block.ID, h.block.Approval, write); err != nil {
+		// A stopped or timed out Plan is handled in ExecuteBlock.
+		if halted(req.Ctx) != nil {
+			return req
+		}
+		h.block.State.Status = workflow.Failed
+		h.block.State.End = s.now()
+		write()
+		req.Data.err = err
+		req.Next = s.afterBlock(req, s.PlanDeferredChecks)
+	}
+	return req
+}
+
+// waitApproval waits for a Decision on an Approval for the object with the ID. write is called
+// to store the Approval whenever it changes. If the Approval is nil or already approved, this returns
+// immediately. A dry run does not wait on Approvals and leaves them untouched. This returns an error if
+// the Approval is rejected, expires or the Plan is stopped. A Decision that Decide() accepted before the
+// Approval expired is honoured.
+func (s *States) waitApproval(req statemachine.Request[Data], id uuid.UUID, a *workflow.Approval, write func()) error {
+	if a == nil || context.DryRun(req.Ctx) {
+		return nil
+	}
+
+	switch a.Status {
+	case workflow.ASApproved:
+		return nil
+	case workflow.ASRejected, workflow.ASExpired:
+		// This can happen on recovery.
+		return fmt.Errorf("approval for object(%s) was %s", id, a.Status)
+	case workflow.ASNotRequested:
+		a.Status = workflow.ASWaiting
+		a.Requested = s.now()
+		write()
+	}
+
+	decisions := req.Data.Approvals.wait(id)
+	defer req.Data.Approvals.done(id)
+
+	var timeout <-chan time.Time
+	if a.Timeout > 0 {
+		// We use Requested so that a recovered Plan does not restart the timeout.
+		t := time.NewTimer(a.Requested.Add(a.Timeout).Sub(s.now()))
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case <-req.Ctx.Done():
+		return halted(req.Ctx)
+	case <-timeout:
+		d, ok := req.Data.Approvals.expire(id, decisions)
+		if !ok {
+			a.Status = workflow.ASExpired
+			a.Decided = s.now()
+			write()
+			return fmt.Errorf("approval for object(%s) expired after %v", id, a.Timeout)
+		}
+		return s.decide(id, a, d, write)
+	case d := <-decisions:
+		return s.decide(id, a, d, write)
+	}
+}
+
+// decide records the Decision d on the Approval a for the object with the ID and calls write.
+// This returns an error if the Approval was rejected.
+func (s *States) decide(id uuid.UUID, a *workflow.Approval, d Decision, write func()) error {
+	a.Approver = d.Approver
+	a.Comment = d.Comment
+	a.Decided = s.now()
+	a.Status = workflow.ASApproved
+	if !d.Approved {
+		a.Status = workflow.ASRejected
+	}
+	write()
+	if !d.Approved {
+		return fmt.Errorf("approval for object(%s) was rejected by %s", id, d.Approver)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package sm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+
+	"github.com/google/uuid"
+	"github.com/gostdlib/base/statemachine"
+)
+
+func TestApprovalsDecide(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New()
+	a := NewApprovals()
+
+	if err := a.Decide(id, Decision{Approved: true}); err == nil {
+		t.Errorf("TestApprovalsDecide: Decide() with no waiter: got err == nil, want err != nil")
+	}
+
+	ch := a.wait(id)
+	if err := a.Decide(id, Decision{Approved: true, Approver: "approver"}); err != nil {
+		t.Fatalf("TestApprovalsDecide: Decide() with waiter: got err == %v, want err == nil", err)
+	}
+	d := <-ch
+	if !d.Approved || d.Approver != "approver" {
+		t.Errorf("TestApprovalsDecide: got Decision %+v, want approved by approver", d)
+	}
+
+	if err := a.Decide(id, Decision{Approved: true}); err == nil {
+		t.Errorf("TestApprovalsDecide: Decide() twice: got err == nil, want err != nil")
+	}
+
+	// A Decision delivered before the Approval expires is returned by expire().
+	ch = a.wait(id)
+	if err := a.Decide(id, Decision{Approved: true, Approver: "approver"}); err != nil {
+		t.Fatalf("TestApprovalsDecide: Decide() before expire: got err == %v, want err == nil", err)
+	}
+	if d, ok := a.expire(id, ch); !ok || d.Approver != "approver" {
+		t.Errorf("TestApprovalsDecide: expire() after Decide(): got (%+v, %v), want the Decision", d, ok)
+	}
+
+	// A Decision after the Approval expires is an error.
+	ch = a.wait(id)
+	if _, ok := a.expire(id, ch); ok {
+		t.Errorf("TestApprovalsDecide: expire() with no Decision: got ok == true, want ok == false")
+	}
+	if err := a.Decide(id, Decision{Approved: true}); err == nil {
+		t.Errorf("TestApprovalsDecide: Decide() after expire: got err == nil, want err != nil")
+	}
+}
+
+func TestWaitApprovalDecideRacesExpiry(t *testing.T) {
+	t.Parallel()
+
+	// The wait and the expiry race in a select, so we try enough times to see both cases win.
+	for i := 0; i < 50; i++ {
+		id := uuid.New()
+		approvals := NewApprovals()
+		a := &workflow.Approval{Status: workflow.ASWaiting, Requested: time.Now(), Timeout: time.Millisecond}
+
+		var decideErr error
+		decided := false
+		states := &States{
+			// now() is called after we are waiting on the Approval, so we Decide there and
+			// return a time where the Approval has already expired.
+			nower: func() time.Time {
+				if !decided {
+					decided = true
+					decideErr = approvals.Decide(id, Decision{Approved: true, Approver: "approver"})
+				}
+				return a.Requested.Add(time.Hour)
+			},
+		}
+		req := statemachine.Request[Data]{Ctx: context.Background(), Data: Data{Approvals: approvals}}
+
+		err := states.waitApproval(req, id, a, func() {})
+		if decideErr != nil {
+			t.Fatalf("TestWaitApprovalDecideRacesExpiry: got Decide() err == %v, want err == nil", decideErr)
+		}
+		if err != nil {
+			t.Fatalf("TestWaitApprovalDecideRacesExpiry: got err == %v, want err == nil", err)
+		}
+		if a.Status != workflow.ASApproved || a.Approver != "approver" {
+			t.Fatalf("TestWaitApprovalDecideRacesExpiry: got approval status == %v by %q, want %v by approver", a.Status, a.Approver, workflow.ASApproved)
+		}
+	}
+}
+
+func TestBlockApproval(t *testing.T) {
+	t.Parallel()
+
+	states := &States{}
+
+	tests := []struct {
+		name string
+		// approval is the Approval on the Block.
+		approval *workflow.Approval
+		// decision is sent to the Block if not nil.
+		decision *Decision
+		// stop indicates that the Plan is stopped while waiting.
+		stop bool
+
+		wantApprovalStatus workflow.ApprovalStatus
+		wantBlockStatus    workflow.Status
+		wantErr            bool
+		wantNextState      statemachine.State[Data]
+	}{
+		{
+			name:          "No approval",
+			wantNextState: states.ExecuteBlock,
+		},
+		{
+			name:               "Already approved",
+			approval:           &workflow.Approval{Status: workflow.ASApproved},
+			wantApprovalStatus: workflow.ASApproved,
+			wantNextState:      states.ExecuteBlock,
+		},
+		{
+			name:               "Approved",
+			approval:           &workflow.Approval{},
+			decision:           &Decision{Approved: true, Approver: "approver", Comment: "comment"},
+			wantApprovalStatus: workflow.ASApproved,
+			wantNextState:      states.ExecuteBlock,
+		},
+		{
+			name:               "Rejected",
+			approval:           &workflow.Approval{},
+			decision:           &Decision{Approved: false, Approver: "approver", Comment: "comment"},
+			wantApprovalStatus: workflow.ASRejected,
+			wantBlockStatus:    workflow.Failed,
+			wantErr:            true,
+			wantNextState:      states.PlanDeferredChecks,
+		},
+		{
+			name:               "Expired",
+			approval:           &workflow.Approval{Timeout: 10 * time.Millisecond},
+			wantApprovalStatus: workflow.ASExpired,
+			wantBlockStatus:    workflow.Failed,
+			wantErr:            true,
+			wantNextState:      states.PlanDeferredChecks,
+		},
+		{
+			name:               "Stopped while waiting",
+			approval:           &workflow.Approval{},
+			stop:               true,
+			wantApprovalStatus: workflow.ASWaiting,
+			wantNextState:      states.ExecuteBlock,
+		},
+	}
+
+	for _, test := range tests {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		b := &workflow.Block{ID: uuid.New(), State: &workflow.State{}, Approval: test.approval}
+		approvals := NewApprovals()
+
+		req := statemachine.Request[Data]{
+			Ctx: ctx,
+			Data: Data{
+				Plan:      &workflow.Plan{Blocks: []*workflow.Block{b}},
+				blocks:    []block{{block: b}},
+				Approvals: approvals,
+			},
+		}
+
+		if test.decision != nil || test.stop {
+			go func() {
+				for {
+					approvals.mu.Lock()
+					_, waiting := approvals.waiting[b.ID]
+					approvals.mu.Unlock()
+					if waiting {
+						break
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+				if test.stop {
+					cancel()
+					return
+				}
+				if err := approvals.Decide(b.ID, *test.decision); err != nil {
+					panic(err)
+				}
+			}()
+		}
+
+		states := &States{store: &fakeUpdater{}}
+		req = states.BlockApproval(req)
+
+		if test.wantErr != (req.Data.err != nil) {
+			t.Errorf("TestBlockApproval(%s): got err == %v, wantErr == %v", test.name, req.Data.err, test.wantErr)
+		}
+		if b.State.Status != test.wantBlockStatus {
+			t.Errorf("TestBlockApproval(%s): got block status == %v, want %v", test.name, b.State.Status, test.wantBlockStatus)
+		}
+		if test.approval != nil {
+			if test.approval.Status != test.wantApprovalStatus {
+				t.Errorf("TestBlockApproval(%s): got approval status == %v, want %v", test.name, test.approval.Status, test.wantApprovalStatus)
+			}
+			if test.decision != nil {
+				if test.approval.Approver != test.decision.Approver || test.approval.Comment != test.decision.Comment {
+					t.Errorf("TestBlockApproval(%s): approval did not record the approver and comment", test.name)
+				}
+				if test.approval.Decided.IsZero() {
+					t.Errorf("TestBlockApproval(%s): approval Decided was not set", test.name)
+				}
+			}
+		}
+		if methodName(req.Next) != methodName(test.wantNextState) {
+			t.Errorf("TestBlockApproval(%s): got next state = %v, want %v", test.name, methodName(req.Next), methodName(test.wantNextState))
+		}
+	}
+}
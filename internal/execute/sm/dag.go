@@ -0,0 +1,182 @@
+package sm
+
+import (
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+
+	"github.com/google/uuid"
+	"github.com/gostdlib/base/statemachine"
+)
+
+// concurrentBlocks returns true if the Blocks of the Plan are not run in sequence. This is the case
+// when a Block has DependsOn or the Plan's BlockConcurrency is greater than 1.
+func concurrentBlocks(p *workflow.Plan) bool {
+	if p.BlockConcurrency > 1 {
+		return true
+	}
+	for _, b := range p.Blocks {
+		if len(b.DependsOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// afterBlock returns the state that follows the end of the current Block. next is the state used when
+// Blocks are run in sequence. A Block that is run by ExecuteBlocks ends its statemachine.
+func (s *States) afterBlock(req statemachine.Request[Data], next statemachine.State[Data]) statemachine.State[Data] {
+	if req.Data.concurrent {
+		return nil
+	}
+	return next
+}
+
+// blockResult is the result of a Block run by ExecuteBlocks.
+type blockResult struct {
+	block *workflow.Block
+	err   error
+}
+
+// ExecuteBlocks runs the Blocks of a Plan that are not run in sequence. A Block starts once every Block in
+// its DependsOn has completed and fewer than Plan.BlockConcurrency Blocks are running. Each Block runs through
+// the same states as a Block run in sequence, but in its own statemachine. Once a Block fails, the Plan's
+// ContChecks fail or the Plan is stopped, no more Blocks are started and the running Blocks are allowed to
+// finish. As with Blocks run in sequence, a running Block starts no more Sequences once the Plan's ContChecks
+// fail. Blocks that were running when the Plan was recovered are started first.
+func (s *States) ExecuteBlocks(req statemachine.Request[Data]) statemachine.Request[Data] {
+	plan := req.Data.Plan
+	limit := max(plan.BlockConcurrency, 1)
+
+	completed := map[uuid.UUID]bool{}
+	var running, pending []block
+	for _, h := range req.Data.blocks {
+		switch h.block.State.Status {
+		case workflow.Completed:
+			completed[h.block.Key] = true
+		case workflow.Running:
+			running = append(running, h)
+		case workflow.NotStarted:
+			pending = append(pending, h)
+		}
+	}
+	pending = append(running, pending...)
+	req.Data.blocks = nil
+
+	// Blocks run in their own goroutines instead of on the Context's pool. A Block waits on its Sequences,
+	// which run on the pool, so a Block holding a worker could starve its own Sequences.
+	results := make(chan blockResult, len(pending))
+	ctx := req.Ctx
+
+	inFlight := 0
+	halt := false
+
+	// Each running Block reads the Plan's ContChecks from its own channel, as only one reader would receive
+	// a failure from the Plan's channel. A failure is sent to every running Block.
+	contChecks := req.Data.contCheckResult
+	blockChecks := map[uuid.UUID]chan error{}
+	planChecks := func(err error, ok bool) {
+		if !ok {
+			contChecks = nil
+			return
+		}
+		if err == nil {
+			return
+		}
+		contChecks = nil
+		halt = true
+		if req.Data.err == nil {
+			req.Data.err = err
+		}
+		for _, ch := range blockChecks {
+			ch <- err
+		}
+	}
+
+	for {
+		if halted(req.Ctx) != nil {
+			halt = true
+		}
+		select {
+		case err, ok := <-contChecks:
+			planChecks(err, ok)
+		default:
+		}
+		for i := 0; !halt && inFlight < limit && i < len(pending); {
+			h := pending[i]
+			if !dependsCompleted(h.block, completed) {
+				i++
+				continue
+			}
+			pending = append(pending[:i], pending[i+1:]...)
+			inFlight++
+
+			checks := make(chan error, 1)
+			blockChecks[h.block.Key] = checks
+			data := Data{
+				Plan:            plan,
+				Pauser:          req.Data.Pauser,
+				Approvals:       req.Data.Approvals,
+				Events:          req.Data.Events,
+				blocks:          []block{h},
+				concurrent:      true,
+				contCheckResult: checks,
+			}
+			go func() {
+				results <- blockResult{block: h.block, err: s.runBlock(ctx, data)}
+			}()
+		}
+		if inFlight == 0 {
+			break
+		}
+
+		select {
+		case r := <-results:
+			inFlight--
+			delete(blockChecks, r.block.Key)
+			if r.block.State.Status == workflow.Completed {
+				completed[r.block.Key] = true
+			} else {
+				halt = true
+				if req.Data.err == nil {
+					req.Data.err = r.err
+				}
+			}
+		case err, ok := <-contChecks:
+			planChecks(err, ok)
+		}
+	}
+
+	switch {
+	case halted(req.Ctx) != nil:
+		if req.Data.err == nil {
+			req.Data.err = halted(req.Ctx)
+		}
+		req.Next = s.PlanDeferredChecks
+	case halt:
+		req.Next = s.PlanDeferredChecks
+	default:
+		req.Next = s.PlanPostChecks
+	}
+	return req
+}
+
+// runBlock runs the Block in data in its own statemachine and returns the error the Block ended with.
+func (s *States) runBlock(ctx context.Context, data Data) error {
+	req := statemachine.Request[Data]{
+		Ctx:  ctx,
+		Data: data,
+		Next: s.BlockApproval,
+	}
+	req, _ = statemachine.Run(data.blocks[0].block.Name, req)
+	return req.Data.err
+}
+
+// dependsCompleted returns true if every Block that b depends on is in completed, which is keyed by Block Key.
+func dependsCompleted(b *workflow.Block, completed map[uuid.UUID]bool) bool {
+	for _, k := range b.DependsOn {
+		if !completed[k] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,299 @@
+package sm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/google/uuid"
+	"github.com/gostdlib/base/statemachine"
+)
+
+// sleepRunner is a rollbackRunner whose Actions take delay to run.
+type sleepRunner struct {
+	rollbackRunner
+
+	delay time.Duration
+}
+
+func (r *sleepRunner) run(ctx context.Context, action *workflow.Action, updater storage.ActionUpdater) error {
+	time.Sleep(r.delay)
+	return r.rollbackRunner.run(ctx, action, updater)
+}
+
+// maxOverlap returns the most Blocks that were running at the same time.
+func maxOverlap(blocks []*workflow.Block) int {
+	most := 0
+	for _, b := range blocks {
+		if b.State.Start.IsZero() {
+			continue
+		}
+		n := 0
+		for _, o := range blocks {
+			if o.State.Start.IsZero() {
+				continue
+			}
+			if !o.State.Start.After(b.State.Start) && o.State.End.After(b.State.Start) {
+				n++
+			}
+		}
+		most = max(most, n)
+	}
+	return most
+}
+
+func TestExecuteBlocksDAG(t *testing.T) {
+	t.Parallel()
+
+	keys := map[string]uuid.UUID{}
+	key := func(name string) uuid.UUID {
+		if _, ok := keys[name]; !ok {
+			keys[name] = workflow.NewV7()
+		}
+		return keys[name]
+	}
+	// dagBlock creates a Block named name with a single Action with the same name.
+	// The Action fails if name is "fail".
+	dagBlock := func(name string, status workflow.Status, dependsOn ...string) *workflow.Block {
+		b := &workflow.Block{
+			ID:          workflow.NewV7(),
+			Key:         key(name),
+			Name:        name,
+			Concurrency: 1,
+			State:       &workflow.State{Status: status},
+			Sequences: []*workflow.Sequence{
+				{
+					ID:      workflow.NewV7(),
+					Name:    name,
+					State:   &workflow.State{Status: workflow.NotStarted},
+					Actions: rbActions(name),
+				},
+			},
+		}
+		for _, d := range dependsOn {
+			b.DependsOn = append(b.DependsOn, key(d))
+		}
+		return b
+	}
+
+	tests := []struct {
+		name        string
+		concurrency int
+		blocks      []*workflow.Block
+		// wantOrder are groups of Actions that must run in order. The order within a group does not matter.
+		wantOrder    [][]string
+		wantStatuses map[string]workflow.Status
+		wantMax      int
+		wantNext     statemachine.State[Data]
+		wantErr      bool
+	}{
+		{
+			name:        "Success: diamond",
+			concurrency: 2,
+			blocks: []*workflow.Block{
+				dagBlock("d", workflow.NotStarted, "b", "c"),
+				dagBlock("b", workflow.NotStarted, "a"),
+				dagBlock("c", workflow.NotStarted, "a"),
+				dagBlock("a", workflow.NotStarted),
+			},
+			wantOrder: [][]string{{"a"}, {"b", "c"}, {"d"}},
+			wantStatuses: map[string]workflow.Status{
+				"a": workflow.Completed, "b": workflow.Completed, "c": workflow.Completed, "d": workflow.Completed,
+			},
+			wantMax:  2,
+			wantNext: (&States{}).PlanPostChecks,
+		},
+		{
+			name:        "Success: BlockConcurrency limits independent Blocks",
+			concurrency: 2,
+			blocks: []*workflow.Block{
+				dagBlock("a", workflow.NotStarted),
+				dagBlock("b", workflow.NotStarted),
+				dagBlock("c", workflow.NotStarted),
+			},
+			wantOrder: [][]string{{"a", "b", "c"}},
+			wantStatuses: map[string]workflow.Status{
+				"a": workflow.Completed, "b": workflow.Completed, "c": workflow.Completed,
+			},
+			wantMax:  2,
+			wantNext: (&States{}).PlanPostChecks,
+		},
+		{
+			name:        "Success: recovered Blocks",
+			concurrency: 1,
+			blocks: []*workflow.Block{
+				dagBlock("a", workflow.Completed),
+				dagBlock("c", workflow.NotStarted, "b"),
+				dagBlock("b", workflow.Running, "a"),
+			},
+			wantOrder: [][]string{{"b"}, {"c"}},
+			wantStatuses: map[string]workflow.Status{
+				"a": workflow.Completed, "b": workflow.Completed, "c": workflow.Completed,
+			},
+			wantMax:  1,
+			wantNext: (&States{}).PlanPostChecks,
+		},
+		{
+			name:        "Error: failed Block stops dependent and unstarted Blocks",
+			concurrency: 1,
+			blocks: []*workflow.Block{
+				dagBlock("fail", workflow.NotStarted),
+				dagBlock("a", workflow.NotStarted, "fail"),
+				dagBlock("b", workflow.NotStarted),
+			},
+			wantOrder: [][]string{{"fail"}},
+			wantStatuses: map[string]workflow.Status{
+				"fail": workflow.Failed, "a": workflow.NotStarted, "b": workflow.NotStarted,
+			},
+			wantMax:  1,
+			wantNext: (&States{}).PlanDeferredChecks,
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		runner := &sleepRunner{delay: 50 * time.Millisecond}
+		states := &States{store: &fakeUpdater{}, actionRunner: runner.run}
+
+		plan := &workflow.Plan{
+			ID:               workflow.NewV7(),
+			Name:             "plan",
+			BlockConcurrency: test.concurrency,
+			Blocks:           test.blocks,
+			State:            &workflow.State{Status: workflow.Running},
+		}
+		contCheckResult := make(chan error, 1)
+		close(contCheckResult)
+		req := statemachine.Request[Data]{
+			Ctx:  context.Background(),
+			Data: Data{Plan: plan, contCheckResult: contCheckResult},
+		}
+		for _, b := range plan.Blocks {
+			req.Data.blocks = append(req.Data.blocks, block{block: b, contCheckResult: make(chan error, 1)})
+		}
+
+		req = states.ExecuteBlocks(req)
+
+		if methodName(req.Next) != methodName(test.wantNext) {
+			t.Errorf("TestExecuteBlocksDAG(%s): got req.Next == %s, want req.Next == %s", test.name, methodName(req.Next), methodName(test.wantNext))
+		}
+		if (req.Data.err != nil) != test.wantErr {
+			t.Errorf("TestExecuteBlocksDAG(%s): got err == %v, wantErr == %v", test.name, req.Data.err, test.wantErr)
+		}
+		for _, b := range plan.Blocks {
+			if b.State.Status != test.wantStatuses[b.Name] {
+				t.Errorf("TestExecuteBlocksDAG(%s): got block(%s) status == %v, want %v", test.name, b.Name, b.State.Status, test.wantStatuses[b.Name])
+			}
+		}
+		if got := maxOverlap(plan.Blocks); got != test.wantMax {
+			t.Errorf("TestExecuteBlocksDAG(%s): got %d Blocks running at once, want %d", test.name, got, test.wantMax)
+		}
+
+		ran := runner.ran
+		for _, group := range test.wantOrder {
+			if len(ran) < len(group) {
+				t.Errorf("TestExecuteBlocksDAG(%s): got ran == %v, want groups %v", test.name, runner.ran, test.wantOrder)
+				break
+			}
+			got := map[string]bool{}
+			for _, n := range ran[:len(group)] {
+				got[n] = true
+			}
+			for _, n := range group {
+				if !got[n] {
+					t.Errorf("TestExecuteBlocksDAG(%s): got ran == %v, want groups %v", test.name, runner.ran, test.wantOrder)
+				}
+			}
+			ran = ran[len(group):]
+		}
+		if len(ran) != 0 {
+			t.Errorf("TestExecuteBlocksDAG(%s): got ran == %v, want groups %v", test.name, runner.ran, test.wantOrder)
+		}
+	}
+}
+
+// contCheckRunner is a sleepRunner that fails the Plan's ContChecks when the Action named contcheck runs.
+type contCheckRunner struct {
+	sleepRunner
+
+	contCheckResult chan error
+}
+
+func (r *contCheckRunner) run(ctx context.Context, action *workflow.Action, updater storage.ActionUpdater) error {
+	if action.Name == "contcheck" {
+		r.contCheckResult <- fmt.Errorf("contcheck failed")
+		close(r.contCheckResult)
+	}
+	return r.sleepRunner.run(ctx, action, updater)
+}
+
+func TestExecuteBlocksContChecks(t *testing.T) {
+	t.Parallel()
+
+	seq := func(name string) *workflow.Sequence {
+		return &workflow.Sequence{
+			ID:      workflow.NewV7(),
+			Name:    name,
+			State:   &workflow.State{Status: workflow.NotStarted},
+			Actions: rbActions(name),
+		}
+	}
+	a := &workflow.Block{
+		ID:          workflow.NewV7(),
+		Key:         workflow.NewV7(),
+		Name:        "a",
+		Concurrency: 1,
+		State:       &workflow.State{Status: workflow.NotStarted},
+		Sequences:   []*workflow.Sequence{seq("contcheck"), seq("a")},
+	}
+	b := &workflow.Block{
+		ID:          workflow.NewV7(),
+		Key:         workflow.NewV7(),
+		Name:        "b",
+		Concurrency: 1,
+		DependsOn:   []uuid.UUID{a.Key},
+		State:       &workflow.State{Status: workflow.NotStarted},
+		Sequences:   []*workflow.Sequence{seq("b")},
+	}
+
+	contCheckResult := make(chan error, 1)
+	runner := &contCheckRunner{sleepRunner: sleepRunner{delay: 50 * time.Millisecond}, contCheckResult: contCheckResult}
+	states := &States{store: &fakeUpdater{}, actionRunner: runner.run}
+
+	plan := &workflow.Plan{
+		ID:               workflow.NewV7(),
+		Name:             "plan",
+		BlockConcurrency: 2,
+		Blocks:           []*workflow.Block{a, b},
+		State:            &workflow.State{Status: workflow.Running},
+	}
+	req := statemachine.Request[Data]{
+		Ctx:  context.Background(),
+		Data: Data{Plan: plan, contCheckResult: contCheckResult},
+	}
+	for _, b := range plan.Blocks {
+		req.Data.blocks = append(req.Data.blocks, block{block: b, contCheckResult: make(chan error, 1)})
+	}
+
+	req = states.ExecuteBlocks(req)
+
+	if methodName(req.Next) != methodName(states.PlanDeferredChecks) {
+		t.Errorf("TestExecuteBlocksContChecks: got req.Next == %s, want req.Next == %s", methodName(req.Next), methodName(states.PlanDeferredChecks))
+	}
+	if req.Data.err == nil {
+		t.Errorf("TestExecuteBlocksContChecks: got err == nil, want err != nil")
+	}
+	if a.State.Status != workflow.Failed {
+		t.Errorf("TestExecuteBlocksContChecks: got block(a) status == %v, want %v", a.State.Status, workflow.Failed)
+	}
+	if b.State.Status != workflow.NotStarted {
+		t.Errorf("TestExecuteBlocksContChecks: got block(b) status == %v, want %v", b.State.Status, workflow.NotStarted)
+	}
+	// The running Block must not start its next Sequence once the Plan's ContChecks have failed.
+	if len(runner.ran) != 1 || runner.ran[0] != "contcheck" {
+		t.Errorf("TestExecuteBlocksContChecks: got ran == %v, want [contcheck]", runner.ran)
+	}
+}
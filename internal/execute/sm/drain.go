@@ -0,0 +1,242 @@
+package sm
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/google/uuid"
+)
+
+// errDrained is returned for an Action that is not started because the States is draining.
+var errDrained = errors.New("workstream is closing, action was not started")
+
+// gate is used to drain the States before the process exits. Once draining, no new Actions start and only
+// the Actions that are running write to storage. Once sealed, nothing more is written to storage. Plans that
+// are running see their remaining Actions fail, but as those results are not written, storage holds each Plan
+// as it was when its last Action finished, which recovery can resume. A gate is safe for concurrent use.
+// A nil gate is always open.
+type gate struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	// draining is set once no new Actions may start.
+	draining bool
+	// sealed is set once nothing more may be written to storage.
+	sealed bool
+	// actions is the number of Actions that are running.
+	actions int
+	// writes is the number of writes to storage in progress.
+	writes int
+}
+
+// newGate creates a new gate that is open.
+func newGate() *gate {
+	g := &gate{}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// admittedKey is the Context key that marks the Context of an Action that the gate admitted.
+type admittedKey struct{}
+
+// enterAction counts a running Action and returns a Context for it that can write while the gate is draining.
+// If the gate is draining, this returns false and the Action must not run.
+func (g *gate) enterAction(ctx context.Context) (context.Context, bool) {
+	if g == nil {
+		return ctx, true
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.draining {
+		return ctx, false
+	}
+	g.actions++
+	return context.WithValue(ctx, admittedKey{}, true), true
+}
+
+// exitAction is called when an Action that was admitted by enterAction finishes.
+func (g *gate) exitAction() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.actions--
+	g.cond.Broadcast()
+}
+
+// closing returns true once the gate is draining.
+func (g *gate) closing() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.draining
+}
+
+// drained returns true if an object that ended with status did not complete because the States is draining.
+// Such an object has not failed: storage holds it as it was last written and recovery resumes it.
+func (s *States) drained(status workflow.Status) bool {
+	return status != workflow.Completed && s.gate.closing()
+}
+
+// enterWrite counts a write in progress. If this returns false, the write must be dropped.
+func (g *gate) enterWrite(ctx context.Context) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.sealed {
+		return false
+	}
+	if g.draining && ctx.Value(admittedKey{}) == nil {
+		return false
+	}
+	g.writes++
+	return true
+}
+
+// exitWrite is called when a write that was counted with enterWrite finishes.
+func (g *gate) exitWrite() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.writes--
+	g.cond.Broadcast()
+}
+
+// drain stops new Actions from starting and waits for running Actions to finish, then seals the gate.
+// If ctx is done before the Actions finish, the gate is sealed anyway and ctx.Err() is returned.
+func (g *gate) drain(ctx context.Context) error {
+	g.mu.Lock()
+	g.draining = true
+	g.mu.Unlock()
+
+	err := g.wait(ctx, func() bool { return g.actions == 0 })
+	if sErr := g.seal(ctx); err == nil {
+		err = sErr
+	}
+	return err
+}
+
+// seal stops all writes to storage and waits for writes in progress to finish.
+func (g *gate) seal(ctx context.Context) error {
+	g.mu.Lock()
+	g.draining = true
+	g.sealed = true
+	g.mu.Unlock()
+
+	return g.wait(ctx, func() bool { return g.writes == 0 })
+}
+
+// wait waits until done returns true or ctx is done. done is called with g.mu held.
+func (g *gate) wait(ctx context.Context, done func() bool) error {
+	stop := context.AfterFunc(ctx, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.cond.Broadcast()
+	})
+	defer stop()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for !done() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		g.cond.Wait()
+	}
+	return nil
+}
+
+// Drain stops the States from starting new Actions and waits for running Actions to finish. Then writes to
+// storage are stopped and the Plans are left as they were last written, so that recovery resumes them.
+// The States cannot be used afterwards. If ctx is done before the running Actions finish, writes are
+// stopped anyway and ctx.Err() is returned.
+func (s *States) Drain(ctx context.Context) error {
+	return s.gate.drain(ctx)
+}
+
+// Halt stops new Actions from starting and all writes to storage without waiting for running Actions to
+// finish. The States cannot be used afterwards.
+func (s *States) Halt(ctx context.Context) error {
+	return s.gate.seal(ctx)
+}
+
+// gateVault is a storage.Vault whose writes from the statemachine pass through a gate. A write that the
+// gate does not allow is dropped and reported as a success.
+type gateVault struct {
+	storage.Vault
+	gate *gate
+}
+
+func (v gateVault) UpdatePlan(ctx context.Context, p *workflow.Plan) error {
+	if !v.gate.enterWrite(ctx) {
+		return nil
+	}
+	defer v.gate.exitWrite()
+	return v.Vault.UpdatePlan(ctx, p)
+}
+
+func (v gateVault) UpdateChecks(ctx context.Context, c *workflow.Checks) error {
+	if !v.gate.enterWrite(ctx) {
+		return nil
+	}
+	defer v.gate.exitWrite()
+	return v.Vault.UpdateChecks(ctx, c)
+}
+
+func (v gateVault) UpdateBlock(ctx context.Context, b *workflow.Block) error {
+	if !v.gate.enterWrite(ctx) {
+		return nil
+	}
+	defer v.gate.exitWrite()
+	return v.Vault.UpdateBlock(ctx, b)
+}
+
+func (v gateVault) AddSequences(ctx context.Context, b *workflow.Block, seqs []*workflow.Sequence) error {
+	if !v.gate.enterWrite(ctx) {
+		return nil
+	}
+	defer v.gate.exitWrite()
+	return v.Vault.AddSequences(ctx, b, seqs)
+}
+
+func (v gateVault) UpdateSequence(ctx context.Context, seq *workflow.Sequence) error {
+	if !v.gate.enterWrite(ctx) {
+		return nil
+	}
+	defer v.gate.exitWrite()
+	return v.Vault.UpdateSequence(ctx, seq)
+}
+
+func (v gateVault) UpdateAction(ctx context.Context, a *workflow.Action) error {
+	if !v.gate.enterWrite(ctx) {
+		return nil
+	}
+	defer v.gate.exitWrite()
+	return v.Vault.UpdateAction(ctx, a)
+}
+
+func (v gateVault) Lock(ctx context.Context, owner, planID uuid.UUID, names []string) error {
+	if !v.gate.enterWrite(ctx) {
+		return nil
+	}
+	defer v.gate.exitWrite()
+	return v.Vault.Lock(ctx, owner, planID, names)
+}
+
+func (v gateVault) Unlock(ctx context.Context, owner uuid.UUID, names []string) error {
+	if !v.gate.enterWrite(ctx) {
+		return nil
+	}
+	defer v.gate.exitWrite()
+	return v.Vault.Unlock(ctx, owner, names)
+}
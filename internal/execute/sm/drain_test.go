@@ -0,0 +1,123 @@
+package sm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow/context"
+)
+
+func TestGateDrain(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	g := newGate()
+
+	actCtx, ok := g.enterAction(ctx)
+	if !ok {
+		t.Fatalf("TestGateDrain: enterAction() on open gate: got false, want true")
+	}
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- g.drain(ctx)
+	}()
+
+	// Wait for the drain to start.
+	for {
+		g.mu.Lock()
+		draining := g.draining
+		g.mu.Unlock()
+		if draining {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, ok := g.enterAction(ctx); ok {
+		t.Errorf("TestGateDrain: enterAction() while draining: got true, want false")
+	}
+	if g.enterWrite(ctx) {
+		t.Errorf("TestGateDrain: enterWrite() while draining from outside an Action: got true, want false")
+	}
+	if !g.enterWrite(actCtx) {
+		t.Fatalf("TestGateDrain: enterWrite() while draining from a running Action: got false, want true")
+	}
+	g.exitWrite()
+
+	select {
+	case <-drained:
+		t.Fatalf("TestGateDrain: drain() returned while an Action was running")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	g.exitAction()
+	if err := <-drained; err != nil {
+		t.Fatalf("TestGateDrain: drain(): got err == %v, want err == nil", err)
+	}
+	if g.enterWrite(actCtx) {
+		t.Errorf("TestGateDrain: enterWrite() after drain: got true, want false")
+	}
+}
+
+func TestGateDrainCancel(t *testing.T) {
+	t.Parallel()
+
+	g := newGate()
+	actCtx, _ := g.enterAction(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := g.drain(ctx); err == nil {
+		t.Errorf("TestGateDrainCancel: drain() with running Action and expired Context: got err == nil, want err != nil")
+	}
+	if g.enterWrite(actCtx) {
+		t.Errorf("TestGateDrainCancel: enterWrite() after drain(): got true, want false")
+	}
+}
+
+func TestGateSeal(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	g := newGate()
+
+	actCtx, _ := g.enterAction(ctx)
+	if !g.enterWrite(actCtx) {
+		t.Fatalf("TestGateSeal: enterWrite() on open gate: got false, want true")
+	}
+
+	sealed := make(chan error, 1)
+	go func() {
+		sealed <- g.seal(ctx)
+	}()
+
+	select {
+	case <-sealed:
+		t.Fatalf("TestGateSeal: seal() returned while a write was in progress")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	g.exitWrite()
+	// seal() does not wait for the running Action.
+	if err := <-sealed; err != nil {
+		t.Fatalf("TestGateSeal: seal(): got err == %v, want err == nil", err)
+	}
+	if _, ok := g.enterAction(ctx); ok {
+		t.Errorf("TestGateSeal: enterAction() after seal(): got true, want false")
+	}
+	if g.enterWrite(actCtx) {
+		t.Errorf("TestGateSeal: enterWrite() after seal(): got true, want false")
+	}
+}
+
+func TestNilGate(t *testing.T) {
+	t.Parallel()
+
+	var g *gate
+	if _, ok := g.enterAction(context.Background()); !ok {
+		t.Errorf("TestNilGate: enterAction(): got false, want true")
+	}
+	g.exitAction()
+}
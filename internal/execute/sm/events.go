@@ -0,0 +1,270 @@
+package sm
+
+import (
+	"sync"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+	"github.com/element-of-surprise/coercion/workflow/utils/walk"
+
+	"github.com/google/uuid"
+)
+
+// maxHistory is the number of events that an Events keeps for subscribers.
+const maxHistory = 1000
+
+// Events records the workflow.Events of a running Plan and delivers them to subscribers. The last
+// maxHistory events are kept so that a subscriber that joins late receives them before new events.
+// A subscriber that joins late or falls behind by more than that misses the oldest events.
+// An Events is safe for concurrent use.
+type Events struct {
+	mu     sync.Mutex
+	planID uuid.UUID
+	// history is a ring buffer of the last events. The event with number n is at history[n%size].
+	history []workflow.Event
+	// size is the number of events that history holds.
+	size int
+	// total is the number of events that have been recorded.
+	total int
+	// status is the last known Status of each object in the Plan.
+	status map[uuid.UUID]workflow.Status
+	// attempts is the last known number of finished Attempts for each Action in the Plan.
+	attempts map[uuid.UUID]int
+	// progress is when progress was last reported for each Action in the Plan.
+	progress map[uuid.UUID]time.Time
+	// changed is closed and replaced when an event is added or the Events is closed.
+	changed chan struct{}
+	closed  bool
+
+	nower nower
+}
+
+// NewEvents creates a new Events for the Plan. The current state of the Plan is recorded so
+// that a recovered Plan only emits events for transitions that happen after recovery.
+func NewEvents(plan *workflow.Plan) *Events {
+	e := &Events{
+		planID:   plan.ID,
+		size:     maxHistory,
+		status:   map[uuid.UUID]workflow.Status{},
+		attempts: map[uuid.UUID]int{},
+		progress: map[uuid.UUID]time.Time{},
+		changed:  make(chan struct{}),
+	}
+
+	for item := range walk.Plan(context.Background(), plan, walk.WithSubPlans()) {
+		id := item.Value.(ider).GetID()
+		if state := item.Value.(getStater).GetState(); state != nil {
+			e.status[id] = state.Status
+		}
+		if item.Value.Type() == workflow.OTAction {
+			finished, _ := splitAttempts(item.Action().Attempts)
+			e.attempts[id] = len(finished)
+		}
+	}
+	return e
+}
+
+type ider interface {
+	GetID() uuid.UUID
+}
+
+type getStater interface {
+	GetState() *workflow.State
+}
+
+// Subscribe returns a channel that receives the events that are kept for the Plan, followed by new events
+// as they happen. The channel is closed after the last event once the Plan has finished or when the Context
+// is cancelled.
+func (e *Events) Subscribe(ctx context.Context) <-chan workflow.Event {
+	ch := make(chan workflow.Event, 1)
+
+	go func() {
+		defer close(ch)
+
+		next := 0
+		for {
+			e.mu.Lock()
+			var evs []workflow.Event
+			evs, next = e.since(next)
+			changed := e.changed
+			closed := e.closed
+			e.mu.Unlock()
+
+			if len(evs) == 0 {
+				if closed {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-changed:
+				}
+				continue
+			}
+
+			for _, ev := range evs {
+				select {
+				case <-ctx.Done():
+					return
+				case ch <- ev:
+				}
+			}
+		}
+	}()
+
+	return ch
+}
+
+// since returns a copy of the events from event number next that are still kept and the number of the
+// event after them. e.mu must be held.
+func (e *Events) since(next int) ([]workflow.Event, int) {
+	if oldest := e.total - len(e.history); next < oldest {
+		next = oldest
+	}
+	evs := make([]workflow.Event, 0, e.total-next)
+	for n := next; n < e.total; n++ {
+		evs = append(evs, e.history[n%e.size])
+	}
+	return evs, e.total
+}
+
+// add adds ev to the history, replacing the oldest event once size events are kept. e.mu must be held.
+func (e *Events) add(ev workflow.Event) {
+	if len(e.history) < e.size {
+		e.history = append(e.history, ev)
+	} else {
+		e.history[e.total%e.size] = ev
+	}
+	e.total++
+}
+
+// Close closes the Events. Subscribers receive any events they have not yet seen and then their channels are closed.
+// This should be called when the Plan has finished.
+func (e *Events) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return
+	}
+	e.closed = true
+	close(e.changed)
+}
+
+// record records an event for the object if its Status has changed or, for an Action, it has a new Attempt
+// or the plugin running its Attempt reported progress.
+func (e *Events) record(t workflow.ObjectType, id, key uuid.UUID, state *workflow.State, attempts []*workflow.Attempt) {
+	if e == nil || state == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return
+	}
+
+	finished, running := splitAttempts(attempts)
+	var progress *workflow.Progress
+	if running != nil && running.Progress.Time.After(e.progress[id]) {
+		progress = running.Progress
+	}
+
+	old, ok := e.status[id]
+	if !ok {
+		old = workflow.NotStarted
+	}
+	oldAttempts := e.attempts[id]
+	if old == state.Status && oldAttempts == len(finished) && progress == nil {
+		return
+	}
+	e.status[id] = state.Status
+	if t == workflow.OTAction {
+		e.attempts[id] = len(finished)
+	}
+	if progress != nil {
+		e.progress[id] = progress.Time
+	}
+
+	ev := workflow.Event{
+		PlanID:    e.planID,
+		Type:      t,
+		ID:        id,
+		Key:       key,
+		OldStatus: old,
+		NewStatus: state.Status,
+		Attempt:   len(attempts),
+		Progress:  progress,
+		Time:      e.now(),
+	}
+	if running == nil && len(finished) > 0 {
+		ev.Err = finished[len(finished)-1].Err
+	}
+	e.add(ev)
+
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+// splitAttempts splits the Attempts of an Action into those that finished and the Attempt that is running,
+// if there is one.
+func splitAttempts(attempts []*workflow.Attempt) (finished []*workflow.Attempt, running *workflow.Attempt) {
+	if n := len(attempts); n > 0 && attempts[n-1].Running() {
+		return attempts[:n-1], attempts[n-1]
+	}
+	return attempts, nil
+}
+
+func (e *Events) now() time.Time {
+	if e.nower == nil {
+		return time.Now().UTC()
+	}
+	return e.nower()
+}
+
+type eventsKey struct{}
+
+// withEvents attaches the Events to the Context. States and actions.Runner record an event in the Events
+// from the Context at each state transition.
+func withEvents(ctx context.Context, e *Events) context.Context {
+	if e == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, eventsKey{}, e)
+}
+
+// eventsFrom returns the Events attached to the Context, or nil if there are none.
+func eventsFrom(ctx context.Context) *Events {
+	e, _ := ctx.Value(eventsKey{}).(*Events)
+	return e
+}
+
+// The following record an event for a state transition of an object. They are called where the transition
+// happens, whether or not the object is then written to storage. A nil *Events records nothing.
+
+func (e *Events) plan(p *workflow.Plan) {
+	e.record(workflow.OTPlan, p.ID, uuid.Nil, p.State, nil)
+}
+
+func (e *Events) checks(c *workflow.Checks) {
+	e.record(workflow.OTCheck, c.ID, c.Key, c.State, nil)
+}
+
+func (e *Events) block(b *workflow.Block) {
+	e.record(workflow.OTBlock, b.ID, b.Key, b.State, nil)
+}
+
+func (e *Events) sequence(s *workflow.Sequence) {
+	e.record(workflow.OTSequence, s.ID, s.Key, s.State, nil)
+}
+
+func (e *Events) action(a *workflow.Action) {
+	e.record(workflow.OTAction, a.ID, a.Key, a.State, a.Attempts)
+}
+
+// recordAction records an event for a state transition of an Action in actions.Runner.
+func recordAction(ctx context.Context, a *workflow.Action) {
+	eventsFrom(ctx).action(a)
+}
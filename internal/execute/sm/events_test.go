@@ -0,0 +1,174 @@
+package sm
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+
+	"github.com/google/uuid"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	pErr := &plugins.Error{Message: "failed"}
+
+	action := &workflow.Action{ID: uuid.New(), Key: uuid.New(), State: &workflow.State{Status: workflow.NotStarted}}
+	seq := &workflow.Sequence{ID: uuid.New(), State: &workflow.State{Status: workflow.NotStarted}, Actions: []*workflow.Action{action}}
+	block := &workflow.Block{ID: uuid.New(), Key: uuid.New(), State: &workflow.State{Status: workflow.Completed}, Sequences: []*workflow.Sequence{seq}}
+	plan := &workflow.Plan{ID: uuid.New(), State: &workflow.State{Status: workflow.Running}, Blocks: []*workflow.Block{block}}
+
+	events := NewEvents(plan)
+	events.nower = func() time.Time { return now }
+
+	ctx, cancel := context.WithCancel(withEvents(context.Background(), events))
+	defer cancel()
+	early := events.Subscribe(ctx)
+
+	recorder := eventsFrom(ctx)
+
+	// The Plan and Block have not changed since NewEvents(), so they do not emit.
+	recorder.plan(plan)
+	recorder.block(block)
+
+	seq.State.Status = workflow.Running
+	recorder.sequence(seq)
+
+	action.State.Status = workflow.Running
+	recorder.action(action)
+	// No change, so no event.
+	recorder.action(action)
+
+	action.Attempts = append(action.Attempts, &workflow.Attempt{Err: pErr})
+	recorder.action(action)
+
+	// Progress on the running Attempt emits an event, the same progress does not.
+	prog := &workflow.Progress{Percent: 50, Msg: "halfway", Time: now}
+	running := *action
+	running.Attempts = append(slices.Clip(action.Attempts), &workflow.Attempt{Progress: prog})
+	recorder.action(&running)
+	recorder.action(&running)
+
+	action.Attempts = append(action.Attempts, &workflow.Attempt{})
+	action.State.Status = workflow.Completed
+	recorder.action(action)
+
+	seq.State.Status = workflow.Completed
+	recorder.sequence(seq)
+
+	plan.State.Status = workflow.Completed
+	recorder.plan(plan)
+
+	// This subscriber joins late and must receive the history.
+	late := events.Subscribe(ctx)
+	events.Close()
+
+	// Events after Close() are not recorded.
+	recorder.plan(plan)
+
+	want := []workflow.Event{
+		{PlanID: plan.ID, Type: workflow.OTSequence, ID: seq.ID, OldStatus: workflow.NotStarted, NewStatus: workflow.Running, Time: now},
+		{PlanID: plan.ID, Type: workflow.OTAction, ID: action.ID, Key: action.Key, OldStatus: workflow.NotStarted, NewStatus: workflow.Running, Time: now},
+		{PlanID: plan.ID, Type: workflow.OTAction, ID: action.ID, Key: action.Key, OldStatus: workflow.Running, NewStatus: workflow.Running, Attempt: 1, Err: pErr, Time: now},
+		{PlanID: plan.ID, Type: workflow.OTAction, ID: action.ID, Key: action.Key, OldStatus: workflow.Running, NewStatus: workflow.Running, Attempt: 2, Progress: &workflow.Progress{Percent: 50, Msg: "halfway", Time: now}, Time: now},
+		{PlanID: plan.ID, Type: workflow.OTAction, ID: action.ID, Key: action.Key, OldStatus: workflow.Running, NewStatus: workflow.Completed, Attempt: 2, Time: now},
+		{PlanID: plan.ID, Type: workflow.OTSequence, ID: seq.ID, OldStatus: workflow.Running, NewStatus: workflow.Completed, Time: now},
+		{PlanID: plan.ID, Type: workflow.OTPlan, ID: plan.ID, OldStatus: workflow.Running, NewStatus: workflow.Completed, Time: now},
+	}
+
+	for name, ch := range map[string]<-chan workflow.Event{"early": early, "late": late} {
+		var got []workflow.Event
+		for ev := range ch {
+			got = append(got, ev)
+		}
+		if diff := pretty.Compare(want, got); diff != "" {
+			t.Errorf("TestEvents(%s subscriber): -want/+got:\n%s", name, diff)
+		}
+	}
+}
+
+func TestEventsSubscribeCancel(t *testing.T) {
+	t.Parallel()
+
+	events := NewEvents(&workflow.Plan{ID: uuid.New(), State: &workflow.State{Status: workflow.Running}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := events.Subscribe(ctx)
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Errorf("TestEventsSubscribeCancel: got an event, want closed channel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("TestEventsSubscribeCancel: channel was not closed after Context was cancelled")
+	}
+}
+
+func TestEventsHistoryLimit(t *testing.T) {
+	t.Parallel()
+
+	plan := &workflow.Plan{ID: uuid.New(), State: &workflow.State{Status: workflow.NotStarted}}
+	events := NewEvents(plan)
+	events.size = 2
+
+	// Each of these is a transition, only the last two are kept.
+	for _, status := range []workflow.Status{workflow.Running, workflow.Stopped, workflow.Completed} {
+		plan.State.Status = status
+		events.plan(plan)
+	}
+	events.Close()
+
+	var got []workflow.Status
+	for ev := range events.Subscribe(context.Background()) {
+		got = append(got, ev.NewStatus)
+	}
+	want := []workflow.Status{workflow.Stopped, workflow.Completed}
+	if diff := pretty.Compare(want, got); diff != "" {
+		t.Errorf("TestEventsHistoryLimit: -want/+got:\n%s", diff)
+	}
+}
+
+func TestEventsWithoutWrites(t *testing.T) {
+	t.Parallel()
+
+	checks := &workflow.Checks{
+		ID:      uuid.New(),
+		State:   &workflow.State{Status: workflow.NotStarted},
+		Actions: []*workflow.Action{{Name: "action", State: &workflow.State{}}},
+	}
+	plan := &workflow.Plan{ID: uuid.New(), State: &workflow.State{Status: workflow.Running}, PreChecks: checks}
+	events := NewEvents(plan)
+	ctx := withEvents(context.Background(), events)
+
+	// A sealed gate drops all writes, such as while the States is draining.
+	g := newGate()
+	if err := g.seal(ctx); err != nil {
+		t.Fatalf("TestEventsWithoutWrites: seal() returned error: %v", err)
+	}
+	store := &fakeUpdater{}
+	states := &States{store: gateVault{Vault: store, gate: g}, actionsParallelRunner: fakeParallelActionRunner}
+
+	if err := states.runChecksOnce(ctx, checks); err != nil {
+		t.Fatalf("TestEventsWithoutWrites: runChecksOnce() returned error: %v", err)
+	}
+	events.Close()
+
+	if store.calls.Load() != 0 {
+		t.Fatalf("TestEventsWithoutWrites: got %d writes, want 0", store.calls.Load())
+	}
+	var got []workflow.Event
+	for ev := range events.Subscribe(context.Background()) {
+		got = append(got, ev)
+	}
+	if len(got) != 1 || got[0].ID != checks.ID || got[0].NewStatus != workflow.Completed {
+		t.Errorf("TestEventsWithoutWrites: got events %+v, want the Checks completing", got)
+	}
+}
@@ -9,13 +9,18 @@ import (
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/element-of-surprise/coercion/workflow/storage"
 	"github.com/element-of-surprise/coercion/workflow/utils/clone"
+	"github.com/google/uuid"
 )
 
 var cloneOpts = []clone.Option{clone.WithKeepSecrets(), clone.WithKeepState()}
 
 func fakeActionRunner(ctx context.Context, action *workflow.Action, updater storage.ActionUpdater) error {
-	if action.Name == "error" {
+	switch action.Name {
+	case "error":
 		return fmt.Errorf("error")
+	case "wait":
+		<-ctx.Done()
+		return ctx.Err()
 	}
 	return nil
 }
@@ -37,6 +42,7 @@ type fakeUpdater struct {
 	seqs    []*workflow.Sequence
 	actions []*workflow.Action
 	checks  []*workflow.Checks
+	added   []*workflow.Sequence
 	calls   atomic.Int32
 
 	storage.Vault
@@ -53,6 +59,21 @@ func (f *fakeUpdater) Create(ctx context.Context, plan *workflow.Plan) error {
 	return nil
 }
 
+// Read returns the last Plan with id that was written, or the Plan that was created with id.
+func (f *fakeUpdater) Read(ctx context.Context, id uuid.UUID) (*workflow.Plan, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, plans := range [][]*workflow.Plan{f.plans, f.create} {
+		for i := len(plans) - 1; i >= 0; i-- {
+			if plans[i].ID == id {
+				return clone.Plan(ctx, plans[i], cloneOpts...), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("plan(%s) not found", id)
+}
+
 func (f *fakeUpdater) UpdatePlan(ctx context.Context, plan *workflow.Plan) error {
 	f.calls.Add(1)
 
@@ -107,6 +128,18 @@ func (f *fakeUpdater) UpdateSequence(ctx context.Context, seq *workflow.Sequence
 	return nil
 }
 
+func (f *fakeUpdater) AddSequences(ctx context.Context, block *workflow.Block, seqs []*workflow.Sequence) error {
+	f.calls.Add(1)
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, seq := range seqs {
+		f.added = append(f.added, clone.Sequence(ctx, seq, cloneOpts...))
+	}
+	f.actions = append(f.actions, clone.Action(ctx, block.Generator, cloneOpts...))
+	return nil
+}
+
 func fakeRunChecksOnce(ctx context.Context, checks *workflow.Checks) error {
 	if checks.Actions[0].Name == "error" {
 		return fmt.Errorf("error")
@@ -1,9 +1,12 @@
 package sm
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/storage"
 
 	"github.com/gostdlib/base/statemachine"
 	"github.com/gostdlib/base/telemetry/log"
@@ -12,12 +15,58 @@ import (
 // finalStates is used to set the finalStates states on the Plan by examining the Plan's object states.
 type finalStates struct{}
 
-// start is simply the starting place for the statemachine. It does nothing.
+// start is simply the starting place for the statemachine. If the Plan, or the Block that runs it as a
+// SubPlan, exceeded its Timeout, it moves to the timedOut state. If the Plan was stopped by a user, it moves
+// to the stopped state. If the Plan or one of its objects could not get its locks, it moves to the locked state.
 func (f finalStates) start(req statemachine.Request[Data]) statemachine.Request[Data] {
+	var te timeoutError
+	if errors.As(halted(req.Ctx), &te) {
+		req.Data.err = te
+		req.Next = f.timedOut
+		return req
+	}
+	if stopped(req.Ctx) {
+		req.Next = f.stopped
+		return req
+	}
+	if errors.Is(req.Data.err, storage.ErrLocked) {
+		req.Next = f.locked
+		return req
+	}
 	req.Next = f.bypassChecks
 	return req
 }
 
+// stopped records a Plan as Stopped with the reason FRStopped. This is the final state
+// for a Plan that a user stopped.
+func (f finalStates) stopped(req statemachine.Request[Data]) statemachine.Request[Data] {
+	plan := req.Data.Plan
+	plan.State.Status = workflow.Stopped
+	plan.Reason = workflow.FRStopped
+	req.Err = errStopped
+	return req
+}
+
+// timedOut records a Plan as Failed with the reason FRTimeout. This is the final state for a Plan that
+// exceeded its Timeout.
+func (f finalStates) timedOut(req statemachine.Request[Data]) statemachine.Request[Data] {
+	plan := req.Data.Plan
+	plan.State.Status = workflow.Failed
+	plan.Reason = workflow.FRTimeout
+	req.Err = req.Data.err
+	return req
+}
+
+// locked records a Plan as Failed with the reason FRLocked. This is the final state for a Plan that
+// failed because it, one of its Blocks or enough of its Sequences could not get their locks.
+func (f finalStates) locked(req statemachine.Request[Data]) statemachine.Request[Data] {
+	plan := req.Data.Plan
+	plan.State.Status = workflow.Failed
+	plan.Reason = workflow.FRLocked
+	req.Err = req.Data.err
+	return req
+}
+
 // bypassChecks looks through all the checks in the in the Plan bypass and Completes the Plan if there are
 // bypass checks defined and they all pass. If there are no bypasses defined, the Plan is examined
 // further.
@@ -29,6 +78,31 @@ func (f finalStates) bypassChecks(req statemachine.Request[Data]) statemachine.R
 		req.Next = f.end // Records the Plan as Completed
 		return req
 	}
+	req.Next = f.approvals
+	return req
+}
+
+// approvals fails the Plan if the Plan's Approval or any Block's Approval was rejected or expired.
+func (f finalStates) approvals(req statemachine.Request[Data]) statemachine.Request[Data] {
+	plan := req.Data.Plan
+
+	approvals := []*workflow.Approval{plan.Approval}
+	for _, block := range plan.Blocks {
+		approvals = append(approvals, block.Approval)
+	}
+
+	for _, a := range approvals {
+		if a == nil {
+			continue
+		}
+		switch a.Status {
+		case workflow.ASRejected, workflow.ASExpired:
+			plan.State.Status = workflow.Failed
+			plan.Reason = workflow.FRApproval
+			req.Err = fmt.Errorf("approval %s", a.Status)
+			return req
+		}
+	}
 	req.Next = f.planChecks
 	return req
 }
@@ -50,18 +124,21 @@ func (f finalStates) planChecks(req statemachine.Request[Data]) statemachine.Req
 	return req
 }
 
-// blocks checks the state of the block and fails the Plan if any of the blocks failed. If a block is not in a
-// state we should be in, it generates an ErrInternalFailure.
+// blocks checks the state of the blocks and fails the Plan if any of the blocks failed, recording the names of
+// the blocks that failed in the error. If a block is not in a state we should be in, it generates an ErrInternalFailure.
 func (f finalStates) blocks(req statemachine.Request[Data]) statemachine.Request[Data] {
 	plan := req.Data.Plan
+
+	var failed []string
+	notStarted := false
 	for _, block := range req.Data.Plan.Blocks {
 		switch block.State.Status {
 		case workflow.Completed:
 		case workflow.Failed:
-			plan.State.Status = workflow.Failed
-			plan.Reason = workflow.FRBlock
-			req.Err = fmt.Errorf("block failure")
-			return req
+			failed = append(failed, block.Name)
+		case workflow.NotStarted:
+			// No more blocks are started after a block fails, so this is only valid if a block failed.
+			notStarted = true
 		default:
 			plan.State.Status = workflow.Failed
 			plan.Reason = workflow.FRBlock
@@ -69,6 +146,24 @@ func (f finalStates) blocks(req statemachine.Request[Data]) statemachine.Request
 			return req
 		}
 	}
+
+	switch {
+	case len(failed) > 0:
+		plan.State.Status = workflow.Failed
+		plan.Reason = workflow.FRBlock
+		req.Err = fmt.Errorf("block failure: blocks(%s) failed", strings.Join(failed, ", "))
+		// A Block or its Sequences exceeded a Timeout.
+		if errors.Is(req.Data.err, errTimeout) {
+			plan.Reason = workflow.FRTimeout
+			req.Err = fmt.Errorf("%w: %w", req.Err, req.Data.err)
+		}
+		return req
+	case notStarted:
+		plan.State.Status = workflow.Failed
+		plan.Reason = workflow.FRBlock
+		req.Err = fmt.Errorf("block End state reached in %s state, which is invalid: %w", workflow.NotStarted, ErrInternalFailure)
+		return req
+	}
 	req.Next = f.end
 	return req
 }
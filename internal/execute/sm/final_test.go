@@ -2,9 +2,14 @@ package sm
 
 import (
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/google/uuid"
 	"github.com/gostdlib/base/statemachine"
 )
 
@@ -66,6 +71,69 @@ func TestPlanChecks(t *testing.T) {
 	}
 }
 
+func TestFinalsApprovals(t *testing.T) {
+	t.Parallel()
+
+	finals := finalStates{}
+
+	tests := []struct {
+		name          string
+		planApproval  *workflow.Approval
+		blockApproval *workflow.Approval
+		wantNext      statemachine.State[Data]
+		wantReason    workflow.FailureReason
+		wantErr       bool
+	}{
+		{
+			name:     "no approvals",
+			wantNext: finals.planChecks,
+		},
+		{
+			name:          "all approved",
+			planApproval:  &workflow.Approval{Status: workflow.ASApproved},
+			blockApproval: &workflow.Approval{Status: workflow.ASApproved},
+			wantNext:      finals.planChecks,
+		},
+		{
+			name:         "plan approval rejected",
+			planApproval: &workflow.Approval{Status: workflow.ASRejected},
+			wantReason:   workflow.FRApproval,
+			wantErr:      true,
+		},
+		{
+			name:          "block approval expired",
+			blockApproval: &workflow.Approval{Status: workflow.ASExpired},
+			wantReason:    workflow.FRApproval,
+			wantErr:       true,
+		},
+	}
+
+	for _, test := range tests {
+		plan := &workflow.Plan{
+			Approval: test.planApproval,
+			Blocks:   []*workflow.Block{{Approval: test.blockApproval, State: &workflow.State{}}},
+			State:    &workflow.State{Status: workflow.Running},
+		}
+
+		req := finals.approvals(statemachine.Request[Data]{Data: Data{Plan: plan}})
+		switch {
+		case req.Err == nil && test.wantErr:
+			t.Errorf("TestFinalsApprovals(%s): got err == nil, want err != nil", test.name)
+		case req.Err != nil && !test.wantErr:
+			t.Errorf("TestFinalsApprovals(%s): got err == %v, want err == nil", test.name, req.Err)
+		}
+		if test.wantErr && plan.State.Status != workflow.Failed {
+			t.Errorf("TestFinalsApprovals(%s): got status == %v, want status == %v", test.name, plan.State.Status, workflow.Failed)
+		}
+		if methodName(req.Next) != methodName(test.wantNext) {
+			t.Errorf("TestFinalsApprovals(%s): got next == %v, want next == %v", test.name, methodName(req.Next), methodName(test.wantNext))
+		}
+		if plan.Reason != test.wantReason {
+			t.Errorf("TestFinalsApprovals(%s): got reason == %v, want reason == %v", test.name, plan.Reason, test.wantReason)
+		}
+	}
+}
+
 func TestBlocks(t *testing.T) {
 	t.Parallel()
 
@@ -73,24 +141,56 @@ func TestBlocks(t *testing.T) {
 
 	tests := []struct {
 		name        string
-		block       *workflow.Block
+		blocks      []*workflow.Block
+		dataErr     error
 		wantNext    statemachine.State[Data]
+		wantReason  workflow.FailureReason
 		wantErr     bool
+		wantErrMsg  string
 		internalErr bool
 	}{
 		{
 			name:     "block is completed",
-			block:    &workflow.Block{State: &workflow.State{Status: workflow.Completed}},
+			blocks:   []*workflow.Block{{State: &workflow.State{Status: workflow.Completed}}},
 			wantNext: finals.end,
 		},
 		{
-			name:    "block is failed",
-			block:   &workflow.Block{State: &workflow.State{Status: workflow.Failed}},
-			wantErr: true,
+			name:       "block is failed",
+			blocks:     []*workflow.Block{{State: &workflow.State{Status: workflow.Failed}}},
+			wantReason: workflow.FRBlock,
+			wantErr:    true,
+		},
+		{
+			name:       "block timed out",
+			blocks:     []*workflow.Block{{State: &workflow.State{Status: workflow.Failed}}},
+			dataErr:    timeoutError{Type: workflow.OTBlock, Name: "block", Timeout: time.Minute},
+			wantReason: workflow.FRTimeout,
+			wantErr:    true,
+		},
+		{
+			name: "several blocks failed",
+			blocks: []*workflow.Block{
+				{Name: "a", State: &workflow.State{Status: workflow.Failed}},
+				{Name: "b", State: &workflow.State{Status: workflow.Completed}},
+				{Name: "c", State: &workflow.State{Status: workflow.Failed}},
+				{Name: "d", State: &workflow.State{Status: workflow.NotStarted}},
+			},
+			wantReason: workflow.FRBlock,
+			wantErr:    true,
+			wantErrMsg: "block failure: blocks(a, c) failed",
+		},
+		{
+			name: "block not started without a failure",
+			blocks: []*workflow.Block{
+				{Name: "a", State: &workflow.State{Status: workflow.Completed}},
+				{Name: "b", State: &workflow.State{Status: workflow.NotStarted}},
+			},
+			wantErr:     true,
+			internalErr: true,
 		},
 		{
 			name:        "block is in an invalid state",
-			block:       &workflow.Block{State: &workflow.State{Status: workflow.Running}},
+			blocks:      []*workflow.Block{{State: &workflow.State{Status: workflow.Running}}},
 			wantErr:     true,
 			internalErr: true,
 		},
@@ -98,11 +198,14 @@ func TestBlocks(t *testing.T) {
 
 	for _, test := range tests {
 		plan := &workflow.Plan{
-			Blocks: []*workflow.Block{test.block},
+			Blocks: test.blocks,
 			State:  &workflow.State{Status: workflow.Running},
 		}
 
-		req := finals.blocks(statemachine.Request[Data]{Data: Data{Plan: plan}})
+		req := finals.blocks(statemachine.Request[Data]{Data: Data{Plan: plan, err: test.dataErr}})
+		if test.wantReason != workflow.FRUnknown && plan.Reason != test.wantReason {
+			t.Errorf("TestBlocks(%s): got reason == %s, want reason == %s", test.name, plan.Reason, test.wantReason)
+		}
 		switch {
 		case req.Err == nil && test.wantErr:
 			t.Errorf("TestBlocks(%s): got err == nil, want err != nil", test.name)
@@ -112,6 +215,9 @@ func TestBlocks(t *testing.T) {
 			if errors.Is(req.Err, ErrInternalFailure) != test.internalErr {
 				t.Errorf("TestBlocks(%s): got err == %v, want err == %v", test.name, req.Err, ErrInternalFailure)
 			}
+			if test.wantErrMsg != "" && req.Err.Error() != test.wantErrMsg {
+				t.Errorf("TestBlocks(%s): got err == %q, want err == %q", test.name, req.Err, test.wantErrMsg)
+			}
 		}
 		if test.wantNext != nil {
 			if methodName(req.Next) != methodName(test.wantNext) {
@@ -133,6 +239,106 @@ func TestFinalsEnd(t *testing.T) {
 	}
 }
 
+func TestFinalsStopped(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := WithStop(context.Background())
+	cancel()
+
+	plan := &workflow.Plan{State: &workflow.State{Status: workflow.Running}}
+	req := statemachine.Request[Data]{Ctx: ctx, Data: Data{Plan: plan}}
+	f := finalStates{}
+	req = f.start(req)
+	if methodName(req.Next) != methodName(f.stopped) {
+		t.Fatalf("TestFinalsStopped: got next == %v, want next == %v", methodName(req.Next), methodName(f.stopped))
+	}
+	req = req.Next(req)
+	if req.Data.Plan.State.Status != workflow.Stopped {
+		t.Errorf("TestFinalsStopped: expected plan to be stopped, got %s", req.Data.Plan.State.Status)
+	}
+	if req.Data.Plan.Reason != workflow.FRStopped {
+		t.Errorf("TestFinalsStopped: expected plan reason FRStopped, got %s", req.Data.Plan.Reason)
+	}
+	if !errors.Is(req.Err, errStopped) {
+		t.Errorf("TestFinalsStopped: got err == %v, want err == %v", req.Err, errStopped)
+	}
+}
+
+func TestFinalsTimedOut(t *testing.T) {
+	t.Parallel()
+
+	planID := workflow.NewV7()
+
+	tests := []struct {
+		name     string
+		causeID  uuid.UUID
+		wantNext string
+	}{
+		{
+			name:     "plan timed out",
+			causeID:  planID,
+			wantNext: methodName(finalStates{}.timedOut),
+		},
+		{
+			name:     "the Block running the plan as a SubPlan timed out",
+			causeID:  workflow.NewV7(),
+			wantNext: methodName(finalStates{}.timedOut),
+		},
+	}
+
+	for _, test := range tests {
+		te := timeoutError{Type: workflow.OTPlan, ID: test.causeID, Name: "plan", Timeout: time.Minute}
+		ctx, cancel := context.WithDeadlineCause(context.Background(), time.Now().Add(-time.Second), te)
+		defer cancel()
+
+		plan := &workflow.Plan{ID: planID, State: &workflow.State{Status: workflow.Running}}
+		req := statemachine.Request[Data]{Ctx: ctx, Data: Data{Plan: plan, err: errStopped}}
+		f := finalStates{}
+		req = f.start(req)
+		if methodName(req.Next) != test.wantNext {
+			t.Errorf("TestFinalsTimedOut(%s): got next == %v, want next == %v", test.name, methodName(req.Next), test.wantNext)
+			continue
+		}
+		if test.wantNext != methodName(f.timedOut) {
+			continue
+		}
+		req = req.Next(req)
+		if plan.State.Status != workflow.Failed {
+			t.Errorf("TestFinalsTimedOut(%s): got status == %s, want status == %s", test.name, plan.State.Status, workflow.Failed)
+		}
+		if plan.Reason != workflow.FRTimeout {
+			t.Errorf("TestFinalsTimedOut(%s): got reason == %s, want reason == %s", test.name, plan.Reason, workflow.FRTimeout)
+		}
+		if !errors.Is(req.Err, errTimeout) {
+			t.Errorf("TestFinalsTimedOut(%s): got err == %v, want err == %v", test.name, req.Err, errTimeout)
+		}
+	}
+}
+
+func TestFinalsLocked(t *testing.T) {
+	t.Parallel()
+
+	plan := &workflow.Plan{ID: workflow.NewV7(), State: &workflow.State{Status: workflow.Running}}
+	lockErr := fmt.Errorf("could not acquire locks: %w", storage.ErrLocked)
+	req := statemachine.Request[Data]{Ctx: context.Background(), Data: Data{Plan: plan, err: lockErr}}
+
+	f := finalStates{}
+	req = f.start(req)
+	if methodName(req.Next) != methodName(f.locked) {
+		t.Fatalf("TestFinalsLocked: got next == %v, want next == %v", methodName(req.Next), methodName(f.locked))
+	}
+	req = req.Next(req)
+	if plan.State.Status != workflow.Failed {
+		t.Errorf("TestFinalsLocked: got status == %s, want status == %s", plan.State.Status, workflow.Failed)
+	}
+	if plan.Reason != workflow.FRLocked {
+		t.Errorf("TestFinalsLocked: got reason == %s, want reason == %s", plan.Reason, workflow.FRLocked)
+	}
+	if !errors.Is(req.Err, storage.ErrLocked) {
+		t.Errorf("TestFinalsLocked: got err == %v, want err == %v", req.Err, storage.ErrLocked)
+	}
+}
+
 func TestExamineChecks(t *testing.T) {
 	t.Parallel()
 
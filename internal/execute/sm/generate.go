@@ -0,0 +1,76 @@
+package sm
+
+import (
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+
+	"github.com/gostdlib/base/statemachine"
+	"github.com/gostdlib/base/telemetry/log"
+)
+
+// BlockGenerate runs the Generator of the current block. The Sequences it generates are added to the
+// Block and written to the store before ExecuteSequences runs them.
+func (s *States) BlockGenerate(req statemachine.Request[Data]) statemachine.Request[Data] {
+	h := req.Data.blocks[0]
+	req.Next = s.ExecuteSequences
+
+	g := h.block.Generator
+	// A Completed Generator was recovered and its Sequences are already in the Block.
+	if g == nil || g.State.Status == workflow.Completed {
+		return req
+	}
+
+	fail := func(err error) statemachine.Request[Data] {
+		h.block.State.Status = workflow.Failed
+		req.Data.err = err
+		if hErr := halted(req.Ctx); hErr != nil {
+			h.block.State.Status = workflow.Stopped
+			req.Data.err = hErr
+		}
+		req.Next = s.BlockDeferredChecks
+		return req
+	}
+
+	if err := s.runAction(req.Ctx, g, generatorUpdater{ActionUpdater: s.store}); err != nil {
+		return fail(err)
+	}
+
+	// In a dry run, a Generator whose plugin does not implement plugins.DryRunner has no response,
+	// so there are no Sequences to add.
+	if context.DryRun(req.Ctx) {
+		if final := g.FinalAttempt(); final == nil || final.Resp == nil {
+			return req
+		}
+	}
+
+	seqs, err := h.block.Generate(s.registry)
+	if err != nil {
+		g.State.Status = workflow.Failed
+		eventsFrom(req.Ctx).action(g)
+		if err := s.store.UpdateAction(req.Ctx, g); err != nil {
+			log.Fatalf("failed to write Action: %v", err)
+		}
+		return fail(err)
+	}
+
+	if err := s.store.AddSequences(context.WithoutCancel(req.Ctx), h.block, seqs); err != nil {
+		log.Fatalf("failed to write generated Sequences: %v", err)
+	}
+	return req
+}
+
+// generatorUpdater is the storage.ActionUpdater used to run a Block's Generator. It does not write
+// the Generator once it is Completed. That is written by storage.BlockUpdater.AddSequences() with the
+// Sequences that were generated, so a recovered Generator is never Completed without its Sequences.
+type generatorUpdater struct {
+	storage.ActionUpdater
+}
+
+// UpdateAction implements storage.ActionUpdater.UpdateAction().
+func (u generatorUpdater) UpdateAction(ctx context.Context, a *workflow.Action) error {
+	if a.State.Status == workflow.Completed {
+		return nil
+	}
+	return u.ActionUpdater.UpdateAction(ctx, a)
+}
@@ -0,0 +1,146 @@
+package sm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/element-of-surprise/coercion/internal/execute/sm/testing/plugins"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/gostdlib/base/statemachine"
+)
+
+type genResp struct {
+	seqs []*workflow.Sequence
+}
+
+func (g genResp) Sequences() []*workflow.Sequence {
+	return g.seqs
+}
+
+func TestBlockGenerate(t *testing.T) {
+	t.Parallel()
+
+	plug := &plugins.Plugin{AlwaysRespond: true}
+	reg := registry.New()
+	reg.Register(plug)
+
+	seqs := func() []*workflow.Sequence {
+		return []*workflow.Sequence{
+			{Name: "gen0", Descr: "gen0", Actions: []*workflow.Action{{Name: "action", Descr: "action", Plugin: plugins.Name, Req: plugins.Req{}}}},
+			{Name: "gen1", Descr: "gen1", Actions: []*workflow.Action{{Name: "action", Descr: "action", Plugin: plugins.Name, Req: plugins.Req{}}}},
+		}
+	}
+
+	// runner acts like the action statemachine, which records the response and writes the final state.
+	runner := func(resp any, err error) actionRunner {
+		return func(ctx context.Context, action *workflow.Action, updater storage.ActionUpdater) error {
+			action.Attempts = append(action.Attempts, &workflow.Attempt{Resp: resp})
+			action.State.Status = workflow.Completed
+			if err != nil {
+				action.State.Status = workflow.Failed
+			}
+			if err := updater.UpdateAction(ctx, action); err != nil {
+				return err
+			}
+			return err
+		}
+	}
+
+	tests := []struct {
+		name          string
+		generator     *workflow.Action
+		runner        actionRunner
+		wantAdded     int
+		wantStatus    workflow.Status
+		wantGenStatus workflow.Status
+		wantWrites    int
+		wantNext      statemachine.State[Data]
+		wantErr       bool
+	}{
+		{
+			name:       "No Generator",
+			wantStatus: workflow.Running,
+			wantNext:   (&States{}).ExecuteSequences,
+		},
+		{
+			name:          "Generator already Completed",
+			generator:     &workflow.Action{Name: "generator", State: &workflow.State{Status: workflow.Completed}},
+			wantStatus:    workflow.Running,
+			wantGenStatus: workflow.Completed,
+			wantNext:      (&States{}).ExecuteSequences,
+		},
+		{
+			name:          "Success",
+			generator:     &workflow.Action{Name: "generator", State: &workflow.State{Status: workflow.NotStarted}},
+			runner:        runner(genResp{seqs: seqs()}, nil),
+			wantAdded:     2,
+			wantStatus:    workflow.Running,
+			wantGenStatus: workflow.Completed,
+			// The Completed Generator is only written with the Sequences.
+			wantWrites: 1,
+			wantNext:   (&States{}).ExecuteSequences,
+		},
+		{
+			name:          "Error: Generator failed",
+			generator:     &workflow.Action{Name: "generator", State: &workflow.State{Status: workflow.NotStarted}},
+			runner:        runner(nil, fmt.Errorf("error")),
+			wantStatus:    workflow.Failed,
+			wantGenStatus: workflow.Failed,
+			wantWrites:    1,
+			wantNext:      (&States{}).BlockDeferredChecks,
+			wantErr:       true,
+		},
+		{
+			name:          "Error: response does not implement Generated",
+			generator:     &workflow.Action{Name: "generator", State: &workflow.State{Status: workflow.NotStarted}},
+			runner:        runner(plugins.Resp{}, nil),
+			wantStatus:    workflow.Failed,
+			wantGenStatus: workflow.Failed,
+			wantWrites:    1,
+			wantNext:      (&States{}).BlockDeferredChecks,
+			wantErr:       true,
+		},
+	}
+
+	for _, test := range tests {
+		store := &fakeUpdater{}
+		states := &States{store: store, registry: reg, actionRunner: test.runner}
+
+		b := &workflow.Block{
+			Name:      "block",
+			Generator: test.generator,
+			State:     &workflow.State{Status: workflow.Running},
+		}
+		req := statemachine.Request[Data]{
+			Ctx:  context.Background(),
+			Data: Data{blocks: []block{{block: b}}},
+		}
+
+		req = states.BlockGenerate(req)
+
+		if methodName(req.Next) != methodName(test.wantNext) {
+			t.Errorf("TestBlockGenerate(%s): got req.Next == %s, want req.Next == %s", test.name, methodName(req.Next), methodName(test.wantNext))
+		}
+		if (req.Data.err != nil) != test.wantErr {
+			t.Errorf("TestBlockGenerate(%s): got err == %v, wantErr == %v", test.name, req.Data.err, test.wantErr)
+		}
+		if b.State.Status != test.wantStatus {
+			t.Errorf("TestBlockGenerate(%s): got block status == %v, want %v", test.name, b.State.Status, test.wantStatus)
+		}
+		if len(b.Sequences) != test.wantAdded || len(store.added) != test.wantAdded {
+			t.Errorf("TestBlockGenerate(%s): got %d Sequences in Block and %d written, want %d", test.name, len(b.Sequences), len(store.added), test.wantAdded)
+		}
+		if len(store.actions) != test.wantWrites {
+			t.Errorf("TestBlockGenerate(%s): got %d Generator writes, want %d", test.name, len(store.actions), test.wantWrites)
+		}
+		if len(store.actions) > 0 && store.actions[len(store.actions)-1].State.Status != test.wantGenStatus {
+			t.Errorf("TestBlockGenerate(%s): got written Generator status == %v, want %v", test.name, store.actions[len(store.actions)-1].State.Status, test.wantGenStatus)
+		}
+		if test.generator != nil && test.generator.State.Status != test.wantGenStatus {
+			t.Errorf("TestBlockGenerate(%s): got Generator status == %v, want %v", test.name, test.generator.State.Status, test.wantGenStatus)
+		}
+	}
+}
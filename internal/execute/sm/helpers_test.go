@@ -2,6 +2,7 @@ package sm
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -252,6 +253,43 @@ func TestExecSeq(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			// A recovered Sequence does not run the Actions that already completed.
+			name: "recovered seq skips completed actions",
+			seq: &workflow.Sequence{
+				Name:    "seq",
+				Actions: []*workflow.Action{{Name: "error", State: &workflow.State{Status: workflow.Completed}}, {Name: "action"}},
+				State:   &workflow.State{},
+			},
+			wantSeq: &workflow.Sequence{
+				Name:    "seq",
+				Actions: []*workflow.Action{{Name: "error", State: &workflow.State{Status: workflow.Completed}}, {Name: "action"}},
+				State: &workflow.State{
+					Status: workflow.Completed,
+					Start:  start,
+					End:    end,
+				},
+			},
+			dbUpdates: []*workflow.Sequence{
+				{
+					Name:    "seq",
+					Actions: []*workflow.Action{{Name: "error", State: &workflow.State{Status: workflow.Completed}}, {Name: "action"}},
+					State: &workflow.State{
+						Status: workflow.Running,
+						Start:  start,
+					},
+				},
+				{
+					Name:    "seq",
+					Actions: []*workflow.Action{{Name: "error", State: &workflow.State{Status: workflow.Completed}}, {Name: "action"}},
+					State: &workflow.State{
+						Status: workflow.Completed,
+						Start:  start,
+						End:    end,
+					},
+				},
+			},
+		},
 		{
 			name: "seq completed",
 			seq: &workflow.Sequence{
@@ -319,6 +357,57 @@ func TestExecSeq(t *testing.T) {
 	}
 }
 
+func TestExecSeqTimeout(t *testing.T) {
+	t.Parallel()
+
+	seq := &workflow.Sequence{
+		ID:      workflow.NewV7(),
+		Name:    "seq",
+		Actions: []*workflow.Action{{Name: "action"}, {Name: "wait"}, {Name: "action"}},
+		Timeout: 10 * time.Millisecond,
+		State:   &workflow.State{},
+	}
+	updater := &fakeUpdater{}
+	states := &States{store: updater, actionRunner: fakeActionRunner}
+
+	err := states.execSeq(context.Background(), seq)
+	if !errors.Is(err, errTimeout) {
+		t.Fatalf("TestExecSeqTimeout: got err == %v, want err == %v", err, errTimeout)
+	}
+	if seq.State.Status != workflow.Failed {
+		t.Errorf("TestExecSeqTimeout: got status == %s, want status == %s", seq.State.Status, workflow.Failed)
+	}
+	if len(updater.seqs) != 2 || updater.seqs[1].State.Status != workflow.Failed {
+		t.Errorf("TestExecSeqTimeout: expected the Failed Sequence to be written, got %d writes", len(updater.seqs))
+	}
+
+	// A stopped parent Context is a stop, not a timeout.
+	seq.State = &workflow.State{}
+	ctx, cancel := WithStop(context.Background())
+	cancel()
+	err = states.execSeq(ctx, seq)
+	if !errors.Is(err, errStopped) {
+		t.Errorf("TestExecSeqTimeout(stopped): got err == %v, want err == %v", err, errStopped)
+	}
+	if seq.State.Status != workflow.Stopped {
+		t.Errorf("TestExecSeqTimeout(stopped): got status == %s, want status == %s", seq.State.Status, workflow.Stopped)
+	}
+
+	// A Block that timed out stops the Sequence, but that is not recorded as a user stop.
+	seq.State = &workflow.State{}
+	blockID := workflow.NewV7()
+	ctx, cancel = withTimeout(context.Background(), workflow.OTBlock, blockID, "block", time.Now().Add(-time.Hour), time.Minute)
+	defer cancel()
+	err = states.execSeq(ctx, seq)
+	var te timeoutError
+	if !errors.As(err, &te) || te.ID != blockID {
+		t.Errorf("TestExecSeqTimeout(block timeout): got err == %v, want the Block's timeoutError", err)
+	}
+	if seq.State.Status != workflow.Stopped {
+		t.Errorf("TestExecSeqTimeout(block timeout): got status == %s, want status == %s", seq.State.Status, workflow.Stopped)
+	}
+}
+
 func TestResetActions(t *testing.T) {
 	t.Parallel()
 
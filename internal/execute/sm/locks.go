@@ -0,0 +1,65 @@
+package sm
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/google/uuid"
+
+	"github.com/gostdlib/base/telemetry/log"
+)
+
+// lockPoll is how often we retry getting locks that are held by another object when the LockPolicy is LPWait.
+var lockPoll = 1 * time.Second
+
+// lock acquires the Locks for owner, which belongs to the Plan with planID. If another object holds one of
+// the locks and the LockPolicy is LPWait, this retries until it gets the locks or the Context is cancelled,
+// in which case the Context's error is returned. If the LockPolicy is LPFail, this returns an error that
+// wraps storage.ErrLocked. A dry run does not lock.
+func (s *States) lock(ctx context.Context, owner, planID uuid.UUID, locks *workflow.Locks) error {
+	if locks == nil || context.DryRun(ctx) {
+		return nil
+	}
+
+	for {
+		err := s.store.Lock(ctx, owner, planID, locks.Names)
+		switch {
+		case err == nil:
+			return nil
+		case !errors.Is(err, storage.ErrLocked):
+			log.Fatalf("failed to acquire locks: %v", err)
+		case locks.Policy == workflow.LPFail:
+			return fmt.Errorf("could not acquire locks(%v): %w", locks.Names, err)
+		}
+
+		if err := after(ctx, lockPoll); err != nil {
+			return err
+		}
+	}
+}
+
+// unlock releases the Locks held by owner. A dry run does not lock, so there is nothing to unlock.
+func (s *States) unlock(ctx context.Context, owner uuid.UUID, locks *workflow.Locks) {
+	if locks == nil || context.DryRun(ctx) {
+		return
+	}
+	if err := s.store.Unlock(context.WithoutCancel(ctx), owner, locks.Names); err != nil {
+		log.Fatalf("failed to release locks: %v", err)
+	}
+}
+
+// unlockAll releases the Locks held by the Plan and all of its Blocks and Sequences. Releasing a lock
+// that is not held does nothing, so this is safe to call when the Plan ends no matter how it ended.
+func (s *States) unlockAll(ctx context.Context, plan *workflow.Plan) {
+	s.unlock(ctx, plan.ID, plan.Locks)
+	for _, b := range plan.Blocks {
+		s.unlock(ctx, b.ID, b.Locks)
+		for _, seq := range b.Sequences {
+			s.unlock(ctx, seq.ID, seq.Locks)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package sm
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/google/uuid"
+)
+
+// fakeLocker is a fakeUpdater that also holds locks in memory.
+type fakeLocker struct {
+	mu    sync.Mutex
+	locks map[string]uuid.UUID
+
+	*fakeUpdater
+}
+
+func newFakeLocker() *fakeLocker {
+	return &fakeLocker{locks: map[string]uuid.UUID{}, fakeUpdater: &fakeUpdater{}}
+}
+
+func (f *fakeLocker) Lock(ctx context.Context, owner, planID uuid.UUID, names []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, n := range names {
+		if held, ok := f.locks[n]; ok && held != owner {
+			return storage.ErrLocked
+		}
+	}
+	for _, n := range names {
+		f.locks[n] = owner
+	}
+	return nil
+}
+
+func (f *fakeLocker) Unlock(ctx context.Context, owner uuid.UUID, names []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, n := range names {
+		if f.locks[n] == owner {
+			delete(f.locks, n)
+		}
+	}
+	return nil
+}
+
+func (f *fakeLocker) held(name string) (uuid.UUID, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	owner, ok := f.locks[name]
+	return owner, ok
+}
+
+func TestLock(t *testing.T) {
+	t.Parallel()
+
+	holder := workflow.NewV7()
+	owner := workflow.NewV7()
+	planID := workflow.NewV7()
+
+	tests := []struct {
+		name    string
+		locks   *workflow.Locks
+		ctx     func() (context.Context, context.CancelFunc)
+		release bool
+		wantErr error
+	}{
+		{
+			name:  "nil Locks",
+			locks: nil,
+		},
+		{
+			name:  "lock is free",
+			locks: &workflow.Locks{Names: []string{"host/def"}},
+		},
+		{
+			name:    "LPFail and lock is held",
+			locks:   &workflow.Locks{Names: []string{"host/abc"}, Policy: workflow.LPFail},
+			wantErr: storage.ErrLocked,
+		},
+		{
+			name:    "LPWait and lock is released",
+			locks:   &workflow.Locks{Names: []string{"host/abc"}},
+			release: true,
+		},
+		{
+			name:  "LPWait and Context is cancelled",
+			locks: &workflow.Locks{Names: []string{"host/abc"}},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name:  "dry run does not lock",
+			locks: &workflow.Locks{Names: []string{"host/abc"}, Policy: workflow.LPFail},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.SetDryRun(context.Background()))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		store := newFakeLocker()
+		store.Lock(context.Background(), holder, planID, []string{"host/abc"})
+		states := &States{store: store}
+
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if test.ctx != nil {
+			ctx, cancel = test.ctx()
+		}
+		defer cancel()
+
+		if test.release {
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				store.Unlock(context.Background(), holder, []string{"host/abc"})
+			}()
+		}
+
+		err := states.lock(ctx, owner, planID, test.locks)
+		switch {
+		case test.wantErr == nil && err != nil:
+			t.Errorf("TestLock(%s): got err == %v, want err == nil", test.name, err)
+			continue
+		case test.wantErr != nil && !errors.Is(err, test.wantErr):
+			t.Errorf("TestLock(%s): got err == %v, want err == %v", test.name, err, test.wantErr)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if test.locks == nil || context.DryRun(ctx) {
+			continue
+		}
+		for _, n := range test.locks.Names {
+			if got, _ := store.held(n); got != owner {
+				t.Errorf("TestLock(%s): lock(%s) held by %s, want %s", test.name, n, got, owner)
+			}
+		}
+	}
+}
+
+func TestUnlockAll(t *testing.T) {
+	t.Parallel()
+
+	seq := &workflow.Sequence{ID: workflow.NewV7(), Locks: &workflow.Locks{Names: []string{"host/abc"}}}
+	block := &workflow.Block{ID: workflow.NewV7(), Locks: &workflow.Locks{Names: []string{"cluster/east-1"}}, Sequences: []*workflow.Sequence{seq}}
+	plan := &workflow.Plan{ID: workflow.NewV7(), Locks: &workflow.Locks{Names: []string{"region/east"}}, Blocks: []*workflow.Block{block}}
+	other := workflow.NewV7()
+
+	store := newFakeLocker()
+	states := &States{store: store}
+	ctx := context.Background()
+
+	for _, l := range []struct {
+		owner uuid.UUID
+		names []string
+	}{
+		{plan.ID, plan.Locks.Names},
+		{block.ID, block.Locks.Names},
+		{seq.ID, seq.Locks.Names},
+		{other, []string{"host/def"}},
+	} {
+		if err := states.lock(ctx, l.owner, plan.ID, &workflow.Locks{Names: l.names}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	states.unlockAll(ctx, plan)
+
+	for _, n := range []string{"region/east", "cluster/east-1", "host/abc"} {
+		if _, ok := store.held(n); ok {
+			t.Errorf("TestUnlockAll: lock(%s) is still held", n)
+		}
+	}
+	if got, _ := store.held("host/def"); got != other {
+		t.Errorf("TestUnlockAll: lock(host/def) held by %s, want %s", got, other)
+	}
+}
+
+func TestExecSeqLocked(t *testing.T) {
+	t.Parallel()
+
+	seq := &workflow.Sequence{
+		ID:      workflow.NewV7(),
+		Name:    "seq",
+		Actions: []*workflow.Action{{Name: "action", State: &workflow.State{}}},
+		Locks:   &workflow.Locks{Names: []string{"host/abc"}, Policy: workflow.LPFail},
+		State:   &workflow.State{},
+	}
+	store := newFakeLocker()
+	states := &States{store: store, actionRunner: fakeActionRunner}
+	holder := workflow.NewV7()
+	ctx := context.Background()
+
+	store.Lock(ctx, holder, workflow.NewV7(), []string{"host/abc"})
+	err := states.execSeq(ctx, seq)
+	if !errors.Is(err, storage.ErrLocked) {
+		t.Fatalf("TestExecSeqLocked: got err == %v, want err == %v", err, storage.ErrLocked)
+	}
+	if seq.State.Status != workflow.Failed {
+		t.Errorf("TestExecSeqLocked: got status == %s, want status == %s", seq.State.Status, workflow.Failed)
+	}
+
+	// Once the lock is free, the Sequence runs and releases its lock when it is done.
+	store.Unlock(ctx, holder, []string{"host/abc"})
+	seq.State = &workflow.State{}
+	if err := states.execSeq(ctx, seq); err != nil {
+		t.Fatalf("TestExecSeqLocked(free): got err == %v, want err == nil", err)
+	}
+	if seq.State.Status != workflow.Completed {
+		t.Errorf("TestExecSeqLocked(free): got status == %s, want status == %s", seq.State.Status, workflow.Completed)
+	}
+	if _, ok := store.held("host/abc"); ok {
+		t.Errorf("TestExecSeqLocked(free): lock was not released")
+	}
+}
@@ -0,0 +1,215 @@
+package sm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+
+	"github.com/gostdlib/base/statemachine"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// metrics holds the instruments that record a Plan's execution. A nil *metrics records nothing, which
+// is what a States that was not given a Meter with WithMeter() uses.
+type metrics struct {
+	plans      metric.Int64Counter
+	running    metric.Int64UpDownCounter
+	blocks     metric.Int64Counter
+	sequences  metric.Int64Counter
+	actions    metric.Int64Counter
+	actionDur  metric.Float64Histogram
+	attemptDur metric.Float64Histogram
+	retries    metric.Int64Histogram
+	contFailed metric.Int64Counter
+	tolerated  metric.Float64Histogram
+}
+
+// newMetrics creates the instruments from m.
+func newMetrics(m metric.Meter) (*metrics, error) {
+	met := &metrics{}
+	var err, e error
+
+	met.plans, e = m.Int64Counter(
+		"coercion.plans",
+		metric.WithDescription("The number of Plans that ended, by final status."),
+	)
+	err = errors.Join(err, e)
+	met.running, e = m.Int64UpDownCounter(
+		"coercion.plans.running",
+		metric.WithDescription("The number of Plans that are currently running."),
+	)
+	err = errors.Join(err, e)
+	met.blocks, e = m.Int64Counter(
+		"coercion.blocks",
+		metric.WithDescription("The number of Blocks that ended, by final status."),
+	)
+	err = errors.Join(err, e)
+	met.sequences, e = m.Int64Counter(
+		"coercion.sequences",
+		metric.WithDescription("The number of Sequences that ended, by final status."),
+	)
+	err = errors.Join(err, e)
+	met.actions, e = m.Int64Counter(
+		"coercion.actions",
+		metric.WithDescription("The number of Actions that ended, by plugin and final status."),
+	)
+	err = errors.Join(err, e)
+	met.actionDur, e = m.Float64Histogram(
+		"coercion.action.duration",
+		metric.WithDescription("The time an Action ran, including all of its Attempts, by plugin."),
+		metric.WithUnit("s"),
+	)
+	err = errors.Join(err, e)
+	met.attemptDur, e = m.Float64Histogram(
+		"coercion.attempt.duration",
+		metric.WithDescription("The time each Attempt of an Action ran, by plugin and status."),
+		metric.WithUnit("s"),
+	)
+	err = errors.Join(err, e)
+	met.retries, e = m.Int64Histogram(
+		"coercion.action.retries",
+		metric.WithDescription("The number of times an Action was retried before it ended, by plugin."),
+	)
+	err = errors.Join(err, e)
+	met.contFailed, e = m.Int64Counter(
+		"coercion.contchecks.failures",
+		metric.WithDescription("The number of times ContChecks failed."),
+	)
+	err = errors.Join(err, e)
+	met.tolerated, e = m.Float64Histogram(
+		"coercion.block.tolerated_failures.consumed",
+		metric.WithDescription(
+			"The fraction of a Block's tolerated failures that its failed Sequences used. "+
+				"Over 1 means the Block exceeded them.",
+		),
+	)
+	err = errors.Join(err, e)
+
+	if err != nil {
+		return nil, err
+	}
+	return met, nil
+}
+
+// WithMeter sets the metric.Meter that records a Plan's execution. If this is not set, no metrics are recorded.
+func WithMeter(m metric.Meter) Option {
+	return func(s *States) error {
+		if m == nil {
+			return fmt.Errorf("meter cannot be nil")
+		}
+		met, err := newMetrics(m)
+		if err != nil {
+			return fmt.Errorf("could not create metrics: %w", err)
+		}
+		s.metrics = met
+		return nil
+	}
+}
+
+// planStarted records that the Plan in req is running. Only Plans that have been recorded as started are
+// recorded by planEnded(), so a Plan that had already ended when it was recovered is not counted twice.
+func (m *metrics) planStarted(req *statemachine.Request[Data]) {
+	if m == nil {
+		return
+	}
+	req.Data.running = true
+	m.running.Add(req.Ctx, 1)
+}
+
+// planEnded records the final status of the Plan in req. A Plan that was drained is no longer running, but
+// its status is not recorded as it has not ended. It is recorded by the recovery that resumes it.
+func (m *metrics) planEnded(req statemachine.Request[Data], drained bool) {
+	if m == nil || !req.Data.running {
+		return
+	}
+	ctx := context.WithoutCancel(req.Ctx)
+	m.running.Add(ctx, -1)
+	if drained {
+		return
+	}
+	m.plans.Add(ctx, 1, metric.WithAttributes(statusAttr(req.Data.Plan.State.Status)))
+}
+
+// blockEnded records the final status of b. Nothing is recorded for a Block that was drained.
+func (m *metrics) blockEnded(ctx context.Context, b *workflow.Block, drained bool) {
+	if m == nil || drained {
+		return
+	}
+	m.blocks.Add(context.WithoutCancel(ctx), 1, metric.WithAttributes(statusAttr(b.State.Status)))
+}
+
+// seqEnded records the final status of seq. Nothing is recorded for a Sequence that was drained.
+func (m *metrics) seqEnded(ctx context.Context, seq *workflow.Sequence, drained bool) {
+	if m == nil || drained {
+		return
+	}
+	m.sequences.Add(context.WithoutCancel(ctx), 1, metric.WithAttributes(statusAttr(seq.State.Status)))
+}
+
+// actionEnded records the final status, duration and retries of a. This is called each time an Action runs,
+// so the Attempts of ContChecks are recorded before they are reset for the next run.
+func (m *metrics) actionEnded(ctx context.Context, a *workflow.Action) {
+	if m == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	plugin := attribute.String(attrPlugin, a.Plugin)
+
+	m.actions.Add(ctx, 1, metric.WithAttributes(plugin, statusAttr(a.State.Status)))
+	if !a.State.Start.IsZero() && !a.State.End.IsZero() {
+		m.actionDur.Record(ctx, a.State.End.Sub(a.State.Start).Seconds(), metric.WithAttributes(plugin))
+	}
+	if len(a.Attempts) > 0 {
+		m.retries.Record(ctx, int64(len(a.Attempts)-1), metric.WithAttributes(plugin))
+	}
+}
+
+// contChecksFailed records that ContChecks failed.
+func (m *metrics) contChecksFailed(ctx context.Context) {
+	if m == nil {
+		return
+	}
+	m.contFailed.Add(context.WithoutCancel(ctx), 1)
+}
+
+// toleratedFailures records how much of the tolerated failures of b were used by failed of the started
+// Sequences. Nothing is recorded for a Block that tolerates no failures or any number of failures.
+func (m *metrics) toleratedFailures(ctx context.Context, b *workflow.Block, failed, started int) {
+	if m == nil {
+		return
+	}
+	// This mirrors workflow.Block.ExceedsToleratedFailures().
+	var allowed float64
+	if b.ToleratedFailurePercent == 0 {
+		if b.ToleratedFailures < 0 {
+			return
+		}
+		allowed = float64(b.ToleratedFailures)
+	} else {
+		total := len(b.Sequences)
+		if b.ToleratedFailureSample > 0 && started >= b.ToleratedFailureSample {
+			total = started
+		}
+		allowed = b.ToleratedFailurePercent / 100 * float64(total)
+	}
+	if allowed == 0 {
+		return
+	}
+	m.tolerated.Record(context.WithoutCancel(ctx), float64(failed)/allowed)
+}
+
+// attemptDuration returns the histogram that the actions.Runner records the duration of each Attempt in.
+func (m *metrics) attemptDuration() metric.Float64Histogram {
+	if m == nil {
+		return nil
+	}
+	return m.attemptDur
+}
+
+// statusAttr returns the attribute for status.
+func statusAttr(status workflow.Status) attribute.KeyValue {
+	return attribute.String(attrStatus, status.String())
+}
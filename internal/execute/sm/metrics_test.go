@@ -0,0 +1,169 @@
+package sm
+
+import (
+	"testing"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+
+	"github.com/gostdlib/base/statemachine"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func newTestMetrics(t *testing.T) (*metrics, *sdkmetric.ManualReader) {
+	t.Helper()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	m, err := newMetrics(mp.Meter("test"))
+	if err != nil {
+		t.Fatalf("newMetrics() returned error: %v", err)
+	}
+	return m, reader
+}
+
+// collect returns the metrics in reader by name.
+func collect(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Aggregation {
+	t.Helper()
+
+	rm := metricdata.ResourceMetrics{}
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() returned error: %v", err)
+	}
+	got := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			got[m.Name] = m.Data
+		}
+	}
+	return got
+}
+
+func TestPlanMetrics(t *testing.T) {
+	t.Parallel()
+
+	m, reader := newTestMetrics(t)
+	plan := &workflow.Plan{State: &workflow.State{Status: workflow.Completed}}
+
+	// A Plan that was already finished when it was recovered is not recorded.
+	m.planEnded(statemachine.Request[Data]{Ctx: context.Background(), Data: Data{Plan: plan}}, false)
+	if _, ok := collect(t, reader)["coercion.plans"]; ok {
+		t.Fatalf("TestPlanMetrics: Plan that was not started was recorded")
+	}
+
+	req := statemachine.Request[Data]{Ctx: context.Background(), Data: Data{Plan: plan}}
+	m.planStarted(&req)
+	if got := collect(t, reader)["coercion.plans.running"].(metricdata.Sum[int64]).DataPoints[0].Value; got != 1 {
+		t.Errorf("TestPlanMetrics: got %d running Plans, want 1", got)
+	}
+	m.planEnded(req, false)
+
+	got := collect(t, reader)
+	if v := got["coercion.plans.running"].(metricdata.Sum[int64]).DataPoints[0].Value; v != 0 {
+		t.Errorf("TestPlanMetrics: got %d running Plans after End, want 0", v)
+	}
+	points := got["coercion.plans"].(metricdata.Sum[int64]).DataPoints
+	if len(points) != 1 || points[0].Value != 1 {
+		t.Fatalf("TestPlanMetrics: got Plan counts %+v, want a single count of 1", points)
+	}
+	if v, _ := points[0].Attributes.Value(attrStatus); v.AsString() != workflow.Completed.String() {
+		t.Errorf("TestPlanMetrics: got status attribute %q, want %q", v.AsString(), workflow.Completed)
+	}
+
+	// A drained Plan is no longer running, but has not ended.
+	m, reader = newTestMetrics(t)
+	plan.State.Status = workflow.Failed
+	req = statemachine.Request[Data]{Ctx: context.Background(), Data: Data{Plan: plan}}
+	m.planStarted(&req)
+	m.planEnded(req, true)
+	got = collect(t, reader)
+	if v := got["coercion.plans.running"].(metricdata.Sum[int64]).DataPoints[0].Value; v != 0 {
+		t.Errorf("TestPlanMetrics: got %d running Plans after a drain, want 0", v)
+	}
+	if _, ok := got["coercion.plans"]; ok {
+		t.Errorf("TestPlanMetrics: drained Plan was recorded as ended")
+	}
+
+	// A States without metrics records nothing.
+	var none *metrics
+	none.planStarted(&req)
+	none.planEnded(req, false)
+	none.actionEnded(context.Background(), &workflow.Action{State: &workflow.State{}})
+	if none.attemptDuration() != nil {
+		t.Errorf("TestPlanMetrics: nil metrics returned an attempt duration histogram")
+	}
+}
+
+func TestToleratedFailuresMetric(t *testing.T) {
+	t.Parallel()
+
+	seqs := make([]*workflow.Sequence, 10)
+
+	tests := []struct {
+		name    string
+		block   *workflow.Block
+		failed  int
+		started int
+		want    float64
+		none    bool
+	}{
+		{
+			name:   "ToleratedFailures",
+			block:  &workflow.Block{ToleratedFailures: 4, Sequences: seqs},
+			failed: 1,
+			want:   0.25,
+		},
+		{
+			name:   "Exceeded ToleratedFailures",
+			block:  &workflow.Block{ToleratedFailures: 2, Sequences: seqs},
+			failed: 3,
+			want:   1.5,
+		},
+		{
+			name:   "ToleratedFailurePercent",
+			block:  &workflow.Block{ToleratedFailurePercent: 20, Sequences: seqs},
+			failed: 1,
+			want:   0.5,
+		},
+		{
+			name:    "ToleratedFailureSample",
+			block:   &workflow.Block{ToleratedFailurePercent: 50, ToleratedFailureSample: 4, Sequences: seqs},
+			failed:  1,
+			started: 4,
+			want:    0.5,
+		},
+		{
+			name:   "No failures tolerated",
+			block:  &workflow.Block{Sequences: seqs},
+			failed: 1,
+			none:   true,
+		},
+		{
+			name:   "Unlimited failures tolerated",
+			block:  &workflow.Block{ToleratedFailures: -1, Sequences: seqs},
+			failed: 1,
+			none:   true,
+		},
+	}
+
+	for _, test := range tests {
+		m, reader := newTestMetrics(t)
+		m.toleratedFailures(context.Background(), test.block, test.failed, test.started)
+
+		data, ok := collect(t, reader)["coercion.block.tolerated_failures.consumed"]
+		switch {
+		case test.none && ok:
+			t.Errorf("TestToleratedFailuresMetric(%s): got a recording, want none", test.name)
+			continue
+		case test.none:
+			continue
+		case !ok:
+			t.Errorf("TestToleratedFailuresMetric(%s): got no recording, want %v", test.name, test.want)
+			continue
+		}
+		if got := data.(metricdata.Histogram[float64]).DataPoints[0].Sum; got != test.want {
+			t.Errorf("TestToleratedFailuresMetric(%s): got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
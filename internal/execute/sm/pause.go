@@ -0,0 +1,133 @@
+package sm
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+
+	"github.com/gostdlib/base/statemachine"
+)
+
+// Pauser is used to pause and resume a running Plan. A paused Plan does not start new Blocks or
+// Sequences, but anything that is already running is allowed to complete. ContChecks continue to run.
+// A Pauser is safe for concurrent use.
+type Pauser struct {
+	mu     sync.Mutex
+	paused bool
+	// resume is closed when the Plan is not paused.
+	resume chan struct{}
+	// write writes Plan.Paused to storage. If nil, nothing is written.
+	write func(ctx context.Context, paused bool) error
+}
+
+// NewPauser creates a new Pauser for plan. If Plan.Paused is set, the Plan will pause at the first
+// Block or Sequence boundary. This is used when recovering a Plan that was paused.
+func (s *States) NewPauser(plan *workflow.Plan) *Pauser {
+	return newPauser(
+		plan.Paused,
+		func(ctx context.Context, paused bool) error {
+			return s.setPaused(ctx, plan, paused)
+		},
+	)
+}
+
+// newPauser creates a new Pauser that writes Plan.Paused with write.
+func newPauser(paused bool, write func(ctx context.Context, paused bool) error) *Pauser {
+	p := &Pauser{paused: paused, resume: make(chan struct{}), write: write}
+	if !paused {
+		close(p.resume)
+	}
+	return p
+}
+
+// Pause pauses the Plan. Plan.Paused is written to storage before this returns, so that the pause
+// survives a restart. This returns false if the Plan was already paused.
+func (p *Pauser) Pause(ctx context.Context) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused {
+		return false, nil
+	}
+	if err := p.setPaused(ctx, true); err != nil {
+		return false, err
+	}
+	p.paused = true
+	p.resume = make(chan struct{})
+	return true, nil
+}
+
+// Resume resumes a paused Plan. Plan.Paused is cleared in storage before this returns. This returns
+// false if the Plan was not paused.
+func (p *Pauser) Resume(ctx context.Context) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.paused {
+		return false, nil
+	}
+	if err := p.setPaused(ctx, false); err != nil {
+		return false, err
+	}
+	p.paused = false
+	close(p.resume)
+	return true, nil
+}
+
+// setPaused writes Plan.Paused to storage. p.mu must be held.
+func (p *Pauser) setPaused(ctx context.Context, paused bool) error {
+	if p.write == nil {
+		return nil
+	}
+	return p.write(ctx, paused)
+}
+
+// resumed returns a channel that is closed when the Plan is not paused.
+func (p *Pauser) resumed() chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.resume
+}
+
+// waitPaused blocks while the Plan is paused. Plan.Paused has already been written by the Pauser,
+// this only waits for the Plan to be resumed. This returns early if the Plan is stopped.
+func (s *States) waitPaused(req statemachine.Request[Data]) {
+	if req.Data.Pauser == nil {
+		return
+	}
+
+	select {
+	case <-req.Ctx.Done():
+	case <-req.Data.Pauser.resumed():
+	}
+}
+
+// updatePlan writes plan to storage. Writes of Plans are serialized with setPaused().
+func (s *States) updatePlan(ctx context.Context, plan *workflow.Plan) error {
+	s.planMu.Lock()
+	defer s.planMu.Unlock()
+
+	return s.store.UpdatePlan(ctx, plan)
+}
+
+// setPaused sets Plan.Paused and writes it to storage while the Plan runs. The statemachine changes the
+// Plan's State without holding a lock, so the Plan is read back from storage and only Paused is changed
+// on the copy that is written.
+func (s *States) setPaused(ctx context.Context, plan *workflow.Plan, paused bool) error {
+	s.planMu.Lock()
+	defer s.planMu.Unlock()
+
+	stored, err := s.store.Read(ctx, plan.ID)
+	if err != nil {
+		return fmt.Errorf("could not read plan(%s): %w", plan.ID, err)
+	}
+	stored.Paused = paused
+	if err := s.store.UpdatePlan(context.WithoutCancel(ctx), stored); err != nil {
+		return fmt.Errorf("could not write plan(%s): %w", plan.ID, err)
+	}
+	plan.Paused = paused
+	return nil
+}
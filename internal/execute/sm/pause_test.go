@@ -0,0 +1,172 @@
+package sm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+
+	"github.com/google/uuid"
+	"github.com/gostdlib/base/statemachine"
+)
+
+func TestPauser(t *testing.T) {
+	t.Parallel()
+
+	var writes []bool
+	var writeErr error
+	p := newPauser(
+		false,
+		func(ctx context.Context, paused bool) error {
+			if writeErr != nil {
+				return writeErr
+			}
+			writes = append(writes, paused)
+			return nil
+		},
+	)
+	ctx := context.Background()
+
+	if ok, _ := p.Resume(ctx); ok {
+		t.Errorf("TestPauser: Resume() on unpaused Pauser: got true, want false")
+	}
+	if ok, _ := p.Pause(ctx); !ok {
+		t.Errorf("TestPauser: Pause() on unpaused Pauser: got false, want true")
+	}
+	if len(writes) != 1 || !writes[0] {
+		t.Errorf("TestPauser: Pause() returned without writing Paused == true, got writes %v", writes)
+	}
+	if ok, _ := p.Pause(ctx); ok {
+		t.Errorf("TestPauser: Pause() on paused Pauser: got true, want false")
+	}
+	select {
+	case <-p.resumed():
+		t.Errorf("TestPauser: resumed() channel closed while paused")
+	default:
+	}
+
+	writeErr = fmt.Errorf("error")
+	if ok, err := p.Resume(ctx); ok || err == nil {
+		t.Errorf("TestPauser: Resume() with failed write: got (%v, %v), want (false, error)", ok, err)
+	}
+	select {
+	case <-p.resumed():
+		t.Errorf("TestPauser: resumed() channel closed after Resume() failed to write")
+	default:
+	}
+	writeErr = nil
+
+	if ok, _ := p.Resume(ctx); !ok {
+		t.Errorf("TestPauser: Resume() on paused Pauser: got false, want true")
+	}
+	if len(writes) != 2 || writes[1] {
+		t.Errorf("TestPauser: Resume() returned without writing Paused == false, got writes %v", writes)
+	}
+	select {
+	case <-p.resumed():
+	default:
+		t.Errorf("TestPauser: resumed() channel not closed after Resume()")
+	}
+
+	if ok, _ := newPauser(true, nil).Pause(ctx); ok {
+		t.Errorf("TestPauser: Pause() on Pauser created paused: got true, want false")
+	}
+}
+
+func TestSetPaused(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	id := uuid.New()
+	store := &fakeUpdater{}
+	store.Create(ctx, &workflow.Plan{ID: id, State: &workflow.State{Status: workflow.Running}})
+	states := &States{store: store}
+
+	// The State of the running Plan may be changing, only Paused is written.
+	plan := &workflow.Plan{ID: id, State: &workflow.State{Status: workflow.Completed}}
+	p := states.NewPauser(plan)
+
+	if _, err := p.Pause(ctx); err != nil {
+		t.Fatalf("TestSetPaused: Pause() returned error: %v", err)
+	}
+	if !plan.Paused {
+		t.Errorf("TestSetPaused: got Plan.Paused == false after Pause(), want true")
+	}
+	if len(store.plans) != 1 {
+		t.Fatalf("TestSetPaused: got %d plan writes, want 1", len(store.plans))
+	}
+	if got := store.plans[0]; !got.Paused || got.State.Status != workflow.Running {
+		t.Errorf("TestSetPaused: got write with Paused == %v and Status == %v, want true and Running", got.Paused, got.State.Status)
+	}
+
+	if _, err := p.Resume(ctx); err != nil {
+		t.Fatalf("TestSetPaused: Resume() returned error: %v", err)
+	}
+	if plan.Paused {
+		t.Errorf("TestSetPaused: got Plan.Paused == true after Resume(), want false")
+	}
+	if len(store.plans) != 2 || store.plans[1].Paused {
+		t.Errorf("TestSetPaused: Resume() did not write Paused == false")
+	}
+}
+
+func TestWaitPaused(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		// stop indicates we stop the Plan instead of resuming it.
+		stop bool
+	}{
+		{name: "Resume"},
+		{name: "Stop while paused", stop: true},
+	}
+
+	for _, test := range tests {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		store := &fakeUpdater{}
+		states := &States{store: store}
+		pauser := newPauser(true, nil)
+
+		req := statemachine.Request[Data]{
+			Ctx: ctx,
+			Data: Data{
+				Plan:   &workflow.Plan{State: &workflow.State{}},
+				Pauser: pauser,
+			},
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			states.waitPaused(req)
+		}()
+
+		select {
+		case <-done:
+			t.Fatalf("TestWaitPaused(%s): waitPaused() returned while paused", test.name)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		if test.stop {
+			cancel()
+		} else {
+			pauser.Resume(ctx)
+		}
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("TestWaitPaused(%s): waitPaused() did not return", test.name)
+		}
+
+		// The Pauser writes Plan.Paused, waitPaused() only waits.
+		if store.calls.Load() != 0 {
+			t.Errorf("TestWaitPaused(%s): got %d writes, want 0", test.name, store.calls.Load())
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package sm
+
+import (
+	"sync"
+
+	"github.com/element-of-surprise/coercion/workflow"
+)
+
+// rampLimiter limits how many Sequences of a Block run at the same time. The limit starts at the first
+// level of the Block's Ramp and moves to the next level once Ramp.Successes Sequences succeed at the
+// current level. A failed Sequence returns the limit to the first level. A Block without a Ramp has a
+// single level, its Concurrency.
+type rampLimiter struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	levels []int
+	// needed is the number of successes needed to move to the next level.
+	needed int
+
+	// level is the index in levels of the current limit.
+	level int
+	// successes is the number of Sequences that succeeded at the current level.
+	successes int
+	// running is the number of Sequences that hold a slot.
+	running int
+}
+
+// newRampLimiter creates a rampLimiter for the Block.
+func newRampLimiter(b *workflow.Block) *rampLimiter {
+	r := &rampLimiter{levels: b.Ramp.Levels(b.Concurrency)}
+	if b.Ramp != nil {
+		r.needed = b.Ramp.Successes
+	}
+	r.cond = sync.NewCond(&r.mu)
+	return r
+}
+
+// acquire blocks until fewer Sequences than the current limit are running and takes a slot.
+func (r *rampLimiter) acquire() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for r.running >= r.levels[r.level] {
+		r.cond.Wait()
+	}
+	r.running++
+}
+
+// release gives back a slot taken with acquire. succeeded is whether the Sequence that held it succeeded.
+func (r *rampLimiter) release(succeeded bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.running--
+	switch {
+	case !succeeded:
+		r.level = 0
+		r.successes = 0
+	case r.level < len(r.levels)-1:
+		r.successes++
+		if r.successes >= r.needed {
+			r.level++
+			r.successes = 0
+		}
+	}
+	r.cond.Broadcast()
+}
+
+// cancel gives back a slot taken with acquire for a Sequence that was not run. The level is not changed.
+func (r *rampLimiter) cancel() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.running--
+	r.cond.Broadcast()
+}
+
+// limit returns the current limit.
+func (r *rampLimiter) limit() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.levels[r.level]
+}
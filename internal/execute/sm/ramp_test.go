@@ -0,0 +1,107 @@
+package sm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+)
+
+func TestRampLimiter(t *testing.T) {
+	t.Parallel()
+
+	// result is the outcome of a Sequence, true for success.
+	type result = bool
+
+	tests := []struct {
+		name    string
+		block   *workflow.Block
+		results []result
+		// wantLimits are the limits after each result.
+		wantLimits []int
+	}{
+		{
+			name:       "no Ramp",
+			block:      &workflow.Block{Concurrency: 3},
+			results:    []result{true, false, true},
+			wantLimits: []int{3, 3, 3},
+		},
+		{
+			name:       "steps up after successes",
+			block:      &workflow.Block{Concurrency: 10, Ramp: &workflow.Ramp{Steps: []int{1, 2, 5}, Successes: 2}},
+			results:    []result{true, true, true, true, true, true, true},
+			wantLimits: []int{1, 2, 2, 5, 5, 10, 10},
+		},
+		{
+			name:       "failure returns to the first step",
+			block:      &workflow.Block{Concurrency: 10, Ramp: &workflow.Ramp{Steps: []int{1, 2, 5}, Successes: 1}},
+			results:    []result{true, true, false, true},
+			wantLimits: []int{2, 5, 1, 2},
+		},
+	}
+
+	for _, test := range tests {
+		r := newRampLimiter(test.block)
+		for i, res := range test.results {
+			r.acquire()
+			r.release(res)
+			if got := r.limit(); got != test.wantLimits[i] {
+				t.Errorf("TestRampLimiter(%s): after result %d: got limit %d, want %d", test.name, i, got, test.wantLimits[i])
+			}
+		}
+	}
+}
+
+func TestRampLimiterBlocks(t *testing.T) {
+	t.Parallel()
+
+	r := newRampLimiter(&workflow.Block{Concurrency: 2, Ramp: &workflow.Ramp{Successes: 1}})
+
+	acquire := func() chan struct{} {
+		acquired := make(chan struct{})
+		go func() {
+			r.acquire()
+			close(acquired)
+		}()
+		return acquired
+	}
+
+	r.acquire()
+	second := acquire()
+	select {
+	case <-second:
+		t.Fatalf("TestRampLimiterBlocks: acquired a second slot at a limit of 1")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A success steps the limit up to 2, so the waiting Sequence and one more can hold a slot.
+	r.release(true)
+	select {
+	case <-second:
+	case <-time.After(time.Second):
+		t.Fatalf("TestRampLimiterBlocks: did not acquire a slot after a release")
+	}
+	select {
+	case <-acquire():
+	case <-time.After(time.Second):
+		t.Fatalf("TestRampLimiterBlocks: did not acquire a second slot after the limit went up")
+	}
+}
+
+func TestRampLimiterCancel(t *testing.T) {
+	t.Parallel()
+
+	r := newRampLimiter(&workflow.Block{Concurrency: 2, Ramp: &workflow.Ramp{Steps: []int{1, 2}, Successes: 1}})
+	r.acquire()
+	r.release(true)
+
+	// A slot given back for a Sequence that did not run frees the slot without returning to the first step.
+	r.acquire()
+	r.cancel()
+	if got := r.limit(); got != 2 {
+		t.Errorf("TestRampLimiterCancel: got limit %d, want 2", got)
+	}
+	if r.running != 0 {
+		t.Errorf("TestRampLimiterCancel: got %d slots held, want 0", r.running)
+	}
+}
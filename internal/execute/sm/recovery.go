@@ -12,8 +12,12 @@ import (
 // Recovery restarts execution of a Plan that has already started running, but the service crashed before it completed.
 func (s *States) Recovery(req statemachine.Request[Data]) statemachine.Request[Data] {
 	plan := req.Data.Plan
+	req.Ctx = withEvents(req.Ctx, req.Data.Events)
 
 	fixPlan(plan)
+	// Rollbacks that were running when the service crashed are finished, even if the Plan is done.
+	s.resumeRollbacks(withKeys(context.SetPlanID(req.Ctx, plan.ID), plan), plan)
+
 	switch plan.State.Status {
 	case workflow.NotStarted:
 		req.Next = s.Start
@@ -25,16 +29,29 @@ func (s *States) Recovery(req statemachine.Request[Data]) statemachine.Request[D
 	// Okay, we are in the running state. Let's setup to run.
 
 	req.Ctx = context.SetPlanID(req.Ctx, req.Data.Plan.ID)
+	req.Ctx = withKeys(req.Ctx, plan)
+	s.startPlanSpan(&req)
+	s.metrics.planStarted(&req)
+	startPlanTimeout(&req)
 
 	for _, b := range req.Data.Plan.Blocks {
 		req.Data.blocks = append(req.Data.blocks, block{block: b, contCheckResult: make(chan error, 1)})
 	}
 	req.Data.contCheckResult = make(chan error, 1)
 
-	if err := s.store.UpdatePlan(req.Ctx, plan); err != nil {
+	eventsFrom(req.Ctx).plan(plan)
+	if err := s.updatePlan(req.Ctx, plan); err != nil {
 		log.Fatalf("failed to write Plan: %v", err)
 	}
 
+	// The Plan still holds its locks if they were not cleaned up during recovery, so this only
+	// re-acquires locks that were lost.
+	if err := s.lock(req.Ctx, plan.ID, plan.ID, plan.Locks); err != nil {
+		req.Data.err = err
+		req.Next = s.End
+		return req
+	}
+
 	req.Next = s.PlanBypassChecks
 	return req
 }
@@ -48,6 +65,10 @@ func fixAction(a *workflow.Action) {
 	if a.State.Status != workflow.Running {
 		return
 	}
+	// An Attempt that was running when the service stopped did not finish, so it is made again.
+	if n := len(a.Attempts); n > 0 && a.Attempts[n-1].Running() {
+		a.Attempts = a.Attempts[:n-1]
+	}
 	if len(a.Attempts) == 0 {
 		resetAction(a)
 	}
@@ -104,6 +125,7 @@ func fixSeq(s *workflow.Sequence) {
 
 	completed := 0
 	running := 0
+	failed := 0
 	for _, a := range s.Actions {
 		fixAction(a)
 		switch a.State.Status {
@@ -111,6 +133,8 @@ func fixSeq(s *workflow.Sequence) {
 			completed++
 		case workflow.Running:
 			running++
+		case workflow.Failed:
+			failed++
 		case workflow.Stopped:
 			stopped++
 		}
@@ -126,6 +150,11 @@ func fixSeq(s *workflow.Sequence) {
 		s.State.End = time.Now()
 		return
 	}
+	if failed > 0 {
+		s.State.Status = workflow.Failed
+		s.State.End = time.Now()
+		return
+	}
 	if completed == 0 && running == 0 {
 		s.State.Status = workflow.NotStarted
 		s.State.Start = time.Time{}
@@ -172,6 +201,21 @@ func fixBlock(b *workflow.Block) {
 		fixChecks(b.PostChecks)
 	}
 
+	// A SubPlan is recovered when its block runs again, so the block is left Running to keep its start time.
+	if b.SubPlan != nil {
+		return
+	}
+
+	// The Sequences from a Generator are written with its Completed state, so a Generator that did not
+	// complete has added nothing and is run again.
+	if g := b.Generator; g != nil && g.State.Status != workflow.Completed {
+		if g.State.Status == workflow.Failed {
+			b.State.Status = workflow.Failed
+			return
+		}
+		resetAction(g)
+	}
+
 	running := 0
 	completed := 0
 	failed := 0
@@ -204,13 +248,13 @@ func fixBlock(b *workflow.Block) {
 		b.State.End = time.Time{}
 		return
 	}
-	if completed == len(b.Sequences) && b.PostChecks == nil || b.PostChecks.State.Status == workflow.Completed {
+	if completed == len(b.Sequences) && (b.PostChecks == nil || b.PostChecks.State.Status == workflow.Completed) {
 		b.State.Status = workflow.Completed
 		b.State.End = time.Now()
 		return
 	}
 	if running == 0 && completed+failed == len(b.Sequences) {
-		if failed <= b.ToleratedFailures {
+		if !b.ExceedsToleratedFailures(failed, completed+failed) {
 			b.State.Status = workflow.Completed
 			b.State.End = time.Now()
 			return
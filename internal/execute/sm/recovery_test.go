@@ -168,6 +168,31 @@ func TestFixSeq(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "running sequence, an action failed, sequence is failed",
+			seq: &workflow.Sequence{
+				State: &workflow.State{Status: workflow.Running, Start: now},
+				Actions: []*workflow.Action{
+					{State: &workflow.State{Status: workflow.Completed}},
+					{State: &workflow.State{Status: workflow.Failed}},
+					{State: &workflow.State{Status: workflow.NotStarted}},
+				},
+				Rollback: []*workflow.Action{
+					{State: &workflow.State{Status: workflow.Running}},
+				},
+			},
+			want: &workflow.Sequence{
+				State: &workflow.State{Status: workflow.Failed, Start: now},
+				Actions: []*workflow.Action{
+					{State: &workflow.State{Status: workflow.Completed}},
+					{State: &workflow.State{Status: workflow.Failed}},
+					{State: &workflow.State{Status: workflow.NotStarted}},
+				},
+				Rollback: []*workflow.Action{
+					{State: &workflow.State{Status: workflow.Running}},
+				},
+			},
+		},
 		{
 			name: "running sequence, all actions completed, complete sequence",
 			seq: &workflow.Sequence{
@@ -222,7 +247,7 @@ func TestFixSeq(t *testing.T) {
 				}
 			}
 		}
-		if test.seq.State.Status == workflow.Completed {
+		if test.seq.State.Status == workflow.Completed || test.seq.State.Status == workflow.Failed {
 			if test.seq.State.End.IsZero() {
 				t.Errorf("TestFixSeq(%s): got seq.State.End == 0, want non-zero", test.name)
 			}
@@ -249,6 +274,19 @@ func TestFixBlock(t *testing.T) {
 				State: &workflow.State{Status: workflow.Completed},
 			},
 		},
+		{
+			name: "running block with a sub-plan stays running",
+			b: &workflow.Block{
+				State:     &workflow.State{Status: workflow.Running, Start: time.Unix(1, 0)},
+				PreChecks: &workflow.Checks{State: &workflow.State{Status: workflow.Completed}},
+				SubPlan:   &workflow.Plan{State: &workflow.State{Status: workflow.Running}},
+			},
+			want: &workflow.Block{
+				State:     &workflow.State{Status: workflow.Running, Start: time.Unix(1, 0)},
+				PreChecks: &workflow.Checks{State: &workflow.State{Status: workflow.Completed}},
+				SubPlan:   &workflow.Plan{State: &workflow.State{Status: workflow.Running}},
+			},
+		},
 		{
 			name: "running block, prechecks failed, block fails",
 			b: &workflow.Block{
@@ -371,6 +409,45 @@ func TestFixBlock(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "running block, generator running, block not started",
+			b: &workflow.Block{
+				State:     &workflow.State{Status: workflow.Running},
+				Generator: &workflow.Action{State: &workflow.State{Status: workflow.Running}, Attempts: []*workflow.Attempt{{}}},
+			},
+			want: &workflow.Block{
+				State:     &workflow.State{Status: workflow.NotStarted},
+				Generator: &workflow.Action{State: &workflow.State{Status: workflow.NotStarted}},
+			},
+		},
+		{
+			name: "running block, generator failed, block fails",
+			b: &workflow.Block{
+				State:     &workflow.State{Status: workflow.Running},
+				Generator: &workflow.Action{State: &workflow.State{Status: workflow.Failed}},
+			},
+			want: &workflow.Block{
+				State:     &workflow.State{Status: workflow.Failed},
+				Generator: &workflow.Action{State: &workflow.State{Status: workflow.Failed}},
+			},
+		},
+		{
+			name: "running block, generator completed, generated sequences completed, completes block",
+			b: &workflow.Block{
+				State:     &workflow.State{Status: workflow.Running},
+				Generator: &workflow.Action{State: &workflow.State{Status: workflow.Completed}},
+				Sequences: []*workflow.Sequence{
+					{State: &workflow.State{Status: workflow.Completed}},
+				},
+			},
+			want: &workflow.Block{
+				State:     &workflow.State{Status: workflow.Completed},
+				Generator: &workflow.Action{State: &workflow.State{Status: workflow.Completed}},
+				Sequences: []*workflow.Sequence{
+					{State: &workflow.State{Status: workflow.Completed}},
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -378,6 +455,9 @@ func TestFixBlock(t *testing.T) {
 		if test.want.State.Status != test.b.State.Status {
 			t.Errorf("TestFixBlock(%s): got state %v, want %v", test.name, test.b.State.Status, test.want.State.Status)
 		}
+		if test.want.Generator != nil && test.want.Generator.State.Status != test.b.Generator.State.Status {
+			t.Errorf("TestFixBlock(%s): got generator state %v, want %v", test.name, test.b.Generator.State.Status, test.want.Generator.State.Status)
+		}
 	}
 }
 
@@ -475,6 +555,34 @@ func TestFixPlan(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "running plan, several blocks running, plan keeps running",
+			plan: &workflow.Plan{
+				State: &workflow.State{Status: workflow.Running},
+				Blocks: []*workflow.Block{
+					{
+						State:     &workflow.State{Status: workflow.Running},
+						Sequences: []*workflow.Sequence{{State: &workflow.State{Status: workflow.Completed}}},
+					},
+					{
+						State: &workflow.State{Status: workflow.Running},
+						Sequences: []*workflow.Sequence{
+							{State: &workflow.State{Status: workflow.Completed}},
+							{
+								State: &workflow.State{Status: workflow.Running},
+								Actions: []*workflow.Action{
+									{State: &workflow.State{Status: workflow.Running}, Attempts: []*workflow.Attempt{{}}},
+								},
+							},
+						},
+					},
+					{State: &workflow.State{Status: workflow.NotStarted}},
+				},
+			},
+			want: &workflow.Plan{
+				State: &workflow.State{Status: workflow.Running},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,33 @@
+package sm
+
+import (
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+	"github.com/google/uuid"
+)
+
+type keysKey struct{}
+
+// withKeys attaches the Actions in the Plan's Sequences that have a Key to the Context. These are
+// the Actions that an Action's Refs can reference.
+func withKeys(ctx context.Context, plan *workflow.Plan) context.Context {
+	keys := map[uuid.UUID]*workflow.Action{}
+	for _, b := range plan.Blocks {
+		for _, s := range b.Sequences {
+			for _, a := range s.Actions {
+				if a.Key != uuid.Nil {
+					keys[a.Key] = a
+				}
+			}
+		}
+	}
+	return context.WithValue(ctx, keysKey{}, keys)
+}
+
+// lookupFrom returns a function that returns the Action with a Key from the Actions attached to the Context.
+func lookupFrom(ctx context.Context) func(uuid.UUID) *workflow.Action {
+	keys, _ := ctx.Value(keysKey{}).(map[uuid.UUID]*workflow.Action)
+	return func(key uuid.UUID) *workflow.Action {
+		return keys[key]
+	}
+}
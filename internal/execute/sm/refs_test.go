@@ -0,0 +1,41 @@
+package sm
+
+import (
+	"testing"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+
+	"github.com/google/uuid"
+)
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	keyed := &workflow.Action{ID: uuid.New(), Key: uuid.New()}
+	unkeyed := &workflow.Action{ID: uuid.New()}
+	check := &workflow.Action{ID: uuid.New(), Key: uuid.New()}
+	plan := &workflow.Plan{
+		PreChecks: &workflow.Checks{Actions: []*workflow.Action{check}},
+		Blocks: []*workflow.Block{
+			{Sequences: []*workflow.Sequence{{Actions: []*workflow.Action{unkeyed, keyed}}}},
+		},
+	}
+
+	lookup := lookupFrom(withKeys(context.Background(), plan))
+
+	if got := lookup(keyed.Key); got != keyed {
+		t.Errorf("TestLookup: lookup(keyed): got %v, want %v", got, keyed)
+	}
+	if got := lookup(check.Key); got != nil {
+		t.Errorf("TestLookup: lookup(check): got %v, want nil", got)
+	}
+	if got := lookup(uuid.Nil); got != nil {
+		t.Errorf("TestLookup: lookup(uuid.Nil): got %v, want nil", got)
+	}
+
+	// Without keys on the Context, nothing is found.
+	if got := lookupFrom(context.Background())(keyed.Key); got != nil {
+		t.Errorf("TestLookup: lookup without keys: got %v, want nil", got)
+	}
+}
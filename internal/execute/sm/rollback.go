@@ -0,0 +1,97 @@
+package sm
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+)
+
+// rollbackSeq runs the Rollback of a Sequence that failed after one of its Actions started.
+// The Rollback runs even if the Context has been cancelled.
+func (s *States) rollbackSeq(ctx context.Context, seq *workflow.Sequence) {
+	if len(seq.Rollback) == 0 || seq.State.Status != workflow.Failed || !actionsStarted(seq.Actions) {
+		return
+	}
+	s.rollback(context.WithoutCancel(ctx), seq.Rollback)
+}
+
+// rollbackBlock runs the Rollback of a Block that failed after its Sequences started. The Rollback of each
+// Completed Sequence is run first, starting with the last one to complete. The Rollback of a Failed Sequence
+// was run when it failed. If any of the Rollbacks fail, the rest are not run.
+// The Rollbacks run even if the Context has been cancelled.
+func (s *States) rollbackBlock(ctx context.Context, b *workflow.Block) {
+	if b.State.Status != workflow.Failed || !seqsStarted(b.Sequences) {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	completed := make([]*workflow.Sequence, 0, len(b.Sequences))
+	for _, seq := range b.Sequences {
+		if seq.State.Status == workflow.Completed {
+			completed = append(completed, seq)
+		}
+	}
+	slices.SortStableFunc(completed, func(a, b *workflow.Sequence) int {
+		return b.State.End.Compare(a.State.End)
+	})
+
+	for _, seq := range completed {
+		if err := s.rollback(ctx, seq.Rollback); err != nil {
+			return
+		}
+	}
+	s.rollback(ctx, b.Rollback)
+}
+
+// resumeRollbacks runs the rest of any Rollback that was interrupted because the service crashed.
+// Recovery has already fixed the states in the Plan. Rollbacks that completed or failed are not run again.
+func (s *States) resumeRollbacks(ctx context.Context, plan *workflow.Plan) {
+	for _, b := range plan.Blocks {
+		for _, seq := range b.Sequences {
+			s.rollbackSeq(ctx, seq)
+		}
+		s.rollbackBlock(ctx, b)
+	}
+}
+
+// rollback runs the Actions of a Rollback in order. Actions that have Completed are skipped so that
+// a Rollback can be resumed. The first Action that fails stops the Rollback and its error is returned.
+func (s *States) rollback(ctx context.Context, actions []*workflow.Action) error {
+	for _, a := range actions {
+		switch a.State.Status {
+		case workflow.Completed:
+			continue
+		case workflow.Failed:
+			return fmt.Errorf("rollback action(%s) failed", a.Name)
+		case workflow.Running:
+			// The service crashed while this was running, so it is run again.
+			resetAction(a)
+		}
+		if err := s.runAction(ctx, a, s.store); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// actionsStarted returns true if any of the Actions have started.
+func actionsStarted(actions []*workflow.Action) bool {
+	for _, a := range actions {
+		if a.State.Status != workflow.NotStarted {
+			return true
+		}
+	}
+	return false
+}
+
+// seqsStarted returns true if any of the Sequences have started.
+func seqsStarted(seqs []*workflow.Sequence) bool {
+	for _, seq := range seqs {
+		if seq.State.Status != workflow.NotStarted {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,296 @@
+package sm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+// rollbackRunner records the names of the Actions it runs. Actions named "fail" fail.
+type rollbackRunner struct {
+	mu  sync.Mutex
+	ran []string
+}
+
+func (r *rollbackRunner) run(ctx context.Context, action *workflow.Action, updater storage.ActionUpdater) error {
+	r.mu.Lock()
+	r.ran = append(r.ran, action.Name)
+	r.mu.Unlock()
+
+	action.Attempts = append(action.Attempts, &workflow.Attempt{})
+	action.State.Status = workflow.Completed
+	var err error
+	if action.Name == "fail" {
+		action.State.Status = workflow.Failed
+		err = fmt.Errorf("error")
+	}
+	if uerr := updater.UpdateAction(ctx, action); uerr != nil {
+		return uerr
+	}
+	return err
+}
+
+func rbActions(names ...string) []*workflow.Action {
+	actions := make([]*workflow.Action, 0, len(names))
+	for _, n := range names {
+		actions = append(actions, &workflow.Action{Name: n, State: &workflow.State{Status: workflow.NotStarted}})
+	}
+	return actions
+}
+
+func TestRollback(t *testing.T) {
+	t.Parallel()
+
+	withStatus := func(actions []*workflow.Action, statuses ...workflow.Status) []*workflow.Action {
+		for i, s := range statuses {
+			actions[i].State.Status = s
+			if s == workflow.Running {
+				actions[i].Attempts = []*workflow.Attempt{{}}
+			}
+		}
+		return actions
+	}
+
+	tests := []struct {
+		name    string
+		actions []*workflow.Action
+		wantRan []string
+		wantErr bool
+	}{
+		{
+			name: "No Actions",
+		},
+		{
+			name:    "Success",
+			actions: rbActions("a0", "a1"),
+			wantRan: []string{"a0", "a1"},
+		},
+		{
+			name:    "Completed Actions are skipped",
+			actions: withStatus(rbActions("a0", "a1"), workflow.Completed),
+			wantRan: []string{"a1"},
+		},
+		{
+			name:    "Running Action is run again",
+			actions: withStatus(rbActions("a0", "a1"), workflow.Completed, workflow.Running),
+			wantRan: []string{"a1"},
+		},
+		{
+			name:    "Error: Action fails",
+			actions: rbActions("a0", "fail", "a2"),
+			wantRan: []string{"a0", "fail"},
+			wantErr: true,
+		},
+		{
+			name:    "Error: Action already Failed",
+			actions: withStatus(rbActions("a0", "a1"), workflow.Completed, workflow.Failed),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		runner := &rollbackRunner{}
+		states := &States{store: &fakeUpdater{}, actionRunner: runner.run}
+
+		err := states.rollback(context.Background(), test.actions)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestRollback(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestRollback(%s): got err == %s, want err == nil", test.name, err)
+		}
+		if diff := pretty.Compare(test.wantRan, runner.ran); diff != "" {
+			t.Errorf("TestRollback(%s): ran: -want/+got:\n%s", test.name, diff)
+		}
+		if test.wantErr && test.actions[len(test.actions)-1].State.Status == workflow.Completed {
+			t.Errorf("TestRollback(%s): Actions after the failure were run", test.name)
+		}
+	}
+}
+
+func TestExecSeqRollback(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		actions []*workflow.Action
+		ctx     func() context.Context
+		wantRan []string
+	}{
+		{
+			name:    "Sequence completes",
+			actions: rbActions("a0", "a1"),
+			wantRan: []string{"a0", "a1"},
+		},
+		{
+			name:    "Sequence fails",
+			actions: rbActions("a0", "fail", "a2"),
+			wantRan: []string{"a0", "fail", "r0", "r1"},
+		},
+		{
+			name:    "Sequence stopped",
+			actions: rbActions("a0", "a1"),
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+		},
+	}
+
+	for _, test := range tests {
+		runner := &rollbackRunner{}
+		states := &States{store: &fakeUpdater{}, actionRunner: runner.run}
+		seq := &workflow.Sequence{
+			ID:       workflow.NewV7(),
+			Name:     "seq",
+			Actions:  test.actions,
+			Rollback: rbActions("r0", "r1"),
+			State:    &workflow.State{},
+		}
+
+		ctx := context.Background()
+		if test.ctx != nil {
+			ctx = test.ctx()
+		}
+		states.execSeq(ctx, seq)
+
+		if diff := pretty.Compare(test.wantRan, runner.ran); diff != "" {
+			t.Errorf("TestExecSeqRollback(%s): ran: -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestRollbackBlock(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	seq := func(name string, status workflow.Status, end time.Time) *workflow.Sequence {
+		return &workflow.Sequence{
+			Name:     name,
+			State:    &workflow.State{Status: status, End: end},
+			Rollback: rbActions(name + "-rollback"),
+		}
+	}
+	block := func(status workflow.Status, seqs ...*workflow.Sequence) *workflow.Block {
+		return &workflow.Block{
+			Name:      "block",
+			State:     &workflow.State{Status: status},
+			Sequences: seqs,
+			Rollback:  rbActions("block-rollback"),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		block   *workflow.Block
+		wantRan []string
+	}{
+		{
+			name:  "Block Completed",
+			block: block(workflow.Completed, seq("s0", workflow.Completed, now)),
+		},
+		{
+			name:  "Block Stopped",
+			block: block(workflow.Stopped, seq("s0", workflow.Completed, now)),
+		},
+		{
+			name:  "Block Failed before Sequences started",
+			block: block(workflow.Failed, seq("s0", workflow.NotStarted, time.Time{})),
+		},
+		{
+			name: "Block Failed",
+			block: block(
+				workflow.Failed,
+				seq("s0", workflow.Completed, now.Add(-time.Minute)),
+				seq("s1", workflow.Failed, now),
+				seq("s2", workflow.Completed, now),
+				seq("s3", workflow.NotStarted, time.Time{}),
+			),
+			wantRan: []string{"s2-rollback", "s0-rollback", "block-rollback"},
+		},
+		{
+			name: "Sequence Rollback fails",
+			block: func() *workflow.Block {
+				s1 := seq("s1", workflow.Completed, now)
+				s1.Rollback = rbActions("fail")
+				return block(workflow.Failed, seq("s0", workflow.Completed, now.Add(-time.Minute)), s1)
+			}(),
+			wantRan: []string{"fail"},
+		},
+	}
+
+	for _, test := range tests {
+		runner := &rollbackRunner{}
+		states := &States{store: &fakeUpdater{}, actionRunner: runner.run}
+
+		states.rollbackBlock(context.Background(), test.block)
+
+		if diff := pretty.Compare(test.wantRan, runner.ran); diff != "" {
+			t.Errorf("TestRollbackBlock(%s): ran: -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestResumeRollbacks(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	started := func(status workflow.Status) []*workflow.Action {
+		a := rbActions("action")
+		a[0].State.Status = status
+		return a
+	}
+
+	// s0 failed and was rolling back when the service crashed.
+	s0 := &workflow.Sequence{
+		Name:     "s0",
+		State:    &workflow.State{Status: workflow.Failed, End: now},
+		Actions:  started(workflow.Failed),
+		Rollback: rbActions("s0-r0", "s0-r1"),
+	}
+	s0.Rollback[0].State.Status = workflow.Completed
+	s0.Rollback[1].State.Status = workflow.Running
+	// s1 completed and its Rollback was run by the Block.
+	s1 := &workflow.Sequence{
+		Name:     "s1",
+		State:    &workflow.State{Status: workflow.Completed, End: now},
+		Actions:  started(workflow.Completed),
+		Rollback: rbActions("s1-r0"),
+	}
+	s1.Rollback[0].State.Status = workflow.Completed
+
+	plan := &workflow.Plan{
+		ID: workflow.NewV7(),
+		Blocks: []*workflow.Block{
+			{
+				Name:      "block",
+				State:     &workflow.State{Status: workflow.Failed},
+				Sequences: []*workflow.Sequence{s0, s1},
+				Rollback:  rbActions("block-r0"),
+			},
+		},
+	}
+
+	runner := &rollbackRunner{}
+	states := &States{store: &fakeUpdater{}, actionRunner: runner.run}
+	states.resumeRollbacks(context.Background(), plan)
+
+	want := []string{"s0-r1", "block-r0"}
+	if diff := pretty.Compare(want, runner.ran); diff != "" {
+		t.Errorf("TestResumeRollbacks: ran: -want/+got:\n%s", diff)
+	}
+
+	// Running it again does nothing, as all Rollbacks have completed.
+	runner.ran = nil
+	states.resumeRollbacks(context.Background(), plan)
+	if len(runner.ran) != 0 {
+		t.Errorf("TestResumeRollbacks: got %v run a second time, want nothing", runner.ran)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -16,29 +17,55 @@ import (
 
 	"github.com/gostdlib/base/statemachine"
 	"github.com/gostdlib/base/telemetry/log"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var ErrInternalFailure = errors.New("internal failure")
 
+// errStopped is recorded as the Plan's error when a user stops the Plan.
+var errStopped = errors.New("plan was stopped")
+
 // block is a wrapper around a workflow.Block that contains additional information for the statemachine.
 type block struct {
 	block *workflow.Block
 
 	contCancel      context.CancelFunc
 	contCheckResult chan error
+
+	// planCtx is the Plan's Context, which is restored when the Block ends.
+	planCtx context.Context
+	// span is the Block's span. This is nil until the Block starts.
+	span trace.Span
+	// timeoutCancel cancels the Block's Timeout. This is nil if the Block has no Timeout.
+	timeoutCancel context.CancelFunc
 }
 
 // Data represents the data that is passed between states.
 type Data struct {
 	// Plan is the workflow.Plan that is being executed.
 	Plan *workflow.Plan
+	// Pauser is used to pause the Plan at Block and Sequence boundaries. If nil, the Plan cannot be paused.
+	Pauser *Pauser
+	// Approvals is used to deliver Decisions on Approvals to the Plan. If nil, Approvals can only expire.
+	Approvals *Approvals
+	// Events records the events of the Plan for subscribers. If nil, no events are recorded.
+	Events *Events
 
 	// blocks is a list of blocks that are being executed. These are removed as each block is completed.
 	blocks []block
+	// concurrent is set in the statemachine that runs a single Block of a Plan whose Blocks are not run
+	// in sequence. When set, the statemachine ends when the Block ends. See ExecuteBlocks.
+	concurrent bool
 	// contCancel is the context.CancelFunc that will cancel the continuous check for the Plan.
 	contCancel context.CancelFunc
 	// contCheckResult is the channel that will receive the result of the continuous check for the Plan.
 	contCheckResult chan error
+	// timeoutCancel cancels the Plan's Timeout. This is nil if the Plan has no Timeout.
+	timeoutCancel context.CancelFunc
+	// span is the Plan's span. This is nil if the Plan was not started or recovered by this statemachine.
+	span trace.Span
+	// running is set when the Plan is recorded as running in the metrics, so that End records how it ended.
+	running bool
 
 	err error
 }
@@ -46,17 +73,19 @@ type Data struct {
 // contChecks will check if any of the continuous checks on the Plan or the current block have failed.
 // If a check has failed, the type of the object that failed is returned (OTPlan or OTBlock).
 func (d Data) contChecksPassing() (workflow.ObjectType, error) {
-	if len(d.blocks) == 0 {
-		select {
-		case err := <-d.contCheckResult:
+	// The Plan is checked on its own, as the closed channel of a Block without ContChecks is always
+	// ready and would hide a failure of the Plan's ContChecks from a select on both.
+	select {
+	case err := <-d.contCheckResult:
+		if err != nil {
 			return workflow.OTPlan, err
-		default:
-			return workflow.OTUnknown, nil
 		}
+	default:
+	}
+	if len(d.blocks) == 0 {
+		return workflow.OTUnknown, nil
 	}
 	select {
-	case err := <-d.contCheckResult:
-		return workflow.OTPlan, err
 	case err := <-d.blocks[0].contCheckResult:
 		return workflow.OTBlock, err
 	default:
@@ -82,6 +111,15 @@ type States struct {
 
 	actionsSM actions.Runner
 
+	// gate is used to drain the States when the Workstream closes. Writes to store pass through it.
+	gate *gate
+	// tracer creates the spans of a Plan's execution. If nil, a tracer from otel.GetTracerProvider() is used.
+	tracer trace.Tracer
+	// metrics records the Plan's execution. If nil, nothing is recorded.
+	metrics *metrics
+	// planMu serializes writes of Plans, so that Plan.Paused can be written by a Pauser while the Plan runs.
+	planMu sync.Mutex
+
 	// nower is the function that returns the current time. This is set to time.Now by default.
 	nower nower
 
@@ -95,15 +133,39 @@ type States struct {
 	actionRunner actionRunner
 }
 
+// Option is an optional argument for New().
+type Option func(*States) error
+
+// WithLogCapture sets how the records that plugins log during each Attempt are captured.
+func WithLogCapture(c actions.LogCapture) Option {
+	return func(s *States) error {
+		if c.MaxRecords < 0 || c.MaxBytes < 0 {
+			return fmt.Errorf("log capture limits cannot be negative")
+		}
+		s.actionsSM.Logs = c
+		return nil
+	}
+}
+
 // New creates a new States statemachine.
-func New(store storage.Vault, registry *registry.Register) (*States, error) {
+func New(store storage.Vault, registry *registry.Register, options ...Option) (*States, error) {
 	if store == nil {
 		return nil, fmt.Errorf("store is required")
 	}
+	g := newGate()
 	s := &States{
-		store:    store,
+		store:    gateVault{Vault: store, gate: g},
 		registry: registry,
+		gate:     g,
+	}
+	for _, o := range options {
+		if err := o(s); err != nil {
+			return nil, err
+		}
 	}
+	s.actionsSM.Tracer = s.tracer
+	s.actionsSM.Transition = recordAction
+	s.actionsSM.AttemptDuration = s.metrics.attemptDuration()
 	return s, nil
 }
 
@@ -113,6 +175,10 @@ func (s *States) Start(req statemachine.Request[Data]) statemachine.Request[Data
 	plan := req.Data.Plan
 
 	req.Ctx = context.SetPlanID(req.Ctx, req.Data.Plan.ID)
+	req.Ctx = withEvents(req.Ctx, req.Data.Events)
+	req.Ctx = withKeys(req.Ctx, plan)
+	s.startPlanSpan(&req)
+	s.metrics.planStarted(&req)
 
 	for _, b := range req.Data.Plan.Blocks {
 		req.Data.blocks = append(req.Data.blocks, block{block: b, contCheckResult: make(chan error, 1)})
@@ -121,11 +187,19 @@ func (s *States) Start(req statemachine.Request[Data]) statemachine.Request[Data
 
 	plan.State.Status = workflow.Running
 	plan.State.Start = s.now()
+	startPlanTimeout(&req)
 
-	if err := s.store.UpdatePlan(req.Ctx, plan); err != nil {
+	eventsFrom(req.Ctx).plan(plan)
+	if err := s.updatePlan(req.Ctx, plan); err != nil {
 		log.Fatalf("failed to write Plan: %v", err)
 	}
 
+	if err := s.lock(req.Ctx, plan.ID, plan.ID, plan.Locks); err != nil {
+		req.Data.err = err
+		req.Next = s.End
+		return req
+	}
+
 	req.Next = s.PlanBypassChecks
 	return req
 }
@@ -134,7 +208,8 @@ func (s *States) Start(req statemachine.Request[Data]) statemachine.Request[Data
 // or no gates are present, the Plan is executed.
 func (s *States) PlanBypassChecks(req statemachine.Request[Data]) statemachine.Request[Data] {
 	defer func() {
-		if err := s.store.UpdatePlan(req.Ctx, req.Data.Plan); err != nil {
+		eventsFrom(req.Ctx).plan(req.Data.Plan)
+		if err := s.updatePlan(req.Ctx, req.Data.Plan); err != nil {
 			log.Fatalf("failed to write Plan: %v", err)
 		}
 	}()
@@ -159,7 +234,8 @@ func (s *States) PlanBypassChecks(req statemachine.Request[Data]) statemachine.R
 // PlanPreChecks runs all PreChecks and ContChecks on the Plan before proceeding.
 func (s *States) PlanPreChecks(req statemachine.Request[Data]) statemachine.Request[Data] {
 	defer func() {
-		if err := s.store.UpdatePlan(req.Ctx, req.Data.Plan); err != nil {
+		eventsFrom(req.Ctx).plan(req.Data.Plan)
+		if err := s.updatePlan(req.Ctx, req.Data.Plan); err != nil {
 			log.Fatalf("failed to write Plan: %v", err)
 		}
 	}()
@@ -172,6 +248,9 @@ func (s *States) PlanPreChecks(req statemachine.Request[Data]) statemachine.Requ
 	err := s.runPreChecks(req.Ctx, req.Data.Plan.PreChecks, req.Data.Plan.ContChecks)
 	if err != nil {
 		req.Data.err = err
+		if hErr := halted(req.Ctx); hErr != nil {
+			req.Data.err = hErr
+		}
 		req.Next = s.PlanDeferredChecks
 		return req
 	}
@@ -194,7 +273,7 @@ func (s *States) PlanStartContChecks(req statemachine.Request[Data]) statemachin
 		close(req.Data.contCheckResult)
 	}
 
-	req.Next = s.ExecuteBlock
+	req.Next = s.PlanApproval
 	return req
 }
 
@@ -209,6 +288,7 @@ func (s *States) ExecuteBlock(req statemachine.Request[Data]) statemachine.Reque
 	h := req.Data.blocks[0]
 
 	defer func() {
+		eventsFrom(req.Ctx).block(h.block)
 		if err := s.store.UpdateBlock(req.Ctx, h.block); err != nil {
 			log.Fatalf("failed to write Block: %v", err)
 		}
@@ -220,19 +300,60 @@ func (s *States) ExecuteBlock(req statemachine.Request[Data]) statemachine.Reque
 		} else {
 			req.Data.blocks = req.Data.blocks[1:]
 		}
-		req.Next = s.ExecuteBlock
+		req.Next = s.afterBlock(req, s.BlockApproval)
+		return req
+	}
+
+	// If the Plan is paused, we wait here until it is resumed before starting the next block.
+	s.waitPaused(req)
+
+	// The Plan was stopped or timed out, so we don't start any more blocks. Unstarted objects are marked
+	// Stopped in End.
+	if err := halted(req.Ctx); err != nil {
+		h.block.State.Status = workflow.Stopped
+		req.Data.err = err
+		req.Next = s.afterBlock(req, s.PlanDeferredChecks)
+		return req
+	}
+
+	// The Block's Timeout includes the EntranceDelay and ExitDelay. A recovered Block keeps its
+	// original start time so that it does not get a new Timeout.
+	start := s.now()
+	if h.block.State.Status == workflow.Running && !h.block.State.Start.IsZero() {
+		start = h.block.State.Start
+	}
+	h.planCtx = req.Ctx
+	req.Ctx, h.span = s.startSpan(req.Ctx, "Block", keyedAttrs(req.Ctx, h.block.ID, h.block.Key, h.block.Name)...)
+	startBlockTimeout(&req, &h, start)
+	req.Data.blocks[0] = h
+
+	if err := s.lock(req.Ctx, h.block.ID, context.PlanID(req.Ctx), h.block.Locks); err != nil {
+		h.block.State.Status = workflow.Failed
+		req.Data.err = err
+		if hErr := halted(req.Ctx); hErr != nil {
+			h.block.State.Status = workflow.Stopped
+			req.Data.err = hErr
+		}
+		endBlockTimeout(&req, h)
+		s.endRunSpan(h.span, h.block.State.Status, req.Data.err)
+		s.metrics.blockEnded(req.Ctx, h.block, s.drained(h.block.State.Status))
+		req.Next = s.afterBlock(req, s.PlanDeferredChecks)
 		return req
 	}
 
 	if err := after(req.Ctx, h.block.EntranceDelay); err != nil {
 		h.block.State.Status = workflow.Stopped
 		req.Data.err = err
-		req.Next = s.PlanDeferredChecks
+		s.unlock(req.Ctx, h.block.ID, h.block.Locks)
+		endBlockTimeout(&req, h)
+		s.endRunSpan(h.span, h.block.State.Status, req.Data.err)
+		s.metrics.blockEnded(req.Ctx, h.block, s.drained(h.block.State.Status))
+		req.Next = s.afterBlock(req, s.PlanDeferredChecks)
 		return req
 	}
 
 	h.block.State.Status = workflow.Running
-	h.block.State.Start = s.now()
+	h.block.State.Start = start
 	req.Next = s.BlockBypassChecks
 	return req
 }
@@ -271,6 +392,14 @@ func (s *States) BlockPreChecks(req statemachine.Request[Data]) statemachine.Req
 	if err != nil {
 		h.block.State.Status = workflow.Failed
 		req.Data.err = err
+		if hErr := halted(req.Ctx); hErr != nil {
+			h.block.State.Status = workflow.Stopped
+			req.Data.err = hErr
+		}
+		// ContChecks were never started, so BlockEnd must not wait on them.
+		if h.contCheckResult != nil {
+			close(h.contCheckResult)
+		}
 		req.Next = s.BlockDeferredChecks
 		return req
 	}
@@ -284,9 +413,13 @@ func (s *States) BlockPreChecks(req statemachine.Request[Data]) statemachine.Req
 func (s *States) BlockStartContChecks(req statemachine.Request[Data]) statemachine.Request[Data] {
 	h := req.Data.blocks[0]
 
+	req.Next = s.BlockGenerate
+	if h.block.SubPlan != nil {
+		req.Next = s.ExecuteSubPlan
+	}
+
 	if h.block.ContChecks == nil {
 		close(h.contCheckResult)
-		req.Next = s.ExecuteSequences
 		return req
 	}
 
@@ -301,7 +434,6 @@ func (s *States) BlockStartContChecks(req statemachine.Request[Data]) statemachi
 		s.runContChecks(ctx, h.block.ContChecks, h.contCheckResult)
 	}()
 
-	req.Next = s.ExecuteSequences
 	return req
 }
 
@@ -309,36 +441,74 @@ func (s *States) BlockStartContChecks(req statemachine.Request[Data]) statemachi
 func (s *States) ExecuteSequences(req statemachine.Request[Data]) statemachine.Request[Data] {
 	h := req.Data.blocks[0]
 	failures := atomic.Int64{}
+	// started is the number of Sequences that have been started, which a ToleratedFailurePercent can be
+	// evaluated against.
+	started := atomic.Int64{}
 	for _, seq := range h.block.Sequences {
-		if seq.State.Status == workflow.Failed {
+		switch seq.State.Status {
+		case workflow.Failed:
 			failures.Add(1)
+			started.Add(1)
+		case workflow.Completed:
+			started.Add(1)
 		}
 	}
 
 	exceededFailures := func() bool {
-		if h.block.ToleratedFailures >= 0 && failures.Load() > int64(h.block.ToleratedFailures) {
-			return true
+		return h.block.ExceedsToleratedFailures(int(failures.Load()), int(started.Load()))
+	}
+	defer func() {
+		// The Sequences that were drained did not fail.
+		if !s.gate.closing() {
+			s.metrics.toleratedFailures(req.Ctx, h.block, int(failures.Load()), int(started.Load()))
 		}
-		return false
+	}()
+
+	// seqReason holds the last Sequence error that has its own FailureReason, a Timeout or a lock that
+	// could not be acquired, so that a Block that fails because of its Sequences records why.
+	seqReason := atomic.Pointer[error]{}
+	failedErr := func() error {
+		err := fmt.Errorf("block(%s) has exceeded the tolerated failures", h.block.Name)
+		if reason := seqReason.Load(); reason != nil {
+			err = fmt.Errorf("%w: %w", err, *reason)
+		}
+		return err
 	}
 
 	// So the limiter is pretty standard, but you might be asking why we have one if the pool is already limiting.
 	// Its because g.Go() that uses the pool is going to fire off whatever you give it, even if it blocks on waiting for the pool
 	// to have room. So if we call g.Go(), and it blocks and in one that is currently running we go over the failures, we will
 	// still end up running the one we just queued up. So we use the limiter to block the g.Go() from even being called.
-	limiter := make(chan struct{}, h.block.Concurrency)
+	// The limiter also steps the concurrency up when the block has a Ramp.
+	limiter := newRampLimiter(h.block)
 
 	pool := context.Pool(req.Ctx).Limited(h.block.Concurrency)
 
 	g := pool.Group()
 
+	// Sequences are submitted with a Context that cannot be cancelled so that a Sequence that we
+	// have accepted always runs and releases the limiter. A Stop is detected by execSeq using req.Ctx.
+	submitCtx := context.WithoutCancel(req.Ctx)
+
 	for i := 0; i < len(h.block.Sequences); i++ {
 		seq := h.block.Sequences[i]
 		if seq.State.Status == workflow.Completed || seq.State.Status == workflow.Failed {
 			continue
 		}
 
+		// We acquire a slot before checking for a pause so that a Sequence is never started after
+		// a pause has been requested.
+		limiter.acquire()
+
+		s.waitPaused(req)
+
+		if halted(req.Ctx) != nil {
+			limiter.cancel()
+			break
+		}
+
 		if _, err := req.Data.contChecksPassing(); err != nil {
+			limiter.cancel()
 			h.block.State.Status = workflow.Failed
 			req.Data.err = err
 			req.Next = s.BlockDeferredChecks
@@ -346,25 +516,30 @@ func (s *States) ExecuteSequences(req statemachine.Request[Data]) statemachine.R
 		}
 
 		if exceededFailures() {
+			limiter.cancel()
 			h.block.State.Status = workflow.Failed
-			req.Data.err = fmt.Errorf("block(%s) has exceeded the tolerated failures", h.block.Name)
+			req.Data.err = failedErr()
 			req.Next = s.BlockDeferredChecks
 			return req
 		}
 
-		limiter <- struct{}{}
+		started.Add(1)
 		g.Go(
-			req.Ctx,
-			func(ctx context.Context) error {
-				defer func() { <-limiter }()
-
+			submitCtx,
+			func(ctx context.Context) (err error) {
 				// Defense in depth to make sure we don't run more than we should.
 				if exceededFailures() {
+					limiter.cancel()
 					return fmt.Errorf("exceeded tolerated failures")
 				}
-
-				err := s.execSeq(ctx, seq)
-				if err != nil {
+				defer func() { limiter.release(err == nil) }()
+
+				err = s.execSeq(req.Ctx, seq)
+				// A Sequence that was stopped, by a user or because the Plan or Block timed out, is not a failure.
+				if err != nil && seq.State.Status != workflow.Stopped {
+					if errors.Is(err, errTimeout) || errors.Is(err, storage.ErrLocked) {
+						seqReason.Store(&err)
+					}
 					failures.Add(1)
 				}
 				return err
@@ -372,13 +547,19 @@ func (s *States) ExecuteSequences(req statemachine.Request[Data]) statemachine.R
 		)
 	}
 
-	waitCtx := context.WithoutCancel(req.Ctx)
-	g.Wait(waitCtx) // We don't care about the error here, we just want to wait for all sequences to finish.'
+	g.Wait(submitCtx) // We don't care about the error here, we just want to wait for all sequences to finish.'
+
+	if err := halted(req.Ctx); err != nil {
+		h.block.State.Status = workflow.Stopped
+		req.Data.err = err
+		req.Next = s.BlockDeferredChecks
+		return req
+	}
 
 	// Need to recheck in case the last sequence failed and sent us over the edge.
-	if h.block.ToleratedFailures >= 0 && failures.Load() > int64(h.block.ToleratedFailures) {
+	if exceededFailures() {
 		h.block.State.Status = workflow.Failed
-		req.Data.err = fmt.Errorf("block(%s) has exceeded the tolerated failures", h.block.Name)
+		req.Data.err = failedErr()
 		req.Next = s.BlockDeferredChecks
 		return req
 	}
@@ -413,8 +594,12 @@ func (s *States) BlockDeferredChecks(req statemachine.Request[Data]) statemachin
 		return req
 	}
 
-	err := s.runChecksOnce(req.Ctx, h.block.DeferredChecks)
+	// DeferredChecks must run even if the Plan has been stopped.
+	err := s.runChecksOnce(context.WithoutCancel(req.Ctx), h.block.DeferredChecks)
 	if err != nil {
+		if h.block.State.Status == workflow.Stopped {
+			return req
+		}
 		h.block.State.Status = workflow.Failed
 		req.Data.err = err
 		return req
@@ -427,12 +612,27 @@ func (s *States) BlockDeferredChecks(req statemachine.Request[Data]) statemachin
 func (s *States) BlockEnd(req statemachine.Request[Data]) statemachine.Request[Data] {
 	h := req.Data.blocks[0]
 
-	defer func() {
-		h.block.State.End = s.now()
-		if err := s.store.UpdateBlock(req.Ctx, h.block); err != nil {
-			log.Fatalf("failed to write Block: %v", err)
-		}
-	}()
+	req = s.finishBlock(req)
+	// A Block that exceeded its Timeout fails, even if it was stopped by the timeout or was in its ExitDelay.
+	if endBlockTimeout(&req, h) {
+		req.Next = s.afterBlock(req, s.PlanDeferredChecks)
+	}
+	s.rollbackBlock(req.Ctx, h.block)
+	s.unlock(req.Ctx, h.block.ID, h.block.Locks)
+
+	h.block.State.End = s.now()
+	eventsFrom(req.Ctx).block(h.block)
+	if err := s.store.UpdateBlock(req.Ctx, h.block); err != nil {
+		log.Fatalf("failed to write Block: %v", err)
+	}
+	s.endRunSpan(h.span, h.block.State.Status, req.Data.err)
+	s.metrics.blockEnded(req.Ctx, h.block, s.drained(h.block.State.Status))
+	return req
+}
+
+// finishBlock records the final status of the current block and moves to the next block.
+func (s *States) finishBlock(req statemachine.Request[Data]) statemachine.Request[Data] {
+	h := req.Data.blocks[0]
 
 	// Don't use checksCompleted() here, we want to run the block if it is not completed.
 	if h.block.BypassChecks != nil && h.block.BypassChecks.State.Status == workflow.Completed {
@@ -454,23 +654,27 @@ func (s *States) BlockEnd(req statemachine.Request[Data]) statemachine.Request[D
 			if err != nil {
 				h.block.State.Status = workflow.Failed
 				req.Data.err = err
-				req.Next = s.PlanDeferredChecks
+				req.Next = s.afterBlock(req, s.PlanDeferredChecks)
 				return req
 			}
 		}
 
-		if h.block.State.Status == workflow.Running {
+		switch h.block.State.Status {
+		case workflow.Running:
 			h.block.State.Status = workflow.Completed
-		} else {
+		case workflow.Stopped:
+			req.Next = s.afterBlock(req, s.PlanDeferredChecks)
+			return req
+		default:
 			h.block.State.Status = workflow.Failed
-			req.Next = s.PlanDeferredChecks
+			req.Next = s.afterBlock(req, s.PlanDeferredChecks)
 			return req
 		}
 
 		if err := after(req.Ctx, h.block.ExitDelay); err != nil {
 			h.block.State.Status = workflow.Stopped
 			req.Data.err = err
-			req.Next = s.PlanDeferredChecks
+			req.Next = s.afterBlock(req, s.PlanDeferredChecks)
 			return req
 		}
 	}
@@ -480,7 +684,7 @@ func (s *States) BlockEnd(req statemachine.Request[Data]) statemachine.Request[D
 	} else {
 		req.Data.blocks = req.Data.blocks[1:]
 	}
-	req.Next = s.ExecuteBlock
+	req.Next = s.afterBlock(req, s.BlockApproval)
 	return req
 }
 
@@ -503,6 +707,12 @@ func (s *States) PlanPostChecks(req statemachine.Request[Data]) statemachine.Req
 		}
 	}
 
+	// A stopped Plan does not run its PostChecks, they will be marked Stopped in End.
+	if err := halted(req.Ctx); err != nil {
+		req.Data.err = err
+		return req
+	}
+
 	if req.Data.Plan.PostChecks != nil && !isCompleted(req.Data.Plan.PostChecks) {
 		if err := s.runChecksOnce(req.Ctx, req.Data.Plan.PostChecks); err != nil {
 			req.Data.err = err
@@ -520,7 +730,8 @@ func (s *States) PlanDeferredChecks(req statemachine.Request[Data]) statemachine
 	if req.Data.Plan.DeferredChecks == nil || isCompleted(req.Data.Plan.DeferredChecks) {
 		return req
 	}
-	if err := s.runChecksOnce(req.Ctx, req.Data.Plan.DeferredChecks); err != nil {
+	// DeferredChecks must run even if the Plan has been stopped.
+	if err := s.runChecksOnce(context.WithoutCancel(req.Ctx), req.Data.Plan.DeferredChecks); err != nil {
 		req.Data.err = err
 		return req
 	}
@@ -531,9 +742,13 @@ func (s *States) PlanDeferredChecks(req statemachine.Request[Data]) statemachine
 // This will do the calculations of the final state of the Plan.
 func (s *States) End(req statemachine.Request[Data]) statemachine.Request[Data] {
 	plan := req.Data.Plan
+	if req.Data.timeoutCancel != nil {
+		defer req.Data.timeoutCancel()
+	}
 	defer func() {
 		plan.State.End = s.now()
-		if err := s.store.UpdatePlan(req.Ctx, plan); err != nil {
+		eventsFrom(req.Ctx).plan(plan)
+		if err := s.updatePlan(req.Ctx, plan); err != nil {
 			log.Fatalf("failed to write Plan: %v", err)
 		}
 	}()
@@ -543,6 +758,14 @@ func (s *States) End(req statemachine.Request[Data]) statemachine.Request[Data]
 		req.Data.contCancel()
 	}
 
+	// Blocks and Sequences release their locks when they end, this releases the Plan's locks
+	// and any that were left held because the Plan ended early.
+	s.unlockAll(req.Ctx, plan)
+
+	if halted(req.Ctx) != nil {
+		s.stopUnstarted(context.WithoutCancel(req.Ctx), plan)
+	}
+
 	// Runs a new statemachine to calculate the final state of the Plan.
 	f := finalStates{}
 	req.Next = f.start
@@ -556,10 +779,17 @@ func (s *States) End(req statemachine.Request[Data]) statemachine.Request[Data]
 	}
 	req.Next = nil
 
-	// Promote Data.err to the request if it is not nil.
-	if req.Data.err != nil {
+	// Promote Data.err to the request if it is not nil, keeping the error of the final state, such as
+	// which Blocks failed.
+	switch {
+	case req.Data.err == nil:
+	case req.Err == nil:
 		req.Err = req.Data.err
+	case !errors.Is(req.Err, req.Data.err):
+		req.Err = fmt.Errorf("%w: %w", req.Err, req.Data.err)
 	}
+	s.endRunSpan(req.Data.span, plan.State.Status, req.Data.err)
+	s.metrics.planEnded(req, s.drained(plan.State.Status))
 
 	return req
 }
@@ -599,7 +829,11 @@ func (s *States) runPreChecks(ctx context.Context, preChecks *workflow.Checks, c
 
 	if contChecks != nil {
 		g.Go(ctx, func(ctx context.Context) error {
-			return s.runChecksOnce(ctx, contChecks)
+			err := s.runChecksOnce(ctx, contChecks)
+			if err != nil && !s.gate.closing() {
+				s.metrics.contChecksFailed(ctx)
+			}
+			return err
 		})
 	}
 
@@ -631,6 +865,9 @@ func (s *States) runContChecks(ctx context.Context, checks *workflow.Checks, res
 			err := s.runChecksOnce(context.WithoutCancel(ctx), checks)
 			resultCh <- err
 			if err != nil {
+				if !s.gate.closing() {
+					s.metrics.contChecksFailed(ctx)
+				}
 				return
 			}
 		}
@@ -638,19 +875,24 @@ func (s *States) runContChecks(ctx context.Context, checks *workflow.Checks, res
 }
 
 // runChecksOnce runs Checks once and writes the result to the store.
-func (s *States) runChecksOnce(ctx context.Context, checks *workflow.Checks) error {
+func (s *States) runChecksOnce(ctx context.Context, checks *workflow.Checks) (err error) {
 	if s.checksRunner != nil {
 		return s.checksRunner(ctx, checks)
 	}
 
+	ctx, span := s.startSpan(ctx, "Checks", keyedAttrs(ctx, checks.ID, checks.Key, "")...)
+	defer func() { s.endRunSpan(span, checks.State.Status, err) }()
+
 	resetActions(checks.Actions)
 
 	checks.State.Start = s.now()
 
+	eventsFrom(ctx).checks(checks)
 	if err := s.store.UpdateChecks(ctx, checks); err != nil {
 		log.Fatalf("failed to write Checks: %v", err)
 	}
 	defer func() {
+		eventsFrom(ctx).checks(checks)
 		if err := s.store.UpdateChecks(ctx, checks); err != nil {
 			log.Fatalf("failed to write Checks: %v", err)
 		}
@@ -676,6 +918,7 @@ func (s *States) runActionsParallel(ctx context.Context, actions []*workflow.Act
 	for _, action := range actions {
 		action.State.Status = workflow.Running
 		action.State.Start = s.now()
+		eventsFrom(ctx).action(action)
 		if err := s.store.UpdateAction(ctx, action); err != nil {
 			log.Fatalf("failed to write Action: %v", err)
 		}
@@ -696,21 +939,60 @@ func (s *States) runActionsParallel(ctx context.Context, actions []*workflow.Act
 
 // execSeq executes a sequence of actions. Any Job failures fail the Sequnence. The Job may retry
 // based on the retry policy.
-func (s *States) execSeq(ctx context.Context, seq *workflow.Sequence) error {
+func (s *States) execSeq(ctx context.Context, seq *workflow.Sequence) (err error) {
+	ctx, span := s.startSpan(ctx, "Sequence", keyedAttrs(ctx, seq.ID, seq.Key, seq.Name)...)
+	defer func() {
+		s.endRunSpan(span, seq.State.Status, err)
+		s.metrics.seqEnded(ctx, seq, s.drained(seq.State.Status))
+	}()
+
+	// runCtx is only used to run Actions, so that a Sequence that times out can still be written.
+	runCtx := ctx
+	if seq.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = withTimeout(ctx, workflow.OTSequence, seq.ID, seq.Name, s.now(), seq.Timeout)
+		defer cancel()
+	}
+
 	seq.State.Status = workflow.Running
 	seq.State.Start = s.now()
+	eventsFrom(ctx).sequence(seq)
 	if err := s.store.UpdateSequence(ctx, seq); err != nil {
 		log.Fatalf("failed to write Sequence: %v", err)
 	}
 	defer func() {
 		seq.State.End = s.now()
+		eventsFrom(ctx).sequence(seq)
 		if err := s.store.UpdateSequence(ctx, seq); err != nil {
 			log.Fatalf("failed to write Sequence: %v", err)
 		}
 	}()
 
+	if err := s.lock(runCtx, seq.ID, context.PlanID(ctx), seq.Locks); err != nil {
+		if hErr := s.seqHalted(runCtx, seq); hErr != nil {
+			return hErr
+		}
+		seq.State.Status = workflow.Failed
+		return err
+	}
+	defer s.unlock(ctx, seq.ID, seq.Locks)
+	// This runs before the locks are released.
+	defer s.rollbackSeq(ctx, seq)
+
 	for _, action := range seq.Actions {
-		if err := s.runAction(ctx, action, s.store); err != nil {
+		// A recovered Sequence does not run the Actions that already completed.
+		if action.State != nil && action.State.Status == workflow.Completed {
+			continue
+		}
+		// If the Plan was stopped or timed out, we don't start any new Actions. The remaining Actions
+		// are marked Stopped in End.
+		if hErr := s.seqHalted(runCtx, seq); hErr != nil {
+			return hErr
+		}
+		if err := s.runAction(runCtx, action, s.store); err != nil {
+			if hErr := s.seqHalted(runCtx, seq); hErr != nil {
+				return hErr
+			}
 			seq.State.Status = workflow.Failed
 			return err
 		}
@@ -720,14 +1002,41 @@ func (s *States) execSeq(ctx context.Context, seq *workflow.Sequence) error {
 	return nil
 }
 
+// seqHalted sets the status of seq and returns the error from halted() if ctx is halted. A Sequence that
+// exceeded its own Timeout fails, any other halt stops it.
+func (s *States) seqHalted(ctx context.Context, seq *workflow.Sequence) error {
+	err := halted(ctx)
+	if err == nil {
+		return nil
+	}
+	seq.State.Status = workflow.Stopped
+	if _, ok := timedOut(ctx, seq.ID); ok {
+		seq.State.Status = workflow.Failed
+	}
+	return err
+}
+
 // runAction runs an action and returns the response or an error. If the response is not the expected
 // type, it returns a permanent error that prevents retries.
-func (s *States) runAction(ctx context.Context, action *workflow.Action, updater storage.ActionUpdater) error {
+func (s *States) runAction(ctx context.Context, action *workflow.Action, updater storage.ActionUpdater) (err error) {
+	// A draining States does not start new Actions.
+	ctx, ok := s.gate.enterAction(ctx)
+	if !ok {
+		return errDrained
+	}
+	defer s.gate.exitAction()
+
 	if s.actionRunner != nil {
 		return s.actionRunner(ctx, action, updater)
 	}
 
 	ctx = context.SetActionID(ctx, action.ID)
+	ctx, span := s.startSpan(ctx, "Action", actionAttrs(ctx, action)...)
+	defer func() {
+		actionErrCode(span, action)
+		endSpan(span, action.State.Status, err)
+		s.metrics.actionEnded(ctx, action)
+	}()
 
 	req := statemachine.Request[actions.Data]{
 		Ctx: ctx,
@@ -735,10 +1044,11 @@ func (s *States) runAction(ctx context.Context, action *workflow.Action, updater
 			Action:   action,
 			Updater:  updater,
 			Registry: s.registry,
+			Lookup:   lookupFrom(ctx),
 		},
 		Next: s.actionsSM.Start,
 	}
-	_, err := statemachine.Run("run action statemachine", req)
+	_, err = statemachine.Run("run action statemachine", req)
 	if err != nil {
 		return err
 	}
@@ -753,6 +1063,105 @@ func (s *States) now() time.Time {
 	return s.nower().UTC()
 }
 
+// stopUnstarted marks every object in the Plan that has not started as Stopped and writes
+// the change to the store. This is used when a user has stopped the Plan.
+func (s *States) stopUnstarted(ctx context.Context, plan *workflow.Plan) {
+	stop := func(state *workflow.State) bool {
+		if state == nil || state.Status != workflow.NotStarted {
+			return false
+		}
+		state.Status = workflow.Stopped
+		state.End = s.now()
+		return true
+	}
+
+	stopActions := func(actions []*workflow.Action) {
+		for _, a := range actions {
+			if stop(a.State) {
+				eventsFrom(ctx).action(a)
+				if err := s.store.UpdateAction(ctx, a); err != nil {
+					log.Fatalf("failed to write Action: %v", err)
+				}
+			}
+		}
+	}
+	stopChecks := func(checks ...*workflow.Checks) {
+		for _, c := range checks {
+			if c == nil {
+				continue
+			}
+			stopActions(c.Actions)
+			if stop(c.State) {
+				eventsFrom(ctx).checks(c)
+				if err := s.store.UpdateChecks(ctx, c); err != nil {
+					log.Fatalf("failed to write Checks: %v", err)
+				}
+			}
+		}
+	}
+
+	stopChecks(plan.BypassChecks, plan.PreChecks, plan.ContChecks)
+	for _, b := range plan.Blocks {
+		stopChecks(b.BypassChecks, b.PreChecks, b.ContChecks)
+		if b.Generator != nil {
+			stopActions([]*workflow.Action{b.Generator})
+		}
+		for _, seq := range b.Sequences {
+			stopActions(seq.Actions)
+			if stop(seq.State) {
+				eventsFrom(ctx).sequence(seq)
+				if err := s.store.UpdateSequence(ctx, seq); err != nil {
+					log.Fatalf("failed to write Sequence: %v", err)
+				}
+			}
+		}
+		// A SubPlan that started was stopped by its own statemachine.
+		if b.SubPlan != nil && stop(b.SubPlan.State) {
+			s.stopUnstarted(ctx, b.SubPlan)
+			eventsFrom(ctx).plan(b.SubPlan)
+			if err := s.updatePlan(ctx, b.SubPlan); err != nil {
+				log.Fatalf("failed to write Plan: %v", err)
+			}
+		}
+		stopChecks(b.PostChecks, b.DeferredChecks)
+		if stop(b.State) {
+			eventsFrom(ctx).block(b)
+			if err := s.store.UpdateBlock(ctx, b); err != nil {
+				log.Fatalf("failed to write Block: %v", err)
+			}
+		}
+	}
+	stopChecks(plan.PostChecks, plan.DeferredChecks)
+}
+
+// WithStop returns a copy of ctx for running a Plan and a function that stops the Plan. Only a Plan whose
+// Context was cancelled with the returned function is Stopped, a Context that is done for any other reason,
+// such as an exceeded Timeout, is not.
+func WithStop(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return ctx, func() { cancel(errStopped) }
+}
+
+// stopped returns true if the Plan has been stopped by a user. A user stops a Plan by
+// cancelling the Context the statemachine was started with using the function from WithStop().
+func stopped(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errStopped)
+}
+
+// halted returns why ctx is done, or nil if it is not. This is errStopped if the Plan was stopped by a user,
+// the timeoutError of a Plan, Block or Sequence whose Timeout was exceeded or the cause the Context was
+// cancelled with. No new objects are started once the Context is halted and the objects that are interrupted
+// are Stopped. Only the object whose Timeout was exceeded fails.
+func halted(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	if stopped(ctx) {
+		return errStopped
+	}
+	return context.Cause(ctx)
+}
+
 // resetActions adjusts all the actions to their initial un-started state.
 // This is used by the ContChecks to reset the actions before each run.
 func resetActions(actions []*workflow.Action) {
@@ -768,8 +1177,10 @@ func isType(a, b interface{}) bool {
 	return reflect.TypeOf(a) == reflect.TypeOf(b)
 }
 
+// after waits for the duration or until the Context is cancelled, in which case it returns the error from
+// halted(). A dry run does not wait.
 func after(ctx context.Context, d time.Duration) error {
-	if d <= 0 {
+	if d <= 0 || context.DryRun(ctx) {
 		return nil
 	}
 
@@ -778,7 +1189,7 @@ func after(ctx context.Context, d time.Duration) error {
 
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return halted(ctx)
 	case <-t.C:
 	}
 	return nil
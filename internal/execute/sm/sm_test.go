@@ -2,6 +2,7 @@ package sm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"runtime"
@@ -235,8 +236,8 @@ func TestPlanStartContChecks(t *testing.T) {
 				t.Errorf("TestPlanStartContChecks(%s): got req.Data.contCancel == nil, want req.Data.contCancel != nil", test.name)
 			}
 		}
-		if methodName(req.Next) != methodName(states.ExecuteBlock) {
-			t.Errorf("TestPlanStartContChecks(%s): got req.Next == %s, want req.Next == %s", test.name, methodName(req.Next), methodName(states.ExecuteSequences))
+		if methodName(req.Next) != methodName(states.PlanApproval) {
+			t.Errorf("TestPlanStartContChecks(%s): got req.Next == %s, want req.Next == %s", test.name, methodName(req.Next), methodName(states.PlanApproval))
 		}
 	}
 }
@@ -472,8 +473,8 @@ func TestBlockStartContChecks(t *testing.T) {
 		if test.action != nil {
 			<-req.Data.blocks[0].contCheckResult
 		}
-		if methodName(req.Next) != methodName(states.ExecuteSequences) {
-			t.Errorf("TestBlockStartContChecks(%s): got req.Next == %s, want req.Next == %s", test.name, methodName(req.Next), methodName(states.ExecuteSequences))
+		if methodName(req.Next) != methodName(states.BlockGenerate) {
+			t.Errorf("TestBlockStartContChecks(%s): got req.Next == %s, want req.Next == %s", test.name, methodName(req.Next), methodName(states.BlockGenerate))
 		}
 	}
 }
@@ -495,6 +496,7 @@ func TestExecuteSequences(t *testing.T) {
 		name            string
 		block           *workflow.Block
 		contCheckFail   bool
+		stopped         bool
 		wantPluginCalls int
 		wantStatus      workflow.Status
 		wantErr         bool
@@ -542,6 +544,55 @@ func TestExecuteSequences(t *testing.T) {
 			wantStatus:      workflow.Failed,
 			wantErr:         true,
 		},
+		{
+			name: "Success: failures within ToleratedFailurePercent",
+			block: &workflow.Block{
+				ToleratedFailurePercent: 50,
+				Concurrency:             1,
+				Sequences: []*workflow.Sequence{
+					clone.Sequence(ctx, sequenceWithFailure, cloneOpts...),
+					clone.Sequence(ctx, sequenceWithFailure, cloneOpts...),
+					clone.Sequence(ctx, sequenceWithSuccess, cloneOpts...),
+					clone.Sequence(ctx, sequenceWithSuccess, cloneOpts...),
+				},
+			},
+			wantPluginCalls: 4,
+		},
+		{
+			name: "Error: Exceed ToleratedFailurePercent of all Sequences",
+			block: &workflow.Block{
+				ToleratedFailurePercent: 25,
+				Concurrency:             1,
+				Sequences: []*workflow.Sequence{
+					clone.Sequence(ctx, sequenceWithFailure, cloneOpts...),
+					clone.Sequence(ctx, sequenceWithFailure, cloneOpts...), // We should die after this.
+					clone.Sequence(ctx, sequenceWithSuccess, cloneOpts...), // Never should be called.
+					clone.Sequence(ctx, sequenceWithSuccess, cloneOpts...), // Never should be called.
+				},
+			},
+			wantPluginCalls: 2,
+			wantStatus:      workflow.Failed,
+			wantErr:         true,
+		},
+		{
+			name: "Error: Exceed ToleratedFailurePercent of the sample",
+			block: &workflow.Block{
+				ToleratedFailurePercent: 50,
+				ToleratedFailureSample:  2,
+				Concurrency:             1,
+				Sequences: []*workflow.Sequence{
+					clone.Sequence(ctx, sequenceWithSuccess, cloneOpts...),
+					clone.Sequence(ctx, sequenceWithFailure, cloneOpts...),
+					clone.Sequence(ctx, sequenceWithFailure, cloneOpts...), // We should die after this.
+					clone.Sequence(ctx, sequenceWithSuccess, cloneOpts...), // Never should be called.
+					clone.Sequence(ctx, sequenceWithSuccess, cloneOpts...), // Never should be called.
+					clone.Sequence(ctx, sequenceWithSuccess, cloneOpts...), // Never should be called.
+				},
+			},
+			wantPluginCalls: 3,
+			wantStatus:      workflow.Failed,
+			wantErr:         true,
+		},
 		{
 			name: "Error: Continuous Checks fail",
 			block: &workflow.Block{
@@ -555,6 +606,19 @@ func TestExecuteSequences(t *testing.T) {
 			wantStatus:    workflow.Failed,
 			wantErr:       true,
 		},
+		{
+			name: "Error: Plan was stopped",
+			block: &workflow.Block{
+				ToleratedFailures: 0,
+				Concurrency:       1,
+				Sequences: []*workflow.Sequence{
+					clone.Sequence(ctx, sequenceWithSuccess, cloneOpts...), // Never should be called.
+				},
+			},
+			stopped:    true,
+			wantStatus: workflow.Stopped,
+			wantErr:    true,
+		},
 		{
 			name: "Success",
 			block: &workflow.Block{
@@ -585,6 +649,11 @@ func TestExecuteSequences(t *testing.T) {
 		req := statemachine.Request[Data]{
 			Ctx: context.Background(),
 		}
+		if test.stopped {
+			var cancel context.CancelFunc
+			req.Ctx, cancel = WithStop(req.Ctx)
+			cancel()
+		}
 		req.Data.blocks = []block{{block: test.block}}
 		test.block.State = &workflow.State{}
 		if test.contCheckFail {
@@ -764,6 +833,7 @@ func TestBlockEnd(t *testing.T) {
 		name            string
 		data            Data
 		contCheckResult error
+		stopped         bool
 		wantErr         bool
 		wantBlockStatus workflow.Status
 		wantNextState   statemachine.State[Data]
@@ -780,13 +850,24 @@ func TestBlockEnd(t *testing.T) {
 			wantNextState:   states.PlanDeferredChecks,
 			wantBlocksLen:   1,
 		},
+		{
+			name: "Stopped: during the ExitDelay",
+			data: Data{
+				blocks: []block{{block: &workflow.Block{ExitDelay: time.Hour}}},
+			},
+			stopped:         true,
+			wantErr:         true,
+			wantBlockStatus: workflow.Stopped,
+			wantNextState:   states.PlanDeferredChecks,
+			wantBlocksLen:   1,
+		},
 		{
 			name: "Success: bypasschecks success",
 			data: Data{
 				blocks: []block{{block: &workflow.Block{BypassChecks: &workflow.Checks{State: &workflow.State{Status: workflow.Completed}}}}},
 			},
 			wantBlockStatus: workflow.Completed,
-			wantNextState:   states.ExecuteBlock,
+			wantNextState:   states.BlockApproval,
 		},
 		{
 			name: "Success: no more blocks",
@@ -794,7 +875,7 @@ func TestBlockEnd(t *testing.T) {
 				blocks: []block{{}},
 			},
 			wantBlockStatus: workflow.Completed,
-			wantNextState:   states.ExecuteBlock,
+			wantNextState:   states.BlockApproval,
 			wantBlocksLen:   0,
 		},
 		{
@@ -803,7 +884,7 @@ func TestBlockEnd(t *testing.T) {
 				blocks: []block{{}, {}},
 			},
 			wantBlockStatus: workflow.Completed,
-			wantNextState:   states.ExecuteBlock,
+			wantNextState:   states.BlockApproval,
 			wantBlocksLen:   1,
 		},
 	}
@@ -827,6 +908,11 @@ func TestBlockEnd(t *testing.T) {
 			Ctx:  context.Background(),
 			Data: test.data,
 		}
+		if test.stopped {
+			var stop context.CancelFunc
+			req.Ctx, stop = WithStop(req.Ctx)
+			stop()
+		}
 
 		req.Data.blocks[0].contCheckResult = make(chan error, 1)
 		if test.contCheckResult != nil {
@@ -843,6 +929,9 @@ func TestBlockEnd(t *testing.T) {
 		if test.wantErr != (req.Data.err != nil) {
 			t.Errorf("TestBlockEnd(%s): got err == %v, want err == %v", test.name, req.Data.err, test.wantErr)
 		}
+		if test.stopped && !errors.Is(req.Data.err, errStopped) {
+			t.Errorf("TestBlockEnd(%s): got err == %v, want err == %v", test.name, req.Data.err, errStopped)
+		}
 		if block.State.Status != test.wantBlockStatus {
 			t.Errorf("TestBlockEnd(%s): got block status == %v, want block status == %v", test.name, block.State.Status, test.wantBlockStatus)
 		}
@@ -987,6 +1076,30 @@ func TestEnd(t *testing.T) {
 	}
 }
 
+func TestEndBlockFailure(t *testing.T) {
+	dataErr := fmt.Errorf("error")
+
+	plan := &workflow.Plan{
+		State: &workflow.State{Status: workflow.Running},
+		Blocks: []*workflow.Block{
+			{Name: "a", State: &workflow.State{Status: workflow.Failed}},
+			{Name: "b", State: &workflow.State{Status: workflow.Completed}},
+		},
+	}
+	states := &States{store: &fakeUpdater{}}
+
+	req := statemachine.Request[Data]{Data: Data{Plan: plan, err: dataErr}, Ctx: context.Background()}
+	req = states.End(req)
+
+	// The error keeps the failed Blocks from the final state along with the error the Plan ended with.
+	if req.Err == nil || !strings.Contains(req.Err.Error(), "blocks(a) failed") {
+		t.Errorf("TestEndBlockFailure: got err == %v, want the failed Block names", req.Err)
+	}
+	if !errors.Is(req.Err, dataErr) {
+		t.Errorf("TestEndBlockFailure: got err == %v, want it to wrap %v", req.Err, dataErr)
+	}
+}
+
 // methodName returns the name of the method of the given value.
 func methodName(method any) string {
 	if method == nil {
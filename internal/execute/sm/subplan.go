@@ -0,0 +1,67 @@
+package sm
+
+import (
+	"fmt"
+
+	"github.com/element-of-surprise/coercion/workflow"
+
+	"github.com/gostdlib/base/statemachine"
+)
+
+// ExecuteSubPlan runs the SubPlan of the current block to completion. The SubPlan shares the Plan's Pauser,
+// Approvals and Events, so pausing or stopping the Plan pauses or stops the SubPlan, Decisions on the SubPlan's
+// Approvals are delivered to the Plan and subscribers to the Plan's events see the SubPlan's events.
+// The block fails if the SubPlan fails and is Stopped if the SubPlan is stopped.
+func (s *States) ExecuteSubPlan(req statemachine.Request[Data]) statemachine.Request[Data] {
+	h := req.Data.blocks[0]
+	sub := h.block.SubPlan
+	req.Next = s.BlockPostChecks
+
+	runErr := s.runSubPlan(req, sub)
+	switch {
+	case sub.State.Status == workflow.Completed:
+		return req
+	case halted(req.Ctx) != nil:
+		h.block.State.Status = workflow.Stopped
+		req.Data.err = halted(req.Ctx)
+	case sub.State.Status == workflow.Stopped:
+		h.block.State.Status = workflow.Stopped
+		req.Data.err = errStopped
+	default:
+		err := fmt.Errorf("sub-plan(%s) failed", sub.Name)
+		if runErr != nil {
+			err = fmt.Errorf("%w: %w", err, runErr)
+		}
+		h.block.State.Status = workflow.Failed
+		req.Data.err = err
+	}
+	req.Next = s.BlockDeferredChecks
+	return req
+}
+
+// runSubPlan runs the SubPlan in its own statemachine and returns the error it ended with. A SubPlan that is
+// Running was recovered with its Plan and is recovered in turn. A SubPlan that has finished is not run again.
+func (s *States) runSubPlan(req statemachine.Request[Data], sub *workflow.Plan) error {
+	var next statemachine.State[Data]
+	switch sub.State.Status {
+	case workflow.NotStarted:
+		next = s.Start
+	case workflow.Running:
+		next = s.Recovery
+	default:
+		return nil
+	}
+
+	subReq := statemachine.Request[Data]{
+		Ctx: req.Ctx,
+		Data: Data{
+			Plan:      sub,
+			Pauser:    req.Data.Pauser,
+			Approvals: req.Data.Approvals,
+			Events:    req.Data.Events,
+		},
+		Next: next,
+	}
+	_, err := statemachine.Run(sub.Name, subReq)
+	return err
+}
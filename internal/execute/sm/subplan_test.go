@@ -0,0 +1,132 @@
+package sm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/gostdlib/base/statemachine"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestExecuteSubPlan(t *testing.T) {
+	t.Parallel()
+
+	subPlan := func(status workflow.Status, actions ...string) *workflow.Plan {
+		return &workflow.Plan{
+			ID:    workflow.NewV7(),
+			Name:  "sub",
+			State: &workflow.State{Status: status},
+			Blocks: []*workflow.Block{
+				{
+					ID:          workflow.NewV7(),
+					Name:        "block",
+					Concurrency: 1,
+					State:       &workflow.State{Status: workflow.NotStarted},
+					Sequences: []*workflow.Sequence{
+						{
+							ID:      workflow.NewV7(),
+							Name:    "seq",
+							State:   &workflow.State{Status: workflow.NotStarted},
+							Actions: rbActions(actions...),
+						},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		sub           *workflow.Plan
+		ctx           func() context.Context
+		wantRan       []string
+		wantSubStatus workflow.Status
+		wantStatus    workflow.Status
+		wantNext      statemachine.State[Data]
+		wantErr       bool
+	}{
+		{
+			name:          "Success",
+			sub:           subPlan(workflow.NotStarted, "a0", "a1"),
+			wantRan:       []string{"a0", "a1"},
+			wantSubStatus: workflow.Completed,
+			wantStatus:    workflow.Running,
+			wantNext:      (&States{}).BlockPostChecks,
+		},
+		{
+			name:          "SubPlan already Completed",
+			sub:           subPlan(workflow.Completed, "a0"),
+			wantSubStatus: workflow.Completed,
+			wantStatus:    workflow.Running,
+			wantNext:      (&States{}).BlockPostChecks,
+		},
+		{
+			name:          "SubPlan fails",
+			sub:           subPlan(workflow.NotStarted, "a0", "fail", "a2"),
+			wantRan:       []string{"a0", "fail"},
+			wantSubStatus: workflow.Failed,
+			wantStatus:    workflow.Failed,
+			wantNext:      (&States{}).BlockDeferredChecks,
+			wantErr:       true,
+		},
+		{
+			name:          "SubPlan already Failed",
+			sub:           subPlan(workflow.Failed, "a0"),
+			wantSubStatus: workflow.Failed,
+			wantStatus:    workflow.Failed,
+			wantNext:      (&States{}).BlockDeferredChecks,
+			wantErr:       true,
+		},
+		{
+			name: "Plan stopped",
+			sub:  subPlan(workflow.NotStarted, "a0"),
+			ctx: func() context.Context {
+				ctx, cancel := WithStop(context.Background())
+				cancel()
+				return ctx
+			},
+			wantSubStatus: workflow.Stopped,
+			wantStatus:    workflow.Stopped,
+			wantNext:      (&States{}).BlockDeferredChecks,
+			wantErr:       true,
+		},
+	}
+
+	for _, test := range tests {
+		runner := &rollbackRunner{}
+		states := &States{store: &fakeUpdater{}, actionRunner: runner.run}
+
+		b := &workflow.Block{
+			Name:    "parent",
+			SubPlan: test.sub,
+			State:   &workflow.State{Status: workflow.Running},
+		}
+		ctx := context.Background()
+		if test.ctx != nil {
+			ctx = test.ctx()
+		}
+		req := statemachine.Request[Data]{
+			Ctx:  ctx,
+			Data: Data{blocks: []block{{block: b}}},
+		}
+
+		req = states.ExecuteSubPlan(req)
+
+		if methodName(req.Next) != methodName(test.wantNext) {
+			t.Errorf("TestExecuteSubPlan(%s): got req.Next == %s, want req.Next == %s", test.name, methodName(req.Next), methodName(test.wantNext))
+		}
+		if (req.Data.err != nil) != test.wantErr {
+			t.Errorf("TestExecuteSubPlan(%s): got err == %v, wantErr == %v", test.name, req.Data.err, test.wantErr)
+		}
+		if b.State.Status != test.wantStatus {
+			t.Errorf("TestExecuteSubPlan(%s): got block status == %v, want %v", test.name, b.State.Status, test.wantStatus)
+		}
+		if test.sub.State.Status != test.wantSubStatus {
+			t.Errorf("TestExecuteSubPlan(%s): got sub-plan status == %v, want %v", test.name, test.sub.State.Status, test.wantSubStatus)
+		}
+		if diff := pretty.Compare(test.wantRan, runner.ran); diff != "" {
+			t.Errorf("TestExecuteSubPlan(%s): ran: -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/element-of-surprise/coercion/workflow/context"
 
 	"github.com/gostdlib/base/retry/exponential"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Name is the name of the testing plugin.
@@ -26,6 +27,8 @@ type Req struct {
 	Started chan struct{} `json:"-"`
 	// PauseUntil is a channel that Execute() will block on until closed.
 	PauseUntil chan struct{} `json:"-"`
+	// Log is a message that Execute() logs with context.Log(), if set.
+	Log string
 }
 
 type Resp struct {
@@ -59,6 +62,47 @@ type Plugin struct {
 	at atomic.Int64
 }
 
+var _ plugins.DryRunner = &DryRunPlugin{}
+
+// DryRunPlugin is a Plugin that also implements plugins.DryRunner.
+type DryRunPlugin struct {
+	*Plugin
+
+	// DryRuns is a count of how many times DryRun() was called.
+	// You should not set this.
+	DryRuns atomic.Int64
+}
+
+// DryRun reports what the plugin would do. If Req.Arg is "error", this returns an error.
+// Otherwise it returns a Resp with Arg set to "would " + Req.Arg.
+func (h *DryRunPlugin) DryRun(ctx context.Context, req any) (any, *plugins.Error) {
+	h.DryRuns.Add(1)
+
+	r, ok := req.(Req)
+	if !ok {
+		panic("invalid request object")
+	}
+	if r.Arg == "error" {
+		return nil, &plugins.Error{Message: "error"}
+	}
+	return Resp{Arg: "would " + r.Arg}, nil
+}
+
+var _ plugins.ConcurrencyLimiter = &LimitedPlugin{}
+
+// LimitedPlugin is a Plugin that also implements plugins.ConcurrencyLimiter.
+type LimitedPlugin struct {
+	*Plugin
+
+	// Max is the value returned by MaxConcurrent().
+	Max int
+}
+
+// MaxConcurrent returns Max.
+func (h *LimitedPlugin) MaxConcurrent() int {
+	return h.Max
+}
+
 func (h *Plugin) ResetCounts() {
 	h.MaxCount.Store(0)
 	h.Running.Store(0)
@@ -87,6 +131,9 @@ func (h *Plugin) Execute(ctx context.Context, req any) (any, *plugins.Error) {
 	if r.Started != nil {
 		close(r.Started)
 	}
+	if r.Log != "" {
+		context.Log(ctx).Info(r.Log, "arg", r.Arg)
+	}
 
 	if n > h.MaxCount.Load() {
 		h.MaxCount.Store(n)
@@ -111,6 +158,13 @@ func (h *Plugin) Execute(ctx context.Context, req any) (any, *plugins.Error) {
 			id := context.PlanID(ctx).String()
 			return Resp{Arg: id}, nil
 		}
+		if strings.ToLower(r.Arg) == "spanid" {
+			id := trace.SpanFromContext(ctx).SpanContext().SpanID().String()
+			return Resp{Arg: id}, nil
+		}
+		if after, ok := strings.CutPrefix(r.Arg, "echo:"); ok {
+			return Resp{Arg: after}, nil
+		}
 		return Resp{Arg: "ok"}, nil
 	}
 
@@ -0,0 +1,112 @@
+package sm
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+	"github.com/google/uuid"
+
+	"github.com/gostdlib/base/statemachine"
+)
+
+// errTimeout is wrapped by every timeoutError so callers can use errors.Is() to detect a timeout.
+var errTimeout = errors.New("timeout exceeded")
+
+// timeoutError is the cause of a Context cancellation when a Plan, Block or Sequence runs longer than its Timeout.
+type timeoutError struct {
+	// Type is the type of object that timed out.
+	Type workflow.ObjectType
+	// ID is the ID of the object that timed out.
+	ID uuid.UUID
+	// Name is the name of the object that timed out.
+	Name string
+	// Timeout is the Timeout that was exceeded.
+	Timeout time.Duration
+}
+
+// Error implements the error interface.
+func (t timeoutError) Error() string {
+	var kind string
+	switch t.Type {
+	case workflow.OTPlan:
+		kind = "plan"
+	case workflow.OTBlock:
+		kind = "block"
+	case workflow.OTSequence:
+		kind = "sequence"
+	default:
+		kind = t.Type.String()
+	}
+	return fmt.Sprintf("%s(%s) exceeded its timeout(%v)", kind, t.Name, t.Timeout)
+}
+
+// Unwrap returns errTimeout.
+func (t timeoutError) Unwrap() error {
+	return errTimeout
+}
+
+// withTimeout returns a Context that is cancelled with a timeoutError for the object when start + timeout is reached.
+func withTimeout(ctx context.Context, t workflow.ObjectType, id uuid.UUID, name string, start time.Time, timeout time.Duration) (context.Context, context.CancelFunc) {
+	te := timeoutError{Type: t, ID: id, Name: name, Timeout: timeout}
+	return context.WithDeadlineCause(ctx, start.Add(timeout), te)
+}
+
+// timedOut returns the timeoutError if the Context was cancelled because the object with id exceeded its Timeout.
+// A Context cancelled because a parent object timed out, or because a user stopped the Plan, returns false.
+func timedOut(ctx context.Context, id uuid.UUID) (timeoutError, bool) {
+	if ctx.Err() == nil {
+		return timeoutError{}, false
+	}
+	var te timeoutError
+	if errors.As(context.Cause(ctx), &te) && te.ID == id {
+		return te, true
+	}
+	return timeoutError{}, false
+}
+
+// startPlanTimeout sets a deadline on req.Ctx for the Plan's Timeout, measured from when the Plan started.
+// A recovered Plan keeps its original deadline.
+func startPlanTimeout(req *statemachine.Request[Data]) {
+	plan := req.Data.Plan
+	if plan.Timeout <= 0 {
+		return
+	}
+	req.Ctx, req.Data.timeoutCancel = withTimeout(req.Ctx, workflow.OTPlan, plan.ID, plan.Name, plan.State.Start, plan.Timeout)
+}
+
+// startBlockTimeout sets a deadline on req.Ctx for the Timeout of Block h, measured from start. If h does not hold
+// the Plan's Context, req.Ctx is saved in h so that endBlockTimeout() can restore it.
+func startBlockTimeout(req *statemachine.Request[Data], h *block, start time.Time) {
+	if h.block.Timeout <= 0 {
+		return
+	}
+	if h.planCtx == nil {
+		h.planCtx = req.Ctx
+	}
+	req.Ctx, h.timeoutCancel = withTimeout(req.Ctx, workflow.OTBlock, h.block.ID, h.block.Name, start, h.block.Timeout)
+}
+
+// endBlockTimeout stops the Timeout of Block h and restores the Plan's Context to req. If the Block exceeded
+// its Timeout before it completed, it is marked Failed, the timeout is recorded as the Plan's error and
+// this returns true.
+func endBlockTimeout(req *statemachine.Request[Data], h block) bool {
+	ctx := req.Ctx
+	if h.planCtx != nil {
+		req.Ctx = h.planCtx
+	}
+	if h.timeoutCancel == nil {
+		return false
+	}
+	te, ok := timedOut(ctx, h.block.ID)
+	h.timeoutCancel()
+
+	if !ok || h.block.State.Status == workflow.Completed {
+		return false
+	}
+	h.block.State.Status = workflow.Failed
+	req.Data.err = te
+	return true
+}
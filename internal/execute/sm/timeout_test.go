@@ -0,0 +1,116 @@
+package sm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+	"github.com/gostdlib/base/statemachine"
+)
+
+func TestTimedOut(t *testing.T) {
+	t.Parallel()
+
+	id := workflow.NewV7()
+
+	ctx, cancel := withTimeout(context.Background(), workflow.OTBlock, id, "block", time.Now(), time.Hour)
+	if _, ok := timedOut(ctx, id); ok {
+		t.Errorf("TestTimedOut: got timedOut == true before the deadline")
+	}
+	cancel()
+	if _, ok := timedOut(ctx, id); ok {
+		t.Errorf("TestTimedOut: got timedOut == true after cancel")
+	}
+
+	ctx, cancel = withTimeout(context.Background(), workflow.OTBlock, id, "block", time.Now().Add(-time.Hour), time.Minute)
+	defer cancel()
+	te, ok := timedOut(ctx, id)
+	if !ok {
+		t.Fatalf("TestTimedOut: got timedOut == false after the deadline")
+	}
+	if !errors.Is(te, errTimeout) {
+		t.Errorf("TestTimedOut: got err == %v, want err == %v", te, errTimeout)
+	}
+	if te.Error() != "block(block) exceeded its timeout(1m0s)" {
+		t.Errorf("TestTimedOut: got err == %q", te.Error())
+	}
+
+	// A child Context of a timed out object did not time out itself.
+	child, childCancel := withTimeout(ctx, workflow.OTSequence, workflow.NewV7(), "seq", time.Now(), time.Hour)
+	defer childCancel()
+	if _, ok := timedOut(child, te.ID); !ok {
+		t.Errorf("TestTimedOut: got timedOut == false for the parent's ID on the child Context")
+	}
+	if _, ok := timedOut(child, workflow.NewV7()); ok {
+		t.Errorf("TestTimedOut: got timedOut == true for an object that did not time out")
+	}
+}
+
+func TestEndBlockTimeout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		timeout    bool
+		expired    bool
+		status     workflow.Status
+		wantStatus workflow.Status
+		want       bool
+	}{
+		{
+			name:       "block has no timeout",
+			status:     workflow.Running,
+			wantStatus: workflow.Running,
+		},
+		{
+			name:       "block did not time out",
+			timeout:    true,
+			status:     workflow.Completed,
+			wantStatus: workflow.Completed,
+		},
+		{
+			name:       "block completed before it timed out",
+			timeout:    true,
+			expired:    true,
+			status:     workflow.Completed,
+			wantStatus: workflow.Completed,
+		},
+		{
+			name:       "block was stopped by its timeout",
+			timeout:    true,
+			expired:    true,
+			status:     workflow.Stopped,
+			wantStatus: workflow.Failed,
+			want:       true,
+		},
+	}
+
+	for _, test := range tests {
+		planCtx := context.Background()
+		h := block{block: &workflow.Block{ID: workflow.NewV7(), Name: "block", Timeout: time.Minute, State: &workflow.State{Status: test.status}}}
+		req := statemachine.Request[Data]{Ctx: planCtx}
+		if test.timeout {
+			start := time.Now()
+			if test.expired {
+				start = start.Add(-time.Hour)
+			}
+			startBlockTimeout(&req, &h, start)
+		}
+
+		got := endBlockTimeout(&req, h)
+		if got != test.want {
+			t.Errorf("TestEndBlockTimeout(%s): got %v, want %v", test.name, got, test.want)
+		}
+		if h.block.State.Status != test.wantStatus {
+			t.Errorf("TestEndBlockTimeout(%s): got status == %s, want status == %s", test.name, h.block.State.Status, test.wantStatus)
+		}
+		if req.Ctx != planCtx {
+			t.Errorf("TestEndBlockTimeout(%s): the Plan's Context was not restored", test.name)
+		}
+		if test.want && !errors.Is(req.Data.err, errTimeout) {
+			t.Errorf("TestEndBlockTimeout(%s): got err == %v, want err == %v", test.name, req.Data.err, errTimeout)
+		}
+	}
+}
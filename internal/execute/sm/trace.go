@@ -0,0 +1,147 @@
+package sm
+
+import (
+	"fmt"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+
+	"github.com/google/uuid"
+	"github.com/gostdlib/base/statemachine"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the tracer that creates the spans of a Plan's execution.
+const tracerName = "github.com/element-of-surprise/coercion"
+
+// Attribute keys that are set on the spans and metrics of a Plan's execution.
+const (
+	attrPlanID  = "coercion.plan.id"
+	attrID      = "coercion.id"
+	attrKey     = "coercion.key"
+	attrName    = "coercion.name"
+	attrPlugin  = "coercion.plugin"
+	attrStatus  = "coercion.status"
+	attrErrCode = "coercion.error.code"
+	// attrRecovered is set on the link from a recovered Plan's span to the trace of its first run.
+	attrRecovered = "coercion.recovered"
+	// attrDrained is set on the spans of objects that were left unfinished when the States was drained.
+	attrDrained = "coercion.drained"
+)
+
+// WithTracerProvider sets the trace.TracerProvider used to create the spans of a Plan's execution.
+// If this is not set, otel.GetTracerProvider() is used.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(s *States) error {
+		if tp == nil {
+			return fmt.Errorf("tracer provider cannot be nil")
+		}
+		s.tracer = tp.Tracer(tracerName)
+		return nil
+	}
+}
+
+// startSpan starts a span that is a child of any span in ctx.
+func (s *States) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return s.getTracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// getTracer returns the tracer set with WithTracerProvider(), or a tracer from otel.GetTracerProvider().
+func (s *States) getTracer() trace.Tracer {
+	if s.tracer == nil {
+		return otel.GetTracerProvider().Tracer(tracerName)
+	}
+	return s.tracer
+}
+
+// startPlanSpan starts the span for the Plan in req and sets it on req.Ctx. The first time a Plan runs, the
+// span's trace ID is recorded on the Plan. A Plan that already has a trace ID is being recovered, so the span is
+// linked to that trace.
+func (s *States) startPlanSpan(req *statemachine.Request[Data]) {
+	plan := req.Data.Plan
+
+	opts := []trace.SpanStartOption{trace.WithAttributes(objAttrs(plan.ID, uuid.Nil, plan.Name)...)}
+	if tid, err := trace.TraceIDFromHex(plan.TraceID); err == nil {
+		// Only the trace ID of the first run is stored, so the link does not point at a specific span.
+		link := trace.Link{
+			SpanContext: trace.NewSpanContext(trace.SpanContextConfig{TraceID: tid}),
+			Attributes:  []attribute.KeyValue{attribute.Bool(attrRecovered, true)},
+		}
+		opts = append(opts, trace.WithLinks(link))
+	}
+
+	req.Ctx, req.Data.span = s.getTracer().Start(req.Ctx, "Plan", opts...)
+
+	if sc := req.Data.span.SpanContext(); plan.TraceID == "" && sc.HasTraceID() {
+		plan.TraceID = sc.TraceID().String()
+	}
+}
+
+// endSpan ends span with the final status of its object. A span whose object did not complete has an error
+// status with err as its description. span may be nil.
+func endSpan(span trace.Span, status workflow.Status, err error) {
+	if span == nil {
+		return
+	}
+	span.SetAttributes(attribute.String(attrStatus, status.String()))
+	switch {
+	case status == workflow.Completed:
+		span.SetStatus(codes.Ok, "")
+	case err != nil:
+		span.SetStatus(codes.Error, err.Error())
+	case status != workflow.Running:
+		span.SetStatus(codes.Error, status.String())
+	}
+	span.End()
+}
+
+// endRunSpan ends span like endSpan, unless its object did not complete because the States is draining.
+// A drained Plan has not failed, it is resumed by recovery in a trace linked to this one, so its spans
+// are marked as drained instead of with an error status.
+func (s *States) endRunSpan(span trace.Span, status workflow.Status, err error) {
+	if span == nil || !s.drained(status) {
+		endSpan(span, status, err)
+		return
+	}
+	span.SetAttributes(attribute.Bool(attrDrained, true))
+	span.End()
+}
+
+// actionAttrs returns the attributes for the span of an Action.
+func actionAttrs(ctx context.Context, a *workflow.Action) []attribute.KeyValue {
+	return append(
+		objAttrs(context.PlanID(ctx), a.ID, a.Name),
+		attribute.String(attrKey, a.Key.String()),
+		attribute.String(attrPlugin, a.Plugin),
+	)
+}
+
+// actionErrCode adds the error code of the Action's last Attempt to span, if that Attempt failed.
+func actionErrCode(span trace.Span, a *workflow.Action) {
+	if len(a.Attempts) == 0 {
+		return
+	}
+	if last := a.Attempts[len(a.Attempts)-1]; last.Err != nil {
+		span.SetAttributes(attribute.Int64(attrErrCode, int64(last.Err.Code)))
+	}
+}
+
+// keyedAttrs returns the attributes for the span of an object with a Key.
+func keyedAttrs(ctx context.Context, id, key uuid.UUID, name string) []attribute.KeyValue {
+	return append(objAttrs(context.PlanID(ctx), id, name), attribute.String(attrKey, key.String()))
+}
+
+// objAttrs returns the attributes that are set on every span. id is not set if it is uuid.Nil.
+func objAttrs(planID, id uuid.UUID, name string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String(attrPlanID, planID.String())}
+	if id != uuid.Nil {
+		attrs = append(attrs, attribute.String(attrID, id.String()))
+	}
+	if name != "" {
+		attrs = append(attrs, attribute.String(attrName, name))
+	}
+	return attrs
+}
@@ -0,0 +1,134 @@
+package sm
+
+import (
+	"testing"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/context"
+
+	"github.com/google/uuid"
+	"github.com/gostdlib/base/statemachine"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStartPlanSpan(t *testing.T) {
+	t.Parallel()
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	states := &States{tracer: tp.Tracer("test")}
+
+	plan := &workflow.Plan{ID: uuid.New(), Name: "plan", State: &workflow.State{Status: workflow.Completed}}
+	req := statemachine.Request[Data]{Ctx: context.Background(), Data: Data{Plan: plan}}
+	states.startPlanSpan(&req)
+	endSpan(req.Data.span, plan.State.Status, nil)
+
+	first := sr.Ended()[0]
+	if plan.TraceID != first.SpanContext().TraceID().String() {
+		t.Fatalf("TestStartPlanSpan: got Plan.TraceID %q, want %q", plan.TraceID, first.SpanContext().TraceID())
+	}
+	if len(first.Links()) != 0 {
+		t.Errorf("TestStartPlanSpan: first run has %d links, want 0", len(first.Links()))
+	}
+	attrs := attribute.NewSet(first.Attributes()...)
+	if v, _ := attrs.Value(attrPlanID); v.AsString() != plan.ID.String() {
+		t.Errorf("TestStartPlanSpan: got plan id attribute %q, want %q", v.AsString(), plan.ID)
+	}
+	if first.Status().Code != codes.Ok {
+		t.Errorf("TestStartPlanSpan: got status %v, want Ok", first.Status().Code)
+	}
+
+	// A recovered Plan starts a new trace that is linked to the first one.
+	traceID := plan.TraceID
+	req = statemachine.Request[Data]{Ctx: context.Background(), Data: Data{Plan: plan}}
+	states.startPlanSpan(&req)
+	endSpan(req.Data.span, workflow.Failed, nil)
+
+	second := sr.Ended()[1]
+	if plan.TraceID != traceID {
+		t.Errorf("TestStartPlanSpan: recovery changed Plan.TraceID to %q, want %q", plan.TraceID, traceID)
+	}
+	if second.SpanContext().TraceID().String() == traceID {
+		t.Errorf("TestStartPlanSpan: recovered Plan has the trace ID of the first run, want a new trace")
+	}
+	links := second.Links()
+	if len(links) != 1 || links[0].SpanContext.TraceID().String() != traceID {
+		t.Fatalf("TestStartPlanSpan: got links %+v, want one link to trace %s", links, traceID)
+	}
+	if second.Status().Code != codes.Error {
+		t.Errorf("TestStartPlanSpan: got status %v for Failed Plan, want Error", second.Status().Code)
+	}
+}
+
+func TestExecSeqSpan(t *testing.T) {
+	t.Parallel()
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	states := &States{store: &fakeUpdater{}, actionRunner: fakeActionRunner, tracer: tp.Tracer("test")}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	seq := &workflow.Sequence{
+		ID:      uuid.New(),
+		Key:     uuid.New(),
+		Name:    "seq",
+		Actions: []*workflow.Action{{Name: "error"}},
+		State:   &workflow.State{},
+	}
+	if err := states.execSeq(ctx, seq); err == nil {
+		t.Fatalf("TestExecSeqSpan: execSeq(): got err == nil, want err != nil")
+	}
+	parent.End()
+
+	span := sr.Ended()[0]
+	if span.Name() != "Sequence" {
+		t.Fatalf("TestExecSeqSpan: got span %q, want Sequence", span.Name())
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("TestExecSeqSpan: Sequence span is not a child of the span in the Context")
+	}
+	if span.Status().Code != codes.Error || span.Status().Description != "error" {
+		t.Errorf("TestExecSeqSpan: got status %+v, want Error with description error", span.Status())
+	}
+	attrs := attribute.NewSet(span.Attributes()...)
+	if v, _ := attrs.Value(attrKey); v.AsString() != seq.Key.String() {
+		t.Errorf("TestExecSeqSpan: got key attribute %q, want %q", v.AsString(), seq.Key)
+	}
+	if v, _ := attrs.Value(attrStatus); v.AsString() != workflow.Failed.String() {
+		t.Errorf("TestExecSeqSpan: got status attribute %q, want %q", v.AsString(), workflow.Failed)
+	}
+}
+
+func TestExecSeqSpanDrained(t *testing.T) {
+	t.Parallel()
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	states := &States{store: &fakeUpdater{}, actionRunner: fakeActionRunner, tracer: tp.Tracer("test"), gate: newGate()}
+	if err := states.Drain(context.Background()); err != nil {
+		t.Fatalf("TestExecSeqSpanDrained: Drain(): got err == %v, want err == nil", err)
+	}
+
+	seq := &workflow.Sequence{
+		ID:      uuid.New(),
+		Name:    "seq",
+		Actions: []*workflow.Action{{Name: "action", State: &workflow.State{}}},
+		State:   &workflow.State{},
+	}
+	if err := states.execSeq(context.Background(), seq); err == nil {
+		t.Fatalf("TestExecSeqSpanDrained: execSeq(): got err == nil, want err != nil")
+	}
+
+	// The Sequence was handed off to recovery, so its span does not record an error.
+	span := sr.Ended()[0]
+	if span.Status().Code != codes.Unset {
+		t.Errorf("TestExecSeqSpanDrained: got status %+v, want Unset", span.Status())
+	}
+	attrs := attribute.NewSet(span.Attributes()...)
+	if v, _ := attrs.Value(attrDrained); !v.AsBool() {
+		t.Errorf("TestExecSeqSpanDrained: got drained attribute false, want true")
+	}
+}
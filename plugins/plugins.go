@@ -55,7 +55,8 @@ type Plugin interface {
 	// Name returns the name of the plugin. This must be unique in the registry.
 	// The name should include the package path to avoid name collisions.
 	Name() string
-	// Execute executes the plugin.
+	// Execute executes the plugin. A plugin that runs for a long time should report its progress
+	// with context.Progress(ctx).Report(). Records logged with context.Log(ctx) are kept on the Attempt.
 	Execute(ctx context.Context, req any) (any, *Error)
 	// ValidateReq validates the request object. This must check that the request object
 	// is the same as the object returned by Request().
@@ -81,6 +82,25 @@ type Plugin interface {
 	Init() error
 }
 
+// DryRunner is an optional interface that a Plugin can implement to support dry runs. During a dry run,
+// DryRun is called instead of Execute and should report what the plugin would do with the request without
+// making any changes. The response must be the same type as Response(). Plugins that do not implement this
+// are recorded as would execute.
+type DryRunner interface {
+	// DryRun reports what Execute would do with the request object without doing it.
+	DryRun(ctx context.Context, req any) (any, *Error)
+}
+
+// ConcurrencyLimiter is an optional interface that a Plugin can implement to limit the number of
+// executions that can run at the same time across every Plan in the Workstream. This protects services
+// with rate limits, as Block.Concurrency only limits a single Plan. An Action that is waiting for a slot
+// records the wait in Attempt.SlotWait. This can be overridden with registry.WithMaxConcurrent().
+type ConcurrencyLimiter interface {
+	// MaxConcurrent returns the maximum number of concurrent executions of the plugin. A value < 1
+	// means there is no limit.
+	MaxConcurrent() int
+}
+
 // FastRetryPolicy returns a retry plan that is fast at first and then slows down.
 //
 // progression will be:
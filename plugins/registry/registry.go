@@ -29,6 +29,7 @@ import (
 	"strings"
 
 	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/workflow/context"
 	"github.com/gostdlib/base/retry/exponential"
 )
 
@@ -36,13 +37,36 @@ import (
 // but instead via the Registry variable. Use of this type directly is not supported.
 type Register struct {
 	m map[string]plugins.Plugin
+
+	// maxConcurrent holds the limits set with WithMaxConcurrent(). These override plugins.ConcurrencyLimiter.
+	maxConcurrent map[string]int
+	// slots holds a semaphore for each plugin that has a concurrency limit.
+	slots map[string]chan struct{}
+}
+
+// Option is an option for New().
+type Option func(r *Register)
+
+// WithMaxConcurrent sets the maximum number of concurrent executions of the plugin with name across every
+// Plan using the Register. This overrides the limit the plugin sets with plugins.ConcurrencyLimiter. If n < 1,
+// the plugin has no limit.
+func WithMaxConcurrent(name string, n int) Option {
+	return func(r *Register) {
+		r.maxConcurrent[name] = n
+	}
 }
 
 // New creates a new Register. Not for use by the user.
-func New() *Register {
-	return &Register{
-		m: map[string]plugins.Plugin{},
+func New(options ...Option) *Register {
+	r := &Register{
+		m:             map[string]plugins.Plugin{},
+		maxConcurrent: map[string]int{},
+		slots:         map[string]chan struct{}{},
 	}
+	for _, o := range options {
+		o(r)
+	}
+	return r
 }
 
 // Register registers a plugin by name. It panics if the name is empty, the plugin is nil,
@@ -65,7 +89,7 @@ func (r *Register) Register(p plugins.Plugin) error {
 		return fmt.Errorf("plugin(%s) already registered", p.Name())
 	}
 
-	if err := validatePolicy(p.RetryPolicy()); err != nil {
+	if err := ValidatePolicy(p.RetryPolicy()); err != nil {
 		return fmt.Errorf("plugin(%s) has invalid retry plan: %v", p.Name(), err)
 	}
 
@@ -78,6 +102,16 @@ func (r *Register) Register(p plugins.Plugin) error {
 		return fmt.Errorf("plugin(%s) has invalid response: %v", p.Name(), err)
 	}
 
+	n, ok := r.maxConcurrent[p.Name()]
+	if !ok {
+		if l, isLimiter := p.(plugins.ConcurrencyLimiter); isLimiter {
+			n = l.MaxConcurrent()
+		}
+	}
+	if n > 0 {
+		r.slots[p.Name()] = make(chan struct{}, n)
+	}
+
 	r.m[p.Name()] = p
 	return nil
 }
@@ -110,9 +144,38 @@ func (r *Register) Plugin(name string) plugins.Plugin {
 	return r.m[name]
 }
 
-// validatePolicy validates the exponential policy. This is a copy of the exponential.Policy.validate method.
+// MaxConcurrent returns the maximum number of concurrent executions of the plugin with name.
+// This returns 0 if the plugin has no limit.
+func (r *Register) MaxConcurrent(name string) int {
+	if r == nil {
+		return 0
+	}
+	return cap(r.slots[name])
+}
+
+// Acquire waits for a slot to execute the plugin with name and returns a function that releases it.
+// If the plugin has no concurrency limit, this returns immediately. If ctx is done before a slot is
+// available, this returns an error.
+func (r *Register) Acquire(ctx context.Context, name string) (release func(), err error) {
+	if r == nil {
+		return func() {}, nil
+	}
+	slots, ok := r.slots[name]
+	if !ok {
+		return func() {}, nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for plugin(%s) concurrency slot: %w", name, ctx.Err())
+	case slots <- struct{}{}:
+	}
+	return func() { <-slots }, nil
+}
+
+// ValidatePolicy validates the exponential policy. This is a copy of the exponential.Policy.validate method.
 // TODO(element-of-surprise): Remove this when the exponential package is updated to export the validate method.
-func validatePolicy(p exponential.Policy) error {
+func ValidatePolicy(p exponential.Policy) error {
 	if p.InitialInterval <= 0 {
 		return errors.New("Policy.InitialInterval must be greater than 0")
 	}
@@ -6,6 +6,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/workflow/context"
+
 	"github.com/gostdlib/base/retry/exponential"
 	"github.com/kylelemons/godebug/pretty"
 )
@@ -85,7 +88,7 @@ func TestValidatePolicy(t *testing.T) {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			got := validatePolicy(test.policy)
+			got := ValidatePolicy(test.policy)
 			if diff := pretty.Compare(got, test.want); diff != "" {
 				t.Errorf("Validate(): -got +want: %v", diff)
 			}
@@ -222,3 +225,108 @@ func TestGetTags(t *testing.T) {
 		}
 	}
 }
+
+type limitedPlugin struct {
+	plugins.Plugin
+	max int
+}
+
+func (l limitedPlugin) MaxConcurrent() int {
+	return l.max
+}
+
+func TestConcurrencyLimits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		options []Option
+		plugin  plugins.Plugin
+		want    int
+	}{
+		{
+			name:   "no limit",
+			plugin: &fakePlugin{name: "plugin"},
+		},
+		{
+			name:   "plugin sets limit",
+			plugin: limitedPlugin{Plugin: &fakePlugin{name: "plugin"}, max: 2},
+			want:   2,
+		},
+		{
+			name:    "option sets limit",
+			options: []Option{WithMaxConcurrent("plugin", 3)},
+			plugin:  &fakePlugin{name: "plugin"},
+			want:    3,
+		},
+		{
+			name:    "option overrides plugin limit",
+			options: []Option{WithMaxConcurrent("plugin", 1)},
+			plugin:  limitedPlugin{Plugin: &fakePlugin{name: "plugin"}, max: 2},
+			want:    1,
+		},
+		{
+			name:    "option removes plugin limit",
+			options: []Option{WithMaxConcurrent("plugin", 0)},
+			plugin:  limitedPlugin{Plugin: &fakePlugin{name: "plugin"}, max: 2},
+		},
+	}
+
+	for _, test := range tests {
+		r := New(test.options...)
+		if err := r.Register(test.plugin); err != nil {
+			t.Fatalf("TestConcurrencyLimits(%s): Register() returned error: %v", test.name, err)
+		}
+		if got := r.MaxConcurrent("plugin"); got != test.want {
+			t.Errorf("TestConcurrencyLimits(%s): got MaxConcurrent() == %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	t.Parallel()
+
+	r := New(WithMaxConcurrent("plugin", 1))
+	if err := r.Register(&fakePlugin{name: "plugin"}); err != nil {
+		t.Fatalf("TestAcquire: Register() returned error: %v", err)
+	}
+
+	release, err := r.Acquire(context.Background(), "plugin")
+	if err != nil {
+		t.Fatalf("TestAcquire: Acquire() returned error: %v", err)
+	}
+
+	// The only slot is taken, so this must wait until ctx is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := r.Acquire(ctx, "plugin"); err == nil {
+		t.Errorf("TestAcquire: Acquire() with no free slot: got err == nil, want err != nil")
+	}
+
+	// Plugins without a limit never wait.
+	if _, err := r.Acquire(ctx, "other"); err != nil {
+		t.Errorf("TestAcquire: Acquire() on a plugin without a limit: got err == %v, want err == nil", err)
+	}
+
+	release()
+	release, err = r.Acquire(context.Background(), "plugin")
+	if err != nil {
+		t.Fatalf("TestAcquire: Acquire() after release returned error: %v", err)
+	}
+	release()
+}
+
+type fakePlugin struct {
+	name string
+}
+
+func (f *fakePlugin) Name() string { return f.name }
+func (f *fakePlugin) Execute(ctx context.Context, req any) (any, *plugins.Error) {
+	return nil, nil
+}
+func (f *fakePlugin) ValidateReq(req any) error       { return nil }
+func (f *fakePlugin) Request() any                    { return nil }
+func (f *fakePlugin) Response() any                   { return nil }
+func (f *fakePlugin) IsCheck() bool                   { return false }
+func (f *fakePlugin) RetryPolicy() exponential.Policy { return plugins.FastRetryPolicy() }
+func (f *fakePlugin) Init() error                     { return nil }
@@ -0,0 +1,151 @@
+package coercion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/utils/clone"
+	"github.com/google/uuid"
+)
+
+type retryOptions struct {
+	start               bool
+	onlyFailed          bool
+	keepCompletedBlocks bool
+	skipPreChecks       bool
+}
+
+// RetryOption is an optional argument for Workstream.Retry().
+type RetryOption func(*retryOptions) error
+
+// WithRetryStart starts the new Plan after it is submitted.
+func WithRetryStart() RetryOption {
+	return func(o *retryOptions) error {
+		o.start = true
+		return nil
+	}
+}
+
+// WithOnlyFailedSequences only retries Sequences that Failed. Sequences that did not run, such as
+// those that were Stopped or in Blocks after the failure, are not included in the new Plan.
+func WithOnlyFailedSequences() RetryOption {
+	return func(o *retryOptions) error {
+		o.onlyFailed = true
+		return nil
+	}
+}
+
+// WithKeepCompletedBlocks keeps Blocks that Completed in the new Plan, where they are run again in full.
+// By default Completed Blocks are not included.
+func WithKeepCompletedBlocks() RetryOption {
+	return func(o *retryOptions) error {
+		o.keepCompletedBlocks = true
+		return nil
+	}
+}
+
+// WithSkipCompletedPreChecks does not include the Plan-level PreChecks in the new Plan if they Completed
+// in the original Plan. By default PreChecks are run again, as what they verified may have changed since.
+func WithSkipCompletedPreChecks() RetryOption {
+	return func(o *retryOptions) error {
+		o.skipPreChecks = true
+		return nil
+	}
+}
+
+// Retry creates a new Plan from a Failed or Stopped Plan with the id and submits it. The new Plan does not
+// include Sequences that Completed and has its ParentID set to id. Blocks that had all their Sequences complete
+// but failed a check are run again in full. The new Plan must be started with Start(), unless WithRetryStart()
+// is passed. This returns the ID of the new Plan. If there is nothing left to retry, an error is returned.
+func (w *Workstream) Retry(ctx context.Context, id uuid.UUID, options ...RetryOption) (uuid.UUID, error) {
+	opts := retryOptions{}
+	for _, o := range options {
+		if err := o(&opts); err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	old, err := w.store.Read(ctx, id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	switch old.State.Status {
+	case workflow.Failed, workflow.Stopped:
+	default:
+		return uuid.Nil, fmt.Errorf("plan(%s) is %s, only Failed or Stopped plans can be retried", id, old.State.Status)
+	}
+
+	plan := retryPlan(ctx, old, opts)
+	if plan == nil {
+		return uuid.Nil, fmt.Errorf("plan(%s) has nothing to retry", id)
+	}
+	plan.ParentID = old.ID
+
+	newID, err := w.Submit(ctx, plan)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if opts.start {
+		if err := w.Start(ctx, newID); err != nil {
+			return newID, fmt.Errorf("plan(%s) was submitted but failed to start: %w", newID, err)
+		}
+	}
+	return newID, nil
+}
+
+// retryPlan builds the Plan that retries the finished Plan p. If there is nothing to retry, this returns nil.
+func retryPlan(ctx context.Context, p *workflow.Plan, opts retryOptions) *workflow.Plan {
+	// We adjust the states on a copy to decide what is removed by clone.WithRemoveCompletedSequences().
+	src := clone.Plan(ctx, p, clone.WithKeepState(), clone.WithKeepSecrets())
+
+	if opts.skipPreChecks && src.PreChecks != nil && src.PreChecks.State.Status == workflow.Completed {
+		src.PreChecks = nil
+	}
+
+	blocks := make([]*workflow.Block, 0, len(src.Blocks))
+	for _, b := range src.Blocks {
+		switch {
+		case b.State.Status == workflow.Completed:
+			if opts.keepCompletedBlocks {
+				resetSequences(b)
+			}
+		case allCompleted(b.Sequences):
+			// The Block failed a check after all of its Sequences completed, so it must be run in full.
+			resetSequences(b)
+		case opts.onlyFailed:
+			seqs := make([]*workflow.Sequence, 0, len(b.Sequences))
+			for _, seq := range b.Sequences {
+				if seq.State.Status == workflow.Failed {
+					seqs = append(seqs, seq)
+				}
+			}
+			if len(seqs) == 0 {
+				continue
+			}
+			b.Sequences = seqs
+		}
+		blocks = append(blocks, b)
+	}
+	src.Blocks = blocks
+
+	return clone.Plan(ctx, src, clone.WithRemoveCompletedSequences(), clone.WithKeepSecrets())
+}
+
+// allCompleted returns true if all the Sequences are Completed.
+func allCompleted(seqs []*workflow.Sequence) bool {
+	for _, seq := range seqs {
+		if seq.State.Status != workflow.Completed {
+			return false
+		}
+	}
+	return true
+}
+
+// resetSequences sets all Sequences in the Block to NotStarted so that they are retried.
+func resetSequences(b *workflow.Block) {
+	for _, seq := range b.Sequences {
+		seq.State.Status = workflow.NotStarted
+	}
+}
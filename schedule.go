@@ -0,0 +1,64 @@
+package coercion
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/element-of-surprise/coercion/internal/cron"
+	"github.com/element-of-surprise/coercion/internal/execute"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/google/uuid"
+)
+
+// Schedule schedules the submitted plan with the given id to start at a time in the future. The schedule is
+// written to storage, so a scheduled plan still starts after a restart. A scheduled plan is not considered stale
+// until WithMaxSubmit() after the time it is scheduled for, so plans can be submitted well ahead of a maintenance window.
+// Calling Start() before the time starts the plan early.
+func (w *Workstream) Schedule(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return w.exec.Schedule(ctx, id, at)
+}
+
+// SubmitRecurring submits a plan built by factory that starts at the next time in the cron spec. Each time a plan
+// starts, factory builds the next plan, which is submitted and scheduled for the following time in the spec.
+// This returns the ID of the first plan. The spec has the standard five fields (minute, hour, day of month, month
+// and day of week) or is one of @yearly, @monthly, @weekly, @daily or @hourly. All times are in UTC.
+//
+// The schedule is written to storage and survives a restart. As factory cannot be written to storage, after a restart
+// the next plan is a clone of the plan that is starting. Fields marked `coerce:"secure"` are not kept in the clone.
+// Use Unschedule() on the plan that is waiting to start to end the recurrence.
+func (w *Workstream) SubmitRecurring(ctx context.Context, factory func() *workflow.Plan, spec string) (uuid.UUID, error) {
+	if factory == nil {
+		return uuid.Nil, fmt.Errorf("factory is required")
+	}
+	if _, err := cron.Parse(spec); err != nil {
+		return uuid.Nil, err
+	}
+
+	plan := factory()
+	if plan == nil {
+		return uuid.Nil, fmt.Errorf("factory returned a nil plan")
+	}
+
+	id, err := w.Submit(ctx, plan)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := w.exec.ScheduleRecurring(ctx, id, spec, factory); err != nil {
+		return uuid.Nil, fmt.Errorf("plan(%s) was submitted but failed to schedule: %w", id, err)
+	}
+	return id, nil
+}
+
+// Unschedule removes the schedule from the plan with the given id that has not started yet. The plan can still
+// be started with Start(). For a plan from SubmitRecurring(), this ends the recurrence. If the plan is not
+// scheduled, an error is returned.
+func (w *Workstream) Unschedule(ctx context.Context, id uuid.UUID) error {
+	if err := w.exec.Unschedule(ctx, id); err != nil {
+		if err == execute.ErrNotFound {
+			return fmt.Errorf("plan(%s) is not scheduled", id)
+		}
+		return err
+	}
+	return nil
+}
@@ -83,6 +83,55 @@ func WithGroupID(id uuid.UUID) Option {
 	}
 }
 
+// WithTimeout sets the Timeout for the Plan.
+func WithTimeout(d time.Duration) Option {
+	return func(b *BuildPlan) error {
+		if b.emitted {
+			return errors.New("cannot call WithTimeout() after Plan() has been called")
+		}
+
+		if d < 0 {
+			return errors.New("timeout must not be negative")
+		}
+
+		b.current().(*workflow.Plan).Timeout = d
+		return nil
+	}
+}
+
+// WithLocks sets the Locks the Plan holds while it runs.
+func WithLocks(locks *workflow.Locks) Option {
+	return func(b *BuildPlan) error {
+		if b.emitted {
+			return errors.New("cannot call WithLocks() after Plan() has been called")
+		}
+
+		if locks == nil || len(locks.Names) == 0 {
+			return errors.New("locks must have at least one name")
+		}
+
+		b.current().(*workflow.Plan).Locks = locks
+		return nil
+	}
+}
+
+// WithBlockConcurrency sets how many Blocks of the Plan can run at the same time.
+// See workflow.Plan.BlockConcurrency.
+func WithBlockConcurrency(n int) Option {
+	return func(b *BuildPlan) error {
+		if b.emitted {
+			return errors.New("cannot call WithBlockConcurrency() after Plan() has been called")
+		}
+
+		if n < 1 {
+			return errors.New("block concurrency must be at least 1")
+		}
+
+		b.current().(*workflow.Plan).BlockConcurrency = n
+		return nil
+	}
+}
+
 // New creates a new BuildPlan with the internal Plan object having the given
 // name and description.
 func New(name, descr string, options ...Option) (*BuildPlan, error) {
@@ -316,8 +365,26 @@ type BlockArgs struct {
 	Name                     string
 	Descr                    string
 	EntranceDelay, ExitDelay time.Duration
+	Timeout                  time.Duration
 	Concurrency              int
 	ToleratedFailures        int
+	// ToleratedFailurePercent is the percentage of Sequences that can fail. See workflow.Block.ToleratedFailurePercent.
+	ToleratedFailurePercent float64
+	// ToleratedFailureSample is the number of started Sequences the percentage is evaluated against.
+	// See workflow.Block.ToleratedFailureSample.
+	ToleratedFailureSample int
+	Approval               *workflow.Approval
+	Locks                  *workflow.Locks
+	// Ramp steps the Block's concurrency up to Concurrency as Sequences succeed. See workflow.Block.Ramp.
+	Ramp *workflow.Ramp
+	// Generator generates the Block's Sequences when it runs. See workflow.Block.Generator.
+	Generator *workflow.Action
+	// Rollback is run when the Block fails. See workflow.Block.Rollback.
+	Rollback []*workflow.Action
+	// SubPlan is a Plan the Block runs in place of Sequences. See workflow.Block.SubPlan.
+	SubPlan *workflow.Plan
+	// DependsOn are the Keys of Blocks that must complete before this Block starts. See workflow.Block.DependsOn.
+	DependsOn []uuid.UUID
 }
 
 // AddBlock adds a Block to the current workflow Plan. If at any other level of the plan hierarchy,
@@ -343,13 +410,23 @@ func (b *BuildPlan) AddBlock(args BlockArgs) *BuildPlan {
 	switch t := b.current().(type) {
 	case *workflow.Plan:
 		block := &workflow.Block{
-			Name:              args.Name,
-			Key:               args.Key,
-			Descr:             args.Descr,
-			EntranceDelay:     args.EntranceDelay,
-			ExitDelay:         args.ExitDelay,
-			Concurrency:       args.Concurrency,
-			ToleratedFailures: args.ToleratedFailures,
+			Name:                    args.Name,
+			Key:                     args.Key,
+			Descr:                   args.Descr,
+			DependsOn:               args.DependsOn,
+			EntranceDelay:           args.EntranceDelay,
+			ExitDelay:               args.ExitDelay,
+			Timeout:                 args.Timeout,
+			Concurrency:             args.Concurrency,
+			Ramp:                    args.Ramp,
+			ToleratedFailures:       args.ToleratedFailures,
+			ToleratedFailurePercent: args.ToleratedFailurePercent,
+			ToleratedFailureSample:  args.ToleratedFailureSample,
+			Approval:                args.Approval,
+			Locks:                   args.Locks,
+			Generator:               args.Generator,
+			Rollback:                args.Rollback,
+			SubPlan:                 args.SubPlan,
 		}
 		t.Blocks = append(t.Blocks, block)
 		b.chain = append(b.chain, block)
@@ -395,6 +395,30 @@ func TestAddBlock(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Success: with Generator",
+			args: BlockArgs{Name: "test", Descr: "test", Generator: &workflow.Action{Name: "gen"}},
+			want: &BuildPlan{
+				chain: []any{
+					&workflow.Plan{
+						Blocks: []*workflow.Block{{Name: "test", Descr: "test", Generator: &workflow.Action{Name: "gen"}}},
+					},
+					&workflow.Block{Name: "test", Descr: "test", Generator: &workflow.Action{Name: "gen"}},
+				},
+			},
+		},
+		{
+			name: "Success: with Rollback",
+			args: BlockArgs{Name: "test", Descr: "test", Rollback: []*workflow.Action{{Name: "undo"}}},
+			want: &BuildPlan{
+				chain: []any{
+					&workflow.Plan{
+						Blocks: []*workflow.Block{{Name: "test", Descr: "test", Rollback: []*workflow.Action{{Name: "undo"}}}},
+					},
+					&workflow.Block{Name: "test", Descr: "test", Rollback: []*workflow.Action{{Name: "undo"}}},
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
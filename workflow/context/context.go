@@ -20,6 +20,15 @@ type planIDKey struct{}
 // actionIDKey is a key for the actionID in context.Value .
 type actionIDKey struct{}
 
+// dryRunKey is a key for the dry run flag in context.Value .
+type dryRunKey struct{}
+
+// progressKey is a key for the Reporter in context.Value .
+type progressKey struct{}
+
+// logKey is a key for the logger in context.Value .
+type logKey struct{}
+
 // Background returns a non-nil, empty [Context]. It is never canceled, and has no deadline.
 // It is typically used by the main function, initialization, and tests, and as the top-level
 // Context for incoming requests. This differs from the Background() function in the context package
@@ -48,10 +57,19 @@ func Attach(ctx Context) Context {
 }
 
 // Log returns the logger attached to the context. If no logger is attached, it returns log.Default().
+// When called from a plugin's Execute(), records are also captured on the workflow.Attempt.
 func Log(ctx Context) *slog.Logger {
+	if l, ok := ctx.Value(logKey{}).(*slog.Logger); ok {
+		return l
+	}
 	return context.Log(ctx)
 }
 
+// SetLog sets the logger returned by Log() for the Context.
+func SetLog(ctx Context, l *slog.Logger) Context {
+	return context.WithValue(ctx, logKey{}, l)
+}
+
 // Meter returns a metric.Meter scoped to the package that calls context.Meter(). If you need to have a
 // sub-namespace for a specific package, you should use the MeterProvider() function to get the meter provider.
 // If no meter is attached to the context it returns a meter from metrics.Default(). This may be a noop Meter.
@@ -117,3 +135,42 @@ func ActionID(ctx context.Context) uuid.UUID {
 func SetActionID(ctx context.Context, id uuid.UUID) context.Context {
 	return context.WithValue(ctx, actionIDKey{}, id)
 }
+
+// DryRun returns true if the Context is for a dry run of a Plan.
+func DryRun(ctx context.Context) bool {
+	b, _ := ctx.Value(dryRunKey{}).(bool)
+	return b
+}
+
+// SetDryRun marks the Context as being for a dry run of a Plan.
+func SetDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// Reporter reports the progress of a plugin while it executes. See Progress().
+type Reporter interface {
+	// Report records that percent of the work is done, from 0 to 100, and what the plugin is doing.
+	// Reports may be throttled, but the last one is always recorded on the Attempt.
+	Report(percent int, msg string)
+}
+
+// Progress returns the Reporter that a plugin uses to report progress from Execute(). Progress is written
+// to the current workflow.Attempt, where it can be seen while the Action is running. If the Context is not
+// from an executing Action, this returns a Reporter that does nothing.
+func Progress(ctx context.Context) Reporter {
+	r, ok := ctx.Value(progressKey{}).(Reporter)
+	if ok {
+		return r
+	}
+	return noopReporter{}
+}
+
+// SetProgress sets the Reporter returned by Progress() for the Context.
+func SetProgress(ctx context.Context, r Reporter) context.Context {
+	return context.WithValue(ctx, progressKey{}, r)
+}
+
+// noopReporter is a Reporter that does nothing.
+type noopReporter struct{}
+
+func (noopReporter) Report(int, string) {}
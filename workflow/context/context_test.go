@@ -2,6 +2,7 @@ package context
 
 import (
 	"context"
+	"log/slog"
 	"testing"
 
 	"github.com/google/uuid"
@@ -36,3 +37,54 @@ func TestActionID(t *testing.T) {
 		t.Fatalf("TestActionID: got %s, want %s", got, want)
 	}
 }
+
+func TestDryRun(t *testing.T) {
+	ctx := context.Background()
+	if DryRun(ctx) {
+		t.Fatalf("TestDryRun: got true before SetDryRun, want false")
+	}
+
+	ctx = SetDryRun(ctx)
+
+	if !DryRun(ctx) {
+		t.Fatalf("TestDryRun: got false after SetDryRun, want true")
+	}
+}
+
+type fakeReporter struct {
+	percent int
+	msg     string
+}
+
+func (f *fakeReporter) Report(percent int, msg string) {
+	f.percent = percent
+	f.msg = msg
+}
+
+func TestProgress(t *testing.T) {
+	ctx := context.Background()
+	// A Context without a Reporter must not panic.
+	Progress(ctx).Report(10, "nothing")
+
+	r := &fakeReporter{}
+	ctx = SetProgress(ctx, r)
+	Progress(ctx).Report(50, "halfway")
+
+	if r.percent != 50 || r.msg != "halfway" {
+		t.Fatalf("TestProgress: got (%d, %q), want (50, \"halfway\")", r.percent, r.msg)
+	}
+}
+
+func TestLog(t *testing.T) {
+	ctx := context.Background()
+	if Log(ctx) == nil {
+		t.Fatalf("TestLog: got nil logger without SetLog")
+	}
+
+	want := slog.New(slog.DiscardHandler)
+	ctx = SetLog(ctx, want)
+
+	if got := Log(ctx); got != want {
+		t.Fatalf("TestLog: did not get the logger from SetLog")
+	}
+}
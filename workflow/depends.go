@@ -0,0 +1,122 @@
+package workflow
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// validateDependsOn validates that the DependsOn of each Block references the Key of another Block
+// in the Plan and that the dependencies do not form a cycle.
+func (p *Plan) validateDependsOn() error {
+	keys := map[uuid.UUID]int{}
+	for i, b := range p.Blocks {
+		if b != nil && b.Key != uuid.Nil {
+			keys[b.Key] = i
+		}
+	}
+
+	for _, b := range p.Blocks {
+		if b == nil {
+			continue
+		}
+		seen := map[uuid.UUID]bool{}
+		for _, k := range b.DependsOn {
+			switch {
+			case k == uuid.Nil:
+				return fmt.Errorf("block(%s) has a DependsOn without a Key", b.Name)
+			case k == b.Key:
+				return fmt.Errorf("block(%s) cannot depend on itself", b.Name)
+			case seen[k]:
+				return fmt.Errorf("block(%s) depends on block(%s) more than once", b.Name, k)
+			}
+			if _, ok := keys[k]; !ok {
+				return fmt.Errorf("block(%s) depends on block(%s), which is not a Block in the Plan", b.Name, k)
+			}
+			seen[k] = true
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make([]int, len(p.Blocks))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch marks[i] {
+		case visiting:
+			return fmt.Errorf("block(%s) is part of a DependsOn cycle", p.Blocks[i].Name)
+		case visited:
+			return nil
+		}
+		marks[i] = visiting
+		for _, k := range p.Blocks[i].DependsOn {
+			if err := visit(keys[k]); err != nil {
+				return err
+			}
+		}
+		marks[i] = visited
+		return nil
+	}
+	for i, b := range p.Blocks {
+		if b == nil {
+			continue
+		}
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockBefore returns a function that reports if the Block at index a in Plan.Blocks always completes
+// before the Block at index b starts. When Blocks run in sequence, this is any earlier Block. Otherwise
+// it is only a Block that b depends on, directly or through other Blocks.
+// This must only be called after validateDependsOn().
+func (p *Plan) blockBefore() func(a, b int) bool {
+	if !p.dependencies() && p.BlockConcurrency <= 1 {
+		return func(a, b int) bool { return a < b }
+	}
+
+	keys := map[uuid.UUID]int{}
+	for i, b := range p.Blocks {
+		if b != nil && b.Key != uuid.Nil {
+			keys[b.Key] = i
+		}
+	}
+
+	return func(a, b int) bool {
+		seen := map[int]bool{}
+		stack := []int{b}
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if p.Blocks[i] == nil {
+				continue
+			}
+			for _, k := range p.Blocks[i].DependsOn {
+				dep := keys[k]
+				if dep == a {
+					return true
+				}
+				if !seen[dep] {
+					seen[dep] = true
+					stack = append(stack, dep)
+				}
+			}
+		}
+		return false
+	}
+}
+
+// dependencies returns true if any Block in the Plan has DependsOn set.
+func (p *Plan) dependencies() bool {
+	for _, b := range p.Blocks {
+		if b != nil && len(b.DependsOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -16,17 +16,23 @@ func _() {
 	_ = x[FRDeferredCheck-450]
 	_ = x[FRStopped-500]
 	_ = x[FRExceedRecovery-600]
+	_ = x[FRApproval-700]
+	_ = x[FRTimeout-800]
+	_ = x[FRLocked-900]
 }
 
 const (
-	_FailureReason_name_0 = "FRUnknown"
-	_FailureReason_name_1 = "FRPreCheck"
-	_FailureReason_name_2 = "FRBlock"
-	_FailureReason_name_3 = "FRPostCheck"
-	_FailureReason_name_4 = "FRContCheck"
-	_FailureReason_name_5 = "FRDeferredCheck"
-	_FailureReason_name_6 = "FRStopped"
-	_FailureReason_name_7 = "FRExceedRecovery"
+	_FailureReason_name_0  = "FRUnknown"
+	_FailureReason_name_1  = "FRPreCheck"
+	_FailureReason_name_2  = "FRBlock"
+	_FailureReason_name_3  = "FRPostCheck"
+	_FailureReason_name_4  = "FRContCheck"
+	_FailureReason_name_5  = "FRDeferredCheck"
+	_FailureReason_name_6  = "FRStopped"
+	_FailureReason_name_7  = "FRExceedRecovery"
+	_FailureReason_name_8  = "FRApproval"
+	_FailureReason_name_9  = "FRTimeout"
+	_FailureReason_name_10 = "FRLocked"
 )
 
 func (i FailureReason) String() string {
@@ -47,6 +53,12 @@ func (i FailureReason) String() string {
 		return _FailureReason_name_6
 	case i == 600:
 		return _FailureReason_name_7
+	case i == 700:
+		return _FailureReason_name_8
+	case i == 800:
+		return _FailureReason_name_9
+	case i == 900:
+		return _FailureReason_name_10
 	default:
 		return "FailureReason(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
@@ -0,0 +1,121 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/element-of-surprise/coercion/plugins/registry"
+
+	"github.com/google/uuid"
+)
+
+// Generated is implemented by the response of a plugin that is used as a Block's Generator.
+// The response can hold whatever the plugin discovered, such as the machines in a cluster,
+// and Sequences() turns that into the work to do.
+type Generated interface {
+	// Sequences returns the Sequences to add to the Block. These are validated the same way as Sequences
+	// in a submitted Plan, but cannot have Keys or Refs. Returning no Sequences is not an error.
+	Sequences() []*Sequence
+}
+
+// validateGenerator validates that the Action can be used as a Block's Generator.
+func (a *Action) validateGenerator() error {
+	if a == nil {
+		return nil
+	}
+	if len(a.Refs) > 0 {
+		return fmt.Errorf("generator(%s): refs are not supported on a generator", a.Name)
+	}
+	// Without a registry, the plugin is not known. Action.validate() will fail.
+	if a.register == nil {
+		return nil
+	}
+	plug := a.register.Plugin(a.Plugin)
+	if plug == nil {
+		return nil
+	}
+	if plug.IsCheck() {
+		return fmt.Errorf("generator(%s): plugin(%s) is a check plugin", a.Name, a.Plugin)
+	}
+	if _, ok := plug.Response().(Generated); !ok {
+		return fmt.Errorf("generator(%s): plugin(%s) response(%T) does not implement Generated", a.Name, a.Plugin, plug.Response())
+	}
+	return nil
+}
+
+// Generate adds the Sequences from the response of the Block's Generator to the end of the Block's Sequences.
+// The Sequences are validated with the plugins in reg and set up to run. The Generator must have completed.
+// This returns the Sequences that were added. For use internally.
+func (b *Block) Generate(reg *registry.Register) ([]*Sequence, error) {
+	g := b.Generator
+	if g == nil {
+		return nil, fmt.Errorf("block(%s) has no generator", b.Name)
+	}
+	final := g.FinalAttempt()
+	if final == nil || final.Resp == nil {
+		return nil, fmt.Errorf("generator(%s) has no response", g.Name)
+	}
+	gen, ok := final.Resp.(Generated)
+	if !ok {
+		return nil, fmt.Errorf("generator(%s) response(%T) does not implement Generated", g.Name, final.Resp)
+	}
+
+	seqs := gen.Sequences()
+	ctx := context.WithValue(context.Background(), keysMap{}, map[string]bool{})
+	for _, s := range seqs {
+		if err := validateGenerated(ctx, reg, s); err != nil {
+			return nil, fmt.Errorf("generator(%s): %w", g.Name, err)
+		}
+	}
+
+	for _, s := range seqs {
+		s.Defaults()
+		s.SetPlanID(b.planID)
+		for _, a := range append(append([]*Action{}, s.Actions...), s.Rollback...) {
+			a.Defaults()
+			a.SetPlanID(b.planID)
+			if v, ok := a.Req.(interface{ Defaults() }); ok {
+				v.Defaults()
+			}
+		}
+	}
+	b.Sequences = append(b.Sequences, seqs...)
+	return seqs, nil
+}
+
+// validateGenerated validates a Sequence generated by a Block's Generator. The Actions in the Sequence
+// are given reg as their registry.
+func validateGenerated(ctx context.Context, reg *registry.Register, s *Sequence) error {
+	if s == nil {
+		return fmt.Errorf("cannot generate a nil Sequence")
+	}
+	if s.Key != uuid.Nil {
+		return fmt.Errorf("generated Sequence(%s) cannot have a Key", s.Name)
+	}
+	for _, a := range append(append([]*Action{}, s.Actions...), s.Rollback...) {
+		if a == nil {
+			continue
+		}
+		if a.Key != uuid.Nil || len(a.Refs) > 0 {
+			return fmt.Errorf("generated Sequence(%s): Action(%s) cannot have a Key or Refs", s.Name, a.Name)
+		}
+		if a.register != nil {
+			return fmt.Errorf("generated Sequence(%s): Action(%s) had register set, which is not allowed", s.Name, a.Name)
+		}
+		a.register = reg
+		if plug := a.register.Plugin(a.Plugin); plug != nil && plug.IsCheck() {
+			return fmt.Errorf("generated Sequence(%s): plugin(%s) is a check plugin", s.Name, a.Plugin)
+		}
+	}
+
+	q := &queue[validator]{}
+	q.push(s)
+	for val := q.pop(); val != nil; val = q.pop() {
+		vals, err := val.validate(ctx)
+		if err != nil {
+			return fmt.Errorf("generated Sequence(%s): %w", s.Name, err)
+		}
+		q.push(vals...)
+	}
+	return nil
+}
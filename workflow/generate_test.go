@@ -0,0 +1,266 @@
+package workflow
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/google/uuid"
+	"github.com/gostdlib/base/retry/exponential"
+)
+
+type genResp struct {
+	seqs []*Sequence
+}
+
+func (g genResp) Sequences() []*Sequence {
+	return g.seqs
+}
+
+type genPlugin struct {
+	plugins.Plugin
+
+	name  string
+	check bool
+	resp  any
+}
+
+func (g genPlugin) Name() string {
+	return g.name
+}
+
+func (genPlugin) Request() any {
+	return ""
+}
+
+func (g genPlugin) Response() any {
+	return g.resp
+}
+
+func (g genPlugin) IsCheck() bool {
+	return g.check
+}
+
+func (genPlugin) RetryPolicy() exponential.Policy {
+	return plugins.FastRetryPolicy()
+}
+
+func (genPlugin) ValidateReq(req any) error {
+	if _, ok := req.(string); !ok {
+		return errors.New("req is not a string")
+	}
+	return nil
+}
+
+func genRegistry() *registry.Register {
+	reg := registry.New()
+	reg.Register(genPlugin{name: "generator", resp: genResp{}})
+	reg.Register(genPlugin{name: "notGenerator", resp: struct{}{}})
+	reg.Register(genPlugin{name: "check", check: true, resp: genResp{}})
+	reg.Register(genPlugin{name: "action", resp: struct{}{}})
+	return reg
+}
+
+func TestValidateGenerator(t *testing.T) {
+	t.Parallel()
+
+	reg := genRegistry()
+
+	tests := []struct {
+		name      string
+		generator *Action
+		err       bool
+	}{
+		{
+			name: "Success: no generator",
+		},
+		{
+			name:      "Success: no registry",
+			generator: &Action{Plugin: "notGenerator"},
+		},
+		{
+			name:      "Success",
+			generator: &Action{Plugin: "generator", register: reg},
+		},
+		{
+			name:      "Error: has Refs",
+			generator: &Action{Plugin: "generator", Refs: []Ref{{Key: NewV7(), Field: "Field"}}, register: reg},
+			err:       true,
+		},
+		{
+			name:      "Error: check plugin",
+			generator: &Action{Plugin: "check", register: reg},
+			err:       true,
+		},
+		{
+			name:      "Error: response does not implement Generated",
+			generator: &Action{Plugin: "notGenerator", register: reg},
+			err:       true,
+		},
+	}
+
+	for _, test := range tests {
+		err := test.generator.validateGenerator()
+		switch {
+		case test.err && err == nil:
+			t.Errorf("TestValidateGenerator(%s): got err == nil, want err != nil", test.name)
+		case !test.err && err != nil:
+			t.Errorf("TestValidateGenerator(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	planID := NewV7()
+	action := func(plugin string) *Action {
+		return &Action{Name: "action", Descr: "action", Plugin: plugin, Req: "req"}
+	}
+	seq := func(actions ...*Action) *Sequence {
+		return &Sequence{Name: "seq", Descr: "seq", Actions: actions}
+	}
+	block := func(resp any) *Block {
+		b := &Block{
+			Sequences: []*Sequence{{Name: "existing"}},
+			Generator: &Action{
+				Name:     "generator",
+				State:    &State{Status: Completed},
+				Attempts: []*Attempt{{Resp: resp}},
+			},
+		}
+		b.SetPlanID(planID)
+		return b
+	}
+
+	tests := []struct {
+		name  string
+		block *Block
+		want  int
+		err   bool
+	}{
+		{
+			name:  "Success",
+			block: block(genResp{seqs: []*Sequence{seq(action("action")), seq(action("action"), action("action"))}}),
+			want:  2,
+		},
+		{
+			name: "Success: Sequence with a Rollback",
+			block: func() *Block {
+				s := seq(action("action"))
+				s.Rollback = []*Action{action("action")}
+				return block(genResp{seqs: []*Sequence{s}})
+			}(),
+			want: 1,
+		},
+		{
+			name:  "Success: no Sequences",
+			block: block(genResp{}),
+		},
+		{
+			name:  "Error: no generator",
+			block: &Block{},
+			err:   true,
+		},
+		{
+			name:  "Error: no response",
+			block: block(nil),
+			err:   true,
+		},
+		{
+			name:  "Error: response does not implement Generated",
+			block: block(struct{}{}),
+			err:   true,
+		},
+		{
+			name:  "Error: nil Sequence",
+			block: block(genResp{seqs: []*Sequence{nil}}),
+			err:   true,
+		},
+		{
+			name: "Error: Sequence has a Key",
+			block: func() *Block {
+				s := seq(action("action"))
+				s.Key = NewV7()
+				return block(genResp{seqs: []*Sequence{s}})
+			}(),
+			err: true,
+		},
+		{
+			name: "Error: Action has Refs",
+			block: func() *Block {
+				a := action("action")
+				a.Refs = []Ref{{Key: NewV7(), Field: "Field"}}
+				return block(genResp{seqs: []*Sequence{seq(a)}})
+			}(),
+			err: true,
+		},
+		{
+			name: "Error: Rollback Action has Refs",
+			block: func() *Block {
+				s := seq(action("action"))
+				a := action("action")
+				a.Refs = []Ref{{Key: NewV7(), Field: "Field"}}
+				s.Rollback = []*Action{a}
+				return block(genResp{seqs: []*Sequence{s}})
+			}(),
+			err: true,
+		},
+		{
+			name:  "Error: Action uses a check plugin",
+			block: block(genResp{seqs: []*Sequence{seq(action("check"))}}),
+			err:   true,
+		},
+		{
+			name:  "Error: Action plugin not found",
+			block: block(genResp{seqs: []*Sequence{seq(action("missing"))}}),
+			err:   true,
+		},
+		{
+			name:  "Error: Sequence is invalid",
+			block: block(genResp{seqs: []*Sequence{{Name: "seq", Actions: []*Action{action("action")}}}}),
+			err:   true,
+		},
+	}
+
+	for _, test := range tests {
+		existing := len(test.block.Sequences)
+
+		got, err := test.block.Generate(genRegistry())
+		switch {
+		case test.err && err == nil:
+			t.Errorf("TestGenerate(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.err && err != nil:
+			t.Errorf("TestGenerate(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			if len(test.block.Sequences) != existing {
+				t.Errorf("TestGenerate(%s): Block.Sequences changed on error", test.name)
+			}
+			continue
+		}
+
+		if len(got) != test.want {
+			t.Errorf("TestGenerate(%s): got %d Sequences, want %d", test.name, len(got), test.want)
+			continue
+		}
+		if len(test.block.Sequences) != existing+test.want {
+			t.Errorf("TestGenerate(%s): got %d Block.Sequences, want %d", test.name, len(test.block.Sequences), existing+test.want)
+		}
+		for i, s := range got {
+			if test.block.Sequences[existing+i] != s {
+				t.Errorf("TestGenerate(%s): Sequence(%d) was not added to the end of Block.Sequences", test.name, i)
+			}
+			if s.ID == uuid.Nil || s.State == nil || s.GetPlanID() != planID {
+				t.Errorf("TestGenerate(%s): Sequence(%d) did not have defaults applied", test.name, i)
+			}
+			for _, a := range append(append([]*Action{}, s.Actions...), s.Rollback...) {
+				if a.ID == uuid.Nil || a.State == nil || a.GetPlanID() != planID || !a.HasRegister() {
+					t.Errorf("TestGenerate(%s): Action in Sequence(%d) did not have defaults applied", test.name, i)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package workflow
+
+import (
+	"fmt"
+)
+
+// Ramp increases the number of Sequences a Block runs at the same time as its Sequences succeed.
+// This allows a Block to canary a change on a few targets before widening to its full Concurrency.
+type Ramp struct {
+	// Steps are the concurrency levels the Block moves through before it reaches its Concurrency, such as
+	// 1, 2, 5. The Block starts at the first step and returns to it whenever a Sequence fails. Steps must
+	// start at 1, be increasing and be no more than the Block's Concurrency. If not set, the level starts
+	// at 1 and doubles at each step. Optional.
+	Steps []int
+	// Successes is the number of Sequences that must succeed at a level before the Block moves to the next
+	// level. Required.
+	Successes int
+}
+
+// validate validates the Ramp for a Block with concurrency. A nil Ramp is valid.
+func (r *Ramp) validate(concurrency int) error {
+	if r == nil {
+		return nil
+	}
+	if r.Successes < 1 {
+		return fmt.Errorf("ramp successes must be at least 1")
+	}
+	for i, n := range r.Steps {
+		switch {
+		case n < 1:
+			return fmt.Errorf("ramp step(%d) must be at least 1", n)
+		case i == 0 && n != 1:
+			return fmt.Errorf("ramp steps must start at 1, not step(%d)", n)
+		case n > concurrency:
+			return fmt.Errorf("ramp step(%d) cannot be more than the block concurrency(%d)", n, concurrency)
+		case i > 0 && n <= r.Steps[i-1]:
+			return fmt.Errorf("ramp steps must be increasing, step(%d) follows step(%d)", n, r.Steps[i-1])
+		}
+	}
+	return nil
+}
+
+// Levels returns the concurrency levels a Block with concurrency moves through, ending at concurrency.
+// A nil Ramp has the single level concurrency.
+func (r *Ramp) Levels(concurrency int) []int {
+	concurrency = max(concurrency, 1)
+	if r == nil {
+		return []int{concurrency}
+	}
+
+	var levels []int
+	if len(r.Steps) > 0 {
+		levels = append(levels, r.Steps...)
+	} else {
+		for n := 1; n < concurrency; n *= 2 {
+			levels = append(levels, n)
+		}
+	}
+	if len(levels) == 0 || levels[len(levels)-1] < concurrency {
+		levels = append(levels, concurrency)
+	}
+	return levels
+}
@@ -0,0 +1,121 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestRampValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		ramp        *Ramp
+		concurrency int
+		err         bool
+	}{
+		{
+			name:        "Success: nil Ramp",
+			concurrency: 10,
+		},
+		{
+			name:        "Success: Steps",
+			ramp:        &Ramp{Steps: []int{1, 2, 5, 10}, Successes: 3},
+			concurrency: 10,
+		},
+		{
+			name:        "Success: no Steps",
+			ramp:        &Ramp{Successes: 1},
+			concurrency: 10,
+		},
+		{
+			name:        "Error: Successes is 0",
+			ramp:        &Ramp{Steps: []int{1, 2}},
+			concurrency: 10,
+			err:         true,
+		},
+		{
+			name:        "Error: Step is less than 1",
+			ramp:        &Ramp{Steps: []int{0, 2}, Successes: 1},
+			concurrency: 10,
+			err:         true,
+		},
+		{
+			name:        "Error: first Step is not 1",
+			ramp:        &Ramp{Steps: []int{2, 5}, Successes: 1},
+			concurrency: 10,
+			err:         true,
+		},
+		{
+			name:        "Error: Step is more than the concurrency",
+			ramp:        &Ramp{Steps: []int{1, 20}, Successes: 1},
+			concurrency: 10,
+			err:         true,
+		},
+		{
+			name:        "Error: Steps are not increasing",
+			ramp:        &Ramp{Steps: []int{1, 5, 5}, Successes: 1},
+			concurrency: 10,
+			err:         true,
+		},
+	}
+
+	for _, test := range tests {
+		err := test.ramp.validate(test.concurrency)
+		switch {
+		case test.err && err == nil:
+			t.Errorf("TestRampValidate(%s): got err == nil, want err != nil", test.name)
+		case !test.err && err != nil:
+			t.Errorf("TestRampValidate(%s): got err == %v, want err == nil", test.name, err)
+		}
+	}
+}
+
+func TestRampLevels(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		ramp        *Ramp
+		concurrency int
+		want        []int
+	}{
+		{
+			name:        "nil Ramp",
+			concurrency: 10,
+			want:        []int{10},
+		},
+		{
+			name:        "Steps end below the concurrency",
+			ramp:        &Ramp{Steps: []int{1, 2, 5}, Successes: 1},
+			concurrency: 10,
+			want:        []int{1, 2, 5, 10},
+		},
+		{
+			name:        "Steps end at the concurrency",
+			ramp:        &Ramp{Steps: []int{1, 2, 5, 10}, Successes: 1},
+			concurrency: 10,
+			want:        []int{1, 2, 5, 10},
+		},
+		{
+			name:        "no Steps doubles",
+			ramp:        &Ramp{Successes: 1},
+			concurrency: 10,
+			want:        []int{1, 2, 4, 8, 10},
+		},
+		{
+			name:        "no Steps with a concurrency of 1",
+			ramp:        &Ramp{Successes: 1},
+			concurrency: 1,
+			want:        []int{1},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.ramp.Levels(test.concurrency)
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestRampLevels(%s): -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
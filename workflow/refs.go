@@ -0,0 +1,283 @@
+package workflow
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Ref references the response of an earlier Action. Just before an Action with Refs is executed, each Ref
+// copies a value from the response of the referenced Action into the Action's Req. This allows an Action
+// to use data from an earlier step, such as the ID of a VM that an earlier Action created.
+type Ref struct {
+	// Key is the Key of the Action whose response is referenced. That Action must be earlier in the same
+	// Sequence or in a Sequence of an earlier Block. When Blocks do not run in sequence, the earlier Block
+	// must be one that the Action's Block depends on, directly or through other Blocks. An Action in a Sequence's Rollback may reference any
+	// Action in that Sequence and an Action in a Block's Rollback may reference any Action in that Block's
+	// Sequences. Required.
+	Key uuid.UUID
+	// Path is a dot separated path of exported field names in the referenced Action's response, such as "VM.ID".
+	// If empty, the whole response is used.
+	Path string
+	// Field is a dot separated path of exported field names in the Req that is set to the value, such as "VMID".
+	// The value's type must be assignable to the field. Required.
+	Field string
+}
+
+// refPos is the position of an Action in the Sequences of a Plan. Actions in a Sequence's Rollback
+// are positioned after the Sequence's Actions. Actions in a Block's Rollback have a seq of -1.
+type refPos struct {
+	block, seq, pos int
+	action          *Action
+}
+
+// before returns true if the Action at r has finished before the Action at o starts. blockBefore
+// reports if one Block completes before another starts.
+func (r refPos) before(o refPos, blockBefore func(a, b int) bool) bool {
+	if r.block != o.block {
+		return blockBefore(r.block, o.block)
+	}
+	if o.seq == -1 {
+		return true
+	}
+	return r.seq == o.seq && r.pos < o.pos
+}
+
+// validateRefs validates that the Refs in the Plan only reference Actions that have run before them and
+// that the referenced values can be set in the Req. Refs are only supported on Actions in a Sequence
+// and in a Rollback.
+func (p *Plan) validateRefs() error {
+	checks := []*Checks{p.BypassChecks, p.PreChecks, p.ContChecks, p.PostChecks, p.DeferredChecks}
+	keys := map[uuid.UUID]refPos{}
+	for bi, b := range p.Blocks {
+		if b == nil {
+			continue
+		}
+		checks = append(checks, b.BypassChecks, b.PreChecks, b.ContChecks, b.PostChecks, b.DeferredChecks)
+		for si, s := range b.Sequences {
+			if s == nil {
+				continue
+			}
+			for ai, a := range s.Actions {
+				if a != nil && a.Key != uuid.Nil {
+					keys[a.Key] = refPos{block: bi, seq: si, pos: ai, action: a}
+				}
+			}
+		}
+	}
+
+	for _, c := range checks {
+		if c == nil {
+			continue
+		}
+		for _, a := range c.Actions {
+			if a != nil && len(a.Refs) > 0 {
+				return fmt.Errorf("action(%s): refs are only supported on Actions in a Sequence", a.Name)
+			}
+		}
+	}
+
+	blockBefore := p.blockBefore()
+	for bi, b := range p.Blocks {
+		if b == nil {
+			continue
+		}
+		for si, s := range b.Sequences {
+			if s == nil {
+				continue
+			}
+			actions := append(append([]*Action{}, s.Actions...), s.Rollback...)
+			for ai, a := range actions {
+				if err := validateActionRefs(keys, refPos{block: bi, seq: si, pos: ai, action: a}, blockBefore); err != nil {
+					return err
+				}
+			}
+		}
+		for ai, a := range b.Rollback {
+			if err := validateActionRefs(keys, refPos{block: bi, seq: -1, pos: ai, action: a}, blockBefore); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateActionRefs validates the Refs of the Action at pos.
+func validateActionRefs(keys map[uuid.UUID]refPos, pos refPos, blockBefore func(a, b int) bool) error {
+	a := pos.action
+	if a == nil {
+		return nil
+	}
+	for _, ref := range a.Refs {
+		if err := ref.validate(keys, pos, blockBefore); err != nil {
+			return fmt.Errorf("action(%s): %w", a.Name, err)
+		}
+	}
+	return nil
+}
+
+// validate validates the Ref for the Action at pos. keys are the positions of all Actions with a Key.
+func (r Ref) validate(keys map[uuid.UUID]refPos, pos refPos, blockBefore func(a, b int) bool) error {
+	if r.Key == uuid.Nil {
+		return fmt.Errorf("ref must have a Key")
+	}
+	if strings.TrimSpace(r.Field) == "" {
+		return fmt.Errorf("ref(%s) must have a Field", r.Key)
+	}
+	ref, ok := keys[r.Key]
+	if !ok {
+		return fmt.Errorf("ref(%s) does not reference an Action in a Sequence", r.Key)
+	}
+	if !ref.before(pos, blockBefore) {
+		return fmt.Errorf("ref(%s) references Action(%s), which does not run before it", r.Key, ref.action.Name)
+	}
+
+	a := pos.action
+	if a.Req == nil {
+		return fmt.Errorf("ref(%s) cannot set Field(%s) in a nil Req", r.Key, r.Field)
+	}
+	ft, err := typeAt(reflect.TypeOf(a.Req), r.Field)
+	if err != nil {
+		return fmt.Errorf("ref(%s) Field: %w", r.Key, err)
+	}
+
+	// Without a registry, the response type is not known until the Action runs.
+	if ref.action.register == nil {
+		return nil
+	}
+	plug := ref.action.register.Plugin(ref.action.Plugin)
+	if plug == nil {
+		return nil
+	}
+	rt := reflect.TypeOf(plug.Response())
+	if rt == nil {
+		return fmt.Errorf("ref(%s) references plugin(%s), which has no response", r.Key, ref.action.Plugin)
+	}
+	vt, err := typeAt(rt, r.Path)
+	if err != nil {
+		return fmt.Errorf("ref(%s) Path: %w", r.Key, err)
+	}
+	if !vt.AssignableTo(ft) {
+		return fmt.Errorf("ref(%s) value of type %s cannot be assigned to Field(%s) of type %s", r.Key, vt, r.Field, ft)
+	}
+	return nil
+}
+
+// ResolveRefs sets the Fields in the Action's Req to the values referenced by its Refs. lookup returns
+// the Action with a Key. Each referenced Action must have completed. For use internally.
+func (a *Action) ResolveRefs(lookup func(key uuid.UUID) *Action) error {
+	req := a.Req
+	for _, r := range a.Refs {
+		ref := lookup(r.Key)
+		if ref == nil {
+			return fmt.Errorf("ref(%s) does not reference an Action in the Plan", r.Key)
+		}
+		if ref.State == nil || ref.State.Status != Completed {
+			return fmt.Errorf("ref(%s) references Action(%s), which has not completed", r.Key, ref.Name)
+		}
+		final := ref.FinalAttempt()
+		if final == nil || final.Resp == nil {
+			return fmt.Errorf("ref(%s) references Action(%s), which has no response", r.Key, ref.Name)
+		}
+		v, err := valueAt(reflect.ValueOf(final.Resp), r.Path)
+		if err != nil {
+			return fmt.Errorf("ref(%s) Path: %w", r.Key, err)
+		}
+		req, err = setAt(req, r.Field, v)
+		if err != nil {
+			return fmt.Errorf("ref(%s) Field: %w", r.Key, err)
+		}
+	}
+	a.Req = req
+	return nil
+}
+
+// typeAt returns the type of the field at path in t. Pointers are followed.
+func typeAt(t reflect.Type, path string) (reflect.Type, error) {
+	if path == "" {
+		return t, nil
+	}
+	for _, name := range strings.Split(path, ".") {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("%q in path(%s) is not in a struct", name, path)
+		}
+		f, ok := t.FieldByName(name)
+		if !ok || !f.IsExported() {
+			return nil, fmt.Errorf("%q in path(%s) is not an exported field of %s", name, path, t)
+		}
+		t = f.Type
+	}
+	return t, nil
+}
+
+// valueAt returns the value of the field at path in v. Pointers are followed.
+func valueAt(v reflect.Value, path string) (reflect.Value, error) {
+	if path == "" {
+		return v, nil
+	}
+	for _, name := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return reflect.Value{}, fmt.Errorf("%q in path(%s) is in a nil value", name, path)
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("%q in path(%s) is not in a struct", name, path)
+		}
+		f, ok := v.Type().FieldByName(name)
+		if !ok || !f.IsExported() {
+			return reflect.Value{}, fmt.Errorf("%q in path(%s) is not an exported field of %s", name, path, v.Type())
+		}
+		v = v.FieldByIndex(f.Index)
+	}
+	return v, nil
+}
+
+// setAt sets the field at path in req to v and returns req. If req is a pointer, the value it points
+// to is changed. Otherwise a copy of req is changed and returned. Nil pointers on the path are allocated.
+func setAt(req any, path string, v reflect.Value) (any, error) {
+	rv := reflect.ValueOf(req)
+	var root reflect.Value
+	switch {
+	case rv.Kind() == reflect.Pointer && !rv.IsNil():
+		root = rv.Elem()
+	case rv.Kind() == reflect.Pointer:
+		return nil, fmt.Errorf("req is a nil pointer")
+	default:
+		root = reflect.New(rv.Type()).Elem()
+		root.Set(rv)
+	}
+
+	f := root
+	for _, name := range strings.Split(path, ".") {
+		for f.Kind() == reflect.Pointer {
+			if f.IsNil() {
+				f.Set(reflect.New(f.Type().Elem()))
+			}
+			f = f.Elem()
+		}
+		if f.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("%q in path(%s) is not in a struct", name, path)
+		}
+		sf, ok := f.Type().FieldByName(name)
+		if !ok || !sf.IsExported() {
+			return nil, fmt.Errorf("%q in path(%s) is not an exported field of %s", name, path, f.Type())
+		}
+		f = f.FieldByIndex(sf.Index)
+	}
+	if !v.Type().AssignableTo(f.Type()) {
+		return nil, fmt.Errorf("value of type %s cannot be assigned to path(%s) of type %s", v.Type(), path, f.Type())
+	}
+	f.Set(v)
+
+	if rv.Kind() == reflect.Pointer {
+		return req, nil
+	}
+	return root.Interface(), nil
+}
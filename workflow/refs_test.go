@@ -0,0 +1,339 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/google/uuid"
+	"github.com/gostdlib/base/retry/exponential"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+type refVM struct {
+	ID   string
+	Size int
+}
+
+type refResp struct {
+	VM *refVM
+}
+
+type refInner struct {
+	ID string
+}
+
+type refReq struct {
+	VMID  string
+	Size  int
+	Inner *refInner
+}
+
+type refPlugin struct {
+	plugins.Plugin
+}
+
+func (refPlugin) Name() string {
+	return "refPlugin"
+}
+
+func (refPlugin) Request() any {
+	return refReq{}
+}
+
+func (refPlugin) Response() any {
+	return refResp{}
+}
+
+func (refPlugin) RetryPolicy() exponential.Policy {
+	return plugins.FastRetryPolicy()
+}
+
+func TestValidateRefs(t *testing.T) {
+	t.Parallel()
+
+	reg := registry.New()
+	reg.Register(refPlugin{})
+
+	createKey := NewV7()
+	action := func(name string, key uuid.UUID, refs ...Ref) *Action {
+		return &Action{Key: key, Name: name, Plugin: "refPlugin", Req: refReq{}, Refs: refs, register: reg}
+	}
+	plan := func(blocks ...*Block) *Plan {
+		return &Plan{Blocks: blocks}
+	}
+	seqs := func(seqs ...[]*Action) *Block {
+		b := &Block{}
+		for _, actions := range seqs {
+			b.Sequences = append(b.Sequences, &Sequence{Actions: actions})
+		}
+		return b
+	}
+
+	tests := []struct {
+		name string
+		plan *Plan
+		err  bool
+	}{
+		{
+			name: "Success: no refs",
+			plan: plan(seqs([]*Action{action("create", createKey), action("use", uuid.Nil)})),
+		},
+		{
+			name: "Success: ref to earlier Action in the same Sequence",
+			plan: plan(seqs([]*Action{
+				action("create", createKey),
+				action("use", uuid.Nil, Ref{Key: createKey, Path: "VM.ID", Field: "VMID"}),
+			})),
+		},
+		{
+			name: "Error: ref to Action in an earlier Block with a pointer type mismatch",
+			plan: plan(
+				seqs([]*Action{action("create", createKey)}),
+				seqs([]*Action{action("use", uuid.Nil, Ref{Key: createKey, Path: "VM", Field: "Inner"})}),
+			),
+			err: true,
+		},
+		{
+			name: "Success: ref to Action in an earlier Block with a nested Field",
+			plan: plan(
+				seqs([]*Action{action("create", createKey)}),
+				seqs([]*Action{action("use", uuid.Nil, Ref{Key: createKey, Path: "VM.ID", Field: "Inner.ID"})}),
+			),
+		},
+		{
+			name: "Error: ref to later Action",
+			plan: plan(seqs([]*Action{
+				action("use", uuid.Nil, Ref{Key: createKey, Path: "VM.ID", Field: "VMID"}),
+				action("create", createKey),
+			})),
+			err: true,
+		},
+		{
+			name: "Error: ref to Action in another Sequence of the same Block",
+			plan: plan(seqs(
+				[]*Action{action("create", createKey)},
+				[]*Action{action("use", uuid.Nil, Ref{Key: createKey, Path: "VM.ID", Field: "VMID"})},
+			)),
+			err: true,
+		},
+		{
+			name: "Error: ref to itself",
+			plan: plan(seqs([]*Action{action("create", createKey, Ref{Key: createKey, Path: "VM.ID", Field: "VMID"})})),
+			err:  true,
+		},
+		{
+			name: "Error: ref Key does not exist",
+			plan: plan(seqs([]*Action{
+				action("create", createKey),
+				action("use", uuid.Nil, Ref{Key: NewV7(), Path: "VM.ID", Field: "VMID"}),
+			})),
+			err: true,
+		},
+		{
+			name: "Error: ref has no Key",
+			plan: plan(seqs([]*Action{action("use", uuid.Nil, Ref{Path: "VM.ID", Field: "VMID"})})),
+			err:  true,
+		},
+		{
+			name: "Error: ref has no Field",
+			plan: plan(seqs([]*Action{
+				action("create", createKey),
+				action("use", uuid.Nil, Ref{Key: createKey, Path: "VM.ID"}),
+			})),
+			err: true,
+		},
+		{
+			name: "Error: ref Field does not exist",
+			plan: plan(seqs([]*Action{
+				action("create", createKey),
+				action("use", uuid.Nil, Ref{Key: createKey, Path: "VM.ID", Field: "Name"}),
+			})),
+			err: true,
+		},
+		{
+			name: "Error: ref Path does not exist",
+			plan: plan(seqs([]*Action{
+				action("create", createKey),
+				action("use", uuid.Nil, Ref{Key: createKey, Path: "VM.Name", Field: "VMID"}),
+			})),
+			err: true,
+		},
+		{
+			name: "Error: ref type mismatch",
+			plan: plan(seqs([]*Action{
+				action("create", createKey),
+				action("use", uuid.Nil, Ref{Key: createKey, Path: "VM.Size", Field: "VMID"}),
+			})),
+			err: true,
+		},
+		{
+			name: "Success: Sequence Rollback refs an Action in the Sequence",
+			plan: func() *Plan {
+				b := seqs([]*Action{action("create", createKey)})
+				b.Sequences[0].Rollback = []*Action{action("undo", uuid.Nil, Ref{Key: createKey, Path: "VM.ID", Field: "VMID"})}
+				return plan(b)
+			}(),
+		},
+		{
+			name: "Success: Block Rollback refs an Action in the Block",
+			plan: func() *Plan {
+				b := seqs([]*Action{action("other", uuid.Nil)}, []*Action{action("create", createKey)})
+				b.Rollback = []*Action{action("undo", uuid.Nil, Ref{Key: createKey, Path: "VM.ID", Field: "VMID"})}
+				return plan(b)
+			}(),
+		},
+		{
+			name: "Error: Sequence Rollback refs an Action in another Sequence of the same Block",
+			plan: func() *Plan {
+				b := seqs([]*Action{action("create", createKey)}, []*Action{action("other", uuid.Nil)})
+				b.Sequences[1].Rollback = []*Action{action("undo", uuid.Nil, Ref{Key: createKey, Path: "VM.ID", Field: "VMID"})}
+				return plan(b)
+			}(),
+			err: true,
+		},
+		{
+			name: "Error: Block Rollback refs an Action in a later Block",
+			plan: func() *Plan {
+				b := seqs([]*Action{action("other", uuid.Nil)})
+				b.Rollback = []*Action{action("undo", uuid.Nil, Ref{Key: createKey, Path: "VM.ID", Field: "VMID"})}
+				return plan(b, seqs([]*Action{action("create", createKey)}))
+			}(),
+			err: true,
+		},
+		{
+			name: "Success: ref to Action in a Block it depends on through another Block",
+			plan: func() *Plan {
+				a, b, c := seqs([]*Action{action("create", createKey)}), seqs([]*Action{action("other", uuid.Nil)}), seqs([]*Action{
+					action("use", uuid.Nil, Ref{Key: createKey, Path: "VM.ID", Field: "VMID"}),
+				})
+				a.Key, b.Key, c.Key = NewV7(), NewV7(), NewV7()
+				b.DependsOn = []uuid.UUID{a.Key}
+				c.DependsOn = []uuid.UUID{b.Key}
+				return plan(c, b, a)
+			}(),
+		},
+		{
+			name: "Error: ref to Action in an earlier Block that it does not depend on",
+			plan: func() *Plan {
+				a, b := seqs([]*Action{action("create", createKey)}), seqs([]*Action{
+					action("use", uuid.Nil, Ref{Key: createKey, Path: "VM.ID", Field: "VMID"}),
+				})
+				a.Key, b.Key = NewV7(), NewV7()
+				p := plan(a, b)
+				p.BlockConcurrency = 2
+				return p
+			}(),
+			err: true,
+		},
+		{
+			name: "Error: refs on a checks Action",
+			plan: &Plan{
+				PreChecks: &Checks{Actions: []*Action{action("check", uuid.Nil, Ref{Key: createKey, Field: "VMID"})}},
+				Blocks:    []*Block{seqs([]*Action{action("create", createKey)})},
+			},
+			err: true,
+		},
+	}
+
+	for _, test := range tests {
+		err := test.plan.validateRefs()
+		switch {
+		case test.err && err == nil:
+			t.Errorf("TestValidateRefs(%s): got err == nil, want err != nil", test.name)
+		case !test.err && err != nil:
+			t.Errorf("TestValidateRefs(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
+
+func TestResolveRefs(t *testing.T) {
+	t.Parallel()
+
+	createKey := NewV7()
+	completed := func(resp any) *Action {
+		return &Action{
+			Key:      createKey,
+			Name:     "create",
+			State:    &State{Status: Completed},
+			Attempts: []*Attempt{{Resp: resp}},
+		}
+	}
+	resp := refResp{VM: &refVM{ID: "vm-0", Size: 3}}
+
+	tests := []struct {
+		name   string
+		action *Action
+		ref    *Action
+		want   any
+		err    bool
+	}{
+		{
+			name: "Success: value Req",
+			action: &Action{
+				Req: refReq{},
+				Refs: []Ref{
+					{Key: createKey, Path: "VM.ID", Field: "VMID"},
+					{Key: createKey, Path: "VM.Size", Field: "Size"},
+					{Key: createKey, Path: "VM.ID", Field: "Inner.ID"},
+				},
+			},
+			ref:  completed(resp),
+			want: refReq{VMID: "vm-0", Size: 3, Inner: &refInner{ID: "vm-0"}},
+		},
+		{
+			name:   "Success: pointer Req",
+			action: &Action{Req: &refReq{}, Refs: []Ref{{Key: createKey, Path: "VM.ID", Field: "VMID"}}},
+			ref:    completed(&resp),
+			want:   &refReq{VMID: "vm-0"},
+		},
+		{
+			name:   "Error: referenced Action not found",
+			action: &Action{Req: refReq{}, Refs: []Ref{{Key: createKey, Path: "VM.ID", Field: "VMID"}}},
+			err:    true,
+		},
+		{
+			name:   "Error: referenced Action not completed",
+			action: &Action{Req: refReq{}, Refs: []Ref{{Key: createKey, Path: "VM.ID", Field: "VMID"}}},
+			ref:    &Action{Key: createKey, State: &State{Status: Failed}, Attempts: []*Attempt{{Resp: resp}}},
+			err:    true,
+		},
+		{
+			name:   "Error: nil value in Path",
+			action: &Action{Req: refReq{}, Refs: []Ref{{Key: createKey, Path: "VM.ID", Field: "VMID"}}},
+			ref:    completed(refResp{}),
+			err:    true,
+		},
+		{
+			name:   "Error: type mismatch",
+			action: &Action{Req: refReq{}, Refs: []Ref{{Key: createKey, Path: "VM.Size", Field: "VMID"}}},
+			ref:    completed(resp),
+			err:    true,
+		},
+	}
+
+	for _, test := range tests {
+		lookup := func(key uuid.UUID) *Action {
+			if test.ref == nil || test.ref.Key != key {
+				return nil
+			}
+			return test.ref
+		}
+
+		err := test.action.ResolveRefs(lookup)
+		switch {
+		case test.err && err == nil:
+			t.Errorf("TestResolveRefs(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.err && err != nil:
+			t.Errorf("TestResolveRefs(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if diff := pretty.Compare(test.want, test.action.Req); diff != "" {
+			t.Errorf("TestResolveRefs(%s): Req: -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
@@ -57,6 +57,8 @@ type Vault struct {
 	updater
 	closer
 	deleter
+	locker
+	leaser
 	recovery
 
 	private.Storage
@@ -180,12 +182,22 @@ func New(ctx context.Context, swarm, db, container string, cred azcore.TokenCred
 		client: r.contClient,
 		reader: r.reader,
 	}
-	r.updater = newUpdater(mu, r.contClient, &r.itemOpts)
+	r.updater = newUpdater(mu, r.contClient, r.reader, &r.itemOpts)
 	r.deleter = deleter{
 		mu:     mu,
 		client: r.contClient,
 		reader: r.reader,
 	}
+	r.locker = locker{
+		mu:     mu,
+		swarm:  swarm,
+		client: r.contClient,
+	}
+	r.leaser = leaser{
+		mu:     mu,
+		swarm:  swarm,
+		client: r.contClient,
+	}
 	r.closer = closer{}
 	r.recovery = recovery{reader: r.reader, updater: r.updater}
 	return r, nil
@@ -315,6 +327,7 @@ var indexPaths = []azcosmos.IncludedPath{
 	pathToScalar("swarm"),      // plans, checks, sequences, actions
 	pathToScalar("type"),       // plans, checks, sequences, actions
 	pathToScalar("groupID"),    // plans
+	pathToScalar("callerID"),   // plans
 	pathToScalar("submitTime"), // plans
 	pathToScalar("key"),        // blocks, checks, sequences, actions
 	pathToScalar("planID"),     // blocks, checks, sequences, actions
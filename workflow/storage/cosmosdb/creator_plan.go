@@ -106,10 +106,13 @@ func planToSearchEntry(swarm string, p *workflow.Plan) (searchEntry, error) {
 		Descr:        p.Descr,
 		ID:           p.ID,
 		GroupID:      p.GroupID,
+		CallerID:     p.CallerID,
 		StateStatus:  p.State.Status,
 		SubmitTime:   p.SubmitTime,
 		StateStart:   p.State.Start,
 		StateEnd:     p.State.End,
+		Priority:     p.Priority,
+		QueuedAt:     p.QueuedAt,
 	}, nil
 }
 
@@ -175,20 +178,33 @@ func planToEntry(swarm string, p *workflow.Plan) (plansEntry, error) {
 	}
 
 	plan := plansEntry{
-		PartitionKey: keyStr(p.ID),
-		Swarm:        swarm,
-		Type:         workflow.OTPlan,
-		ID:           p.ID,
-		PlanID:       p.ID,
-		GroupID:      p.GroupID,
-		Name:         p.Name,
-		Descr:        p.Descr,
-		Meta:         p.Meta,
-		Blocks:       blocks,
-		StateStatus:  p.State.Status,
-		StateStart:   p.State.Start,
-		StateEnd:     p.State.End,
-		Reason:       p.Reason,
+		PartitionKey:     keyStr(p.ID),
+		Swarm:            swarm,
+		Type:             workflow.OTPlan,
+		ID:               p.ID,
+		PlanID:           p.ID,
+		GroupID:          p.GroupID,
+		ParentID:         p.ParentID,
+		CallerID:         p.CallerID,
+		Name:             p.Name,
+		Descr:            p.Descr,
+		Meta:             p.Meta,
+		Blocks:           blocks,
+		StateStatus:      p.State.Status,
+		StateStart:       p.State.Start,
+		StateEnd:         p.State.End,
+		Reason:           p.Reason,
+		Paused:           p.Paused,
+		Drained:          p.Drained,
+		StartAt:          p.StartAt,
+		Recurring:        p.Recurring,
+		Timeout:          p.Timeout,
+		Locks:            p.Locks,
+		Approval:         p.Approval,
+		BlockConcurrency: p.BlockConcurrency,
+		Priority:         p.Priority,
+		QueuedAt:         p.QueuedAt,
+		TraceID:          p.TraceID,
 	}
 
 	if p.BypassChecks != nil {
@@ -278,7 +294,13 @@ func blockToItem(iCtx *itemsContext, pos int, b *workflow.Block) error {
 		}
 	}
 
-	if b.Sequences == nil {
+	if b.Generator != nil {
+		if err := actionToItems(iCtx, 0, b.Generator); err != nil {
+			return fmt.Errorf("commitBlock(commitGenerator): %w", err)
+		}
+	}
+
+	if b.Sequences == nil && b.Generator == nil && b.SubPlan == nil {
 		return fmt.Errorf("commitBlock: block.Sequences cannot be nil")
 	}
 	for i, seq := range b.Sequences {
@@ -286,6 +308,11 @@ func blockToItem(iCtx *itemsContext, pos int, b *workflow.Block) error {
 			return fmt.Errorf("(commitSequence: %w", err)
 		}
 	}
+	for i, a := range b.Rollback {
+		if err := actionToItems(iCtx, i, a); err != nil {
+			return fmt.Errorf("commitBlock(commitRollback): %w", err)
+		}
+	}
 	item, err := json.Marshal(block)
 	if err != nil {
 		return fmt.Errorf("failed to marshal item: %w", err)
@@ -305,25 +332,37 @@ func blockToEntry(iCtx *itemsContext, pos int, b *workflow.Block) (blocksEntry,
 	if err != nil {
 		return blocksEntry{}, fmt.Errorf("objsToIDs(sequences): %w", err)
 	}
+	rollback, err := objsToIDs(b.Rollback)
+	if err != nil {
+		return blocksEntry{}, fmt.Errorf("objsToIDs(rollback): %w", err)
+	}
 
 	block := blocksEntry{
-		PartitionKey:      keyStr(iCtx.planID),
-		Swarm:             iCtx.swarm,
-		Type:              workflow.OTBlock,
-		ID:                b.ID,
-		Key:               b.Key,
-		PlanID:            iCtx.planID,
-		Name:              b.Name,
-		Descr:             b.Descr,
-		Pos:               pos,
-		EntranceDelay:     b.EntranceDelay,
-		ExitDelay:         b.ExitDelay,
-		Sequences:         sequences,
-		Concurrency:       b.Concurrency,
-		ToleratedFailures: b.ToleratedFailures,
-		StateStatus:       b.State.Status,
-		StateStart:        b.State.Start,
-		StateEnd:          b.State.End,
+		PartitionKey:            keyStr(iCtx.planID),
+		Swarm:                   iCtx.swarm,
+		Type:                    workflow.OTBlock,
+		ID:                      b.ID,
+		Key:                     b.Key,
+		PlanID:                  iCtx.planID,
+		Name:                    b.Name,
+		Descr:                   b.Descr,
+		DependsOn:               b.DependsOn,
+		Pos:                     pos,
+		EntranceDelay:           b.EntranceDelay,
+		ExitDelay:               b.ExitDelay,
+		Timeout:                 b.Timeout,
+		Locks:                   b.Locks,
+		Ramp:                    b.Ramp,
+		Sequences:               sequences,
+		Rollback:                rollback,
+		Concurrency:             b.Concurrency,
+		ToleratedFailures:       b.ToleratedFailures,
+		ToleratedFailurePercent: b.ToleratedFailurePercent,
+		ToleratedFailureSample:  b.ToleratedFailureSample,
+		Approval:                b.Approval,
+		StateStatus:             b.State.Status,
+		StateStart:              b.State.Start,
+		StateEnd:                b.State.End,
 	}
 
 	if b.BypassChecks != nil {
@@ -341,6 +380,12 @@ func blockToEntry(iCtx *itemsContext, pos int, b *workflow.Block) (blocksEntry,
 	if b.DeferredChecks != nil {
 		block.DeferredChecks = b.DeferredChecks.ID
 	}
+	if b.Generator != nil {
+		block.Generator = b.Generator.ID
+	}
+	if b.SubPlan != nil {
+		block.SubPlan = b.SubPlan.ID
+	}
 	return block, nil
 }
 
@@ -362,6 +407,11 @@ func seqToItems(iCtx *itemsContext, pos int, seq *workflow.Sequence) error {
 			return fmt.Errorf("planToEntry(commitAction): %w", err)
 		}
 	}
+	for i, a := range seq.Rollback {
+		if err := actionToItems(iCtx, i, a); err != nil {
+			return fmt.Errorf("planToEntry(commitRollback): %w", err)
+		}
+	}
 	item, err := json.Marshal(sequence)
 	if err != nil {
 		return fmt.Errorf("failed to marshal item: %w", err)
@@ -380,6 +430,10 @@ func sequenceToEntry(iCtx *itemsContext, pos int, seq *workflow.Sequence) (seque
 	if err != nil {
 		return sequencesEntry{}, fmt.Errorf("objsToIDs(actions): %w", err)
 	}
+	rollback, err := objsToIDs(seq.Rollback)
+	if err != nil {
+		return sequencesEntry{}, fmt.Errorf("objsToIDs(rollback): %w", err)
+	}
 
 	return sequencesEntry{
 		PartitionKey: keyStr(iCtx.planID),
@@ -392,6 +446,9 @@ func sequenceToEntry(iCtx *itemsContext, pos int, seq *workflow.Sequence) (seque
 		Descr:        seq.Descr,
 		Pos:          pos,
 		Actions:      actions,
+		Rollback:     rollback,
+		Timeout:      seq.Timeout,
+		Locks:        seq.Locks,
 		StateStatus:  seq.State.Status,
 		StateStart:   seq.State.Start,
 		StateEnd:     seq.State.End,
@@ -444,7 +501,10 @@ func actionToEntry(iCtx *itemsContext, pos int, a *workflow.Action) (actionsEntr
 		Plugin:       a.Plugin,
 		Timeout:      a.Timeout,
 		Retries:      a.Retries,
+		RetryPolicy:  a.RetryPolicy,
+		MaxElapsed:   a.MaxElapsed,
 		Req:          req,
+		Refs:         a.Refs,
 		Attempts:     attempts,
 		StateStatus:  a.State.Status,
 		StateStart:   a.State.Start,
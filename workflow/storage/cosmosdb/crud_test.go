@@ -4,12 +4,14 @@ import (
 	"context"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
 	"github.com/google/uuid"
 
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/element-of-surprise/coercion/workflow/storage/sqlite/testing/plugins"
 	"github.com/element-of-surprise/coercion/workflow/utils/walk"
 )
 
@@ -19,7 +21,18 @@ func TestStorageItemCRUD(t *testing.T) {
 	plan0 := NewTestPlan()
 	plan1 := NewTestPlan()
 	plan2 := NewTestPlan()
-	plans := []*workflow.Plan{plan0, plan1, plan2}
+	// plan2 is run by plan1 as a SubPlan, so it must be created first.
+	plan2.CallerID = plan1.ID
+	subBlock := &workflow.Block{
+		ID:      mustUUID(),
+		Name:    "sub-plan",
+		Descr:   "sub-plan",
+		SubPlan: plan2,
+		State:   &workflow.State{Status: workflow.NotStarted},
+	}
+	subBlock.SetPlanID(plan1.ID)
+	plan1.Blocks = append(plan1.Blocks, subBlock)
+	plans := []*workflow.Plan{plan0, plan2, plan1}
 
 	store := newFakeStorage(testReg)
 
@@ -34,7 +47,7 @@ func TestStorageItemCRUD(t *testing.T) {
 		reg:          testReg,
 	}
 
-	newUpdater(mu, store, defaultIOpts)
+	newUpdater(mu, store, reader, defaultIOpts)
 	v := &Vault{
 		reader: reader,
 		creator: creator{
@@ -42,7 +55,7 @@ func TestStorageItemCRUD(t *testing.T) {
 			client: store,
 			reader: reader,
 		},
-		updater: newUpdater(mu, store, defaultIOpts),
+		updater: newUpdater(mu, store, reader, defaultIOpts),
 		deleter: deleter{
 			mu:     mu,
 			client: store,
@@ -124,12 +137,32 @@ func TestStorageItemCRUD(t *testing.T) {
 		if res.Err != nil {
 			t.Fatalf("error when listing results: %v", res.Err)
 		}
+		if res.Result.ID == plan0.ID {
+			if res.Result.Priority != plan0.Priority || !res.Result.QueuedAt.Equal(plan0.QueuedAt) {
+				t.Errorf("TestStorageItemCRUD(search): got Priority %d, QueuedAt %v, want %d, %v", res.Result.Priority, res.Result.QueuedAt, plan0.Priority, plan0.QueuedAt)
+			}
+		}
 		resultCount++
 	}
 	if resultCount != 2 {
 		t.Fatalf("expected 2 result, got %d", resultCount)
 	}
 
+	results, err = v.Search(ctx, storage.Filters{ByCallerIDs: []uuid.UUID{plan1.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var callerResults []storage.ListResult
+	for res := range results {
+		if res.Err != nil {
+			t.Fatalf("error when listing results: %v", res.Err)
+		}
+		callerResults = append(callerResults, res.Result)
+	}
+	if len(callerResults) != 1 || callerResults[0].ID != plan2.ID || callerResults[0].CallerID != plan1.ID {
+		t.Fatalf("TestStorageItemCRUD(search by caller ID): got %+v, want only plan2", callerResults)
+	}
+
 	// Walk every item and change the state.Status to Stopped.
 	// We can then update all the objects and then test that the updates occurred.
 	for item := range walk.Plan(context.Background(), plan0) {
@@ -151,6 +184,32 @@ func TestStorageItemCRUD(t *testing.T) {
 		t.Fatalf("TestStorageItemCRUD(read back changed object): -want/+got:\n%s", diff)
 	}
 
+	// Add a generated Sequence to the first Block, which also writes the Generator.
+	block := plan0.Blocks[0]
+	seq := &workflow.Sequence{
+		ID:      mustUUID(),
+		Name:    "generated",
+		Descr:   "generated",
+		State:   &workflow.State{Status: workflow.NotStarted},
+		Actions: []*workflow.Action{{ID: mustUUID(), Name: "generated", Descr: "generated", Plugin: plugins.HelloPluginName, Req: plugins.HelloReq{Say: "hello"}, State: &workflow.State{Status: workflow.NotStarted}}},
+	}
+	seq.SetPlanID(plan0.ID)
+	seq.Actions[0].SetPlanID(plan0.ID)
+	block.Sequences = append(block.Sequences, seq)
+	block.Generator.State.Status = workflow.Completed
+	block.Generator.Attempts = []*workflow.Attempt{{Resp: plugins.HelloResp{Said: "hello"}, Start: time.Now().UTC(), End: time.Now().UTC()}}
+	if err := v.AddSequences(ctx, block, []*workflow.Sequence{seq}); err != nil {
+		t.Fatalf("TestStorageItemCRUD(AddSequences): %s", err)
+	}
+
+	got, err = v.Read(ctx, plan0.GetID())
+	if err != nil {
+		t.Fatalf("TestStorageItemCRUD(read back added sequences): error reading plan back")
+	}
+	if diff := prettyConfig.Compare(plan0, got); diff != "" {
+		t.Fatalf("TestStorageItemCRUD(read back added sequences): -want/+got:\n%s", diff)
+	}
+
 	// test delete
 	if err := v.Delete(ctx, plan0.ID); err != nil {
 		t.Fatal(err)
@@ -162,4 +221,12 @@ func TestStorageItemCRUD(t *testing.T) {
 			t.Errorf("TestStorageItemCRUD(delete): %s value in deleted plan still in storage", item.Value.Type())
 		}
 	}
+
+	// Deleting a Plan deletes its SubPlans.
+	if err := v.Delete(ctx, plan1.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Read(ctx, plan2.ID); err == nil {
+		t.Errorf("TestStorageItemCRUD(delete): sub-plan of deleted plan still in storage")
+	}
 }
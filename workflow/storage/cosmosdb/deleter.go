@@ -41,6 +41,12 @@ func (d deleter) Delete(ctx context.Context, id uuid.UUID) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	return d.deleteWithSubPlans(ctx, plan)
+}
+
+// deleteWithSubPlans deletes the plan and its search entry, then the SubPlans of its Blocks. Each Plan is
+// in its own partition, so they cannot be deleted in the same batch.
+func (d deleter) deleteWithSubPlans(ctx context.Context, plan *workflow.Plan) error {
 	deletePlan := func(ctx context.Context, r exponential.Record) error {
 		if err := d.deletePlan(ctx, plan); err != nil {
 			if !isRetriableError(err) {
@@ -66,6 +72,14 @@ func (d deleter) Delete(ctx context.Context, id uuid.UUID) error {
 		return fmt.Errorf("couldn't delete plan: %w", err)
 	}
 
+	for _, b := range plan.Blocks {
+		if b.SubPlan == nil {
+			continue
+		}
+		if err := d.deleteWithSubPlans(ctx, b.SubPlan); err != nil {
+			return fmt.Errorf("couldn't delete sub-plan(%s): %w", b.SubPlan.ID, err)
+		}
+	}
 	return nil
 }
 
@@ -139,9 +153,17 @@ func (d deleter) deleteBlocks(ctx context.Context, batch *azcosmos.Transactional
 		if err := d.deleteChecks(ctx, batch, block.DeferredChecks); err != nil {
 			return fmt.Errorf("couldn't delete block deferredchecks: %w", err)
 		}
+		if block.Generator != nil {
+			if err := d.deleteActions(ctx, batch, []*workflow.Action{block.Generator}); err != nil {
+				return fmt.Errorf("couldn't delete block generator: %w", err)
+			}
+		}
 		if err := d.deleteSeqs(ctx, batch, block.Sequences); err != nil {
 			return fmt.Errorf("couldn't delete block sequences: %w", err)
 		}
+		if err := d.deleteActions(ctx, batch, block.Rollback); err != nil {
+			return fmt.Errorf("couldn't delete block rollback: %w", err)
+		}
 	}
 
 	for _, block := range blocks {
@@ -189,6 +211,9 @@ func (d deleter) deleteSeqs(ctx context.Context, batch *azcosmos.TransactionalBa
 		if err := d.deleteActions(ctx, batch, seq.Actions); err != nil {
 			return fmt.Errorf("couldn't delete sequence actions: %w", err)
 		}
+		if err := d.deleteActions(ctx, batch, seq.Rollback); err != nil {
+			return fmt.Errorf("couldn't delete sequence rollback: %w", err)
+		}
 	}
 
 	for _, seq := range seqs {
@@ -8,6 +8,7 @@ import (
 	"math"
 	"net/http"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -60,6 +61,7 @@ CREATE Table If Not Exists pages (
 CREATE Table If Not Exists search (
 	id TEXT PRIMARY KEY,
 	group_id TEXT,
+	caller_id TEXT,
 	name string,
 	descr string,
 	status INTEGER,
@@ -68,6 +70,21 @@ CREATE Table If Not Exists search (
 	submitTime INTEGER,
 	data BLOB NOT NULL
 );`
+
+		locksTable = `
+CREATE Table If Not Exists locks (
+	id TEXT PRIMARY KEY,
+	swarm TEXT NOT NULL,
+	data BLOB NOT NULL
+);`
+
+		leasesTable = `
+CREATE Table If Not Exists leases (
+	id TEXT PRIMARY KEY,
+	swarm TEXT NOT NULL,
+	etag TEXT NOT NULL,
+	data BLOB NOT NULL
+);`
 	)
 
 	var flags sqlite.OpenFlags
@@ -112,6 +129,20 @@ CREATE Table If Not Exists search (
 	); err != nil {
 		panic(fmt.Sprintf("couldn't create table: %s", err))
 	}
+	if err := sqlitex.ExecuteTransient(
+		conn,
+		locksTable,
+		&sqlitex.ExecOptions{},
+	); err != nil {
+		panic(fmt.Sprintf("couldn't create table: %s", err))
+	}
+	if err := sqlitex.ExecuteTransient(
+		conn,
+		leasesTable,
+		&sqlitex.ExecOptions{},
+	); err != nil {
+		panic(fmt.Sprintf("couldn't create table: %s", err))
+	}
 	return &fakeStorage{pool: pool, reg: reg}
 }
 
@@ -140,7 +171,7 @@ func (f *fakeStorage) writeData(ctx context.Context, id, planID string, data []b
 }
 
 func (f *fakeStorage) writeSearchData(ctx context.Context, data []byte) (err error) {
-	const q = `INSERT OR REPLACE INTO search (id, group_id, name, descr, status, stateStart, stateEnd, submitTime, data) VALUES ($id, $group_id, $name, $descr, $status, $stateStart, $stateEnd, $submitTime, $data);`
+	const q = `INSERT OR REPLACE INTO search (id, group_id, caller_id, name, descr, status, stateStart, stateEnd, submitTime, data) VALUES ($id, $group_id, $caller_id, $name, $descr, $status, $stateStart, $stateEnd, $submitTime, $data);`
 
 	se := searchEntry{}
 	if err := json.Unmarshal(data, &se); err != nil {
@@ -158,6 +189,7 @@ func (f *fakeStorage) writeSearchData(ctx context.Context, data []byte) (err err
 		Named: map[string]any{
 			"$id":         se.ID.String(),
 			"$group_id":   se.GroupID.String(),
+			"$caller_id":  se.CallerID.String(),
 			"$name":       se.Name,
 			"$descr":      se.Descr,
 			"$status":     int(se.StateStatus),
@@ -248,6 +280,13 @@ func (f *fakeStorage) ExecuteTransactionalBatch(ctx context.Context, b azcosmos.
 	}
 	key, ops := unsafeBatchOps(&b)
 
+	switch key {
+	case lockKeyStr:
+		return f.executeLockBatch(ctx, ops)
+	case leaseKeyStr:
+		return f.executeLeaseBatch(ctx, ops)
+	}
+
 	for _, op := range ops {
 		switch op.op {
 		case "Create":
@@ -298,7 +337,11 @@ func (f *fakeStorage) ExecuteTransactionalBatch(ctx context.Context, b azcosmos.
 					panic(err)
 				}
 			} else {
-				if err := f.writeData(ctx, op.itemID, "", op.resourceBody); err != nil {
+				fields, err := getCommonFields(op.resourceBody)
+				if err != nil {
+					panic(err)
+				}
+				if err := f.writeData(ctx, op.itemID, fields.PlanID.String(), op.resourceBody); err != nil {
 					panic(err)
 				}
 			}
@@ -309,6 +352,212 @@ func (f *fakeStorage) ExecuteTransactionalBatch(ctx context.Context, b azcosmos.
 	return azcosmos.TransactionalBatchResponse{}, nil
 }
 
+// executeLockBatch executes a batch in the lock partition. Unlike other batches, this simulates that
+// a batch is atomic: if any Create is for a lock that exists, nothing is written and the batch is
+// reported as not successful.
+func (f *fakeStorage) executeLockBatch(ctx context.Context, ops []batchOp) (resp azcosmos.TransactionalBatchResponse, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.createItemErr || f.deleteItemErr {
+		return azcosmos.TransactionalBatchResponse{}, errors.New("error")
+	}
+
+	conn, err := f.pool.Take(ctx)
+	if err != nil {
+		panic(fmt.Sprintf("couldn't get a connection from the pool: %s", err))
+	}
+	defer f.pool.Put(conn)
+	defer sqlitex.Transaction(conn)(&err)
+
+	results := make([]azcosmos.TransactionalBatchResult, len(ops))
+	for i := range results {
+		results[i].StatusCode = http.StatusFailedDependency
+	}
+
+	for i, op := range ops {
+		switch op.op {
+		case "Create":
+			entry := locksEntry{}
+			if err := json.Unmarshal(op.resourceBody, &entry); err != nil {
+				panic(err)
+			}
+			if _, ok := f.readLock(conn, entry.ID); ok {
+				results[i].StatusCode = http.StatusConflict
+				return azcosmos.TransactionalBatchResponse{OperationResults: results}, nil
+			}
+		case "Delete":
+			if _, ok := f.readLock(conn, op.itemID); !ok {
+				results[i].StatusCode = http.StatusNotFound
+				return azcosmos.TransactionalBatchResponse{OperationResults: results}, nil
+			}
+		default:
+			panic("do not support the lock TransactionBatch op: " + op.op)
+		}
+	}
+
+	for i, op := range ops {
+		switch op.op {
+		case "Create":
+			entry := locksEntry{}
+			if err := json.Unmarshal(op.resourceBody, &entry); err != nil {
+				panic(err)
+			}
+			err = sqlitex.Execute(conn, `INSERT INTO locks (id, swarm, data) VALUES ($id, $swarm, $data);`, &sqlitex.ExecOptions{
+				Named: map[string]any{
+					"$id":    entry.ID,
+					"$swarm": entry.Swarm,
+					"$data":  op.resourceBody,
+				},
+			})
+			results[i].StatusCode = http.StatusCreated
+		case "Delete":
+			err = sqlitex.Execute(conn, `DELETE FROM locks WHERE id = $id;`, &sqlitex.ExecOptions{
+				Named: map[string]any{"$id": op.itemID},
+			})
+			results[i].StatusCode = http.StatusNoContent
+		}
+		if err != nil {
+			panic(err)
+		}
+	}
+	return azcosmos.TransactionalBatchResponse{OperationResults: results, Success: true}, nil
+}
+
+// executeLeaseBatch executes a batch in the lease partition. Like a lock batch this is atomic, and it
+// also simulates ETags: each write gets a new ETag and a Replace or Delete with an IfMatchETag that
+// does not match fails with http.StatusPreconditionFailed.
+func (f *fakeStorage) executeLeaseBatch(ctx context.Context, ops []batchOp) (resp azcosmos.TransactionalBatchResponse, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.createItemErr || f.replaceItemErr || f.deleteItemErr {
+		return azcosmos.TransactionalBatchResponse{}, errors.New("error")
+	}
+
+	conn, err := f.pool.Take(ctx)
+	if err != nil {
+		panic(fmt.Sprintf("couldn't get a connection from the pool: %s", err))
+	}
+	defer f.pool.Put(conn)
+	defer sqlitex.Transaction(conn)(&err)
+
+	results := make([]azcosmos.TransactionalBatchResult, len(ops))
+	for i := range results {
+		results[i].StatusCode = http.StatusFailedDependency
+	}
+
+	for i, op := range ops {
+		id := op.itemID
+		if op.op == "Create" {
+			entry := leasesEntry{}
+			if err := json.Unmarshal(op.resourceBody, &entry); err != nil {
+				panic(err)
+			}
+			id = entry.ID
+		}
+		_, etag, ok := f.readLease(conn, id)
+		switch op.op {
+		case "Create":
+			if ok {
+				results[i].StatusCode = http.StatusConflict
+				return azcosmos.TransactionalBatchResponse{OperationResults: results}, nil
+			}
+		case "Replace", "Delete":
+			if !ok {
+				results[i].StatusCode = http.StatusNotFound
+				return azcosmos.TransactionalBatchResponse{OperationResults: results}, nil
+			}
+			if op.ifMatch != nil && *op.ifMatch != etag {
+				results[i].StatusCode = http.StatusPreconditionFailed
+				return azcosmos.TransactionalBatchResponse{OperationResults: results}, nil
+			}
+		default:
+			panic("do not support the lease TransactionBatch op: " + op.op)
+		}
+	}
+
+	for i, op := range ops {
+		switch op.op {
+		case "Create", "Replace":
+			entry := leasesEntry{}
+			if err := json.Unmarshal(op.resourceBody, &entry); err != nil {
+				panic(err)
+			}
+			entry.ETag = azcore.ETag(uuid.NewString())
+			b, err := json.Marshal(entry)
+			if err != nil {
+				panic(err)
+			}
+			err = sqlitex.Execute(conn, `INSERT OR REPLACE INTO leases (id, swarm, etag, data) VALUES ($id, $swarm, $etag, $data);`, &sqlitex.ExecOptions{
+				Named: map[string]any{
+					"$id":    entry.ID,
+					"$swarm": entry.Swarm,
+					"$etag":  string(entry.ETag),
+					"$data":  b,
+				},
+			})
+			if err != nil {
+				panic(err)
+			}
+			results[i].StatusCode = http.StatusOK
+		case "Delete":
+			err = sqlitex.Execute(conn, `DELETE FROM leases WHERE id = $id;`, &sqlitex.ExecOptions{
+				Named: map[string]any{"$id": op.itemID},
+			})
+			if err != nil {
+				panic(err)
+			}
+			results[i].StatusCode = http.StatusNoContent
+		}
+	}
+	return azcosmos.TransactionalBatchResponse{OperationResults: results, Success: true}, nil
+}
+
+// readLease reads the lease item with id and its ETag from the leases table.
+func (f *fakeStorage) readLease(conn *sqlite.Conn, id string) ([]byte, azcore.ETag, bool) {
+	var item []byte
+	var etag string
+	err := sqlitex.Execute(
+		conn,
+		`SELECT etag, data FROM leases WHERE id = $id`,
+		&sqlitex.ExecOptions{
+			Named: map[string]any{"$id": id},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				etag = stmt.GetText("etag")
+				item = make([]byte, stmt.GetLen("data"))
+				stmt.GetBytes("data", item)
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+	return item, azcore.ETag(etag), item != nil
+}
+
+// readLock reads the lock item with id from the locks table.
+func (f *fakeStorage) readLock(conn *sqlite.Conn, id string) ([]byte, bool) {
+	var item []byte
+	err := sqlitex.Execute(
+		conn,
+		`SELECT data FROM locks WHERE id = $id`,
+		&sqlitex.ExecOptions{
+			Named: map[string]any{"$id": id},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				item = make([]byte, stmt.GetLen("data"))
+				stmt.GetBytes("data", item)
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+	return item, item != nil
+}
+
 func (f *fakeStorage) WritePlan(ctx context.Context, plan *workflow.Plan) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -331,6 +580,13 @@ func (f *fakeStorage) ReadItem(ctx context.Context, pk azcosmos.PartitionKey, it
 		return azcosmos.ItemResponse{}, f.readItemErr
 	}
 
+	switch k, _ := partitionKeyToStr(&pk); k {
+	case lockKeyStr:
+		return f.readLockItem(ctx, itemID)
+	case leaseKeyStr:
+		return f.readLeaseItem(ctx, itemID)
+	}
+
 	d, _, err := f.readItem(ctx, itemID)
 	if err != nil {
 		return azcosmos.ItemResponse{}, err
@@ -338,6 +594,42 @@ func (f *fakeStorage) ReadItem(ctx context.Context, pk azcosmos.PartitionKey, it
 	return azcosmos.ItemResponse{Value: d}, nil
 }
 
+func (f *fakeStorage) readLockItem(ctx context.Context, itemID string) (azcosmos.ItemResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	conn, err := f.pool.Take(ctx)
+	if err != nil {
+		panic(err)
+	}
+	defer f.pool.Put(conn)
+
+	d, ok := f.readLock(conn, itemID)
+	if !ok {
+		return azcosmos.ItemResponse{}, &azcore.ResponseError{StatusCode: http.StatusNotFound}
+	}
+	return azcosmos.ItemResponse{Value: d}, nil
+}
+
+func (f *fakeStorage) readLeaseItem(ctx context.Context, itemID string) (azcosmos.ItemResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	conn, err := f.pool.Take(ctx)
+	if err != nil {
+		panic(err)
+	}
+	defer f.pool.Put(conn)
+
+	d, etag, ok := f.readLease(conn, itemID)
+	if !ok {
+		return azcosmos.ItemResponse{}, &azcore.ResponseError{StatusCode: http.StatusNotFound}
+	}
+	resp := azcosmos.ItemResponse{Value: d}
+	resp.ETag = etag
+	return resp, nil
+}
+
 func (f *fakeStorage) readItem(ctx context.Context, itemID string) (data []byte, planID string, err error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -414,8 +706,13 @@ func (f *fakeStorage) NewQueryItemsPager(query string, pk azcosmos.PartitionKey,
 	}
 
 	s, _ := partitionKeyToStr(&pk)
-	if s == searchKeyStr {
+	switch s {
+	case searchKeyStr:
 		return f.searchItemPager(query, pk, o)
+	case lockKeyStr:
+		return f.locksItemPager(query, pk, o, "locks")
+	case leaseKeyStr:
+		return f.locksItemPager(query, pk, o, "leases")
 	}
 
 	return f.pagesItemPager(query, pk, o)
@@ -444,7 +741,7 @@ func (f *fakeStorage) pagesItemPager(query string, pk azcosmos.PartitionKey, o *
 
 	ids := map[uuid.UUID]struct{}{}
 	if o != nil && len(o.QueryParameters) > 0 {
-		ids = getIDsFromQueryParameters(o.QueryParameters)
+		ids = getIDsFromQueryParameters(o.QueryParameters, "@ids")
 	}
 
 	conn, err := f.pool.Take(context.Background())
@@ -453,7 +750,11 @@ func (f *fakeStorage) pagesItemPager(query string, pk azcosmos.PartitionKey, o *
 	}
 	defer f.pool.Put(conn)
 
-	items := [][]byte{}
+	type posItem struct {
+		pos  int
+		data []byte
+	}
+	found := []posItem{}
 	err = sqlitex.Execute(
 		conn,
 		q,
@@ -476,7 +777,7 @@ func (f *fakeStorage) pagesItemPager(query string, pk azcosmos.PartitionKey, o *
 					if _, ok := ids[id]; len(ids) > 0 && !ok {
 						return nil
 					}
-					items = append(items, b)
+					found = append(found, posItem{pos: c.Pos, data: b})
 				}
 				return nil
 			},
@@ -486,6 +787,15 @@ func (f *fakeStorage) pagesItemPager(query string, pk azcosmos.PartitionKey, o *
 		panic("some type of sqlite error: " + err.Error())
 	}
 
+	// Queries for Actions are ORDER BY c.pos ASC.
+	slices.SortStableFunc(found, func(a, b posItem) int {
+		return a.pos - b.pos
+	})
+	items := make([][]byte, 0, len(found))
+	for _, item := range found {
+		items = append(items, item.data)
+	}
+
 	return runtime.NewPager(runtime.PagingHandler[azcosmos.QueryItemsResponse]{
 		More: func(page azcosmos.QueryItemsResponse) bool {
 			return page.ContinuationToken != nil
@@ -497,7 +807,7 @@ func (f *fakeStorage) pagesItemPager(query string, pk azcosmos.PartitionKey, o *
 }
 
 func (f *fakeStorage) searchItemPager(query string, pk azcosmos.PartitionKey, o *azcosmos.QueryOptions) *runtime.Pager[azcosmos.QueryItemsResponse] {
-	const q = `SELECT id, group_id, name, descr, status, stateStart, stateEnd, submitTime, data FROM search`
+	const q = `SELECT id, group_id, caller_id, name, descr, status, stateStart, stateEnd, submitTime, data FROM search`
 
 	// id, group_id, name, descr, status, stateStart, stateEnd, submitTime
 	if o.QueryParameters == nil {
@@ -513,8 +823,10 @@ func (f *fakeStorage) searchItemPager(query string, pk azcosmos.PartitionKey, o
 		}
 	}
 	ids := map[uuid.UUID]struct{}{}
+	var callerIDs map[uuid.UUID]struct{}
 	if o != nil && len(o.QueryParameters) > 0 {
-		ids = getIDsFromQueryParameters(o.QueryParameters)
+		ids = getIDsFromQueryParameters(o.QueryParameters, "@ids")
+		callerIDs = getIDsFromQueryParameters(o.QueryParameters, "@caller_ids")
 	}
 
 	conn, err := f.pool.Take(context.Background())
@@ -531,6 +843,12 @@ func (f *fakeStorage) searchItemPager(query string, pk azcosmos.PartitionKey, o
 			ResultFunc: func(stmt *sqlite.Stmt) error {
 				b := make([]byte, stmt.GetLen("data"))
 				stmt.GetBytes("data", b)
+				if callerIDs != nil {
+					if _, ok := callerIDs[uuid.MustParse(stmt.GetText("caller_id"))]; ok {
+						items = append(items, b)
+					}
+					return nil
+				}
 				if _, ok := ids[uuid.MustParse(stmt.GetText("id"))]; ok {
 					items = append(items, b)
 				}
@@ -552,6 +870,51 @@ func (f *fakeStorage) searchItemPager(query string, pk azcosmos.PartitionKey, o
 	})
 }
 
+// locksItemPager returns a pager over the items in table, which is the locks or leases table, for the @swarm parameter.
+func (f *fakeStorage) locksItemPager(query string, pk azcosmos.PartitionKey, o *azcosmos.QueryOptions, table string) *runtime.Pager[azcosmos.QueryItemsResponse] {
+	q := fmt.Sprintf(`SELECT data FROM %s WHERE swarm = $swarm`, table)
+
+	var swarm string
+	for _, p := range o.QueryParameters {
+		if "@swarm" == p.Name {
+			swarm = p.Value.(string)
+		}
+	}
+
+	conn, err := f.pool.Take(context.Background())
+	if err != nil {
+		panic("can't get conn object")
+	}
+	defer f.pool.Put(conn)
+
+	items := [][]byte{}
+	err = sqlitex.Execute(
+		conn,
+		q,
+		&sqlitex.ExecOptions{
+			Named: map[string]any{"$swarm": swarm},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				b := make([]byte, stmt.GetLen("data"))
+				stmt.GetBytes("data", b)
+				items = append(items, b)
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		panic("some type of sqlite error: " + err.Error())
+	}
+
+	return runtime.NewPager(runtime.PagingHandler[azcosmos.QueryItemsResponse]{
+		More: func(page azcosmos.QueryItemsResponse) bool {
+			return page.ContinuationToken != nil
+		},
+		Fetcher: func(ctx context.Context, page *azcosmos.QueryItemsResponse) (azcosmos.QueryItemsResponse, error) {
+			return azcosmos.QueryItemsResponse{Items: items}, nil
+		},
+	})
+}
+
 func (f *fakeStorage) limitItemPager(query string, pk azcosmos.PartitionKey, o *azcosmos.QueryOptions) *runtime.Pager[azcosmos.QueryItemsResponse] {
 	const q = `SELECT data FROM search limit $limit`
 
@@ -703,6 +1066,34 @@ func (f *fakeStorage) patchObject(op pathOps, o stateObject) {
 				panic(err)
 			}
 			action.Attempts = attempts
+		case "/paused":
+			plan := o.(*workflow.Plan)
+			plan.Paused = op.Value.(bool)
+		case "/drained":
+			plan := o.(*workflow.Plan)
+			plan.Drained = op.Value.(bool)
+		case "/startAt":
+			plan := o.(*workflow.Plan)
+			plan.StartAt = op.Value.(time.Time)
+		case "/recurring":
+			plan := o.(*workflow.Plan)
+			plan.Recurring = op.Value.(string)
+		case "/queuedAt":
+			plan := o.(*workflow.Plan)
+			plan.QueuedAt = op.Value.(time.Time)
+		case "/traceID":
+			plan := o.(*workflow.Plan)
+			plan.TraceID = op.Value.(string)
+		case "/approval":
+			approval := *op.Value.(*workflow.Approval)
+			switch t := o.(type) {
+			case *workflow.Plan:
+				t.Approval = &approval
+			case *workflow.Block:
+				t.Approval = &approval
+			default:
+				panic(fmt.Sprintf("unsupported type(%T) for /approval", o))
+			}
 		default:
 			panic(fmt.Sprintf("unsupported op Path(%s) on set op", op.Path))
 		}
@@ -790,8 +1181,9 @@ func unsafePathOps(po *azcosmos.PatchOperations) []pathOps {
 
 type batchOp struct {
 	op           string
-	resourceBody []byte // only on Create
-	itemID       string // only on Delete
+	resourceBody []byte       // only on Create
+	itemID       string       // only on Delete
+	ifMatch      *azcore.ETag // only on Replace and Delete
 }
 
 // unsafePathOps extracts unexported `operations` from PatchOperations because the azcosmos authors are sadists.
@@ -859,10 +1251,12 @@ func unsafeBatchOps(t *azcosmos.TransactionalBatch) (string, []batchOp) {
 		case strings.Contains(name, "batchOperationReplace"):
 			rsc := getUnexportedField[[]byte](opValue, "resourceBody")
 			id := getUnexportedField[string](opValue, "id")
-			ops = append(ops, batchOp{op: "Replace", itemID: id, resourceBody: rsc})
+			ifMatch := getUnexportedField[*azcore.ETag](opValue, "ifMatch")
+			ops = append(ops, batchOp{op: "Replace", itemID: id, resourceBody: rsc, ifMatch: ifMatch})
 		case strings.Contains(name, "batchOperationDelete"):
 			field := getUnexportedField[string](opValue, "id")
-			ops = append(ops, batchOp{op: "Delete", itemID: field})
+			ifMatch := getUnexportedField[*azcore.ETag](opValue, "ifMatch")
+			ops = append(ops, batchOp{op: "Delete", itemID: field, ifMatch: ifMatch})
 		default:
 			panic(fmt.Sprintf("TransactionalBatch contains an unknown operation type: %T", opValue.Interface()))
 		}
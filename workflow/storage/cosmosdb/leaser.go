@@ -0,0 +1,192 @@
+package cosmosdb
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/element-of-surprise/coercion/internal/private"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	"github.com/go-json-experiment/json"
+	"github.com/google/uuid"
+	"github.com/gostdlib/base/retry/exponential"
+)
+
+const leaseKeyStr = "planLeases"
+
+// leaseKey is the partition that all leases are stored in.
+var leaseKey = azcosmos.NewPartitionKeyString(leaseKeyStr)
+
+const leasesQuery = `SELECT * FROM c WHERE c.swarm = @swarm`
+
+// leaser implements the storage.Leaser interface. A lease is only written if it has not changed since it
+// was read, using its ETag, so two processes cannot both claim an expired lease.
+type leaser struct {
+	mu     *sync.RWMutex
+	swarm  string
+	client lockerClient
+
+	private.Storage
+}
+
+// leaseID returns the item ID for the lease on the Plan with planID.
+func (l leaser) leaseID(planID uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(l.swarm + "/" + planID.String()))
+}
+
+// Claim implements storage.Leaser.Claim().
+func (l leaser) Claim(ctx context.Context, planID uuid.UUID, owner string, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	claim := func(ctx context.Context, r exponential.Record) error {
+		if err := l.claim(ctx, planID, owner, ttl); err != nil {
+			// If the lease changed between the read and the write, reading it again will tell us who holds it.
+			if errors.Is(err, storage.ErrLeased) || (!isRetriableError(err) && !errors.Is(err, errUncommitted)) {
+				return fmt.Errorf("%w: %w", err, exponential.ErrPermanent)
+			}
+			return err
+		}
+		return nil
+	}
+	return backoff.Retry(context.WithoutCancel(ctx), claim)
+}
+
+func (l leaser) claim(ctx context.Context, planID uuid.UUID, owner string, ttl time.Duration) error {
+	entry, err := l.readLease(ctx, planID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if entry != nil && entry.Owner != owner && now.Before(entry.Expires) {
+		return fmt.Errorf("plan(%s) is leased by %s: %w", planID, entry.Owner, storage.ErrLeased)
+	}
+
+	b, err := json.Marshal(
+		leasesEntry{
+			PartitionKey: leaseKeyStr,
+			Swarm:        l.swarm,
+			ID:           l.leaseID(planID),
+			PlanID:       planID,
+			Owner:        owner,
+			Expires:      now.Add(ttl),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lease for plan(%s): %w", planID, err)
+	}
+
+	batch := l.client.NewTransactionalBatch(leaseKey)
+	if entry == nil {
+		batch.CreateItem(b, emptyItemOptions)
+	} else {
+		batch.ReplaceItem(entry.ID, b, &azcosmos.TransactionalBatchItemOptions{IfMatchETag: &entry.ETag})
+	}
+
+	resp, err := l.client.ExecuteTransactionalBatch(ctx, batch, emptyBatchOptions)
+	if err != nil {
+		if isConflict(err) {
+			return fmt.Errorf("failed to write lease for plan(%s): %w", planID, errUncommitted)
+		}
+		return fmt.Errorf("failed to write lease through Cosmos DB API: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("failed to write lease for plan(%s): %w", planID, errUncommitted)
+	}
+	return nil
+}
+
+// readLease reads the lease on the Plan with planID. If there is no lease, this returns nil.
+func (l leaser) readLease(ctx context.Context, planID uuid.UUID) (*leasesEntry, error) {
+	resp, err := l.client.ReadItem(ctx, leaseKey, l.leaseID(planID), nil)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read lease for plan(%s): %w", planID, err)
+	}
+	entry := &leasesEntry{}
+	if err := json.Unmarshal(resp.Value, entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal lease for plan(%s): %w", planID, err)
+	}
+	return entry, nil
+}
+
+// Release implements storage.Leaser.Release().
+func (l leaser) Release(ctx context.Context, planID uuid.UUID, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	release := func(ctx context.Context, r exponential.Record) error {
+		if err := l.release(ctx, planID, owner); err != nil {
+			if !isRetriableError(err) && !errors.Is(err, errUncommitted) {
+				return fmt.Errorf("%w: %w", err, exponential.ErrPermanent)
+			}
+			return err
+		}
+		return nil
+	}
+	return backoff.Retry(context.WithoutCancel(ctx), release)
+}
+
+func (l leaser) release(ctx context.Context, planID uuid.UUID, owner string) error {
+	entry, err := l.readLease(ctx, planID)
+	if err != nil {
+		return err
+	}
+	if entry == nil || entry.Owner != owner {
+		return nil
+	}
+
+	batch := l.client.NewTransactionalBatch(leaseKey)
+	batch.DeleteItem(entry.ID, &azcosmos.TransactionalBatchItemOptions{IfMatchETag: &entry.ETag})
+
+	resp, err := l.client.ExecuteTransactionalBatch(ctx, batch, emptyBatchOptions)
+	if err != nil {
+		return fmt.Errorf("failed to delete lease through Cosmos DB API: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("failed to delete lease for plan(%s): %w", planID, errUncommitted)
+	}
+	return nil
+}
+
+// Leases implements storage.Leaser.Leases().
+func (l leaser) Leases(ctx context.Context) ([]storage.Lease, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	params := []azcosmos.QueryParameter{{Name: "@swarm", Value: l.swarm}}
+	pager := l.client.NewQueryItemsPager(leasesQuery, leaseKey, &azcosmos.QueryOptions{QueryParameters: params})
+
+	var leases []storage.Lease
+	for pager.More() {
+		res, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("problem listing leases: %w", err)
+		}
+		for _, item := range res.Items {
+			entry := leasesEntry{}
+			if err := json.Unmarshal(item, &entry); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal lease: %w", err)
+			}
+			leases = append(
+				leases,
+				storage.Lease{
+					PlanID:  entry.PlanID,
+					Owner:   entry.Owner,
+					Expires: entry.Expires,
+				},
+			)
+		}
+	}
+	sort.Slice(leases, func(i, j int) bool { return leases[i].PlanID.String() < leases[j].PlanID.String() })
+	return leases, nil
+}
@@ -0,0 +1,88 @@
+package cosmosdb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow/storage"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+)
+
+func TestLeaser(t *testing.T) {
+	ctx := context.Background()
+
+	// Two leasers on the same store act as two processes.
+	store := newFakeStorage(testReg)
+	l1 := leaser{mu: &sync.RWMutex{}, swarm: swarm, client: store}
+	l2 := leaser{mu: &sync.RWMutex{}, swarm: swarm, client: store}
+	other := leaser{mu: &sync.RWMutex{}, swarm: "other", client: store}
+
+	planID := mustUUID()
+
+	if err := l1.Claim(ctx, planID, "process1", 100*time.Millisecond); err != nil {
+		t.Fatalf("TestLeaser(process1 claim): got err == %v, want err == nil", err)
+	}
+	if err := l2.Claim(ctx, planID, "process2", time.Minute); !errors.Is(err, storage.ErrLeased) {
+		t.Fatalf("TestLeaser(process2 claim): got err == %v, want err == storage.ErrLeased", err)
+	}
+	// Renewing by the owner is not an error.
+	if err := l1.Claim(ctx, planID, "process1", 100*time.Millisecond); err != nil {
+		t.Fatalf("TestLeaser(process1 renew): got err == %v, want err == nil", err)
+	}
+	// Leases in another swarm are separate.
+	if err := other.Claim(ctx, planID, "process2", time.Minute); err != nil {
+		t.Fatalf("TestLeaser(other swarm claim): got err == %v, want err == nil", err)
+	}
+
+	leases, err := l2.Leases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 1 || leases[0].PlanID != planID || leases[0].Owner != "process1" || !leases[0].Expires.After(time.Now()) {
+		t.Fatalf("TestLeaser(Leases): got %+v, want an unexpired lease for plan(%s) held by process1", leases, planID)
+	}
+
+	// A write with an old ETag fails, so two processes cannot both take over an expired lease.
+	stale, err := l1.readLease(ctx, planID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if err := l2.Claim(ctx, planID, "process2", time.Minute); err != nil {
+		t.Fatalf("TestLeaser(process2 claim after expiry): got err == %v, want err == nil", err)
+	}
+	batch := store.NewTransactionalBatch(leaseKey)
+	batch.DeleteItem(stale.ID, &azcosmos.TransactionalBatchItemOptions{IfMatchETag: &stale.ETag})
+	if resp, err := store.ExecuteTransactionalBatch(ctx, batch, nil); err != nil || resp.Success {
+		t.Fatalf("TestLeaser(stale ETag): got Success == %v, err == %v, want Success == false", resp.Success, err)
+	}
+	if err := l1.Claim(ctx, planID, "process1", time.Minute); !errors.Is(err, storage.ErrLeased) {
+		t.Fatalf("TestLeaser(process1 renew after takeover): got err == %v, want err == storage.ErrLeased", err)
+	}
+
+	// Only the owner can release the lease.
+	if err := l1.Release(ctx, planID, "process1"); err != nil {
+		t.Fatal(err)
+	}
+	leases, err = l1.Leases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 1 || leases[0].Owner != "process2" {
+		t.Fatalf("TestLeaser(Leases after bad release): got %+v, want a lease held by process2", leases)
+	}
+	if err := l2.Release(ctx, planID, "process2"); err != nil {
+		t.Fatal(err)
+	}
+	leases, err = l1.Leases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 0 {
+		t.Fatalf("TestLeaser(Leases after release): got %+v, want no leases", leases)
+	}
+}
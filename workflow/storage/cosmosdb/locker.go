@@ -0,0 +1,230 @@
+package cosmosdb
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/element-of-surprise/coercion/internal/private"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	"github.com/go-json-experiment/json"
+	"github.com/google/uuid"
+	"github.com/gostdlib/base/retry/exponential"
+)
+
+const lockKeyStr = "planLocks"
+
+// lockKey is the partition that all locks are stored in. Keeping all locks in one partition
+// lets a set of locks be acquired atomically with a transactional batch.
+var lockKey = azcosmos.NewPartitionKeyString(lockKeyStr)
+
+const locksQuery = `SELECT * FROM c WHERE c.swarm = @swarm`
+
+type lockerClient interface {
+	ReadItem(ctx context.Context, partitionKey azcosmos.PartitionKey, itemId string, o *azcosmos.ItemOptions) (azcosmos.ItemResponse, error)
+	NewQueryItemsPager(query string, partitionKey azcosmos.PartitionKey, o *azcosmos.QueryOptions) *runtime.Pager[azcosmos.QueryItemsResponse]
+	NewTransactionalBatch(partitionKey azcosmos.PartitionKey) azcosmos.TransactionalBatch
+	ExecuteTransactionalBatch(ctx context.Context, b azcosmos.TransactionalBatch, o *azcosmos.TransactionalBatchOptions) (azcosmos.TransactionalBatchResponse, error)
+}
+
+var _ lockerClient = &azcosmos.ContainerClient{}
+
+// errUncommitted indicates a transactional batch was not committed because one of its operations failed.
+var errUncommitted = errors.New("batch was not committed")
+
+// locker implements the storage.Locker interface.
+type locker struct {
+	mu     *sync.RWMutex
+	swarm  string
+	client lockerClient
+
+	private.Storage
+}
+
+// lockID returns the item ID for the lock with name. Cosmos does not allow some characters
+// that are common in lock names, such as "/", in an ID, so the name is encoded.
+func (l locker) lockID(name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(l.swarm + "/" + name))
+}
+
+// Lock implements storage.Locker.Lock().
+func (l locker) Lock(ctx context.Context, owner, planID uuid.UUID, names []string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock := func(ctx context.Context, r exponential.Record) error {
+		if err := l.lock(ctx, owner, planID, names); err != nil {
+			if errors.Is(err, storage.ErrLocked) || !isRetriableError(err) {
+				return fmt.Errorf("%w: %w", err, exponential.ErrPermanent)
+			}
+			return err
+		}
+		return nil
+	}
+	return backoff.Retry(context.WithoutCancel(ctx), lock)
+}
+
+func (l locker) lock(ctx context.Context, owner, planID uuid.UUID, names []string) error {
+	now := time.Now().UTC()
+	batch := l.client.NewTransactionalBatch(lockKey)
+	ops := 0
+	for _, name := range names {
+		entry, err := l.readLock(ctx, name)
+		if err != nil {
+			return err
+		}
+		if entry != nil {
+			if entry.Owner == owner {
+				continue
+			}
+			return fmt.Errorf("lock(%s): %w", name, storage.ErrLocked)
+		}
+
+		b, err := json.Marshal(
+			locksEntry{
+				PartitionKey: lockKeyStr,
+				Swarm:        l.swarm,
+				ID:           l.lockID(name),
+				Name:         name,
+				Owner:        owner,
+				PlanID:       planID,
+				Acquired:     now,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to marshal lock(%s): %w", name, err)
+		}
+		batch.CreateItem(b, emptyItemOptions)
+		ops++
+	}
+	if ops == 0 {
+		return nil
+	}
+
+	resp, err := l.client.ExecuteTransactionalBatch(ctx, batch, emptyBatchOptions)
+	if err != nil {
+		if isConflict(err) {
+			return storage.ErrLocked
+		}
+		return fmt.Errorf("failed to create locks through Cosmos DB API: %w", err)
+	}
+	if !resp.Success {
+		for _, r := range resp.OperationResults {
+			// Another owner created one of the locks after we read it.
+			if r.StatusCode == http.StatusConflict {
+				return storage.ErrLocked
+			}
+		}
+		return fmt.Errorf("failed to create locks through Cosmos DB API: %w", errUncommitted)
+	}
+	return nil
+}
+
+// readLock reads the lock with name. If the lock is not held, this returns nil.
+func (l locker) readLock(ctx context.Context, name string) (*locksEntry, error) {
+	resp, err := l.client.ReadItem(ctx, lockKey, l.lockID(name), nil)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read lock(%s): %w", name, err)
+	}
+	entry := &locksEntry{}
+	if err := json.Unmarshal(resp.Value, entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal lock(%s): %w", name, err)
+	}
+	return entry, nil
+}
+
+// Unlock implements storage.Locker.Unlock().
+func (l locker) Unlock(ctx context.Context, owner uuid.UUID, names []string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	unlock := func(ctx context.Context, r exponential.Record) error {
+		if err := l.unlock(ctx, owner, names); err != nil {
+			// A lock may have changed between the read and the delete, reading them again will fix that.
+			if !isRetriableError(err) && !errors.Is(err, errUncommitted) {
+				return fmt.Errorf("%w: %w", err, exponential.ErrPermanent)
+			}
+			return err
+		}
+		return nil
+	}
+	return backoff.Retry(context.WithoutCancel(ctx), unlock)
+}
+
+func (l locker) unlock(ctx context.Context, owner uuid.UUID, names []string) error {
+	batch := l.client.NewTransactionalBatch(lockKey)
+	ops := 0
+	for _, name := range names {
+		entry, err := l.readLock(ctx, name)
+		if err != nil {
+			return err
+		}
+		if entry == nil || entry.Owner != owner {
+			continue
+		}
+		var ifMatchEtag *azcore.ETag = nil
+		if entry.ETag != "" {
+			ifMatchEtag = &entry.ETag
+		}
+		batch.DeleteItem(entry.ID, &azcosmos.TransactionalBatchItemOptions{IfMatchETag: ifMatchEtag})
+		ops++
+	}
+	if ops == 0 {
+		return nil
+	}
+
+	resp, err := l.client.ExecuteTransactionalBatch(ctx, batch, emptyBatchOptions)
+	if err != nil {
+		return fmt.Errorf("failed to delete locks through Cosmos DB API: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("failed to delete locks through Cosmos DB API: %w", errUncommitted)
+	}
+	return nil
+}
+
+// Locks implements storage.Locker.Locks().
+func (l locker) Locks(ctx context.Context) ([]storage.Lock, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	params := []azcosmos.QueryParameter{{Name: "@swarm", Value: l.swarm}}
+	pager := l.client.NewQueryItemsPager(locksQuery, lockKey, &azcosmos.QueryOptions{QueryParameters: params})
+
+	var locks []storage.Lock
+	for pager.More() {
+		res, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("problem listing locks: %w", err)
+		}
+		for _, item := range res.Items {
+			entry := locksEntry{}
+			if err := json.Unmarshal(item, &entry); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal lock: %w", err)
+			}
+			locks = append(
+				locks,
+				storage.Lock{
+					Name:     entry.Name,
+					Owner:    entry.Owner,
+					PlanID:   entry.PlanID,
+					Acquired: entry.Acquired,
+				},
+			)
+		}
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Name < locks[j].Name })
+	return locks, nil
+}
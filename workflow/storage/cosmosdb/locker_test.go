@@ -0,0 +1,75 @@
+package cosmosdb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/element-of-surprise/coercion/workflow/storage"
+)
+
+func TestLocker(t *testing.T) {
+	ctx := context.Background()
+
+	store := newFakeStorage(testReg)
+	l := locker{mu: &sync.RWMutex{}, swarm: swarm, client: store}
+	other := locker{mu: &sync.RWMutex{}, swarm: "other", client: store}
+
+	planID := mustUUID()
+	owner1 := mustUUID()
+	owner2 := mustUUID()
+
+	if err := l.Lock(ctx, owner1, planID, []string{"cluster/east-1", "host/abc"}); err != nil {
+		t.Fatalf("TestLocker(owner1 lock): got err == %v, want err == nil", err)
+	}
+	// Locking again with the same owner is not an error.
+	if err := l.Lock(ctx, owner1, planID, []string{"host/abc"}); err != nil {
+		t.Fatalf("TestLocker(owner1 relock): got err == %v, want err == nil", err)
+	}
+	// Locks in another swarm are separate.
+	if err := other.Lock(ctx, owner2, planID, []string{"host/abc"}); err != nil {
+		t.Fatalf("TestLocker(other swarm lock): got err == %v, want err == nil", err)
+	}
+
+	// owner2 cannot get host/abc, so it must not get host/def either.
+	err := l.Lock(ctx, owner2, planID, []string{"host/def", "host/abc"})
+	if !errors.Is(err, storage.ErrLocked) {
+		t.Fatalf("TestLocker(owner2 lock): got err == %v, want err == storage.ErrLocked", err)
+	}
+
+	locks, err := l.Locks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 2 || locks[0].Name != "cluster/east-1" || locks[1].Name != "host/abc" {
+		t.Fatalf("TestLocker(Locks): got %+v, want cluster/east-1 and host/abc", locks)
+	}
+	for _, lock := range locks {
+		if lock.Owner != owner1 || lock.PlanID != planID || lock.Acquired.IsZero() {
+			t.Errorf("TestLocker(Locks): lock(%s) had Owner(%s), PlanID(%s), Acquired(%v), want Owner(%s), PlanID(%s) and Acquired set", lock.Name, lock.Owner, lock.PlanID, lock.Acquired, owner1, planID)
+		}
+	}
+
+	// owner2 cannot unlock what owner1 holds.
+	if err := l.Unlock(ctx, owner2, []string{"host/abc"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Lock(ctx, owner2, planID, []string{"host/abc"}); !errors.Is(err, storage.ErrLocked) {
+		t.Fatalf("TestLocker(owner2 lock after bad unlock): got err == %v, want err == storage.ErrLocked", err)
+	}
+
+	if err := l.Unlock(ctx, owner1, []string{"cluster/east-1", "host/abc"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Lock(ctx, owner2, planID, []string{"host/def", "host/abc"}); err != nil {
+		t.Fatalf("TestLocker(owner2 lock after unlock): got err == %v, want err == nil", err)
+	}
+	locks, err = l.Locks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 2 || locks[0].Name != "host/abc" || locks[1].Name != "host/def" {
+		t.Fatalf("TestLocker(Locks after unlock): got %+v, want host/abc and host/def", locks)
+	}
+}
@@ -20,9 +20,9 @@ import (
 
 const (
 	// beginning of query to list plans with a filter
-	searchPlans = `SELECT c.id, c.groupID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm`
+	searchPlans = `SELECT c.id, c.groupID, c.callerID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm`
 	// list all plans without parameters
-	listPlans = `SELECT c.id, c.groupID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm ORDER BY c.submitTime DESC`
+	listPlans = `SELECT c.id, c.groupID, c.callerID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm ORDER BY c.submitTime DESC`
 )
 
 // readerClient provides abstraction for testing reader. This is implmented by *azcosmos.ContainerClient.
@@ -160,6 +160,10 @@ func (r reader) buildSearchQuery(filters storage.Filters) (string, []azcosmos.Qu
 		numFilters++
 		build.WriteString(" AND ARRAY_CONTAINS(@group_ids, c.groupID)")
 	}
+	if len(filters.ByCallerIDs) > 0 {
+		numFilters++
+		build.WriteString(" AND ARRAY_CONTAINS(@caller_ids, c.callerID)")
+	}
 	if len(filters.ByStatus) > 0 {
 		build.WriteString(" AND ")
 		if len(filters.ByStatus) > 1 {
@@ -198,6 +202,12 @@ func (r reader) buildSearchQuery(filters storage.Filters) (string, []azcosmos.Qu
 			Value: filters.ByGroupIDs,
 		})
 	}
+	if len(filters.ByCallerIDs) > 0 {
+		parameters = append(parameters, azcosmos.QueryParameter{
+			Name:  "@caller_ids",
+			Value: filters.ByCallerIDs,
+		})
+	}
 	return query, parameters
 }
 
@@ -260,6 +270,7 @@ func (r reader) listResultsFunc(item []byte) (storage.ListResult, error) {
 	result := storage.ListResult{
 		ID:         resp.ID,
 		GroupID:    resp.GroupID,
+		CallerID:   resp.CallerID,
 		Name:       resp.Name,
 		Descr:      resp.Descr,
 		SubmitTime: resp.SubmitTime,
@@ -268,6 +279,8 @@ func (r reader) listResultsFunc(item []byte) (storage.ListResult, error) {
 			Start:  resp.StateStart,
 			End:    resp.StateEnd,
 		},
+		Priority: resp.Priority,
+		QueuedAt: resp.QueuedAt,
 	}
 	return result, nil
 }
@@ -21,7 +21,10 @@ SELECT
 	c.plugin,
 	c.timeout,
 	c.retries,
+	c.retryPolicy,
+	c.maxElapsed,
 	c.req,
+	c.refs,
 	c.attempts,
 	c.stateStatus,
 	c.stateStart,
@@ -85,13 +88,16 @@ func (r reader) docToAction(ctx context.Context, response []byte) (*workflow.Act
 	}
 
 	a := &workflow.Action{
-		ID:      resp.ID,
-		Key:     resp.Key,
-		Name:    resp.Name,
-		Descr:   resp.Descr,
-		Plugin:  resp.Plugin,
-		Timeout: resp.Timeout,
-		Retries: resp.Retries,
+		ID:          resp.ID,
+		Key:         resp.Key,
+		Name:        resp.Name,
+		Descr:       resp.Descr,
+		Plugin:      resp.Plugin,
+		Timeout:     resp.Timeout,
+		Retries:     resp.Retries,
+		RetryPolicy: resp.RetryPolicy,
+		MaxElapsed:  resp.MaxElapsed,
+		Refs:        resp.Refs,
 		State: &workflow.State{
 			Status: resp.StateStatus,
 			Start:  resp.StateStart,
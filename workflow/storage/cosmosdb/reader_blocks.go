@@ -46,16 +46,23 @@ func (p reader) docToBlock(ctx context.Context, response *azcosmos.ItemResponse)
 		Key:           resp.Key,
 		Name:          resp.Name,
 		Descr:         resp.Descr,
+		DependsOn:     resp.DependsOn,
 		EntranceDelay: resp.EntranceDelay,
 		ExitDelay:     resp.ExitDelay,
+		Timeout:       resp.Timeout,
+		Locks:         resp.Locks,
+		Ramp:          resp.Ramp,
 		State: &workflow.State{
 			Status: resp.StateStatus,
 			Start:  resp.StateStart,
 			End:    resp.StateEnd,
 			ETag:   string(resp.ETag),
 		},
-		Concurrency:       resp.Concurrency,
-		ToleratedFailures: resp.ToleratedFailures,
+		Concurrency:             resp.Concurrency,
+		ToleratedFailures:       resp.ToleratedFailures,
+		ToleratedFailurePercent: resp.ToleratedFailurePercent,
+		ToleratedFailureSample:  resp.ToleratedFailureSample,
+		Approval:                resp.Approval,
 	}
 	b.SetPlanID(resp.PlanID)
 
@@ -80,10 +87,30 @@ func (p reader) docToBlock(ctx context.Context, response *azcosmos.ItemResponse)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't get block deferredchecks: %w", err)
 	}
+	if resp.Generator != uuid.Nil {
+		gens, err := p.fetchActionsByIDs(ctx, k, []uuid.UUID{resp.Generator})
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get block generator: %w", err)
+		}
+		if len(gens) != 1 {
+			return nil, fmt.Errorf("couldn't find block generator(%s)", resp.Generator)
+		}
+		b.Generator = gens[0]
+	}
 	b.Sequences, err = p.idsToSequences(ctx, k, resp.Sequences)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't read block sequences: %w", err)
 	}
+	b.Rollback, err = p.idsToActions(ctx, k, resp.Rollback)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read block rollback: %w", err)
+	}
+	if resp.SubPlan != uuid.Nil {
+		b.SubPlan, err = p.fetchPlan(ctx, resp.SubPlan)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read block sub-plan: %w", err)
+		}
+	}
 
 	return b, nil
 }
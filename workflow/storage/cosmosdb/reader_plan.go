@@ -28,11 +28,25 @@ func (p reader) docToPlan(ctx context.Context, response *azcosmos.ItemResponse)
 	}
 
 	plan := &workflow.Plan{
-		ID:         resp.PlanID,
-		GroupID:    resp.GroupID,
-		Name:       resp.Name,
-		Descr:      resp.Descr,
-		SubmitTime: resp.SubmitTime,
+		ID:               resp.PlanID,
+		GroupID:          resp.GroupID,
+		ParentID:         resp.ParentID,
+		CallerID:         resp.CallerID,
+		Name:             resp.Name,
+		Descr:            resp.Descr,
+		SubmitTime:       resp.SubmitTime,
+		Reason:           resp.Reason,
+		Paused:           resp.Paused,
+		Drained:          resp.Drained,
+		StartAt:          resp.StartAt,
+		Recurring:        resp.Recurring,
+		Timeout:          resp.Timeout,
+		Locks:            resp.Locks,
+		Approval:         resp.Approval,
+		BlockConcurrency: resp.BlockConcurrency,
+		Priority:         resp.Priority,
+		QueuedAt:         resp.QueuedAt,
+		TraceID:          resp.TraceID,
 		State: &workflow.State{
 			Status: resp.StateStatus,
 			Start:  resp.StateStart,
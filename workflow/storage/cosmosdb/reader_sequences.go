@@ -41,10 +41,12 @@ func (p reader) docToSequence(ctx context.Context, planID azcosmos.PartitionKey,
 	}
 
 	s := &workflow.Sequence{
-		ID:    resp.ID,
-		Key:   resp.Key,
-		Name:  resp.Name,
-		Descr: resp.Descr,
+		ID:      resp.ID,
+		Key:     resp.Key,
+		Name:    resp.Name,
+		Descr:   resp.Descr,
+		Timeout: resp.Timeout,
+		Locks:   resp.Locks,
 		State: &workflow.State{
 			Status: resp.StateStatus,
 			Start:  resp.StateStart,
@@ -57,6 +59,10 @@ func (p reader) docToSequence(ctx context.Context, planID azcosmos.PartitionKey,
 	if err != nil {
 		return nil, fmt.Errorf("couldn't read sequence actions: %w", err)
 	}
+	s.Rollback, err = p.idsToActions(ctx, planID, resp.Rollback)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read sequence rollback: %w", err)
+	}
 
 	return s, nil
 }
@@ -35,7 +35,7 @@ func TestBuildSearchQuery(t *testing.T) {
 		{
 			name:      "Success: empty filters",
 			filters:   storage.Filters{},
-			wantQuery: `SELECT c.id, c.groupID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm ORDER BY c.submitTime DESC`,
+			wantQuery: `SELECT c.id, c.groupID, c.callerID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm ORDER BY c.submitTime DESC`,
 			wantParams: []azcosmos.QueryParameter{
 				{
 					Name:  "@swarm",
@@ -50,7 +50,7 @@ func TestBuildSearchQuery(t *testing.T) {
 					id1,
 				},
 			},
-			wantQuery: `SELECT c.id, c.groupID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm AND ARRAY_CONTAINS(@ids, c.id) ORDER BY c.submitTime DESC`,
+			wantQuery: `SELECT c.id, c.groupID, c.callerID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm AND ARRAY_CONTAINS(@ids, c.id) ORDER BY c.submitTime DESC`,
 			wantParams: []azcosmos.QueryParameter{
 				{
 					Name:  "@swarm",
@@ -72,7 +72,7 @@ func TestBuildSearchQuery(t *testing.T) {
 					id2,
 				},
 			},
-			wantQuery: `SELECT c.id, c.groupID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm AND ARRAY_CONTAINS(@ids, c.id) ORDER BY c.submitTime DESC`,
+			wantQuery: `SELECT c.id, c.groupID, c.callerID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm AND ARRAY_CONTAINS(@ids, c.id) ORDER BY c.submitTime DESC`,
 			wantParams: []azcosmos.QueryParameter{
 				{
 					Name:  "@swarm",
@@ -94,7 +94,7 @@ func TestBuildSearchQuery(t *testing.T) {
 					id1,
 				},
 			},
-			wantQuery: `SELECT c.id, c.groupID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm AND ARRAY_CONTAINS(@group_ids, c.groupID) ORDER BY c.submitTime DESC`,
+			wantQuery: `SELECT c.id, c.groupID, c.callerID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm AND ARRAY_CONTAINS(@group_ids, c.groupID) ORDER BY c.submitTime DESC`,
 			wantParams: []azcosmos.QueryParameter{
 				{
 					Name:  "@swarm",
@@ -108,6 +108,27 @@ func TestBuildSearchQuery(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Success: by Caller ID",
+			filters: storage.Filters{
+				ByCallerIDs: []uuid.UUID{
+					id1,
+				},
+			},
+			wantQuery: `SELECT c.id, c.groupID, c.callerID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm AND ARRAY_CONTAINS(@caller_ids, c.callerID) ORDER BY c.submitTime DESC`,
+			wantParams: []azcosmos.QueryParameter{
+				{
+					Name:  "@swarm",
+					Value: swarm,
+				},
+				{
+					Name: "@caller_ids",
+					Value: []uuid.UUID{
+						id1,
+					},
+				},
+			},
+		},
 		{
 			name: "Success: by IDs with multiple Group IDs",
 			filters: storage.Filters{
@@ -116,7 +137,7 @@ func TestBuildSearchQuery(t *testing.T) {
 					id2,
 				},
 			},
-			wantQuery: `SELECT c.id, c.groupID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm AND ARRAY_CONTAINS(@group_ids, c.groupID) ORDER BY c.submitTime DESC`,
+			wantQuery: `SELECT c.id, c.groupID, c.callerID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm AND ARRAY_CONTAINS(@group_ids, c.groupID) ORDER BY c.submitTime DESC`,
 			wantParams: []azcosmos.QueryParameter{
 				{
 					Name:  "@swarm",
@@ -138,7 +159,7 @@ func TestBuildSearchQuery(t *testing.T) {
 					workflow.Completed,
 				},
 			},
-			wantQuery: `SELECT c.id, c.groupID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm AND c.stateStatus = @status0 ORDER BY c.submitTime DESC`,
+			wantQuery: `SELECT c.id, c.groupID, c.callerID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm AND c.stateStatus = @status0 ORDER BY c.submitTime DESC`,
 			wantParams: []azcosmos.QueryParameter{
 				{
 					Name:  "@swarm",
@@ -158,7 +179,7 @@ func TestBuildSearchQuery(t *testing.T) {
 					workflow.Failed,
 				},
 			},
-			wantQuery: `SELECT c.id, c.groupID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm AND (c.stateStatus = @status0 OR c.stateStatus = @status1) ORDER BY c.submitTime DESC`,
+			wantQuery: `SELECT c.id, c.groupID, c.callerID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm AND (c.stateStatus = @status0 OR c.stateStatus = @status1) ORDER BY c.submitTime DESC`,
 			wantParams: []azcosmos.QueryParameter{
 				{
 					Name:  "@swarm",
@@ -189,7 +210,7 @@ func TestBuildSearchQuery(t *testing.T) {
 					workflow.Failed,
 				},
 			},
-			wantQuery: `SELECT c.id, c.groupID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm AND ARRAY_CONTAINS(@ids, c.id) AND ARRAY_CONTAINS(@group_ids, c.groupID) AND (c.stateStatus = @status0 OR c.stateStatus = @status1) ORDER BY c.submitTime DESC`,
+			wantQuery: `SELECT c.id, c.groupID, c.callerID, c.name, c.descr, c.submitTime, c.stateStatus, c.stateStart, c.stateEnd FROM c WHERE c.swarm=@swarm AND ARRAY_CONTAINS(@ids, c.id) AND ARRAY_CONTAINS(@group_ids, c.groupID) AND (c.stateStatus = @status0 OR c.stateStatus = @status1) ORDER BY c.submitTime DESC`,
 			wantParams: []azcosmos.QueryParameter{
 				{
 					Name:  "@swarm",
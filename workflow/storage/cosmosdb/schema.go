@@ -6,6 +6,7 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/google/uuid"
+	"github.com/gostdlib/base/retry/exponential"
 )
 
 type plansEntry struct {
@@ -16,49 +17,72 @@ type plansEntry struct {
 	// PlanID is the unique identifier for the plan. This is a duplicate of ID. All other items have ID and PlanID.
 	// While a plan technically doesn't need both, this fits well into the model. While it can be worked around,
 	// it causes all kinds of subtle bugs. By having both it makes everything easier.
-	PlanID         uuid.UUID              `json:"planID,omitempty"`
-	GroupID        uuid.UUID              `json:"groupID,omitempty"`
-	Name           string                 `json:"name,omitempty"`
-	Descr          string                 `json:"descr,omitempty"`
-	Meta           []byte                 `json:"meta,omitempty"`
-	BypassChecks   uuid.UUID              `json:"bypassChecks,omitempty"`
-	PreChecks      uuid.UUID              `json:"preChecks,omitempty"`
-	PostChecks     uuid.UUID              `json:"postChecks,omitempty"`
-	ContChecks     uuid.UUID              `json:"contChecks,omitempty"`
-	DeferredChecks uuid.UUID              `json:"deferredChecks,omitempty"`
-	Blocks         []uuid.UUID            `json:"blocks,omitempty"`
-	StateStatus    workflow.Status        `json:"stateStatus,omitempty"`
-	StateStart     time.Time              `json:"stateStart,omitempty"`
-	StateEnd       time.Time              `json:"stateEnd,omitempty"`
-	SubmitTime     time.Time              `json:"submitTime,omitempty"`
-	Reason         workflow.FailureReason `json:"reason,omitempty"`
+	PlanID           uuid.UUID              `json:"planID,omitempty"`
+	GroupID          uuid.UUID              `json:"groupID,omitempty"`
+	ParentID         uuid.UUID              `json:"parentID,omitempty"`
+	CallerID         uuid.UUID              `json:"callerID,omitempty"`
+	Name             string                 `json:"name,omitempty"`
+	Descr            string                 `json:"descr,omitempty"`
+	Meta             []byte                 `json:"meta,omitempty"`
+	BypassChecks     uuid.UUID              `json:"bypassChecks,omitempty"`
+	PreChecks        uuid.UUID              `json:"preChecks,omitempty"`
+	PostChecks       uuid.UUID              `json:"postChecks,omitempty"`
+	ContChecks       uuid.UUID              `json:"contChecks,omitempty"`
+	DeferredChecks   uuid.UUID              `json:"deferredChecks,omitempty"`
+	Blocks           []uuid.UUID            `json:"blocks,omitempty"`
+	StateStatus      workflow.Status        `json:"stateStatus,omitempty"`
+	StateStart       time.Time              `json:"stateStart,omitempty"`
+	StateEnd         time.Time              `json:"stateEnd,omitempty"`
+	SubmitTime       time.Time              `json:"submitTime,omitempty"`
+	Reason           workflow.FailureReason `json:"reason,omitempty"`
+	Paused           bool                   `json:"paused,omitempty"`
+	Drained          bool                   `json:"drained,omitempty"`
+	StartAt          time.Time              `json:"startAt,omitempty"`
+	Recurring        string                 `json:"recurring,omitempty"`
+	Timeout          time.Duration          `json:"timeout,omitempty"`
+	Locks            *workflow.Locks        `json:"locks,omitempty"`
+	Approval         *workflow.Approval     `json:"approval,omitempty"`
+	BlockConcurrency int                    `json:"blockConcurrency,omitempty"`
+	Priority         int                    `json:"priority,omitempty"`
+	QueuedAt         time.Time              `json:"queuedAt,omitempty"`
+	TraceID          string                 `json:"traceID,omitempty"`
 
 	ETag azcore.ETag `json:"_etag,omitempty"`
 }
 
 type blocksEntry struct {
-	PartitionKey      string              `json:"partitionKey"`
-	Swarm             string              `json:"swarm"`
-	Type              workflow.ObjectType `json:"type,omitempty"`
-	ID                uuid.UUID           `json:"id,omitempty"`
-	Key               uuid.UUID           `json:"key,omitempty"`
-	PlanID            uuid.UUID           `json:"planID,omitempty"`
-	Name              string              `json:"name,omitempty"`
-	Descr             string              `json:"descr,omitempty"`
-	Pos               int                 `json:"pos,omitempty"`
-	EntranceDelay     time.Duration       `json:"entranceDelay,omitempty"`
-	ExitDelay         time.Duration       `json:"exitDelay,omitempty"`
-	BypassChecks      uuid.UUID           `json:"bypassChecks,omitempty"`
-	PreChecks         uuid.UUID           `json:"preChecks,omitempty"`
-	PostChecks        uuid.UUID           `json:"postChecks,omitempty"`
-	ContChecks        uuid.UUID           `json:"contChecks,omitempty"`
-	DeferredChecks    uuid.UUID           `json:"deferredChecks,omitempty"`
-	Sequences         []uuid.UUID         `json:"sequences,omitempty"`
-	Concurrency       int                 `json:"concurrency,omitempty"`
-	ToleratedFailures int                 `json:"toleratedFailures,omitempty"`
-	StateStatus       workflow.Status     `json:"stateStatus,omitempty"`
-	StateStart        time.Time           `json:"stateStart,omitempty"`
-	StateEnd          time.Time           `json:"stateEnd,omitempty"`
+	PartitionKey            string              `json:"partitionKey"`
+	Swarm                   string              `json:"swarm"`
+	Type                    workflow.ObjectType `json:"type,omitempty"`
+	ID                      uuid.UUID           `json:"id,omitempty"`
+	Key                     uuid.UUID           `json:"key,omitempty"`
+	PlanID                  uuid.UUID           `json:"planID,omitempty"`
+	Name                    string              `json:"name,omitempty"`
+	Descr                   string              `json:"descr,omitempty"`
+	DependsOn               []uuid.UUID         `json:"dependsOn,omitempty"`
+	Pos                     int                 `json:"pos,omitempty"`
+	EntranceDelay           time.Duration       `json:"entranceDelay,omitempty"`
+	ExitDelay               time.Duration       `json:"exitDelay,omitempty"`
+	Timeout                 time.Duration       `json:"timeout,omitempty"`
+	Locks                   *workflow.Locks     `json:"locks,omitempty"`
+	Ramp                    *workflow.Ramp      `json:"ramp,omitempty"`
+	BypassChecks            uuid.UUID           `json:"bypassChecks,omitempty"`
+	PreChecks               uuid.UUID           `json:"preChecks,omitempty"`
+	PostChecks              uuid.UUID           `json:"postChecks,omitempty"`
+	ContChecks              uuid.UUID           `json:"contChecks,omitempty"`
+	DeferredChecks          uuid.UUID           `json:"deferredChecks,omitempty"`
+	Sequences               []uuid.UUID         `json:"sequences,omitempty"`
+	Generator               uuid.UUID           `json:"generator,omitempty"`
+	Rollback                []uuid.UUID         `json:"rollback,omitempty"`
+	SubPlan                 uuid.UUID           `json:"subPlan,omitempty"`
+	Concurrency             int                 `json:"concurrency,omitempty"`
+	ToleratedFailures       int                 `json:"toleratedFailures,omitempty"`
+	ToleratedFailurePercent float64             `json:"toleratedFailurePercent,omitempty"`
+	ToleratedFailureSample  int                 `json:"toleratedFailureSample,omitempty"`
+	Approval                *workflow.Approval  `json:"approval,omitempty"`
+	StateStatus             workflow.Status     `json:"stateStatus,omitempty"`
+	StateStart              time.Time           `json:"stateStart,omitempty"`
+	StateEnd                time.Time           `json:"stateEnd,omitempty"`
 
 	ETag azcore.ETag `json:"_etag,omitempty"`
 }
@@ -90,6 +114,9 @@ type sequencesEntry struct {
 	Descr        string              `json:"descr,omitempty"`
 	Pos          int                 `json:"pos,omitempty"`
 	Actions      []uuid.UUID         `json:"actions,omitempty"`
+	Rollback     []uuid.UUID         `json:"rollback,omitempty"`
+	Timeout      time.Duration       `json:"timeout,omitempty"`
+	Locks        *workflow.Locks     `json:"locks,omitempty"`
 	StateStatus  workflow.Status     `json:"stateStatus,omitempty"`
 	StateStart   time.Time           `json:"stateStart,omitempty"`
 	StateEnd     time.Time           `json:"stateEnd,omitempty"`
@@ -110,7 +137,10 @@ type actionsEntry struct {
 	Plugin       string              `json:"plugin,omitempty"`
 	Timeout      time.Duration       `json:"timeout,omitempty"`
 	Retries      int                 `json:"retries,omitempty"`
+	RetryPolicy  *exponential.Policy `json:"retryPolicy,omitempty"`
+	MaxElapsed   time.Duration       `json:"maxElapsed,omitempty"`
 	Req          []byte              `json:"req,omitempty"`
+	Refs         []workflow.Ref      `json:"refs,omitempty"`
 	Attempts     []byte              `json:"attempts,omitempty"`
 	StateStatus  workflow.Status     `json:"stateStatus,omitempty"`
 	StateStart   time.Time           `json:"stateStart,omitempty"`
@@ -130,8 +160,36 @@ type searchEntry struct {
 	Descr        string          `json:"descr,omitempty"`
 	ID           uuid.UUID       `json:"id,omitempty"`
 	GroupID      uuid.UUID       `json:"groupID,omitempty"`
+	CallerID     uuid.UUID       `json:"callerID,omitempty"`
 	SubmitTime   time.Time       `json:"submitTime,omitempty"`
 	StateStatus  workflow.Status `json:"stateStatus,omitempty"`
 	StateStart   time.Time       `json:"stateStart,omitempty"`
 	StateEnd     time.Time       `json:"stateEnd,omitempty"`
+	Priority     int             `json:"priority,omitempty"`
+	QueuedAt     time.Time       `json:"queuedAt,omitempty"`
+}
+
+// locksEntry is a lock held by an object. These are stored in the lockKey partition.
+type locksEntry struct {
+	PartitionKey string    `json:"partitionKey"`
+	Swarm        string    `json:"swarm"`
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Owner        uuid.UUID `json:"owner"`
+	PlanID       uuid.UUID `json:"planID"`
+	Acquired     time.Time `json:"acquired"`
+
+	ETag azcore.ETag `json:"_etag,omitempty"`
+}
+
+// leasesEntry is a lease on a Plan held by a process. These are stored in the leaseKey partition.
+type leasesEntry struct {
+	PartitionKey string    `json:"partitionKey"`
+	Swarm        string    `json:"swarm"`
+	ID           string    `json:"id"`
+	PlanID       uuid.UUID `json:"planID"`
+	Owner        string    `json:"owner"`
+	Expires      time.Time `json:"expires"`
+
+	ETag azcore.ETag `json:"_etag,omitempty"`
 }
@@ -2,11 +2,13 @@ package cosmosdb
 
 import (
 	"context"
+	"log/slog"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
 	"github.com/go-json-experiment/json"
 	"github.com/google/uuid"
+	"github.com/gostdlib/base/retry/exponential"
 	"github.com/kylelemons/godebug/pretty"
 
 	pluglib "github.com/element-of-surprise/coercion/plugins"
@@ -63,6 +65,13 @@ func NewTestPlan() *workflow.Plan {
 		Descr:  "action",
 		Plugin: plugins.HelloPluginName,
 		Req:    plugins.HelloReq{Say: "hello"},
+		Refs:   []workflow.Ref{{Key: mustUUID(), Path: "Said", Field: "Say"}},
+		RetryPolicy: &exponential.Policy{
+			InitialInterval: time.Second,
+			Multiplier:      2,
+			MaxInterval:     time.Minute,
+		},
+		MaxElapsed: 10 * time.Minute,
 		Attempts: []*workflow.Attempt{
 			{
 				Err:   &pluglib.Error{Message: "internal error"},
@@ -70,9 +79,14 @@ func NewTestPlan() *workflow.Plan {
 				End:   time.Now().UTC(),
 			},
 			{
-				Resp:  plugins.HelloResp{Said: "hello"},
-				Start: time.Now().Add(-1 * time.Second).UTC(),
-				End:   time.Now().UTC(),
+				Resp:     plugins.HelloResp{Said: "hello"},
+				Start:    time.Now().Add(-1 * time.Second).UTC(),
+				End:      time.Now().UTC(),
+				Progress: &workflow.Progress{Percent: 50, Msg: "saying hello", Time: time.Now().UTC()},
+				Logs: []workflow.LogRecord{
+					{Time: time.Now().UTC(), Level: slog.LevelWarn, Msg: "said hello", Attrs: []workflow.LogAttr{{Key: "to", Value: "world"}}},
+				},
+				LogsDropped: 1,
 			},
 		},
 	}
@@ -102,8 +116,19 @@ func NewTestPlan() *workflow.Plan {
 		Descr:             "block",
 		EntranceDelay:     1 * time.Second,
 		ExitDelay:         1 * time.Second,
+		Timeout:           1 * time.Minute,
 		ToleratedFailures: 1,
 		Concurrency:       1,
+		DependsOn:         []uuid.UUID{mustUUID()},
+		Locks:             &workflow.Locks{Names: []string{"host/abc"}, Policy: workflow.LPFail},
+		Approval: &workflow.Approval{
+			Timeout:   1 * time.Hour,
+			Status:    workflow.ASApproved,
+			Approver:  "approver",
+			Comment:   "comment",
+			Requested: time.Now().Add(-1 * time.Minute).UTC(),
+			Decided:   time.Now().UTC(),
+		},
 	})
 
 	build.AddChecks(builder.PreChecks, &workflow.Checks{})
@@ -126,7 +151,7 @@ func NewTestPlan() *workflow.Plan {
 	build.AddAction(checkAction5)
 	build.Up()
 
-	build.AddSequence(&workflow.Sequence{Name: "sequence", Descr: "sequence"})
+	build.AddSequence(&workflow.Sequence{Name: "sequence", Descr: "sequence", Timeout: 1 * time.Minute, Locks: &workflow.Locks{Names: []string{"host/def"}}})
 	build.AddAction(seqAction1)
 	build.Up()
 
@@ -136,6 +161,26 @@ func NewTestPlan() *workflow.Plan {
 	}
 
 	plan.SubmitTime = time.Now().UTC()
+	plan.Approval = &workflow.Approval{Status: workflow.ASWaiting, Requested: time.Now().UTC()}
+	plan.ParentID = mustUUID()
+	plan.StartAt = time.Now().UTC()
+	plan.Recurring = "@daily"
+	plan.Priority = 3
+	plan.Drained = true
+	plan.QueuedAt = time.Now().UTC()
+	plan.TraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	plan.Timeout = 1 * time.Hour
+	plan.BlockConcurrency = 2
+	plan.Locks = &workflow.Locks{Names: []string{"cluster/east-1"}}
+	plan.Blocks[0].ToleratedFailurePercent = 25.5
+	plan.Blocks[0].ToleratedFailureSample = 4
+	plan.Blocks[0].Ramp = &workflow.Ramp{Steps: []int{1, 2}, Successes: 2}
+	plan.Blocks[0].Generator = &workflow.Action{Name: "generator", Descr: "generator", Plugin: plugins.HelloPluginName, Req: plugins.HelloReq{Say: "hello"}}
+	plan.Blocks[0].Rollback = []*workflow.Action{{Name: "block rollback", Descr: "block rollback", Plugin: plugins.HelloPluginName, Req: plugins.HelloReq{Say: "undo"}}}
+	plan.Blocks[0].Sequences[0].Rollback = []*workflow.Action{
+		{Name: "seq rollback 0", Descr: "seq rollback 0", Plugin: plugins.HelloPluginName, Req: plugins.HelloReq{Say: "undo"}},
+		{Name: "seq rollback 1", Descr: "seq rollback 1", Plugin: plugins.HelloPluginName, Req: plugins.HelloReq{Say: "undo"}},
+	}
 	for item := range walk.Plan(context.Background(), plan) {
 		setter := item.Value.(setters)
 		setter.SetID(mustUUID())
@@ -162,9 +207,9 @@ func mustUUID() uuid.UUID {
 	return id
 }
 
-func getIDsFromQueryParameters(params []azcosmos.QueryParameter) map[uuid.UUID]struct{} {
+func getIDsFromQueryParameters(params []azcosmos.QueryParameter, name string) map[uuid.UUID]struct{} {
 	for _, param := range params {
-		if param.Name != "@ids" {
+		if param.Name != name {
 			continue
 		}
 		ids := map[uuid.UUID]struct{}{}
@@ -183,6 +228,7 @@ type commonFields struct {
 	Name        string              `json:"name"`
 	Descr       string              `json:"descr"`
 	Type        workflow.ObjectType `json:"type"`
+	Pos         int                 `json:"pos"` // not set on a Type plan
 	StateStatus workflow.Status     `json:"stateStatus"`
 	SubmitTime  time.Time           `json:"submitTime"` // not set except on Type plan
 	StateStart  time.Time           `json:"stateStart"`
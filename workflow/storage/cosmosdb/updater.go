@@ -33,8 +33,8 @@ type updater struct {
 	private.Storage
 }
 
-func newUpdater(mu *sync.RWMutex, client planPatcher, defaultIOpts *azcosmos.ItemOptions) updater {
-	uo := updater{}
+func newUpdater(mu *sync.RWMutex, client blockClient, reader reader, defaultIOpts *azcosmos.ItemOptions) updater {
+	uo := updater{reader: reader}
 
 	uo.planUpdater = planUpdater{
 		mu:           mu,
@@ -49,6 +49,7 @@ func newUpdater(mu *sync.RWMutex, client planPatcher, defaultIOpts *azcosmos.Ite
 	uo.blockUpdater = blockUpdater{
 		mu:           mu,
 		client:       client,
+		reader:       reader,
 		defaultIOpts: defaultIOpts,
 	}
 	uo.sequenceUpdater = sequenceUpdater{
@@ -127,10 +128,13 @@ func replaceSearch(ctx context.Context, client creatorClient, plan *workflow.Pla
 		Descr:        plan.Descr,
 		ID:           plan.ID,
 		GroupID:      plan.GroupID,
+		CallerID:     plan.CallerID,
 		SubmitTime:   plan.SubmitTime,
 		StateStatus:  plan.State.Status,
 		StateStart:   plan.State.Start,
 		StateEnd:     plan.State.End,
+		Priority:     plan.Priority,
+		QueuedAt:     plan.QueuedAt,
 	}
 	b, err = json.Marshal(se)
 	if err != nil {
@@ -2,6 +2,8 @@ package cosmosdb
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"sync"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
@@ -9,14 +11,24 @@ import (
 	"github.com/element-of-surprise/coercion/internal/private"
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/go-json-experiment/json"
+	"github.com/gostdlib/base/retry/exponential"
 )
 
 var _ storage.BlockUpdater = blockUpdater{}
 
+// blockClient provides the methods the blockUpdater uses. Implemented by azcosmos.ContainerClient.
+type blockClient interface {
+	creatorClient
+	patchItemer
+	ReadItem(ctx context.Context, partitionKey azcosmos.PartitionKey, itemId string, o *azcosmos.ItemOptions) (azcosmos.ItemResponse, error)
+}
+
 // blockUpdater implements the storage.blockUpdater interface.
 type blockUpdater struct {
 	mu     *sync.RWMutex
-	client patchItemer
+	client blockClient
+	reader reader
 
 	defaultIOpts *azcosmos.ItemOptions
 
@@ -32,6 +44,10 @@ func (u blockUpdater) UpdateBlock(ctx context.Context, block *workflow.Block) er
 	patch.AppendReplace("/stateStatus", block.State.Status)
 	patch.AppendReplace("/stateStart", block.State.Start)
 	patch.AppendReplace("/stateEnd", block.State.End)
+	// Approval is omitted from the entry when empty, so it must be set instead of replaced.
+	if block.Approval != nil {
+		patch.AppendSet("/approval", block.Approval)
+	}
 
 	itemOpt := itemOptions(u.defaultIOpts)
 	var ifMatchEtag *azcore.ETag = nil
@@ -51,3 +67,106 @@ func (u blockUpdater) UpdateBlock(ctx context.Context, block *workflow.Block) er
 
 	return nil
 }
+
+// AddSequences implements storage.BlockUpdater.AddSequences().
+func (u blockUpdater) AddSequences(ctx context.Context, block *workflow.Block, seqs []*workflow.Sequence) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if block.Generator == nil {
+		return fmt.Errorf("block(%s) has no generator", block.ID)
+	}
+	start := len(block.Sequences) - len(seqs)
+	if start < 0 {
+		return fmt.Errorf("block(%s) has fewer sequences than were added", block.ID)
+	}
+
+	k := key(block.GetPlanID())
+
+	res, err := u.client.ReadItem(ctx, k, block.ID.String(), u.defaultIOpts)
+	if err != nil {
+		return fmt.Errorf("couldn't fetch block by id: %w", err)
+	}
+	var entry blocksEntry
+	if err := json.Unmarshal(res.Value, &entry); err != nil {
+		return fmt.Errorf("couldn't unmarshal block: %w", err)
+	}
+	entry.Sequences, err = objsToIDs(block.Sequences)
+	if err != nil {
+		return fmt.Errorf("objsToIDs(sequences): %w", err)
+	}
+	entry.ETag = ""
+
+	iCtx := &itemsContext{swarm: entry.Swarm, planID: block.GetPlanID(), m: map[string][]byte{}}
+	for i, seq := range seqs {
+		if err := seqToItems(iCtx, start+i, seq); err != nil {
+			return fmt.Errorf("(commitSequence: %w", err)
+		}
+	}
+	blockItem, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal block: %w", err)
+	}
+	gen, err := actionToEntry(iCtx, 0, block.Generator)
+	if err != nil {
+		return err
+	}
+	genItem, err := json.Marshal(gen)
+	if err != nil {
+		return fmt.Errorf("failed to marshal generator: %w", err)
+	}
+
+	batch := u.client.NewTransactionalBatch(k)
+	for _, item := range iCtx.items {
+		batch.CreateItem(item, emptyItemOptions)
+	}
+	batch.ReplaceItem(block.ID.String(), blockItem, ifMatch(block.State.ETag))
+	batch.ReplaceItem(block.Generator.ID.String(), genItem, ifMatch(block.Generator.State.ETag))
+
+	write := func(ctx context.Context, r exponential.Record) error {
+		results, err := u.client.ExecuteTransactionalBatch(ctx, batch, emptyBatchOptions)
+		if err != nil {
+			if !isRetriableError(err) {
+				return fmt.Errorf("%w: %w", err, exponential.ErrPermanent)
+			}
+			return err
+		}
+		for i, result := range results.OperationResults {
+			if result.StatusCode != http.StatusCreated && result.StatusCode != http.StatusOK {
+				return fmt.Errorf("item(%d) has status code %d: %w", i, result.StatusCode, exponential.ErrPermanent)
+			}
+		}
+		return nil
+	}
+	if err := backoff.Retry(context.WithoutCancel(ctx), write); err != nil {
+		return fmt.Errorf("failed to add sequences: %w", err)
+	}
+
+	// The batch response does not contain the ETag for each item, so the block is re-read.
+	stored, err := u.reader.fetchBlockByID(ctx, k, block.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch block: %w", err)
+	}
+	if len(stored.Sequences) != len(block.Sequences) || stored.Generator == nil {
+		return fmt.Errorf("block(%s) read after adding sequences does not match", block.ID)
+	}
+	block.State.ETag = stored.State.ETag
+	block.Generator.State.ETag = stored.Generator.State.ETag
+	for i, seq := range seqs {
+		s := stored.Sequences[start+i]
+		seq.State.ETag = s.State.ETag
+		for x, a := range seq.Actions {
+			a.State.ETag = s.Actions[x].State.ETag
+		}
+	}
+	return nil
+}
+
+// ifMatch returns batch item options that only write the item if it has etag. If etag is empty,
+// the item is always written.
+func ifMatch(etag string) *azcosmos.TransactionalBatchItemOptions {
+	if etag == "" {
+		return emptyItemOptions
+	}
+	return &azcosmos.TransactionalBatchItemOptions{IfMatchETag: (*azcore.ETag)(&etag)}
+}
@@ -34,6 +34,16 @@ func (u planUpdater) UpdatePlan(ctx context.Context, p *workflow.Plan) error {
 
 	patch := azcosmos.PatchOperations{}
 	patch.AppendReplace("/reason", p.Reason)
+	// These fields are omitted from the entry when empty, so they must be set instead of replaced.
+	patch.AppendSet("/paused", p.Paused)
+	patch.AppendSet("/drained", p.Drained)
+	patch.AppendSet("/startAt", p.StartAt)
+	patch.AppendSet("/recurring", p.Recurring)
+	patch.AppendSet("/queuedAt", p.QueuedAt)
+	patch.AppendSet("/traceID", p.TraceID)
+	if p.Approval != nil {
+		patch.AppendSet("/approval", p.Approval)
+	}
 	patch.AppendReplace("/stateStatus", p.State.Status)
 	patch.AppendReplace("/stateStart", p.State.Start)
 	patch.AppendReplace("/stateEnd", p.State.End)
@@ -18,6 +18,8 @@ const insertPlan = `
 	INSERT INTO plans (
 		id,
 		group_id,
+		parent_id,
+		caller_id,
 		name,
 		descr,
 		meta,
@@ -31,9 +33,20 @@ const insertPlan = `
 		state_start,
 		state_end,
 		submit_time,
-		reason
-	) VALUES ($id, $group_id, $name, $descr, $meta, $bypasschecks, $prechecks, $postchecks, $contchecks, $deferredchecks,
-	$blocks, $state_status, $state_start, $state_end, $submit_time, $reason)`
+		reason,
+		paused,
+		drained,
+		start_at,
+		recurring,
+		timeout,
+		locks,
+		approval,
+		block_concurrency,
+		priority,
+		queued_at,
+		trace_id
+	) VALUES ($id, $group_id, $parent_id, $caller_id, $name, $descr, $meta, $bypasschecks, $prechecks, $postchecks, $contchecks, $deferredchecks,
+	$blocks, $state_status, $state_start, $state_end, $submit_time, $reason, $paused, $drained, $start_at, $recurring, $timeout, $locks, $approval, $block_concurrency, $priority, $queued_at, $trace_id)`
 
 var zeroTime = time.Unix(0, 0)
 
@@ -49,6 +62,12 @@ func commitPlan(ctx context.Context, conn *sqlite.Conn, p *workflow.Plan, captur
 	stmt.Query(insertPlan)
 	stmt.SetText("$id", p.ID.String())
 	stmt.SetText("$group_id", p.GroupID.String())
+	if p.ParentID != uuid.Nil {
+		stmt.SetText("$parent_id", p.ParentID.String())
+	}
+	if p.CallerID != uuid.Nil {
+		stmt.SetText("$caller_id", p.CallerID.String())
+	}
 	stmt.SetText("$name", p.Name)
 	stmt.SetText("$descr", p.Descr)
 	stmt.SetBytes("$meta", p.Meta)
@@ -81,6 +100,25 @@ func commitPlan(ctx context.Context, conn *sqlite.Conn, p *workflow.Plan, captur
 		stmt.SetInt64("$submit_time", p.SubmitTime.UnixNano())
 	}
 	stmt.SetInt64("$reason", int64(p.Reason))
+	stmt.SetBool("$paused", p.Paused)
+	stmt.SetBool("$drained", p.Drained)
+	stmt.SetInt64("$start_at", p.StartAt.UnixNano())
+	stmt.SetText("$recurring", p.Recurring)
+	stmt.SetInt64("$timeout", int64(p.Timeout))
+	stmt.SetInt64("$block_concurrency", int64(p.BlockConcurrency))
+	stmt.SetInt64("$priority", int64(p.Priority))
+	stmt.SetInt64("$queued_at", p.QueuedAt.UnixNano())
+	stmt.SetText("$trace_id", p.TraceID)
+	locks, err := encodeLocks(p.Locks)
+	if err != nil {
+		return fmt.Errorf("planToSQL(encodeLocks): %w", err)
+	}
+	stmt.SetBytes("$locks", locks)
+	approval, err := encodeApproval(p.Approval)
+	if err != nil {
+		return fmt.Errorf("planToSQL(encodeApproval): %w", err)
+	}
+	stmt.SetBytes("$approval", approval)
 
 	sStmt, err := stmt.Prepare(conn)
 	if err != nil {
@@ -180,19 +218,29 @@ const insertBlock = `
 		pos,
 		entrancedelay,
 		exitdelay,
+		timeout,
 		bypasschecks,
 		prechecks,
 		postchecks,
 		contchecks,
 		deferredchecks,
 		sequences,
+		generator,
+		rollback,
+		subplan,
 		concurrency,
 		toleratedfailures,
+		toleratedfailurepercent,
+		toleratedfailuresample,
 		state_status,
 		state_start,
-		state_end
-	) VALUES ($id, $key, $plan_id, $name, $descr, $pos, $entrancedelay, $exitdelay, $bypasschecks, $prechecks, $postchecks, $contchecks, $deferredchecks,
-	$sequences, $concurrency, $toleratedfailures,$state_status, $state_start, $state_end)`
+		state_end,
+		locks,
+		approval,
+		depends_on,
+		ramp
+	) VALUES ($id, $key, $plan_id, $name, $descr, $pos, $entrancedelay, $exitdelay, $timeout, $bypasschecks, $prechecks, $postchecks, $contchecks, $deferredchecks,
+	$sequences, $generator, $rollback, $subplan, $concurrency, $toleratedfailures, $toleratedfailurepercent, $toleratedfailuresample, $state_status, $state_start, $state_end, $locks, $approval, $depends_on, $ramp)`
 
 func commitBlock(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, pos int, block *workflow.Block, capture *CaptureStmts) error {
 	stmt := Stmt{}
@@ -208,6 +256,22 @@ func commitBlock(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, pos i
 	if err != nil {
 		return fmt.Errorf("idsToJSON(sequences): %w", err)
 	}
+	rollback, err := rollbackToJSON(block.Rollback)
+	if err != nil {
+		return fmt.Errorf("rollbackToJSON: %w", err)
+	}
+	approval, err := encodeApproval(block.Approval)
+	if err != nil {
+		return fmt.Errorf("encodeApproval: %w", err)
+	}
+	locks, err := encodeLocks(block.Locks)
+	if err != nil {
+		return fmt.Errorf("encodeLocks: %w", err)
+	}
+	ramp, err := encodeRamp(block.Ramp)
+	if err != nil {
+		return fmt.Errorf("encodeRamp: %w", err)
+	}
 
 	stmt.SetText("$id", block.ID.String())
 	stmt.SetText("$key", block.Key.String())
@@ -217,6 +281,7 @@ func commitBlock(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, pos i
 	stmt.SetInt64("$pos", int64(pos))
 	stmt.SetInt64("$entrancedelay", int64(block.EntranceDelay))
 	stmt.SetInt64("$exitdelay", int64(block.ExitDelay))
+	stmt.SetInt64("$timeout", int64(block.Timeout))
 	if block.BypassChecks != nil {
 		stmt.SetText("$bypasschecks", block.BypassChecks.ID.String())
 	}
@@ -233,11 +298,34 @@ func commitBlock(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, pos i
 		stmt.SetText("$deferredchecks", block.DeferredChecks.ID.String())
 	}
 	stmt.SetBytes("$sequences", sequences)
+	if block.Generator != nil {
+		stmt.SetText("$generator", block.Generator.ID.String())
+	}
+	if rollback != nil {
+		stmt.SetBytes("$rollback", rollback)
+	}
+	if block.SubPlan != nil {
+		stmt.SetText("$subplan", block.SubPlan.ID.String())
+	}
 	stmt.SetInt64("$concurrency", int64(block.Concurrency))
 	stmt.SetInt64("$toleratedfailures", int64(block.ToleratedFailures))
+	stmt.SetFloat("$toleratedfailurepercent", block.ToleratedFailurePercent)
+	stmt.SetInt64("$toleratedfailuresample", int64(block.ToleratedFailureSample))
 	stmt.SetInt64("$state_status", int64(block.State.Status))
 	stmt.SetInt64("$state_start", block.State.Start.UnixNano())
 	stmt.SetInt64("$state_end", block.State.End.UnixNano())
+	stmt.SetBytes("$approval", approval)
+	stmt.SetBytes("$locks", locks)
+	if len(block.DependsOn) > 0 {
+		dependsOn, err := json.Marshal(block.DependsOn)
+		if err != nil {
+			return fmt.Errorf("json.Marshal(dependsOn): %w", err)
+		}
+		stmt.SetBytes("$depends_on", dependsOn)
+	}
+	if ramp != nil {
+		stmt.SetBytes("$ramp", ramp)
+	}
 
 	sStmt, err := stmt.Prepare(conn)
 
@@ -247,11 +335,22 @@ func commitBlock(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, pos i
 	}
 	capture.Capture(stmt)
 
+	if block.Generator != nil {
+		if err := commitAction(ctx, conn, planID, 0, block.Generator, capture); err != nil {
+			return fmt.Errorf("commitBlock(commitAction): %w", err)
+		}
+	}
+
 	for i, seq := range block.Sequences {
 		if err := commitSequence(ctx, conn, planID, i, seq, capture); err != nil {
 			return fmt.Errorf("(commitSequence: %w", err)
 		}
 	}
+	for i, a := range block.Rollback {
+		if err := commitAction(ctx, conn, planID, i, a, capture); err != nil {
+			return fmt.Errorf("commitBlock(commitAction): %w", err)
+		}
+	}
 	return nil
 }
 
@@ -264,10 +363,13 @@ const insertSequence = `
 		descr,
 		pos,
 		actions,
+		rollback,
+		timeout,
+		locks,
 		state_status,
 		state_start,
 		state_end
-	) VALUES ($id, $key, $plan_id, $name, $descr, $pos, $actions, $state_status, $state_start, $state_end)`
+	) VALUES ($id, $key, $plan_id, $name, $descr, $pos, $actions, $rollback, $timeout, $locks, $state_status, $state_start, $state_end)`
 
 func commitSequence(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, pos int, seq *workflow.Sequence, capture *CaptureStmts) error {
 	stmt := Stmt{}
@@ -277,6 +379,14 @@ func commitSequence(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, po
 	if err != nil {
 		return fmt.Errorf("idsToJSON(actions): %w", err)
 	}
+	rollback, err := rollbackToJSON(seq.Rollback)
+	if err != nil {
+		return fmt.Errorf("rollbackToJSON: %w", err)
+	}
+	locks, err := encodeLocks(seq.Locks)
+	if err != nil {
+		return fmt.Errorf("encodeLocks: %w", err)
+	}
 
 	stmt.SetText("$id", seq.ID.String())
 	stmt.SetText("$key", seq.Key.String())
@@ -285,6 +395,11 @@ func commitSequence(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, po
 	stmt.SetText("$descr", seq.Descr)
 	stmt.SetInt64("$pos", int64(pos))
 	stmt.SetBytes("$actions", actions)
+	if rollback != nil {
+		stmt.SetBytes("$rollback", rollback)
+	}
+	stmt.SetInt64("$timeout", int64(seq.Timeout))
+	stmt.SetBytes("$locks", locks)
 	stmt.SetInt64("$state_status", int64(seq.State.Status))
 	stmt.SetInt64("$state_start", seq.State.Start.UnixNano())
 	stmt.SetInt64("$state_end", seq.State.End.UnixNano())
@@ -305,6 +420,11 @@ func commitSequence(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, po
 			return fmt.Errorf("planToSQL(commitAction): %w", err)
 		}
 	}
+	for i, a := range seq.Rollback {
+		if err := commitAction(ctx, conn, planID, i, a, capture); err != nil {
+			return fmt.Errorf("planToSQL(commitAction): %w", err)
+		}
+	}
 	return nil
 }
 
@@ -319,12 +439,15 @@ const insertAction = `
 		plugin,
 		timeout,
 		retries,
+		retry_policy,
+		max_elapsed,
 		req,
+		refs,
 		attempts,
 		state_status,
 		state_start,
 		state_end
-	) VALUES ($id, $key, $plan_id, $name, $descr, $pos, $plugin, $timeout, $retries, $req, $attempts,
+	) VALUES ($id, $key, $plan_id, $name, $descr, $pos, $plugin, $timeout, $retries, $retry_policy, $max_elapsed, $req, $refs, $attempts,
 	$state_status, $state_start, $state_end)`
 
 func commitAction(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, pos int, action *workflow.Action, capture *CaptureStmts) error {
@@ -336,6 +459,19 @@ func commitAction(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, pos
 		return fmt.Errorf("json.Marshal(req): %w", err)
 	}
 
+	var policy []byte
+	if action.RetryPolicy != nil {
+		policy, err = json.Marshal(action.RetryPolicy)
+		if err != nil {
+			return fmt.Errorf("json.Marshal(retryPolicy): %w", err)
+		}
+	}
+
+	refs, err := encodeRefs(action.Refs)
+	if err != nil {
+		return fmt.Errorf("can't encode action.Refs: %w", err)
+	}
+
 	attempts, err := encodeAttempts(action.Attempts)
 	if err != nil {
 		return fmt.Errorf("can't encode action.Attempts: %w", err)
@@ -350,7 +486,14 @@ func commitAction(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, pos
 	stmt.SetText("$plugin", action.Plugin)
 	stmt.SetInt64("$timeout", int64(action.Timeout))
 	stmt.SetInt64("$retries", int64(action.Retries))
+	if policy != nil {
+		stmt.SetBytes("$retry_policy", policy)
+	}
+	stmt.SetInt64("$max_elapsed", int64(action.MaxElapsed))
 	stmt.SetBytes("$req", req)
+	if refs != nil {
+		stmt.SetBytes("$refs", refs)
+	}
 	if attempts != nil {
 		stmt.SetBytes("$attempts", attempts)
 	}
@@ -408,10 +551,114 @@ func decodeAttempts(rawAttempts []byte, plug plugins.Plugin) ([]*workflow.Attemp
 	return attempts, nil
 }
 
+// encodeApproval encodes an Approval into JSON. A nil Approval is encoded as nil.
+func encodeApproval(approval *workflow.Approval) ([]byte, error) {
+	if approval == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(approval)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal(approval): %w", err)
+	}
+	return b, nil
+}
+
+// decodeApproval decodes a JSON encoded Approval. If rawApproval is empty, this returns nil.
+func decodeApproval(rawApproval []byte) (*workflow.Approval, error) {
+	if len(rawApproval) == 0 {
+		return nil, nil
+	}
+	approval := &workflow.Approval{}
+	if err := json.Unmarshal(rawApproval, approval); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal(approval): %w", err)
+	}
+	return approval, nil
+}
+
+// encodeLocks encodes Locks into JSON. A nil Locks is encoded as nil.
+func encodeLocks(locks *workflow.Locks) ([]byte, error) {
+	if locks == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(locks)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal(locks): %w", err)
+	}
+	return b, nil
+}
+
+// decodeLocks decodes JSON encoded Locks. If rawLocks is empty, this returns nil.
+func decodeLocks(rawLocks []byte) (*workflow.Locks, error) {
+	if len(rawLocks) == 0 {
+		return nil, nil
+	}
+	locks := &workflow.Locks{}
+	if err := json.Unmarshal(rawLocks, locks); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal(locks): %w", err)
+	}
+	return locks, nil
+}
+
+// encodeRamp encodes a Ramp into JSON. A nil Ramp is encoded as nil.
+func encodeRamp(ramp *workflow.Ramp) ([]byte, error) {
+	if ramp == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(ramp)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal(ramp): %w", err)
+	}
+	return b, nil
+}
+
+// decodeRamp decodes a JSON encoded Ramp. If rawRamp is empty, this returns nil.
+func decodeRamp(rawRamp []byte) (*workflow.Ramp, error) {
+	if len(rawRamp) == 0 {
+		return nil, nil
+	}
+	ramp := &workflow.Ramp{}
+	if err := json.Unmarshal(rawRamp, ramp); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal(ramp): %w", err)
+	}
+	return ramp, nil
+}
+
+// encodeRefs encodes Refs into JSON. No Refs are encoded as nil.
+func encodeRefs(refs []workflow.Ref) ([]byte, error) {
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(refs)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal(refs): %w", err)
+	}
+	return b, nil
+}
+
+// decodeRefs decodes JSON encoded Refs. If rawRefs is empty, this returns nil.
+func decodeRefs(rawRefs []byte) ([]workflow.Ref, error) {
+	if len(rawRefs) == 0 {
+		return nil, nil
+	}
+	var refs []workflow.Ref
+	if err := json.Unmarshal(rawRefs, &refs); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal(refs): %w", err)
+	}
+	return refs, nil
+}
+
 type ider interface {
 	GetID() uuid.UUID
 }
 
+// rollbackToJSON converts the IDs of a Rollback to JSON. A Block or Sequence without a Rollback returns nil.
+func rollbackToJSON(rollback []*workflow.Action) ([]byte, error) {
+	if len(rollback) == 0 {
+		return nil, nil
+	}
+	return idsToJSON(rollback)
+}
+
 func idsToJSON[T any](objs []T) ([]byte, error) {
 	ids := make([]string, 0, len(objs))
 	for _, o := range objs {
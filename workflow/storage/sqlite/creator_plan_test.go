@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sync"
@@ -13,12 +14,14 @@ import (
 	"github.com/element-of-surprise/coercion/plugins/registry"
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/element-of-surprise/coercion/workflow/builder"
+	"github.com/element-of-surprise/coercion/workflow/storage"
 	"github.com/element-of-surprise/coercion/workflow/storage/sqlite/testing/plugins"
 	"github.com/element-of-surprise/coercion/workflow/utils/clone"
 	"github.com/element-of-surprise/coercion/workflow/utils/walk"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/uuid"
+	"github.com/gostdlib/base/retry/exponential"
 	"zombiezen.com/go/sqlite"
 	"zombiezen.com/go/sqlite/sqlitex"
 )
@@ -50,6 +53,13 @@ func init() {
 		Descr:  "action",
 		Plugin: plugins.HelloPluginName,
 		Req:    plugins.HelloReq{Say: "hello"},
+		Refs:   []workflow.Ref{{Key: mustUUID(), Path: "Said", Field: "Say"}},
+		RetryPolicy: &exponential.Policy{
+			InitialInterval: time.Second,
+			Multiplier:      2,
+			MaxInterval:     time.Minute,
+		},
+		MaxElapsed: 10 * time.Minute,
 		Attempts: []*workflow.Attempt{
 			{
 				Err:   &pluglib.Error{Message: "internal error"},
@@ -57,9 +67,14 @@ func init() {
 				End:   time.Now(),
 			},
 			{
-				Resp:  plugins.HelloResp{Said: "hello"},
-				Start: time.Now().Add(-1 * time.Second),
-				End:   time.Now(),
+				Resp:     plugins.HelloResp{Said: "hello"},
+				Start:    time.Now().Add(-1 * time.Second),
+				End:      time.Now(),
+				Progress: &workflow.Progress{Percent: 50, Msg: "saying hello", Time: time.Now()},
+				Logs: []workflow.LogRecord{
+					{Time: time.Now(), Level: slog.LevelWarn, Msg: "said hello", Attrs: []workflow.LogAttr{{Key: "to", Value: "world"}}},
+				},
+				LogsDropped: 1,
 			},
 		},
 	}
@@ -81,8 +96,19 @@ func init() {
 		Descr:             "block",
 		EntranceDelay:     1 * time.Second,
 		ExitDelay:         1 * time.Second,
+		Timeout:           1 * time.Minute,
 		ToleratedFailures: 1,
 		Concurrency:       1,
+		DependsOn:         []uuid.UUID{mustUUID()},
+		Locks:             &workflow.Locks{Names: []string{"host/abc"}, Policy: workflow.LPFail},
+		Approval: &workflow.Approval{
+			Timeout:   1 * time.Hour,
+			Status:    workflow.ASApproved,
+			Approver:  "approver",
+			Comment:   "comment",
+			Requested: time.Now().Add(-1 * time.Minute).UTC(),
+			Decided:   time.Now().UTC(),
+		},
 	})
 
 	build.AddChecks(builder.PreChecks, &workflow.Checks{})
@@ -97,7 +123,7 @@ func init() {
 	build.AddAction(checkAction3)
 	build.Up()
 
-	build.AddSequence(&workflow.Sequence{Name: "sequence", Descr: "sequence"})
+	build.AddSequence(&workflow.Sequence{Name: "sequence", Descr: "sequence", Timeout: 1 * time.Minute, Locks: &workflow.Locks{Names: []string{"host/def"}}})
 	build.AddAction(seqAction1)
 	build.Up()
 
@@ -105,6 +131,26 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	plan.Approval = &workflow.Approval{Status: workflow.ASWaiting, Requested: time.Now().UTC()}
+	plan.ParentID = mustUUID()
+	plan.StartAt = time.Now().UTC()
+	plan.Recurring = "@daily"
+	plan.Priority = 3
+	plan.Drained = true
+	plan.QueuedAt = time.Now().UTC()
+	plan.TraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	plan.Timeout = 1 * time.Hour
+	plan.BlockConcurrency = 2
+	plan.Locks = &workflow.Locks{Names: []string{"cluster/east-1"}}
+	plan.Blocks[0].ToleratedFailurePercent = 25.5
+	plan.Blocks[0].ToleratedFailureSample = 4
+	plan.Blocks[0].Ramp = &workflow.Ramp{Steps: []int{1, 2}, Successes: 2}
+	plan.Blocks[0].Generator = &workflow.Action{Name: "generator", Descr: "generator", Plugin: plugins.HelloPluginName, Req: plugins.HelloReq{Say: "hello"}}
+	plan.Blocks[0].Rollback = []*workflow.Action{{Name: "block rollback", Descr: "block rollback", Plugin: plugins.HelloPluginName, Req: plugins.HelloReq{Say: "undo"}}}
+	plan.Blocks[0].Sequences[0].Rollback = []*workflow.Action{
+		{Name: "seq rollback 0", Descr: "seq rollback 0", Plugin: plugins.HelloPluginName, Req: plugins.HelloReq{Say: "undo"}},
+		{Name: "seq rollback 1", Descr: "seq rollback 1", Plugin: plugins.HelloPluginName, Req: plugins.HelloReq{Say: "undo"}},
+	}
 
 	for item := range walk.Plan(context.Background(), plan) {
 		setter := item.Value.(setters)
@@ -209,6 +255,55 @@ func TestCommitPlan(t *testing.T) {
 	}
 }
 
+func TestAddSequences(t *testing.T) {
+	_, pool, err := dbSetup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := registry.New()
+	reg.Register(&plugins.CheckPlugin{})
+	reg.Register(&plugins.HelloPlugin{})
+
+	reader := reader{
+		pool: pool,
+		reg:  reg,
+	}
+	updater := blockUpdater{
+		mu:   &sync.Mutex{},
+		pool: pool,
+	}
+
+	block := plan.Blocks[0]
+	seq := &workflow.Sequence{
+		ID:      mustUUID(),
+		Name:    "generated",
+		Descr:   "generated",
+		State:   &workflow.State{Status: workflow.NotStarted},
+		Actions: []*workflow.Action{clone.Action(context.Background(), block.Sequences[0].Actions[0], clone.WithKeepState())},
+	}
+	seq.SetPlanID(plan.ID)
+	seq.Actions[0].ID = mustUUID()
+	seq.Actions[0].SetPlanID(plan.ID)
+
+	block.Sequences = append(block.Sequences, seq)
+	block.Generator.State.Status = workflow.Completed
+	block.Generator.Attempts = []*workflow.Attempt{{Resp: plugins.HelloResp{Said: "hello"}, Start: time.Now(), End: time.Now()}}
+
+	if err := updater.AddSequences(context.Background(), block, []*workflow.Sequence{seq}); err != nil {
+		t.Fatal(err)
+	}
+
+	storedPlan, err := reader.Read(context.Background(), plan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(plan, storedPlan, cmp.AllowUnexported(workflow.Action{}, workflow.Block{}, workflow.Checks{}, workflow.Sequence{})); diff != "" {
+		t.Fatalf("Read plan does not match the plan with added sequences: -want/+got:\n%s", diff)
+	}
+}
+
 func TestDeletePlan(t *testing.T) {
 	path, pool, err := dbSetup()
 	if err != nil {
@@ -301,3 +396,152 @@ func countTable(pool *sqlitex.Pool, table string) (int64, error) {
 	}
 	return count, nil
 }
+
+// newTestPlan returns a small Plan with IDs and NotStarted states set, ready to be written to storage.
+func newTestPlan(ctx context.Context, t *testing.T, name string) *workflow.Plan {
+	build, err := builder.New(name, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	build.AddBlock(builder.BlockArgs{Name: "block", Descr: "block", Concurrency: 1})
+	build.AddSequence(&workflow.Sequence{Name: "sequence", Descr: "sequence"})
+	build.AddAction(&workflow.Action{Name: "action", Descr: "action", Plugin: plugins.HelloPluginName, Req: plugins.HelloReq{Say: "hello"}})
+	p, err := build.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for item := range walk.Plan(ctx, p) {
+		setter := item.Value.(setters)
+		setter.SetID(mustUUID())
+		if item.Value.Type() != workflow.OTPlan {
+			setter.(setPlanIDer).SetPlanID(p.ID)
+		}
+		setter.SetState(&workflow.State{Status: workflow.NotStarted})
+	}
+	p.SubmitTime = time.Now().UTC()
+	return p
+}
+
+func TestSubPlan(t *testing.T) {
+	ctx := context.Background()
+
+	reg := registry.New()
+	reg.Register(&plugins.HelloPlugin{})
+
+	vault, err := New(ctx, t.TempDir(), reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.Close(ctx)
+
+	parent := newTestPlan(ctx, t, "parent")
+	child := newTestPlan(ctx, t, "child")
+	child.CallerID = parent.ID
+	parent.Blocks = append(parent.Blocks, &workflow.Block{
+		ID:        mustUUID(),
+		Name:      "sub-plan",
+		Descr:     "sub-plan",
+		Sequences: []*workflow.Sequence{},
+		SubPlan:   child,
+		State:     &workflow.State{Status: workflow.NotStarted},
+	})
+	parent.Blocks[1].SetPlanID(parent.ID)
+
+	if err := vault.Create(ctx, child); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.Create(ctx, parent); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := vault.Read(ctx, parent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(parent, stored, cmp.AllowUnexported(workflow.Action{}, workflow.Block{}, workflow.Checks{}, workflow.Sequence{})); diff != "" {
+		t.Fatalf("TestSubPlan: read plan does not match the original plan: -want/+got:\n%s", diff)
+	}
+
+	results, err := vault.Search(ctx, storage.Filters{ByCallerIDs: []uuid.UUID{parent.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []uuid.UUID
+	for r := range results {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if r.Result.CallerID != parent.ID {
+			t.Errorf("TestSubPlan: got ListResult.CallerID == %s, want %s", r.Result.CallerID, parent.ID)
+		}
+		found = append(found, r.Result.ID)
+	}
+	if diff := cmp.Diff([]uuid.UUID{child.ID}, found); diff != "" {
+		t.Errorf("TestSubPlan: search by caller ID: -want/+got:\n%s", diff)
+	}
+
+	if err := vault.Delete(ctx, parent.ID); err != nil {
+		t.Fatal(err)
+	}
+	countExpect(vault.Pool(), "plans", 0, t)
+	countExpect(vault.Pool(), "blocks", 0, t)
+	countExpect(vault.Pool(), "actions", 0, t)
+}
+
+func TestQueuedPlan(t *testing.T) {
+	ctx := context.Background()
+
+	reg := registry.New()
+	reg.Register(&plugins.HelloPlugin{})
+
+	vault, err := New(ctx, t.TempDir(), reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.Close(ctx)
+
+	plan := newTestPlan(ctx, t, "queued")
+	plan.Priority = 2
+	if err := vault.Create(ctx, plan); err != nil {
+		t.Fatal(err)
+	}
+
+	plan.QueuedAt = time.Now().UTC()
+	if err := vault.UpdatePlan(ctx, plan); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := vault.Search(ctx, storage.Filters{ByIDs: []uuid.UUID{plan.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []storage.ListResult
+	for r := range results {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		found = append(found, r.Result)
+	}
+	if len(found) != 1 {
+		t.Fatalf("TestQueuedPlan: got %d search results, want 1", len(found))
+	}
+	if found[0].Priority != plan.Priority {
+		t.Errorf("TestQueuedPlan: got ListResult.Priority == %d, want %d", found[0].Priority, plan.Priority)
+	}
+	if !found[0].QueuedAt.Equal(plan.QueuedAt) {
+		t.Errorf("TestQueuedPlan: got ListResult.QueuedAt == %v, want %v", found[0].QueuedAt, plan.QueuedAt)
+	}
+
+	// Leaving the queue clears QueuedAt.
+	plan.QueuedAt = time.Time{}
+	if err := vault.UpdatePlan(ctx, plan); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := vault.Read(ctx, plan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.QueuedAt.IsZero() {
+		t.Errorf("TestQueuedPlan: got Plan.QueuedAt == %v, want zero", stored.QueuedAt)
+	}
+}
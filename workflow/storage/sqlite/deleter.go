@@ -96,9 +96,22 @@ func (d deleter) deleteBlocks(ctx context.Context, conn *sqlite.Conn, blocks []*
 		if err := d.deleteChecks(ctx, conn, block.DeferredChecks); err != nil {
 			return fmt.Errorf("couldn't delete block deferredchecks: %w", err)
 		}
+		if block.Generator != nil {
+			if err := d.deleteActions(ctx, conn, []*workflow.Action{block.Generator}); err != nil {
+				return fmt.Errorf("couldn't delete block generator: %w", err)
+			}
+		}
 		if err := d.deletesSeqs(ctx, conn, block.Sequences); err != nil {
 			return fmt.Errorf("couldn't delete block sequences: %w", err)
 		}
+		if err := d.deleteActions(ctx, conn, block.Rollback); err != nil {
+			return fmt.Errorf("couldn't delete block rollback: %w", err)
+		}
+		if block.SubPlan != nil {
+			if err := d.deletePlan(ctx, conn, block.SubPlan); err != nil {
+				return fmt.Errorf("couldn't delete block sub-plan: %w", err)
+			}
+		}
 	}
 
 	for _, block := range blocks {
@@ -145,6 +158,9 @@ func (d deleter) deletesSeqs(ctx context.Context, conn *sqlite.Conn, seqs []*wor
 		if err := d.deleteActions(ctx, conn, seq.Actions); err != nil {
 			return fmt.Errorf("couldn't delete sequence actions: %w", err)
 		}
+		if err := d.deleteActions(ctx, conn, seq.Rollback); err != nil {
+			return fmt.Errorf("couldn't delete sequence rollback: %w", err)
+		}
 	}
 
 	for _, seq := range seqs {
@@ -0,0 +1,155 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/element-of-surprise/coercion/internal/private"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+
+	"github.com/google/uuid"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+var _ storage.Leaser = leaser{}
+
+const (
+	fetchLease  = `SELECT owner, expires FROM leases WHERE plan_id = $plan_id`
+	upsertLease = `INSERT INTO leases (plan_id, owner, expires) VALUES ($plan_id, $owner, $expires)
+ON CONFLICT(plan_id) DO UPDATE SET owner = excluded.owner, expires = excluded.expires`
+	deleteLease = `DELETE FROM leases WHERE plan_id = $plan_id AND owner = $owner`
+	fetchLeases = `SELECT plan_id, owner, expires FROM leases ORDER BY plan_id ASC`
+)
+
+// leaser implements the storage.Leaser interface.
+type leaser struct {
+	mu   *sync.Mutex
+	pool *sqlitex.Pool
+
+	private.Storage
+}
+
+// Claim implements storage.Leaser.Claim(). The lease is read and written in an immediate transaction,
+// which holds the database's write lock so that another process sharing the database cannot claim the
+// lease between the read and the write.
+func (l leaser) Claim(ctx context.Context, planID uuid.UUID, owner string, ttl time.Duration) (err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	conn, err := l.pool.Take(context.WithoutCancel(ctx))
+	if err != nil {
+		return fmt.Errorf("couldn't get a connection from the pool: %w", err)
+	}
+	defer l.pool.Put(conn)
+
+	end, err := sqlitex.ImmediateTransaction(conn)
+	if err != nil {
+		return fmt.Errorf("couldn't begin transaction: %w", err)
+	}
+	defer end(&err)
+
+	var held string
+	var expires int64
+	err = sqlitex.Execute(
+		conn,
+		fetchLease,
+		&sqlitex.ExecOptions{
+			Named: map[string]any{"$plan_id": planID.String()},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				held = stmt.GetText("owner")
+				expires = stmt.GetInt64("expires")
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("couldn't fetch lease for plan(%s): %w", planID, err)
+	}
+
+	now := time.Now()
+	if held != "" && held != owner && now.Before(time.Unix(0, expires)) {
+		return fmt.Errorf("plan(%s) is leased by %s: %w", planID, held, storage.ErrLeased)
+	}
+
+	err = sqlitex.Execute(
+		conn,
+		upsertLease,
+		&sqlitex.ExecOptions{
+			Named: map[string]any{
+				"$plan_id": planID.String(),
+				"$owner":   owner,
+				"$expires": now.Add(ttl).UnixNano(),
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("couldn't write lease for plan(%s): %w", planID, err)
+	}
+	return nil
+}
+
+// Release implements storage.Leaser.Release().
+func (l leaser) Release(ctx context.Context, planID uuid.UUID, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	conn, err := l.pool.Take(context.WithoutCancel(ctx))
+	if err != nil {
+		return fmt.Errorf("couldn't get a connection from the pool: %w", err)
+	}
+	defer l.pool.Put(conn)
+
+	err = sqlitex.Execute(
+		conn,
+		deleteLease,
+		&sqlitex.ExecOptions{
+			Named: map[string]any{
+				"$plan_id": planID.String(),
+				"$owner":   owner,
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("couldn't delete lease for plan(%s): %w", planID, err)
+	}
+	return nil
+}
+
+// Leases implements storage.Leaser.Leases().
+func (l leaser) Leases(ctx context.Context) ([]storage.Lease, error) {
+	conn, err := l.pool.Take(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get a connection from the pool: %w", err)
+	}
+	defer l.pool.Put(conn)
+
+	var leases []storage.Lease
+	err = sqlitex.Execute(
+		conn,
+		fetchLeases,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				planID, err := uuid.Parse(stmt.GetText("plan_id"))
+				if err != nil {
+					return fmt.Errorf("couldn't parse lease plan id: %w", err)
+				}
+				leases = append(
+					leases,
+					storage.Lease{
+						PlanID:  planID,
+						Owner:   stmt.GetText("owner"),
+						Expires: time.Unix(0, stmt.GetInt64("expires")),
+					},
+				)
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch leases: %w", err)
+	}
+	return leases, nil
+}
@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+)
+
+func TestLeaser(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	// Two Vaults on the same database act as two processes.
+	vault1, err := New(ctx, root, registry.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault1.Close(ctx)
+	vault2, err := New(ctx, root, registry.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault2.Close(ctx)
+
+	planID := mustUUID()
+
+	if err := vault1.Claim(ctx, planID, "process1", 100*time.Millisecond); err != nil {
+		t.Fatalf("TestLeaser(process1 claim): got err == %v, want err == nil", err)
+	}
+	if err := vault2.Claim(ctx, planID, "process2", time.Minute); !errors.Is(err, storage.ErrLeased) {
+		t.Fatalf("TestLeaser(process2 claim): got err == %v, want err == storage.ErrLeased", err)
+	}
+	// Renewing by the owner is not an error.
+	if err := vault1.Claim(ctx, planID, "process1", 100*time.Millisecond); err != nil {
+		t.Fatalf("TestLeaser(process1 renew): got err == %v, want err == nil", err)
+	}
+
+	leases, err := vault2.Leases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 1 || leases[0].PlanID != planID || leases[0].Owner != "process1" || !leases[0].Expires.After(time.Now()) {
+		t.Fatalf("TestLeaser(Leases): got %+v, want an unexpired lease for plan(%s) held by process1", leases, planID)
+	}
+
+	// Once the lease expires, another owner can take it over.
+	time.Sleep(150 * time.Millisecond)
+	if err := vault2.Claim(ctx, planID, "process2", time.Minute); err != nil {
+		t.Fatalf("TestLeaser(process2 claim after expiry): got err == %v, want err == nil", err)
+	}
+	if err := vault1.Claim(ctx, planID, "process1", time.Minute); !errors.Is(err, storage.ErrLeased) {
+		t.Fatalf("TestLeaser(process1 renew after takeover): got err == %v, want err == storage.ErrLeased", err)
+	}
+
+	// Only the owner can release the lease.
+	if err := vault1.Release(ctx, planID, "process1"); err != nil {
+		t.Fatal(err)
+	}
+	leases, err = vault1.Leases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 1 || leases[0].Owner != "process2" {
+		t.Fatalf("TestLeaser(Leases after bad release): got %+v, want a lease held by process2", leases)
+	}
+	if err := vault2.Release(ctx, planID, "process2"); err != nil {
+		t.Fatal(err)
+	}
+	leases, err = vault1.Leases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 0 {
+		t.Fatalf("TestLeaser(Leases after release): got %+v, want no leases", leases)
+	}
+}
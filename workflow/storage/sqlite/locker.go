@@ -0,0 +1,169 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/element-of-surprise/coercion/internal/private"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+
+	"github.com/google/uuid"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+var _ storage.Locker = locker{}
+
+const (
+	fetchLockOwner = `SELECT owner FROM locks WHERE name = $name`
+	insertLock     = `INSERT INTO locks (name, owner, plan_id, acquired) VALUES ($name, $owner, $plan_id, $acquired)`
+	deleteLock     = `DELETE FROM locks WHERE name = $name AND owner = $owner`
+	fetchLocks     = `SELECT name, owner, plan_id, acquired FROM locks ORDER BY name ASC`
+)
+
+// locker implements the storage.Locker interface.
+type locker struct {
+	mu   *sync.Mutex
+	pool *sqlitex.Pool
+
+	private.Storage
+}
+
+// Lock implements storage.Locker.Lock().
+func (l locker) Lock(ctx context.Context, owner, planID uuid.UUID, names []string) (err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	conn, err := l.pool.Take(context.WithoutCancel(ctx))
+	if err != nil {
+		return fmt.Errorf("couldn't get a connection from the pool: %w", err)
+	}
+	defer l.pool.Put(conn)
+
+	end, err := sqlitex.ImmediateTransaction(conn)
+	if err != nil {
+		return fmt.Errorf("couldn't begin transaction: %w", err)
+	}
+	defer end(&err)
+
+	now := time.Now().UnixNano()
+	for _, name := range names {
+		var held string
+		err = sqlitex.Execute(
+			conn,
+			fetchLockOwner,
+			&sqlitex.ExecOptions{
+				Named: map[string]any{"$name": name},
+				ResultFunc: func(stmt *sqlite.Stmt) error {
+					held = stmt.GetText("owner")
+					return nil
+				},
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("couldn't fetch lock(%s): %w", name, err)
+		}
+		switch held {
+		case owner.String():
+			continue
+		case "":
+		default:
+			return fmt.Errorf("lock(%s): %w", name, storage.ErrLocked)
+		}
+
+		err = sqlitex.Execute(
+			conn,
+			insertLock,
+			&sqlitex.ExecOptions{
+				Named: map[string]any{
+					"$name":     name,
+					"$owner":    owner.String(),
+					"$plan_id":  planID.String(),
+					"$acquired": now,
+				},
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("couldn't insert lock(%s): %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Unlock implements storage.Locker.Unlock().
+func (l locker) Unlock(ctx context.Context, owner uuid.UUID, names []string) (err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	conn, err := l.pool.Take(context.WithoutCancel(ctx))
+	if err != nil {
+		return fmt.Errorf("couldn't get a connection from the pool: %w", err)
+	}
+	defer l.pool.Put(conn)
+
+	end, err := sqlitex.ImmediateTransaction(conn)
+	if err != nil {
+		return fmt.Errorf("couldn't begin transaction: %w", err)
+	}
+	defer end(&err)
+
+	for _, name := range names {
+		err = sqlitex.Execute(
+			conn,
+			deleteLock,
+			&sqlitex.ExecOptions{
+				Named: map[string]any{
+					"$name":  name,
+					"$owner": owner.String(),
+				},
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("couldn't delete lock(%s): %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Locks implements storage.Locker.Locks().
+func (l locker) Locks(ctx context.Context) ([]storage.Lock, error) {
+	conn, err := l.pool.Take(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get a connection from the pool: %w", err)
+	}
+	defer l.pool.Put(conn)
+
+	var locks []storage.Lock
+	err = sqlitex.Execute(
+		conn,
+		fetchLocks,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				owner, err := uuid.Parse(stmt.GetText("owner"))
+				if err != nil {
+					return fmt.Errorf("couldn't parse lock owner: %w", err)
+				}
+				planID, err := uuid.Parse(stmt.GetText("plan_id"))
+				if err != nil {
+					return fmt.Errorf("couldn't parse lock plan id: %w", err)
+				}
+				locks = append(
+					locks,
+					storage.Lock{
+						Name:     stmt.GetText("name"),
+						Owner:    owner,
+						PlanID:   planID,
+						Acquired: time.Unix(0, stmt.GetInt64("acquired")),
+					},
+				)
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch locks: %w", err)
+	}
+	return locks, nil
+}
@@ -0,0 +1,73 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+)
+
+func TestLocker(t *testing.T) {
+	ctx := context.Background()
+
+	vault, err := New(ctx, t.TempDir(), registry.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.Close(ctx)
+
+	planID := mustUUID()
+	owner1 := mustUUID()
+	owner2 := mustUUID()
+
+	if err := vault.Lock(ctx, owner1, planID, []string{"cluster/east-1", "host/abc"}); err != nil {
+		t.Fatalf("TestLocker(owner1 lock): got err == %v, want err == nil", err)
+	}
+	// Locking again with the same owner is not an error.
+	if err := vault.Lock(ctx, owner1, planID, []string{"host/abc"}); err != nil {
+		t.Fatalf("TestLocker(owner1 relock): got err == %v, want err == nil", err)
+	}
+
+	// owner2 cannot get host/abc, so it must not get host/def either.
+	err = vault.Lock(ctx, owner2, planID, []string{"host/def", "host/abc"})
+	if !errors.Is(err, storage.ErrLocked) {
+		t.Fatalf("TestLocker(owner2 lock): got err == %v, want err == storage.ErrLocked", err)
+	}
+
+	locks, err := vault.Locks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 2 {
+		t.Fatalf("TestLocker(Locks): got %d locks, want 2", len(locks))
+	}
+	for _, l := range locks {
+		if l.Owner != owner1 || l.PlanID != planID || l.Acquired.IsZero() {
+			t.Errorf("TestLocker(Locks): lock(%s) had Owner(%s), PlanID(%s), Acquired(%v), want Owner(%s), PlanID(%s) and Acquired set", l.Name, l.Owner, l.PlanID, l.Acquired, owner1, planID)
+		}
+	}
+
+	// owner2 cannot unlock what owner1 holds.
+	if err := vault.Unlock(ctx, owner2, []string{"host/abc"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.Lock(ctx, owner2, planID, []string{"host/abc"}); !errors.Is(err, storage.ErrLocked) {
+		t.Fatalf("TestLocker(owner2 lock after bad unlock): got err == %v, want err == storage.ErrLocked", err)
+	}
+
+	if err := vault.Unlock(ctx, owner1, []string{"cluster/east-1", "host/abc"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.Lock(ctx, owner2, planID, []string{"host/def", "host/abc"}); err != nil {
+		t.Fatalf("TestLocker(owner2 lock after unlock): got err == %v, want err == nil", err)
+	}
+	locks, err = vault.Locks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 2 || locks[0].Name != "host/abc" || locks[1].Name != "host/def" {
+		t.Fatalf("TestLocker(Locks after unlock): got %+v, want host/abc and host/def", locks)
+	}
+}
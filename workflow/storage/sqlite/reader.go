@@ -111,7 +111,7 @@ func (r reader) Search(ctx context.Context, filters storage.Filters) (chan stora
 }
 
 func (r reader) buildSearchQuery(filters storage.Filters) (string, []any, map[string]any) {
-	const sel = `SELECT id, group_id, name, descr, submit_time, state_status, state_start, state_end FROM plans WHERE`
+	const sel = `SELECT id, group_id, caller_id, name, descr, submit_time, state_status, state_start, state_end, priority, queued_at FROM plans WHERE`
 
 	var named = map[string]any{}
 	var args []any
@@ -132,6 +132,13 @@ func (r reader) buildSearchQuery(filters storage.Filters) (string, []any, map[st
 		numFilters++
 		build.WriteString(" group_id IN $group_ids")
 	}
+	if len(filters.ByCallerIDs) > 0 {
+		if numFilters > 0 {
+			build.WriteString(" AND")
+		}
+		numFilters++
+		build.WriteString(" caller_id IN $caller_ids")
+	}
 	if len(filters.ByStatus) > 0 {
 		if numFilters > 0 {
 			build.WriteString(" AND")
@@ -153,14 +160,19 @@ func (r reader) buildSearchQuery(filters storage.Filters) (string, []any, map[st
 	query := build.String()
 	if len(filters.ByIDs) > 0 {
 		var idArgs []any
-		query, idArgs = replaceWithIDs(query, "$id", filters.ByIDs)
+		query, idArgs = replaceWithIDs(query, "$ids", filters.ByIDs)
 		args = append(args, idArgs...)
 	}
 	if len(filters.ByGroupIDs) > 0 {
 		var groupArgs []any
-		query, groupArgs = replaceWithIDs(query, "$group_id", filters.ByGroupIDs)
+		query, groupArgs = replaceWithIDs(query, "$group_ids", filters.ByGroupIDs)
 		args = append(args, groupArgs...)
 	}
+	if len(filters.ByCallerIDs) > 0 {
+		var callerArgs []any
+		query, callerArgs = replaceWithIDs(query, "$caller_ids", filters.ByCallerIDs)
+		args = append(args, callerArgs...)
+	}
 	return query, args, named
 }
 
@@ -168,7 +180,7 @@ func (r reader) buildSearchQuery(filters storage.Filters) (string, []any, map[st
 // return with most recent submiited first. Limit sets the maximum number of
 // entrie to return
 func (r reader) List(ctx context.Context, limit int) (chan storage.Stream[storage.ListResult], error) {
-	const listPlans = `SELECT id, group_id, name, descr, submit_time, state_status, state_start, state_end FROM plans ORDER BY submit_time DESC`
+	const listPlans = `SELECT id, group_id, caller_id, name, descr, submit_time, state_status, state_start, state_end, priority, queued_at FROM plans ORDER BY submit_time DESC`
 
 	conn, err := r.pool.Take(ctx)
 	if err != nil {
@@ -229,6 +241,12 @@ func (r reader) listResultsFunc(stmt *sqlite.Stmt) (storage.ListResult, error) {
 	if err != nil {
 		return storage.ListResult{}, fmt.Errorf("couldn't get group ID: %w", err)
 	}
+	if cid := stmt.GetText("caller_id"); cid != "" {
+		result.CallerID, err = uuid.Parse(cid)
+		if err != nil {
+			return storage.ListResult{}, fmt.Errorf("couldn't get caller ID: %w", err)
+		}
+	}
 	result.Name = stmt.GetText("name")
 	result.Descr = stmt.GetText("descr")
 	result.SubmitTime = time.Unix(0, stmt.GetInt64("submit_time"))
@@ -237,6 +255,11 @@ func (r reader) listResultsFunc(stmt *sqlite.Stmt) (storage.ListResult, error) {
 		Start:  time.Unix(0, stmt.GetInt64("state_start")),
 		End:    time.Unix(0, stmt.GetInt64("state_end")),
 	}
+	result.Priority = int(stmt.GetInt64("priority"))
+	result.QueuedAt, err = timeFromField("queued_at", stmt)
+	if err != nil {
+		return storage.ListResult{}, fmt.Errorf("couldn't get queued at: %w", err)
+	}
 	return result, nil
 }
 
@@ -9,6 +9,7 @@ import (
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/go-json-experiment/json"
 	"github.com/google/uuid"
+	"github.com/gostdlib/base/retry/exponential"
 	"zombiezen.com/go/sqlite"
 	"zombiezen.com/go/sqlite/sqlitex"
 )
@@ -27,6 +28,27 @@ func (r reader) fieldToActions(ctx context.Context, conn *sqlite.Conn, stmt *sql
 	return actions, nil
 }
 
+// fieldToRollback converts the "rollback" field in a sqlite row to a list of workflow.Actions.
+// If the object has no Rollback, this returns nil.
+func (r reader) fieldToRollback(ctx context.Context, conn *sqlite.Conn, stmt *sqlite.Stmt) ([]*workflow.Action, error) {
+	if fieldToBytes("rollback", stmt) == nil {
+		return nil, nil
+	}
+	ids, err := fieldToIDs("rollback", stmt)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read rollback ids: %w", err)
+	}
+
+	actions, err := r.fetchActionsByIDs(ctx, conn, ids)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch rollback actions by ids: %w", err)
+	}
+	if len(actions) != len(ids) {
+		return nil, fmt.Errorf("found %d of %d rollback actions", len(actions), len(ids))
+	}
+	return actions, nil
+}
+
 // fetchActionsByIDs fetches a list of actions by their IDs.
 func (r reader) fetchActionsByIDs(ctx context.Context, conn *sqlite.Conn, ids []uuid.UUID) ([]*workflow.Action, error) {
 	if len(ids) == 0 {
@@ -88,6 +110,13 @@ func (r reader) actionRowToAction(ctx context.Context, stmt *sqlite.Stmt) (*work
 	a.Plugin = stmt.GetText("plugin")
 	a.Timeout = time.Duration(stmt.GetInt64("timeout"))
 	a.Retries = int(stmt.GetInt64("retries"))
+	if b := fieldToBytes("retry_policy", stmt); len(b) > 0 {
+		a.RetryPolicy = &exponential.Policy{}
+		if err := json.Unmarshal(b, a.RetryPolicy); err != nil {
+			return nil, fmt.Errorf("couldn't unmarshal retry policy: %w", err)
+		}
+	}
+	a.MaxElapsed = time.Duration(stmt.GetInt64("max_elapsed"))
 	a.State, err = fieldToState(stmt)
 	if err != nil {
 		return nil, fmt.Errorf("actionRowToAction: %w", err)
@@ -114,6 +143,10 @@ func (r reader) actionRowToAction(ctx context.Context, stmt *sqlite.Stmt) (*work
 			a.Req = req
 		}
 	}
+	a.Refs, err = decodeRefs(fieldToBytes("refs", stmt))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode refs: %w", err)
+	}
 	b = fieldToBytes("attempts", stmt)
 	if len(b) > 0 {
 		a.Attempts, err = decodeAttempts(b, plug)
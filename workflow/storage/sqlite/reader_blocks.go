@@ -88,12 +88,33 @@ func (p reader) blockRowToBlock(ctx context.Context, conn *sqlite.Conn, stmt *sq
 	b.Descr = stmt.GetText("descr")
 	b.EntranceDelay = time.Duration(stmt.GetInt64("entrancedelay"))
 	b.ExitDelay = time.Duration(stmt.GetInt64("exitdelay"))
+	b.Timeout = time.Duration(stmt.GetInt64("timeout"))
 	b.State, err = fieldToState(stmt)
 	if err != nil {
 		return nil, fmt.Errorf("blockRowToBlock: %w", err)
 	}
 	b.Concurrency = int(stmt.GetInt64("concurrency"))
+	b.Ramp, err = decodeRamp(fieldToBytes("ramp", stmt))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read block ramp: %w", err)
+	}
+	if fieldToBytes("depends_on", stmt) != nil {
+		b.DependsOn, err = fieldToIDs("depends_on", stmt)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read block depends on: %w", err)
+		}
+	}
 	b.ToleratedFailures = int(stmt.GetInt64("toleratedfailures"))
+	b.ToleratedFailurePercent = stmt.GetFloat("toleratedfailurepercent")
+	b.ToleratedFailureSample = int(stmt.GetInt64("toleratedfailuresample"))
+	b.Approval, err = decodeApproval(fieldToBytes("approval", stmt))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read block approval: %w", err)
+	}
+	b.Locks, err = decodeLocks(fieldToBytes("locks", stmt))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read block locks: %w", err)
+	}
 	b.BypassChecks, err = p.fieldToCheck(ctx, "bypasschecks", conn, stmt)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't read block bypasschecks: %w", err)
@@ -115,10 +136,76 @@ func (p reader) blockRowToBlock(ctx context.Context, conn *sqlite.Conn, stmt *sq
 		return nil, fmt.Errorf("couldn't read block deferredchecks: %w", err)
 	}
 
+	b.Generator, err = p.fieldToGenerator(ctx, conn, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read block generator: %w", err)
+	}
 	b.Sequences, err = p.fieldToSequences(ctx, conn, stmt)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't read block sequences: %w", err)
 	}
+	b.Rollback, err = p.fieldToRollback(ctx, conn, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read block rollback: %w", err)
+	}
+	b.SubPlan, err = fieldToSubPlan(stmt)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read block sub-plan: %w", err)
+	}
 
 	return b, nil
 }
+
+// fieldToGenerator reads the "generator" field in a sqlite row and returns the Block's Generator, if it has one.
+func (p reader) fieldToGenerator(ctx context.Context, conn *sqlite.Conn, stmt *sqlite.Stmt) (*workflow.Action, error) {
+	strID := stmt.GetText("generator")
+	if strID == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(strID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't convert ID to UUID: %w", err)
+	}
+	actions, err := p.fetchActionsByIDs(ctx, conn, []uuid.UUID{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(actions) != 1 {
+		return nil, fmt.Errorf("couldn't find generator(%s)", id)
+	}
+	return actions[0], nil
+}
+
+// fieldToSubPlan reads the "subplan" field in a sqlite row and returns a Plan holding only the ID of the
+// Block's SubPlan, if it has one. The SubPlan is read by fetchSubPlans once the row has been read, as reading
+// it here would reuse the cached statements that are reading its Plan.
+func fieldToSubPlan(stmt *sqlite.Stmt) (*workflow.Plan, error) {
+	strID := stmt.GetText("subplan")
+	if strID == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(strID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't convert ID to UUID: %w", err)
+	}
+	return &workflow.Plan{ID: id}, nil
+}
+
+// fetchSubPlans replaces the SubPlans of the blocks, which only hold their ID, with the stored Plans.
+func (p reader) fetchSubPlans(ctx context.Context, conn *sqlite.Conn, blocks []*workflow.Block) error {
+	for _, b := range blocks {
+		if b.SubPlan == nil {
+			continue
+		}
+		id := b.SubPlan.ID
+		sub, err := p.fetchPlanWithConn(ctx, conn, id)
+		if err != nil {
+			return fmt.Errorf("couldn't fetch sub-plan(%s): %w", id, err)
+		}
+		if sub.ID != id {
+			return fmt.Errorf("couldn't find sub-plan(%s)", id)
+		}
+		b.SubPlan = sub
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/google/uuid"
@@ -12,15 +13,26 @@ import (
 
 // fetchPlan fetches a plan by its id.
 func (p reader) fetchPlan(ctx context.Context, id uuid.UUID) (*workflow.Plan, error) {
-	plan := &workflow.Plan{}
-
 	conn, err := p.pool.Take(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't get a connection from the pool: %w", err)
 	}
 	defer p.pool.Put(conn)
 
-	err = sqlitex.Execute(
+	plan, err := p.fetchPlanWithConn(ctx, conn, id)
+	defer sqlitex.Transaction(conn)(&err)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch plan: %w", err)
+	}
+	return plan, nil
+}
+
+// fetchPlanWithConn fetches a plan by its id using conn. This is used to read the SubPlan of a Block
+// on the connection that is reading the Block.
+func (p reader) fetchPlanWithConn(ctx context.Context, conn *sqlite.Conn, id uuid.UUID) (*workflow.Plan, error) {
+	plan := &workflow.Plan{}
+
+	err := sqlitex.Execute(
 		conn,
 		fetchPlanByID,
 		&sqlitex.ExecOptions{
@@ -42,12 +54,48 @@ func (p reader) fetchPlan(ctx context.Context, id uuid.UUID) (*workflow.Plan, er
 						return fmt.Errorf("couldn't convert GroupID to UUID: %w", err)
 					}
 				}
+				if pid := stmt.GetText("parent_id"); pid != "" {
+					plan.ParentID, err = uuid.Parse(pid)
+					if err != nil {
+						return fmt.Errorf("couldn't convert ParentID to UUID: %w", err)
+					}
+				}
+				if cid := stmt.GetText("caller_id"); cid != "" {
+					plan.CallerID, err = uuid.Parse(cid)
+					if err != nil {
+						return fmt.Errorf("couldn't convert CallerID to UUID: %w", err)
+					}
+				}
 				plan.Name = stmt.GetText("name")
 				plan.Descr = stmt.GetText("descr")
 				plan.SubmitTime, err = timeFromField("submit_time", stmt)
 				if err != nil {
 					return fmt.Errorf("couldn't get plan submit time: %w", err)
 				}
+				plan.Reason = workflow.FailureReason(stmt.GetInt64("reason"))
+				plan.Paused = stmt.GetBool("paused")
+				plan.Drained = stmt.GetBool("drained")
+				plan.StartAt, err = timeFromField("start_at", stmt)
+				if err != nil {
+					return fmt.Errorf("couldn't get plan start at: %w", err)
+				}
+				plan.Recurring = stmt.GetText("recurring")
+				plan.Timeout = time.Duration(stmt.GetInt64("timeout"))
+				plan.BlockConcurrency = int(stmt.GetInt64("block_concurrency"))
+				plan.Priority = int(stmt.GetInt64("priority"))
+				plan.QueuedAt, err = timeFromField("queued_at", stmt)
+				if err != nil {
+					return fmt.Errorf("couldn't get plan queued at: %w", err)
+				}
+				plan.TraceID = stmt.GetText("trace_id")
+				plan.Locks, err = decodeLocks(fieldToBytes("locks", stmt))
+				if err != nil {
+					return fmt.Errorf("couldn't get plan locks: %w", err)
+				}
+				plan.Approval, err = decodeApproval(fieldToBytes("approval", stmt))
+				if err != nil {
+					return fmt.Errorf("couldn't get plan approval: %w", err)
+				}
 				plan.State, err = fieldToState(stmt)
 				if err != nil {
 					return fmt.Errorf("couldn't get plan state: %w", err)
@@ -84,9 +132,11 @@ func (p reader) fetchPlan(ctx context.Context, id uuid.UUID) (*workflow.Plan, er
 			},
 		},
 	)
-	defer sqlitex.Transaction(conn)(&err)
 	if err != nil {
-		return nil, fmt.Errorf("couldn't fetch plan: %w", err)
+		return nil, err
+	}
+	if err := p.fetchSubPlans(ctx, conn, plan.Blocks); err != nil {
+		return nil, err
 	}
 	return plan, nil
 }
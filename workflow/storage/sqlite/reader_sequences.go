@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/google/uuid"
@@ -84,6 +85,11 @@ func (p reader) sequenceRowToSequence(ctx context.Context, conn *sqlite.Conn, st
 	}
 	s.Name = stmt.GetText("name")
 	s.Descr = stmt.GetText("descr")
+	s.Timeout = time.Duration(stmt.GetInt64("timeout"))
+	s.Locks, err = decodeLocks(fieldToBytes("locks", stmt))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read sequence locks: %w", err)
+	}
 	s.State, err = fieldToState(stmt)
 	if err != nil {
 		return nil, fmt.Errorf("sequenceRowToSequence: %w", err)
@@ -92,6 +98,10 @@ func (p reader) sequenceRowToSequence(ctx context.Context, conn *sqlite.Conn, st
 	if err != nil {
 		return nil, fmt.Errorf("couldn't read sequence actions: %w", err)
 	}
+	s.Rollback, err = p.fieldToRollback(ctx, conn, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read sequence rollback: %w", err)
+	}
 
 	return s, nil
 }
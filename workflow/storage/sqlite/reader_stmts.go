@@ -12,6 +12,8 @@ const fetchPlanByID = `
 SELECT
 	id,
 	group_id,
+	parent_id,
+	caller_id,
  	name,
 	descr,
 	meta,
@@ -25,7 +27,18 @@ SELECT
 	state_start,
 	state_end,
 	submit_time,
-	reason
+	reason,
+	paused,
+	drained,
+	start_at,
+	recurring,
+	timeout,
+	locks,
+	approval,
+	block_concurrency,
+	priority,
+	queued_at,
+	trace_id
 FROM plans
 WHERE id = $id`
 
@@ -39,17 +52,27 @@ SELECT
 	pos,
 	entrancedelay,
 	exitdelay,
+	timeout,
 	bypasschecks,
 	prechecks,
 	postchecks,
 	contchecks,
 	deferredchecks,
 	sequences,
+	generator,
+	rollback,
+	subplan,
 	concurrency,
 	toleratedfailures,
+	toleratedfailurepercent,
+	toleratedfailuresample,
 	state_status,
 	state_start,
-	state_end
+	state_end,
+	locks,
+	approval,
+	depends_on,
+	ramp
 FROM blocks
 WHERE id = $id`
 
@@ -74,6 +97,9 @@ SELECT
 	name,
 	descr,
 	actions,
+	rollback,
+	timeout,
+	locks,
 	state_status,
 	state_start,
 	state_end
@@ -90,7 +116,10 @@ SELECT
 	plugin,
 	timeout,
 	retries,
+	retry_policy,
+	max_elapsed,
 	req,
+	refs,
 	attempts,
 	state_status,
 	state_start,
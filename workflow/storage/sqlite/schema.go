@@ -1,17 +1,25 @@
 package sqlite
 
+// schemaVersion is the version of the schema in tables. It is stored as the database's user_version.
+// A database that was created before the version was recorded has a user_version of 0.
+const schemaVersion = 1
+
 var tables = []string{
 	planSchema,
 	blocksSchema,
 	checksSchema,
 	sequencesSchema,
 	actionsSchema,
+	locksSchema,
+	leasesSchema,
 }
 
 var planSchema = `
 CREATE Table If Not Exists plans (
 	id TEXT PRIMARY KEY,
 	group_id TEXT NOT NULL,
+	parent_id TEXT,
+	caller_id TEXT,
 	name TEXT NOT NULL,
 	descr TEXT NOT NULL,
 	meta BLOB,
@@ -25,7 +33,18 @@ CREATE Table If Not Exists plans (
 	state_start INTEGER NOT NULL,
 	state_end INTEGER NOT NULL,
 	submit_time INTEGER NOT NULL,
-	reason INTEGER
+	reason INTEGER,
+	paused INTEGER,
+	drained INTEGER,
+	start_at INTEGER,
+	recurring TEXT,
+	timeout INTEGER,
+	locks BLOB,
+	approval BLOB,
+	block_concurrency INTEGER,
+	priority INTEGER,
+	queued_at INTEGER,
+	trace_id TEXT
 );`
 
 var blocksSchema = `
@@ -38,17 +57,27 @@ CREATE Table If Not Exists blocks (
     pos INTEGER NOT NULL,
     entrancedelay INTEGER NOT NULL,
     exitdelay INTEGER NOT NULL,
+    timeout INTEGER,
     bypasschecks TEXT,
     prechecks TEXT,
     postchecks TEXT,
     contchecks TEXT,
     deferredchecks TEXT,
     sequences BLOB NOT NULL,
+    generator TEXT,
+    rollback BLOB,
+    subplan TEXT,
     concurrency INTEGER NOT NULL,
     toleratedfailures INTEGER NOT NULL,
+    toleratedfailurepercent REAL,
+    toleratedfailuresample INTEGER,
     state_status INTEGER NOT NULL,
     state_start INTEGER NOT NULL,
-    state_end INTEGER NOT NULL
+    state_end INTEGER NOT NULL,
+    locks BLOB,
+    approval BLOB,
+    depends_on BLOB,
+    ramp BLOB
 );`
 
 var checksSchema = `
@@ -72,6 +101,9 @@ CREATE Table If Not Exists sequences (
     descr TEXT NOT NULL,
     pos INTEGER NOT NULL,
     actions BLOB NOT NULL,
+    rollback BLOB,
+    timeout INTEGER,
+    locks BLOB,
     state_status INTEGER NOT NULL,
     state_start INTEGER NOT NULL,
     state_end INTEGER NOT NULL
@@ -88,13 +120,31 @@ CREATE Table If Not Exists actions (
     plugin TEXT NOT NULL,
     timeout INTEGER NOT NULL,
     retries INTEGER NOT NULL,
+    retry_policy BLOB,
+    max_elapsed INTEGER,
     req BLOB,
+    refs BLOB,
     attempts BLOB,
     state_status INTEGER NOT NULL,
     state_start INTEGER NOT NULL,
     state_end INTEGER NOT NULL
 );`
 
+var locksSchema = `
+CREATE Table If Not Exists locks (
+    name TEXT PRIMARY KEY,
+    owner TEXT NOT NULL,
+    plan_id TEXT NOT NULL,
+    acquired INTEGER NOT NULL
+);`
+
+var leasesSchema = `
+CREATE Table If Not Exists leases (
+    plan_id TEXT PRIMARY KEY,
+    owner TEXT NOT NULL,
+    expires INTEGER NOT NULL
+);`
+
 var indexes = []string{
 	`CREATE INDEX If Not Exists idx_plans ON plans(id, group_id, state_status, state_start, state_end, reason);`,
 	`CREATE INDEX If Not Exists idx_blocks ON blocks(id, key, plan_id, state_status, state_start, state_end);`,
@@ -102,3 +152,46 @@ var indexes = []string{
 	`CREATE INDEX If Not Exists idx_sequences ON sequences(id, key, plan_id, state_status, state_start, state_end);`,
 	`CREATE INDEX If Not Exists idx_actions ON actions(id, key, plan_id, state_status, state_start, state_end, plugin);`,
 }
+
+// migrations holds the statements that upgrade a database from one schema version to the next,
+// migrations[v] upgrades a database at version v. tables always creates the latest version, so these
+// are only applied to a database that existed before it was opened. Tables that are new in a version
+// are created by tables.
+var migrations = [][]string{
+	// Version 1 adds the columns for the fields that were added after the first schema. locks and
+	// leases are new tables.
+	{
+		`ALTER TABLE plans ADD COLUMN parent_id TEXT;`,
+		`ALTER TABLE plans ADD COLUMN caller_id TEXT;`,
+		`ALTER TABLE plans ADD COLUMN paused INTEGER;`,
+		`ALTER TABLE plans ADD COLUMN drained INTEGER;`,
+		`ALTER TABLE plans ADD COLUMN start_at INTEGER;`,
+		`ALTER TABLE plans ADD COLUMN recurring TEXT;`,
+		`ALTER TABLE plans ADD COLUMN timeout INTEGER;`,
+		`ALTER TABLE plans ADD COLUMN locks BLOB;`,
+		`ALTER TABLE plans ADD COLUMN approval BLOB;`,
+		`ALTER TABLE plans ADD COLUMN block_concurrency INTEGER;`,
+		`ALTER TABLE plans ADD COLUMN priority INTEGER;`,
+		`ALTER TABLE plans ADD COLUMN queued_at INTEGER;`,
+		`ALTER TABLE plans ADD COLUMN trace_id TEXT;`,
+
+		`ALTER TABLE blocks ADD COLUMN timeout INTEGER;`,
+		`ALTER TABLE blocks ADD COLUMN generator TEXT;`,
+		`ALTER TABLE blocks ADD COLUMN rollback BLOB;`,
+		`ALTER TABLE blocks ADD COLUMN subplan TEXT;`,
+		`ALTER TABLE blocks ADD COLUMN toleratedfailurepercent REAL;`,
+		`ALTER TABLE blocks ADD COLUMN toleratedfailuresample INTEGER;`,
+		`ALTER TABLE blocks ADD COLUMN locks BLOB;`,
+		`ALTER TABLE blocks ADD COLUMN approval BLOB;`,
+		`ALTER TABLE blocks ADD COLUMN depends_on BLOB;`,
+		`ALTER TABLE blocks ADD COLUMN ramp BLOB;`,
+
+		`ALTER TABLE sequences ADD COLUMN rollback BLOB;`,
+		`ALTER TABLE sequences ADD COLUMN timeout INTEGER;`,
+		`ALTER TABLE sequences ADD COLUMN locks BLOB;`,
+
+		`ALTER TABLE actions ADD COLUMN retry_policy BLOB;`,
+		`ALTER TABLE actions ADD COLUMN max_elapsed INTEGER;`,
+		`ALTER TABLE actions ADD COLUMN refs BLOB;`,
+	},
+}
@@ -0,0 +1,182 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/storage/sqlite/testing/plugins"
+
+	"github.com/google/go-cmp/cmp"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// baselineTables is the schema that databases were created with before the schema was versioned.
+var baselineTables = []string{
+	`CREATE Table plans (
+	id TEXT PRIMARY KEY,
+	group_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	descr TEXT NOT NULL,
+	meta BLOB,
+	bypasschecks TEXT,
+	prechecks TEXT,
+	postchecks TEXT,
+	contchecks TEXT,
+	deferredchecks TEXT,
+	blocks BLOB NOT NULL,
+	state_status INTEGER NOT NULL,
+	state_start INTEGER NOT NULL,
+	state_end INTEGER NOT NULL,
+	submit_time INTEGER NOT NULL,
+	reason INTEGER
+);`,
+	`CREATE Table blocks (
+    id TEXT PRIMARY KEY,
+    key TEXT,
+    plan_id BLOB NOT NULL,
+    name TEXT NOT NULL,
+    descr TEXT NOT NULL,
+    pos INTEGER NOT NULL,
+    entrancedelay INTEGER NOT NULL,
+    exitdelay INTEGER NOT NULL,
+    bypasschecks TEXT,
+    prechecks TEXT,
+    postchecks TEXT,
+    contchecks TEXT,
+    deferredchecks TEXT,
+    sequences BLOB NOT NULL,
+    concurrency INTEGER NOT NULL,
+    toleratedfailures INTEGER NOT NULL,
+    state_status INTEGER NOT NULL,
+    state_start INTEGER NOT NULL,
+    state_end INTEGER NOT NULL
+);`,
+	`CREATE Table checks (
+    id TEXT PRIMARY KEY,
+    key TEXT,
+    plan_id TEXT NOT NULL,
+    actions BLOB NOT NULL,
+    delay INTEGER NOT NULL,
+    state_status INTEGER NOT NULL,
+    state_start INTEGER NOT NULL,
+    state_end INTEGER NOT NULL
+);`,
+	`CREATE Table sequences (
+    id TEXT PRIMARY KEY,
+    key TEXT,
+    plan_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    descr TEXT NOT NULL,
+    pos INTEGER NOT NULL,
+    actions BLOB NOT NULL,
+    state_status INTEGER NOT NULL,
+    state_start INTEGER NOT NULL,
+    state_end INTEGER NOT NULL
+);`,
+	`CREATE Table actions (
+    id TEXT PRIMARY KEY,
+    key TEXT,
+    plan_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    descr TEXT NOT NULL,
+    pos INTEGER NOT NULL,
+    plugin TEXT NOT NULL,
+    timeout INTEGER NOT NULL,
+    retries INTEGER NOT NULL,
+    req BLOB,
+    attempts BLOB,
+    state_status INTEGER NOT NULL,
+    state_start INTEGER NOT NULL,
+    state_end INTEGER NOT NULL
+);`,
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	conn, err := sqlite.OpenConn(filepath.Join(root, "workstream.db"), sqlite.OpenReadWrite, sqlite.OpenCreate, sqlite.OpenWAL)
+	if err != nil {
+		t.Fatalf("TestMigrateBaseline: couldn't open database: %v", err)
+	}
+	for _, table := range baselineTables {
+		if err := sqlitex.ExecuteTransient(conn, table, nil); err != nil {
+			t.Fatalf("TestMigrateBaseline: couldn't create baseline table: %v", err)
+		}
+	}
+	old := mustUUID()
+	err = sqlitex.Execute(
+		conn,
+		`INSERT INTO plans (id, group_id, name, descr, blocks, state_status, state_start, state_end, submit_time, reason)
+		VALUES ($id, '', 'old', 'old', '[]', $status, 0, 0, 0, 0);`,
+		&sqlitex.ExecOptions{
+			Named: map[string]any{"$id": old.String(), "$status": int64(workflow.Completed)},
+		},
+	)
+	if err != nil {
+		t.Fatalf("TestMigrateBaseline: couldn't insert baseline plan: %v", err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatalf("TestMigrateBaseline: couldn't close database: %v", err)
+	}
+
+	reg := registry.New()
+	reg.Register(&plugins.CheckPlugin{})
+	reg.Register(&plugins.HelloPlugin{})
+
+	vault, err := New(ctx, root, reg)
+	if err != nil {
+		t.Fatalf("TestMigrateBaseline: New() on a baseline database returned error: %v", err)
+	}
+
+	pool := vault.Pool()
+	conn, err = pool.Take(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := userVersion(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != schemaVersion {
+		t.Errorf("TestMigrateBaseline: got schema version %d, want %d", version, schemaVersion)
+	}
+
+	// A Plan with all the fields added since the baseline can be written and read back.
+	if err := commitPlan(ctx, conn, plan, nil); err != nil {
+		t.Fatalf("TestMigrateBaseline: couldn't commit plan: %v", err)
+	}
+	pool.Put(conn)
+
+	got, err := vault.Read(ctx, plan.ID)
+	if err != nil {
+		t.Fatalf("TestMigrateBaseline: Read() returned error: %v", err)
+	}
+	if diff := cmp.Diff(plan, got, cmp.AllowUnexported(workflow.Action{}, workflow.Block{}, workflow.Checks{}, workflow.Sequence{})); diff != "" {
+		t.Errorf("TestMigrateBaseline: read plan does not match the committed plan: -want/+got:\n%s", diff)
+	}
+
+	// The Plan written with the baseline schema is still read.
+	oldPlan, err := vault.Read(ctx, old)
+	if err != nil {
+		t.Fatalf("TestMigrateBaseline: Read() of baseline plan returned error: %v", err)
+	}
+	if oldPlan.Name != "old" || oldPlan.State.Status != workflow.Completed {
+		t.Errorf("TestMigrateBaseline: got baseline plan %q in %s, want %q in %s", oldPlan.Name, oldPlan.State.Status, "old", workflow.Completed)
+	}
+
+	if err := vault.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The migrations are not applied again.
+	vault, err = New(ctx, root, reg)
+	if err != nil {
+		t.Fatalf("TestMigrateBaseline: New() on a migrated database returned error: %v", err)
+	}
+	vault.Close(ctx)
+}
@@ -43,6 +43,8 @@ type Vault struct {
 	updater
 	closer
 	deleter
+	locker
+	leaser
 
 	private.Storage
 }
@@ -135,6 +137,8 @@ func New(ctx context.Context, root string, reg *registry.Register, options ...Op
 	r.updater = newUpdater(r.mu, pool, r.capture)
 	r.closer = closer{pool: pool}
 	r.deleter = deleter{mu: r.mu, pool: pool, reader: r.reader}
+	r.locker = locker{mu: r.mu, pool: pool}
+	r.leaser = leaser{mu: r.mu, pool: pool}
 	return r, nil
 }
 
@@ -146,7 +150,23 @@ func (v *Vault) Pool() *sqlitex.Pool {
 	return v.pool
 }
 
-func createTables(ctx context.Context, conn *sqlite.Conn) error {
+// createTables creates the tables and indexes of the schema. A database that has tables from an earlier
+// schema version is migrated to schemaVersion.
+func createTables(ctx context.Context, conn *sqlite.Conn) (err error) {
+	defer sqlitex.Transaction(conn)(&err)
+
+	version, err := userVersion(conn)
+	if err != nil {
+		return err
+	}
+	if version > schemaVersion {
+		return fmt.Errorf("database schema version(%d) is newer than this version supports(%d)", version, schemaVersion)
+	}
+	existed, err := tableExists(conn, "plans")
+	if err != nil {
+		return err
+	}
+
 	for _, table := range tables {
 		if err := sqlitex.ExecuteTransient(
 			conn,
@@ -156,10 +176,63 @@ func createTables(ctx context.Context, conn *sqlite.Conn) error {
 			return fmt.Errorf("couldn't create table: %w", err)
 		}
 	}
+	if existed {
+		for v := version; v < schemaVersion; v++ {
+			for _, stmt := range migrations[v] {
+				if err := sqlitex.ExecuteTransient(conn, stmt, &sqlitex.ExecOptions{}); err != nil {
+					return fmt.Errorf("couldn't migrate schema to version(%d): %w", v+1, err)
+				}
+			}
+		}
+	}
 	for _, index := range indexes {
 		if err := sqlitex.ExecuteTransient(conn, index, &sqlitex.ExecOptions{}); err != nil {
 			return fmt.Errorf("couldn't create index: %w", err)
 		}
 	}
+
+	// PRAGMA does not accept parameters.
+	if err := sqlitex.ExecuteTransient(conn, fmt.Sprintf("PRAGMA user_version = %d;", schemaVersion), nil); err != nil {
+		return fmt.Errorf("couldn't set schema version: %w", err)
+	}
 	return nil
 }
+
+// userVersion returns the user_version of the database, which is the version of its schema.
+func userVersion(conn *sqlite.Conn) (int, error) {
+	var version int
+	err := sqlitex.ExecuteTransient(
+		conn,
+		"PRAGMA user_version;",
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				version = stmt.ColumnInt(0)
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// tableExists returns true if the database has a table with name.
+func tableExists(conn *sqlite.Conn, name string) (bool, error) {
+	exists := false
+	err := sqlitex.ExecuteTransient(
+		conn,
+		"SELECT name FROM sqlite_master WHERE type = 'table' AND name = $name;",
+		&sqlitex.ExecOptions{
+			Named: map[string]any{"$name": name},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				exists = true
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		return false, fmt.Errorf("couldn't check for table(%s): %w", name, err)
+	}
+	return exists, nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/element-of-surprise/coercion/internal/private"
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/element-of-surprise/coercion/workflow/storage"
+	"zombiezen.com/go/sqlite"
 	"zombiezen.com/go/sqlite/sqlitex"
 )
 
@@ -33,6 +34,14 @@ func (a actionUpdater) UpdateAction(ctx context.Context, action *workflow.Action
 	}
 	defer a.pool.Put(conn)
 
+	if err := execUpdateAction(conn, action, a.capture); err != nil {
+		return fmt.Errorf("ActionWriter.Write: %w", err)
+	}
+	return nil
+}
+
+// execUpdateAction writes the state and attempts of action on conn.
+func execUpdateAction(conn *sqlite.Conn, action *workflow.Action, capture *CaptureStmts) error {
 	stmt := Stmt{}
 	stmt.Query(updateAction)
 	stmt.SetText("$id", action.ID.String())
@@ -42,20 +51,20 @@ func (a actionUpdater) UpdateAction(ctx context.Context, action *workflow.Action
 
 	b, err := encodeAttempts(action.Attempts)
 	if err != nil {
-		return fmt.Errorf("ActionWriter.Write: %w", err)
+		return err
 	}
 	stmt.SetBytes("$attempts", b)
 
 	sStmt, err := stmt.Prepare(conn)
 	if err != nil {
-		return fmt.Errorf("ActionWriter.Write: %w", err)
+		return err
 	}
 
 	_, err = sStmt.Step()
 	if err != nil {
-		return fmt.Errorf("ActionWriter.Write: %w", err)
+		return err
 	}
-	a.capture.Capture(stmt)
+	capture.Capture(stmt)
 
 	return nil
 }
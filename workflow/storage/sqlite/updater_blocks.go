@@ -39,6 +39,11 @@ func (b blockUpdater) UpdateBlock(ctx context.Context, action *workflow.Block) e
 	stmt.SetInt64("$state_status", int64(action.State.Status))
 	stmt.SetInt64("$state_start", action.State.Start.UnixNano())
 	stmt.SetInt64("$state_end", action.State.End.UnixNano())
+	approval, err := encodeApproval(action.Approval)
+	if err != nil {
+		return fmt.Errorf("BlockWriter.Write: %w", err)
+	}
+	stmt.SetBytes("$approval", approval)
 
 	sStmt, err := stmt.Prepare(conn)
 	if err != nil {
@@ -53,3 +58,58 @@ func (b blockUpdater) UpdateBlock(ctx context.Context, action *workflow.Block) e
 
 	return nil
 }
+
+// AddSequences implements storage.BlockUpdater.AddSequences().
+func (b blockUpdater) AddSequences(ctx context.Context, block *workflow.Block, seqs []*workflow.Sequence) (err error) {
+	if block.Generator == nil {
+		return fmt.Errorf("BlockWriter.AddSequences: block(%s) has no generator", block.ID)
+	}
+	start := len(block.Sequences) - len(seqs)
+	if start < 0 {
+		return fmt.Errorf("BlockWriter.AddSequences: the sequences are not in block(%s)", block.ID)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	conn, err := b.pool.Take(context.WithoutCancel(ctx))
+	if err != nil {
+		return fmt.Errorf("couldn't get a connection from the pool: %w", err)
+	}
+	defer b.pool.Put(conn)
+
+	end, err := sqlitex.ImmediateTransaction(conn)
+	if err != nil {
+		return fmt.Errorf("BlockWriter.AddSequences: %w", err)
+	}
+	defer end(&err)
+
+	for i, seq := range seqs {
+		if err = commitSequence(ctx, conn, block.GetPlanID(), start+i, seq, b.capture); err != nil {
+			return fmt.Errorf("BlockWriter.AddSequences: %w", err)
+		}
+	}
+
+	ids, err := idsToJSON(block.Sequences)
+	if err != nil {
+		return fmt.Errorf("BlockWriter.AddSequences: %w", err)
+	}
+	stmt := Stmt{}
+	stmt.Query(updateBlockSequences)
+	stmt.SetText("$id", block.ID.String())
+	stmt.SetBytes("$sequences", ids)
+
+	sStmt, err := stmt.Prepare(conn)
+	if err != nil {
+		return fmt.Errorf("BlockWriter.AddSequences: %w", err)
+	}
+	if _, err = sStmt.Step(); err != nil {
+		return fmt.Errorf("BlockWriter.AddSequences: %w", err)
+	}
+	b.capture.Capture(stmt)
+
+	if err = execUpdateAction(conn, block.Generator, b.capture); err != nil {
+		return fmt.Errorf("BlockWriter.AddSequences: %w", err)
+	}
+	return nil
+}
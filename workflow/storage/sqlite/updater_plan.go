@@ -38,6 +38,17 @@ func (u planUpdater) UpdatePlan(ctx context.Context, plan *workflow.Plan) error
 	stmt.Query(updatePlan)
 	stmt.SetText("$id", plan.ID.String())
 	stmt.SetInt64("$reason", int64(plan.Reason))
+	stmt.SetBool("$paused", plan.Paused)
+	stmt.SetBool("$drained", plan.Drained)
+	stmt.SetInt64("$start_at", plan.StartAt.UnixNano())
+	stmt.SetText("$recurring", plan.Recurring)
+	stmt.SetInt64("$queued_at", plan.QueuedAt.UnixNano())
+	stmt.SetText("$trace_id", plan.TraceID)
+	approval, err := encodeApproval(plan.Approval)
+	if err != nil {
+		return fmt.Errorf("PlanUpdater.UpdatePlan: %w", err)
+	}
+	stmt.SetBytes("$approval", approval)
 	stmt.SetInt64("$state_status", int64(plan.State.Status))
 	stmt.SetInt64("$state_start", plan.State.Start.UnixNano())
 	stmt.SetInt64("$state_end", plan.State.End.UnixNano())
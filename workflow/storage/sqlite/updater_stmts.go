@@ -4,6 +4,13 @@ const updatePlan = `
 UPDATE plans
 SET
 	reason = $reason,
+	paused = $paused,
+	drained = $drained,
+	start_at = $start_at,
+	recurring = $recurring,
+	queued_at = $queued_at,
+	trace_id = $trace_id,
+	approval = $approval,
 	state_status = $state_status,
 	state_start = $state_start,
 	state_end = $state_end
@@ -22,7 +29,14 @@ UPDATE blocks
 SET
 	state_status = $state_status,
 	state_start = $state_start,
-	state_end = $state_end
+	state_end = $state_end,
+	approval = $approval
+WHERE id = $id`
+
+const updateBlockSequences = `
+UPDATE blocks
+SET
+	sequences = $sequences
 WHERE id = $id`
 
 const updateSequence = `
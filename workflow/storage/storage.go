@@ -3,7 +3,10 @@
 package storage
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -19,13 +22,15 @@ type Filters struct {
 	ByIDs []uuid.UUID
 	// ByGroupIDs is a list of Group IDs to search by.
 	ByGroupIDs []uuid.UUID
+	// ByCallerIDs is a list of Plan IDs to find the SubPlans of. This matches Plans whose CallerID is in the list.
+	ByCallerIDs []uuid.UUID
 	// ByStatus is a list of Plan states to search by.
 	ByStatus []workflow.Status
 }
 
 // Validate validates the search filter.
 func (f Filters) Validate() error {
-	if len(f.ByIDs)+len(f.ByGroupIDs)+len(f.ByStatus) == 0 {
+	if len(f.ByIDs)+len(f.ByGroupIDs)+len(f.ByCallerIDs)+len(f.ByStatus) == 0 {
 		return fmt.Errorf("at least one search filter must be provided")
 	}
 	return nil
@@ -45,6 +50,8 @@ type ListResult struct {
 	ID uuid.UUID
 	// GroupID is the Group ID.
 	GroupID uuid.UUID
+	// CallerID is the ID of the Plan that runs this Plan as a SubPlan. This is uuid.Nil if the Plan is not a SubPlan.
+	CallerID uuid.UUID
 	// Name is the Plan name.
 	Name string
 	// Descr is the Plan description.
@@ -53,6 +60,56 @@ type ListResult struct {
 	SubmitTime time.Time
 	// State is the Plan state.
 	State *workflow.State
+	// Priority is the Plan priority.
+	Priority int
+	// QueuedAt is the time the Plan was queued to start. This is zero if the Plan is not queued.
+	QueuedAt time.Time
+}
+
+// Queued returns true if the Plan is waiting in the queue to start.
+func (l ListResult) Queued() bool {
+	return !l.QueuedAt.IsZero()
+}
+
+// CompareQueued compares the queue positions of two queued Plans for use with slices.SortFunc. It returns
+// a negative number if a starts before b and a positive number if b starts before a. Sorting the queued
+// results of a Search by this gives each Plan's position in the queue.
+func CompareQueued(a, b ListResult) int {
+	if a.Priority != b.Priority {
+		return cmp.Compare(b.Priority, a.Priority)
+	}
+	if c := a.QueuedAt.Compare(b.QueuedAt); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.ID[:], b.ID[:])
+}
+
+// ErrLocked is returned by Locker.Lock() when a lock is held by another owner.
+var ErrLocked = errors.New("lock is held by another owner")
+
+// Lock is a named lock held in storage.
+type Lock struct {
+	// Name is the name of the lock.
+	Name string
+	// Owner is the ID of the Plan, Block or Sequence that holds the lock.
+	Owner uuid.UUID
+	// PlanID is the ID of the Plan that the Owner belongs to.
+	PlanID uuid.UUID
+	// Acquired is the time the lock was acquired.
+	Acquired time.Time
+}
+
+// ErrLeased is returned by Leaser.Claim() when the lease on a Plan is held by another owner and has not expired.
+var ErrLeased = errors.New("lease is held by another owner")
+
+// Lease is a claim on a Plan by a process sharing storage. Only the owner of an unexpired lease may execute the Plan.
+type Lease struct {
+	// PlanID is the ID of the Plan that is leased.
+	PlanID uuid.UUID
+	// Owner is the ID of the process that holds the lease.
+	Owner string
+	// Expires is the time the lease expires unless it is renewed.
+	Expires time.Time
 }
 
 // Vault is a storage reader and writer for Plan data. An implementation of Vault must ensure
@@ -67,11 +124,14 @@ type Vault interface {
 	Updater
 	Closer
 	Deleter
+	Locker
+	Leaser
 }
 
 // Creator allows for creating Plan data in storage.
 type Creator interface {
 	// Create creates a new Plan in storage. This fails if the Plan ID already exists.
+	// The SubPlans of the Plan's Blocks are not created, they must be created before the Plan.
 	Create(ctx context.Context, plan *workflow.Plan) error
 
 	private.Storage
@@ -86,16 +146,44 @@ type Closer interface {
 
 // Deleter allows for deleting Plan data from storage.
 type Deleter interface {
+	// Delete deletes the Plan with the id and the SubPlans of its Blocks from storage.
 	Delete(ctx context.Context, id uuid.UUID) error
 
 	private.Storage
 }
 
+// Locker allows for holding named locks in storage. A lock can only be held by one owner at a time.
+type Locker interface {
+	// Lock acquires all the locks in names for owner, which belongs to the Plan with planID. Either all locks
+	// are acquired or none are. If another owner holds any of the locks, this returns ErrLocked.
+	// Locking names that owner already holds is not an error.
+	Lock(ctx context.Context, owner, planID uuid.UUID, names []string) error
+	// Unlock releases the locks in names that are held by owner. Names not held by owner are ignored.
+	Unlock(ctx context.Context, owner uuid.UUID, names []string) error
+	// Locks returns all the locks that are held.
+	Locks(ctx context.Context) ([]Lock, error)
+
+	private.Storage
+}
+
+// Leaser allows processes sharing storage to divide up Plans between them. A Plan is leased by one owner at a time.
+type Leaser interface {
+	// Claim claims the lease on the Plan with planID for owner until ttl from now. If owner already holds
+	// the lease, it is renewed. If another owner holds the lease and it has not expired, this returns ErrLeased.
+	Claim(ctx context.Context, planID uuid.UUID, owner string, ttl time.Duration) error
+	// Release releases the lease on the Plan with planID if it is held by owner.
+	Release(ctx context.Context, planID uuid.UUID, owner string) error
+	// Leases returns all the leases, including those that have expired.
+	Leases(ctx context.Context) ([]Lease, error)
+
+	private.Storage
+}
+
 // Reader allows for reading Plan data from storage.
 type Reader interface {
 	// Exists returns true if the Plan ID exists in the storage.
 	Exists(ctx context.Context, id uuid.UUID) (bool, error)
-	// Read returns a Plan from the storage.
+	// Read returns a Plan from the storage. The SubPlans of the Plan's Blocks are read with it.
 	Read(ctx context.Context, id uuid.UUID) (*workflow.Plan, error)
 	// Search returns a list of Plan IDs that match the filter.
 	Search(ctx context.Context, filters Filters) (chan Stream[ListResult], error)
@@ -130,6 +218,10 @@ type PlanUpdater interface {
 type BlockUpdater interface {
 	// UpdateBlock writes Block data to storage, but not underlying data.
 	UpdateBlock(context.Context, *workflow.Block) error
+	// AddSequences writes seqs, which the Block's Generator added to the end of the Block's Sequences, with
+	// their Actions. The Generator's state is written with them. This is atomic, so a Generator is only
+	// Completed in storage if the Sequences it generated are stored.
+	AddSequences(ctx context.Context, block *workflow.Block, seqs []*workflow.Sequence) error
 
 	private.Storage
 }
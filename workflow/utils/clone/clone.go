@@ -4,12 +4,14 @@ package clone
 import (
 	"context"
 	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/element-of-surprise/coercion/plugins"
 	"github.com/element-of-surprise/coercion/workflow"
 
 	"github.com/brunoga/deep"
+	"github.com/google/uuid"
 )
 
 type cloneOptions struct {
@@ -35,9 +37,11 @@ func WithKeepSecrets() Option {
 	}
 }
 
-// WithRemoveCompletedSequences removes Sequences that are Completed from Blocks.
-// If a Block contains only Completed Sequences, the Block is removed as long as
-// all PreChecks, PostChecks have completed and ContChecks are not in a failed state.
+// WithRemoveCompletedSequences removes Sequences that are Completed from Blocks. A Sequence that did not
+// complete is kept whole, including its Completed Actions, as a Sequence is always run from its first Action.
+// Completed Actions are not removed on their own.
+// If a Block contains only Completed Sequences, the Block is removed as long as its PreChecks and PostChecks
+// have completed and its ContChecks and DeferredChecks have not failed. A SubPlan is pruned in the same way.
 // If no Blocks exist and all the Plan checks are in a state similar to above, a returned Plan will be nil.
 func WithRemoveCompletedSequences() Option {
 	return func(c cloneOptions) cloneOptions {
@@ -82,10 +86,15 @@ func Plan(ctx context.Context, p *workflow.Plan, options ...Option) *workflow.Pl
 	copy(meta, p.Meta)
 
 	np := &workflow.Plan{
-		Name:    p.Name,
-		Descr:   p.Descr,
-		GroupID: p.GroupID,
-		Meta:    meta,
+		Name:             p.Name,
+		Descr:            p.Descr,
+		GroupID:          p.GroupID,
+		Meta:             meta,
+		Timeout:          p.Timeout,
+		Locks:            cloneLocks(p.Locks),
+		Approval:         cloneApproval(p.Approval, opts.keepState),
+		BlockConcurrency: p.BlockConcurrency,
+		Priority:         p.Priority,
 	}
 
 	if opts.keepState {
@@ -93,6 +102,14 @@ func Plan(ctx context.Context, p *workflow.Plan, options ...Option) *workflow.Pl
 		np.Reason = p.Reason
 		np.State = cloneState(p.State)
 		np.SubmitTime = p.SubmitTime
+		np.Paused = p.Paused
+		np.Drained = p.Drained
+		np.ParentID = p.ParentID
+		np.CallerID = p.CallerID
+		np.StartAt = p.StartAt
+		np.Recurring = p.Recurring
+		np.QueuedAt = p.QueuedAt
+		np.TraceID = p.TraceID
 	}
 
 	if p.BypassChecks != nil {
@@ -123,26 +140,20 @@ func Plan(ctx context.Context, p *workflow.Plan, options ...Option) *workflow.Pl
 		}
 		np.Blocks = append(np.Blocks, nb)
 	}
-
 	if opts.removeCompleted {
-		// We have some blocks left, so we don't return nil
-		if len(np.Blocks) != 0 {
-			return np
-		}
-		// There are no blocks, but if any of these are not in a good state, we return the Plan.
-		if p.PreChecks.State.Status != workflow.Completed {
-			return np
-		}
-		if p.PostChecks.State.Status != workflow.Completed {
-			return np
-		}
-		if p.ContChecks.State.Status == workflow.Failed {
-			return np
-		}
-		if p.DeferredChecks.State.Status == workflow.Failed {
-			return np
+		removeCompletedDepends(np.Blocks)
+	}
+
+	// If there are no blocks left and the checks are all in a good state, there is nothing to do.
+	if opts.removeCompleted && len(np.Blocks) == 0 {
+		switch {
+		case checksStatus(p.PreChecks) != workflow.Completed:
+		case checksStatus(p.PostChecks) != workflow.Completed:
+		case checksStatus(p.ContChecks) == workflow.Failed:
+		case checksStatus(p.DeferredChecks) == workflow.Failed:
+		default:
+			return nil
 		}
-		return nil
 	}
 
 	if !opts.keepSecrets && opts.callNum == 1 {
@@ -152,6 +163,29 @@ func Plan(ctx context.Context, p *workflow.Plan, options ...Option) *workflow.Pl
 	return np
 }
 
+// removeCompletedDepends removes the Keys of Blocks that are not in blocks from the DependsOn of each Block.
+// Blocks that were removed because they completed no longer need to be waited on.
+func removeCompletedDepends(blocks []*workflow.Block) {
+	keys := map[uuid.UUID]bool{}
+	for _, b := range blocks {
+		keys[b.Key] = true
+	}
+	for _, b := range blocks {
+		b.DependsOn = slices.DeleteFunc(b.DependsOn, func(k uuid.UUID) bool { return !keys[k] })
+		if len(b.DependsOn) == 0 {
+			b.DependsOn = nil
+		}
+	}
+}
+
+// checksStatus returns the Status of the Checks. Checks that don't exist are considered Completed.
+func checksStatus(c *workflow.Checks) workflow.Status {
+	if c == nil || c.State == nil {
+		return workflow.Completed
+	}
+	return c.State.Status
+}
+
 // Checks clones a set of Checks. This includes all sub-objects.
 func Checks(ctx context.Context, c *workflow.Checks, options ...Option) *workflow.Checks {
 	if c == nil {
@@ -198,12 +232,20 @@ func Block(ctx context.Context, b *workflow.Block, options ...Option) *workflow.
 	opts.callNum++
 
 	n := &workflow.Block{
-		Name:              b.Name,
-		Descr:             b.Descr,
-		EntranceDelay:     b.EntranceDelay,
-		ExitDelay:         b.ExitDelay,
-		Concurrency:       b.Concurrency,
-		ToleratedFailures: b.ToleratedFailures,
+		Key:                     b.Key,
+		Name:                    b.Name,
+		Descr:                   b.Descr,
+		DependsOn:               slices.Clone(b.DependsOn),
+		EntranceDelay:           b.EntranceDelay,
+		ExitDelay:               b.ExitDelay,
+		Timeout:                 b.Timeout,
+		Concurrency:             b.Concurrency,
+		Ramp:                    cloneRamp(b.Ramp),
+		ToleratedFailures:       b.ToleratedFailures,
+		ToleratedFailurePercent: b.ToleratedFailurePercent,
+		ToleratedFailureSample:  b.ToleratedFailureSample,
+		Approval:                cloneApproval(b.Approval, opts.keepState),
+		Locks:                   cloneLocks(b.Locks),
 	}
 
 	if opts.keepState {
@@ -211,13 +253,6 @@ func Block(ctx context.Context, b *workflow.Block, options ...Option) *workflow.
 		n.State = cloneState(b.State)
 	}
 
-	var (
-		preChecksCompleted      bool
-		contChecksNotFailed     bool
-		postChecksCompleted     bool
-		deferredChecksCompleted bool
-	)
-
 	if b.BypassChecks != nil {
 		state := b.BypassChecks.State
 		if state != nil && state.Status == workflow.Completed {
@@ -228,45 +263,56 @@ func Block(ctx context.Context, b *workflow.Block, options ...Option) *workflow.
 		n.BypassChecks = Checks(ctx, b.BypassChecks, withOptions(opts))
 	}
 	if b.PreChecks != nil {
-		state := b.PreChecks.State
-		if state != nil && b.PreChecks.State.Status == workflow.Completed {
-			preChecksCompleted = true
-		}
 		n.PreChecks = Checks(ctx, b.PreChecks, withOptions(opts))
 	}
 	if b.ContChecks != nil {
-		state := b.ContChecks.State
-		if state != nil && state.Status != workflow.Failed {
-			contChecksNotFailed = true
-		}
 		n.ContChecks = Checks(ctx, b.ContChecks, withOptions(opts))
 	}
 	if b.PostChecks != nil {
-		state := b.PostChecks.State
-		if state != nil && b.PostChecks.State.Status == workflow.Completed {
-			postChecksCompleted = true
-		}
 		n.PostChecks = Checks(ctx, b.PostChecks, withOptions(opts))
 	}
 	if b.DeferredChecks != nil {
-		state := b.DeferredChecks.State
-		if state != nil && b.DeferredChecks.State.Status == workflow.Completed {
-			deferredChecksCompleted = true
-		}
 		n.DeferredChecks = Checks(ctx, b.DeferredChecks, withOptions(opts))
 	}
 
-	n.Sequences = make([]*workflow.Sequence, 0, len(b.Sequences))
-	for _, seq := range b.Sequences {
+	seqs := b.Sequences
+	switch {
+	case b.Generator == nil:
+	case opts.keepState:
+		n.Generator = Action(ctx, b.Generator, withOptions(opts))
+	default:
+		// Sequences added by the Generator are generated again when the clone runs. But if they are kept
+		// so that they can finish, the Generator has done its work and is not cloned.
+		gen := generated(b)
+		switch {
+		case gen == 0:
+			n.Generator = Action(ctx, b.Generator, withOptions(opts))
+		case !opts.removeCompleted:
+			seqs = seqs[:len(seqs)-gen]
+			n.Generator = Action(ctx, b.Generator, withOptions(opts))
+		}
+	}
+
+	n.Sequences = make([]*workflow.Sequence, 0, len(seqs))
+	for _, seq := range seqs {
 		ns := Sequence(ctx, seq, withOptions(opts))
 		if ns == nil {
 			continue
 		}
 		n.Sequences = append(n.Sequences, ns)
 	}
-
-	if opts.removeCompleted && len(n.Sequences) == 0 {
-		if preChecksCompleted || contChecksNotFailed || postChecksCompleted || deferredChecksCompleted {
+	n.Rollback = cloneActions(ctx, b.Rollback, opts)
+	// A SubPlan that has completed is removed with WithRemoveCompletedSequences().
+	n.SubPlan = Plan(ctx, b.SubPlan, withOptions(opts))
+
+	// If there are no Sequences left to run or generate and the checks are all in a good state, the Block has completed.
+	if opts.removeCompleted && len(n.Sequences) == 0 && n.Generator == nil && n.SubPlan == nil {
+		switch {
+		case checksStatus(b.PreChecks) != workflow.Completed:
+		case checksStatus(b.PostChecks) != workflow.Completed:
+		case checksStatus(b.ContChecks) == workflow.Failed:
+		case checksStatus(b.DeferredChecks) == workflow.Failed:
+		default:
 			return nil
 		}
 	}
@@ -278,6 +324,22 @@ func Block(ctx context.Context, b *workflow.Block, options ...Option) *workflow.
 	return n
 }
 
+// generated returns the number of Sequences at the end of the Block that were added by its Generator.
+func generated(b *workflow.Block) int {
+	if b.Generator.State == nil || b.Generator.State.Status != workflow.Completed {
+		return 0
+	}
+	final := b.Generator.FinalAttempt()
+	if final == nil {
+		return 0
+	}
+	gen, ok := final.Resp.(workflow.Generated)
+	if !ok {
+		return 0
+	}
+	return min(len(gen.Sequences()), len(b.Sequences))
+}
+
 // Sequence clones a Sequence. This includes all sub-objects.
 func Sequence(ctx context.Context, s *workflow.Sequence, options ...Option) *workflow.Sequence {
 	if s == nil {
@@ -290,10 +352,16 @@ func Sequence(ctx context.Context, s *workflow.Sequence, options ...Option) *wor
 	}
 	opts.callNum++
 
+	if opts.removeCompleted && s.State != nil && s.State.Status == workflow.Completed {
+		return nil
+	}
+
 	ns := &workflow.Sequence{
 		Name:    s.Name,
 		Descr:   s.Descr,
-		Actions: make([]*workflow.Action, len(s.Actions)),
+		Actions: make([]*workflow.Action, 0, len(s.Actions)),
+		Timeout: s.Timeout,
+		Locks:   cloneLocks(s.Locks),
 	}
 
 	if opts.keepState {
@@ -301,17 +369,18 @@ func Sequence(ctx context.Context, s *workflow.Sequence, options ...Option) *wor
 		ns.State = cloneState(s.State)
 	}
 
-	for i, a := range s.Actions {
+	for _, a := range s.Actions {
 		na := Action(ctx, a, withOptions(opts))
 		if na == nil {
 			continue
 		}
-		ns.Actions[i] = na
+		ns.Actions = append(ns.Actions, na)
 	}
 
 	if len(ns.Actions) == 0 {
 		return nil
 	}
+	ns.Rollback = cloneActions(ctx, s.Rollback, opts)
 
 	if !opts.keepSecrets && opts.callNum == 1 {
 		Secure(ns)
@@ -332,17 +401,20 @@ func Action(ctx context.Context, a *workflow.Action, options ...Option) *workflo
 	}
 	opts.callNum++
 
-	if opts.removeCompleted && a.State.Status == workflow.Completed {
-		return nil
-	}
-
 	na := &workflow.Action{
-		Name:    a.Name,
-		Descr:   a.Descr,
-		Plugin:  a.Plugin,
-		Timeout: a.Timeout,
-		Retries: a.Retries,
-		Req:     deep.MustCopy(a.Req),
+		Key:        a.Key,
+		Name:       a.Name,
+		Descr:      a.Descr,
+		Plugin:     a.Plugin,
+		Timeout:    a.Timeout,
+		Retries:    a.Retries,
+		MaxElapsed: a.MaxElapsed,
+		Req:        deep.MustCopy(a.Req),
+		Refs:       slices.Clone(a.Refs),
+	}
+	if a.RetryPolicy != nil {
+		policy := *a.RetryPolicy
+		na.RetryPolicy = &policy
 	}
 
 	if opts.keepState {
@@ -358,6 +430,20 @@ func Action(ctx context.Context, a *workflow.Action, options ...Option) *workflo
 	return na
 }
 
+// cloneActions clones a list of Actions, such as a Rollback. A nil list stays nil.
+func cloneActions(ctx context.Context, actions []*workflow.Action, opts cloneOptions) []*workflow.Action {
+	if actions == nil {
+		return nil
+	}
+	n := make([]*workflow.Action, 0, len(actions))
+	for _, a := range actions {
+		if na := Action(ctx, a, withOptions(opts)); na != nil {
+			n = append(n, na)
+		}
+	}
+	return n
+}
+
 // cloneState clones a *workflow.State.
 func cloneState(state *workflow.State) *workflow.State {
 	if state == nil {
@@ -371,6 +457,39 @@ func cloneState(state *workflow.State) *workflow.State {
 	}
 }
 
+// cloneApproval clones a *workflow.Approval. The decision on the Approval is only kept if keepState is true.
+func cloneApproval(a *workflow.Approval, keepState bool) *workflow.Approval {
+	if a == nil {
+		return nil
+	}
+
+	n := &workflow.Approval{Timeout: a.Timeout}
+	if keepState {
+		n.Status = a.Status
+		n.Approver = a.Approver
+		n.Comment = a.Comment
+		n.Requested = a.Requested
+		n.Decided = a.Decided
+	}
+	return n
+}
+
+// cloneLocks clones a *workflow.Locks.
+func cloneLocks(l *workflow.Locks) *workflow.Locks {
+	if l == nil {
+		return nil
+	}
+	return &workflow.Locks{Names: slices.Clone(l.Names), Policy: l.Policy}
+}
+
+// cloneRamp clones a *workflow.Ramp.
+func cloneRamp(r *workflow.Ramp) *workflow.Ramp {
+	if r == nil {
+		return nil
+	}
+	return &workflow.Ramp{Steps: slices.Clone(r.Steps), Successes: r.Successes}
+}
+
 // cloneAttempts clones a []*workflow.Attempt.
 func cloneAttempts(attempts []*workflow.Attempt) []*workflow.Attempt {
 	if len(attempts) == 0 {
@@ -380,16 +499,37 @@ func cloneAttempts(attempts []*workflow.Attempt) []*workflow.Attempt {
 	sl := make([]*workflow.Attempt, 0, len(attempts))
 	for _, attempt := range attempts {
 		na := &workflow.Attempt{
-			Resp:  deep.MustCopy(attempt.Resp),
-			Err:   cloneErr(attempt.Err),
-			Start: attempt.Start,
-			End:   attempt.End,
+			Resp:        deep.MustCopy(attempt.Resp),
+			Err:         cloneErr(attempt.Err),
+			Start:       attempt.Start,
+			End:         attempt.End,
+			DryRun:      attempt.DryRun,
+			SlotWait:    attempt.SlotWait,
+			Logs:        cloneLogs(attempt.Logs),
+			LogsDropped: attempt.LogsDropped,
+		}
+		if attempt.Progress != nil {
+			p := *attempt.Progress
+			na.Progress = &p
 		}
 		sl = append(sl, na)
 	}
 	return sl
 }
 
+// cloneLogs clones the log records of an Attempt.
+func cloneLogs(logs []workflow.LogRecord) []workflow.LogRecord {
+	if logs == nil {
+		return nil
+	}
+	n := make([]workflow.LogRecord, 0, len(logs))
+	for _, l := range logs {
+		l.Attrs = slices.Clone(l.Attrs)
+		n = append(n, l)
+	}
+	return n
+}
+
 // cloneErr clones a *plugins.Err.
 func cloneErr(e *plugins.Error) *plugins.Error {
 	if e == nil {
@@ -2,12 +2,15 @@ package clone
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
 	"testing"
 	"time"
 
 	"github.com/element-of-surprise/coercion/plugins"
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/google/uuid"
+	"github.com/gostdlib/base/retry/exponential"
 
 	"github.com/kylelemons/godebug/pretty"
 )
@@ -52,6 +55,8 @@ func TestPlan(t *testing.T) {
 		Descr:      "descr",
 		GroupID:    id,
 		Meta:       []byte("hello"),
+		Timeout:    time.Hour,
+		Locks:      &workflow.Locks{Names: []string{"cluster/east-1"}},
 		PreChecks:  Checks(ctx, checks, WithKeepSecrets(), WithKeepState()),
 		PostChecks: Checks(ctx, checks, WithKeepSecrets(), WithKeepState()),
 		ContChecks: Checks(ctx, checks, WithKeepSecrets(), WithKeepState()),
@@ -64,6 +69,14 @@ func TestPlan(t *testing.T) {
 		},
 		Reason:     workflow.FRBlock,
 		SubmitTime: start,
+		ParentID:   id,
+		CallerID:   id,
+		StartAt:    start,
+		Recurring:  "@daily",
+		QueuedAt:   start,
+		Priority:   2,
+		Drained:    true,
+		TraceID:    "4bf92f3577b34da6a3ce929d0e0e4736",
 	}
 
 	tests := []struct {
@@ -83,10 +96,13 @@ func TestPlan(t *testing.T) {
 				Descr:      "descr",
 				GroupID:    id,
 				Meta:       []byte("hello"),
+				Timeout:    time.Hour,
+				Locks:      &workflow.Locks{Names: []string{"cluster/east-1"}},
 				PreChecks:  Checks(ctx, checks),
 				PostChecks: Checks(ctx, checks),
 				ContChecks: Checks(ctx, checks),
 				Blocks:     []*workflow.Block{Block(ctx, plan.Blocks[0])},
+				Priority:   2,
 			},
 		},
 		{
@@ -105,6 +121,8 @@ func TestPlan(t *testing.T) {
 				Descr:      "descr",
 				GroupID:    id,
 				Meta:       []byte("hello"),
+				Timeout:    time.Hour,
+				Locks:      &workflow.Locks{Names: []string{"cluster/east-1"}},
 				PreChecks:  Checks(ctx, checks, WithKeepState()),
 				PostChecks: Checks(ctx, checks, WithKeepState()),
 				ContChecks: Checks(ctx, checks, WithKeepState()),
@@ -115,6 +133,14 @@ func TestPlan(t *testing.T) {
 				},
 				Reason:     workflow.FRBlock,
 				SubmitTime: start,
+				ParentID:   id,
+				CallerID:   id,
+				StartAt:    start,
+				Recurring:  "@daily",
+				QueuedAt:   start,
+				Priority:   2,
+				Drained:    true,
+				TraceID:    "4bf92f3577b34da6a3ce929d0e0e4736",
 			},
 		},
 		{
@@ -126,10 +152,13 @@ func TestPlan(t *testing.T) {
 				Descr:      "descr",
 				GroupID:    id,
 				Meta:       []byte("hello"),
+				Timeout:    time.Hour,
+				Locks:      &workflow.Locks{Names: []string{"cluster/east-1"}},
 				PreChecks:  Checks(ctx, checks, WithKeepSecrets()),
 				PostChecks: Checks(ctx, checks, WithKeepSecrets()),
 				ContChecks: Checks(ctx, checks, WithKeepSecrets()),
 				Blocks:     []*workflow.Block{Block(ctx, plan.Blocks[0], WithKeepSecrets())},
+				Priority:   2,
 			},
 		},
 	}
@@ -143,6 +172,341 @@ func TestPlan(t *testing.T) {
 	}
 }
 
+func TestRemoveCompletedSequences(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	state := func(s workflow.Status) *workflow.State {
+		return &workflow.State{Status: s}
+	}
+	action := func(name string, s workflow.Status) *workflow.Action {
+		return &workflow.Action{Name: name, Req: Req{Data: "Hello"}, State: state(s)}
+	}
+	checks := func(s workflow.Status) *workflow.Checks {
+		return &workflow.Checks{State: state(s), Actions: []*workflow.Action{action("check", workflow.Completed)}}
+	}
+
+	plan := func() *workflow.Plan {
+		return &workflow.Plan{
+			Name:      "plan",
+			Descr:     "descr",
+			PreChecks: checks(workflow.Completed),
+			State:     state(workflow.Failed),
+			Blocks: []*workflow.Block{
+				{
+					Name:  "completed",
+					Descr: "descr",
+					State: state(workflow.Completed),
+					Sequences: []*workflow.Sequence{
+						{Name: "seq", Descr: "descr", State: state(workflow.Completed), Actions: []*workflow.Action{action("action", workflow.Completed)}},
+					},
+				},
+				{
+					Name:      "failed",
+					Descr:     "descr",
+					PreChecks: checks(workflow.Completed),
+					State:     state(workflow.Failed),
+					Sequences: []*workflow.Sequence{
+						{Name: "seq0", Descr: "descr", State: state(workflow.Completed), Actions: []*workflow.Action{action("action", workflow.Completed)}},
+						{
+							Name:  "seq1",
+							Descr: "descr",
+							State: state(workflow.Failed),
+							Actions: []*workflow.Action{
+								action("action0", workflow.Completed),
+								action("action1", workflow.Failed),
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	got := Plan(ctx, plan(), WithRemoveCompletedSequences(), WithKeepSecrets())
+
+	want := &workflow.Plan{
+		Name:      "plan",
+		Descr:     "descr",
+		Meta:      []byte{},
+		PreChecks: Checks(ctx, checks(workflow.Completed), WithKeepSecrets()),
+		Blocks: []*workflow.Block{
+			{
+				Name:      "failed",
+				Descr:     "descr",
+				PreChecks: Checks(ctx, checks(workflow.Completed), WithKeepSecrets()),
+				Sequences: []*workflow.Sequence{
+					{
+						Name:  "seq1",
+						Descr: "descr",
+						Actions: []*workflow.Action{
+							{Name: "action0", Req: Req{Data: "Hello"}},
+							{Name: "action1", Req: Req{Data: "Hello"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	if diff := pretty.Compare(want, got); diff != "" {
+		t.Errorf("TestRemoveCompletedSequences: -want/+got:\n%s", diff)
+	}
+
+	done := plan()
+	done.Blocks = done.Blocks[:1]
+	if got := Plan(ctx, done, WithRemoveCompletedSequences()); got != nil {
+		t.Errorf("TestRemoveCompletedSequences(all completed): got Plan, want nil")
+	}
+}
+
+func TestBlockSubPlan(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	state := func(s workflow.Status) *workflow.State {
+		return &workflow.State{Status: s}
+	}
+	subBlock := func(name string, s workflow.Status) *workflow.Block {
+		return &workflow.Block{
+			Name:      name,
+			State:     state(s),
+			Sequences: []*workflow.Sequence{{Name: "seq", State: state(s), Actions: []*workflow.Action{{Name: "action", State: state(s)}}}},
+		}
+	}
+	block := func(statuses ...workflow.Status) *workflow.Block {
+		sub := &workflow.Plan{Name: "sub", CallerID: workflow.NewV7(), State: state(workflow.Failed)}
+		for i, s := range statuses {
+			sub.Blocks = append(sub.Blocks, subBlock(fmt.Sprintf("block%d", i), s))
+		}
+		return &workflow.Block{Name: "block", State: state(workflow.Failed), SubPlan: sub}
+	}
+
+	tests := []struct {
+		name       string
+		block      *workflow.Block
+		options    []Option
+		wantNil    bool
+		wantBlocks []string
+		wantCaller bool
+	}{
+		{
+			name:       "SubPlan is cloned",
+			block:      block(workflow.Completed, workflow.Failed),
+			wantBlocks: []string{"block0", "block1"},
+		},
+		{
+			name:       "WithKeepState()",
+			block:      block(workflow.Completed, workflow.Failed),
+			options:    []Option{WithKeepState()},
+			wantBlocks: []string{"block0", "block1"},
+			wantCaller: true,
+		},
+		{
+			name:       "WithRemoveCompletedSequences() removes the completed parts of the SubPlan",
+			block:      block(workflow.Completed, workflow.Failed),
+			options:    []Option{WithRemoveCompletedSequences()},
+			wantBlocks: []string{"block1"},
+		},
+		{
+			name:    "WithRemoveCompletedSequences() removes a Block whose SubPlan completed",
+			block:   block(workflow.Completed),
+			options: []Option{WithRemoveCompletedSequences()},
+			wantNil: true,
+		},
+	}
+
+	for _, test := range tests {
+		got := Block(ctx, test.block, append(test.options, WithKeepSecrets())...)
+		if test.wantNil {
+			if got != nil {
+				t.Errorf("TestBlockSubPlan(%s): got Block, want nil", test.name)
+			}
+			continue
+		}
+		if got.SubPlan == nil || got.SubPlan == test.block.SubPlan {
+			t.Errorf("TestBlockSubPlan(%s): SubPlan was not cloned", test.name)
+			continue
+		}
+
+		names := []string{}
+		for _, b := range got.SubPlan.Blocks {
+			names = append(names, b.Name)
+		}
+		if diff := pretty.Compare(test.wantBlocks, names); diff != "" {
+			t.Errorf("TestBlockSubPlan(%s): SubPlan Blocks: -want/+got:\n%s", test.name, diff)
+		}
+		if (got.SubPlan.CallerID == test.block.SubPlan.CallerID) != test.wantCaller {
+			t.Errorf("TestBlockSubPlan(%s): got CallerID == %s, want kept == %v", test.name, got.SubPlan.CallerID, test.wantCaller)
+		}
+	}
+}
+
+type genResp struct {
+	Seqs []*workflow.Sequence
+}
+
+func (g genResp) Sequences() []*workflow.Sequence {
+	return g.Seqs
+}
+
+func TestPlanDependsOn(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	state := func(s workflow.Status) *workflow.State {
+		return &workflow.State{Status: s}
+	}
+	keys := map[string]uuid.UUID{"a": workflow.NewV7(), "b": workflow.NewV7(), "c": workflow.NewV7()}
+	block := func(name string, s workflow.Status, dependsOn ...string) *workflow.Block {
+		b := &workflow.Block{
+			Key:       keys[name],
+			Name:      name,
+			State:     state(s),
+			Sequences: []*workflow.Sequence{{Name: "seq", State: state(s), Actions: []*workflow.Action{{Name: "action", State: state(s)}}}},
+		}
+		for _, d := range dependsOn {
+			b.DependsOn = append(b.DependsOn, keys[d])
+		}
+		return b
+	}
+	plan := func() *workflow.Plan {
+		return &workflow.Plan{
+			Name:             "plan",
+			BlockConcurrency: 2,
+			State:            state(workflow.Failed),
+			Blocks: []*workflow.Block{
+				block("a", workflow.Completed),
+				block("b", workflow.Failed, "a"),
+				block("c", workflow.NotStarted, "a", "b"),
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		options   []Option
+		wantDepOn map[string][]uuid.UUID
+	}{
+		{
+			name:      "DependsOn is cloned",
+			wantDepOn: map[string][]uuid.UUID{"a": nil, "b": {keys["a"]}, "c": {keys["a"], keys["b"]}},
+		},
+		{
+			name:      "WithRemoveCompletedSequences() removes dependencies on completed Blocks",
+			options:   []Option{WithRemoveCompletedSequences()},
+			wantDepOn: map[string][]uuid.UUID{"b": nil, "c": {keys["b"]}},
+		},
+	}
+
+	for _, test := range tests {
+		p := plan()
+		got := Plan(ctx, p, append(test.options, WithKeepSecrets())...)
+
+		if got.BlockConcurrency != p.BlockConcurrency {
+			t.Errorf("TestPlanDependsOn(%s): got BlockConcurrency == %d, want %d", test.name, got.BlockConcurrency, p.BlockConcurrency)
+		}
+		gotDepOn := map[string][]uuid.UUID{}
+		for _, b := range got.Blocks {
+			if b.Key != keys[b.Name] {
+				t.Errorf("TestPlanDependsOn(%s): got block(%s) Key == %s, want %s", test.name, b.Name, b.Key, keys[b.Name])
+			}
+			gotDepOn[b.Name] = b.DependsOn
+		}
+		if diff := pretty.Compare(test.wantDepOn, gotDepOn); diff != "" {
+			t.Errorf("TestPlanDependsOn(%s): DependsOn: -want/+got:\n%s", test.name, diff)
+		}
+		// The original must not share its DependsOn with the clone.
+		if diff := pretty.Compare([]uuid.UUID{keys["a"], keys["b"]}, p.Blocks[2].DependsOn); diff != "" {
+			t.Errorf("TestPlanDependsOn(%s): original DependsOn changed: -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestBlockGenerator(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	state := func(s workflow.Status) *workflow.State {
+		return &workflow.State{Status: s}
+	}
+	seq := func(name string, s workflow.Status) *workflow.Sequence {
+		return &workflow.Sequence{Name: name, State: state(s), Actions: []*workflow.Action{{Name: "action", State: state(s)}}}
+	}
+	block := func(genStatus workflow.Status) *workflow.Block {
+		gen0, gen1 := seq("gen0", workflow.Completed), seq("gen1", workflow.Failed)
+		return &workflow.Block{
+			Name:      "block",
+			State:     state(workflow.Failed),
+			Sequences: []*workflow.Sequence{seq("static", workflow.Completed), gen0, gen1},
+			Generator: &workflow.Action{
+				Name:     "generator",
+				State:    state(genStatus),
+				Attempts: []*workflow.Attempt{{Resp: genResp{Seqs: []*workflow.Sequence{gen0, gen1}}}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		block     *workflow.Block
+		options   []Option
+		wantSeqs  []string
+		wantGen   bool
+		wantState bool
+	}{
+		{
+			name:     "Generated Sequences are removed",
+			block:    block(workflow.Completed),
+			wantSeqs: []string{"static"},
+			wantGen:  true,
+		},
+		{
+			name:      "WithKeepState()",
+			block:     block(workflow.Completed),
+			options:   []Option{WithKeepState()},
+			wantSeqs:  []string{"static", "gen0", "gen1"},
+			wantGen:   true,
+			wantState: true,
+		},
+		{
+			name:     "WithRemoveCompletedSequences() keeps generated Sequences without the Generator",
+			block:    block(workflow.Completed),
+			options:  []Option{WithRemoveCompletedSequences()},
+			wantSeqs: []string{"gen1"},
+		},
+		{
+			name:     "Generator did not complete",
+			block:    block(workflow.Failed),
+			wantSeqs: []string{"static", "gen0", "gen1"},
+			wantGen:  true,
+		},
+	}
+
+	for _, test := range tests {
+		got := Block(ctx, test.block, append(test.options, WithKeepSecrets())...)
+
+		names := []string{}
+		for _, s := range got.Sequences {
+			names = append(names, s.Name)
+		}
+		if diff := pretty.Compare(test.wantSeqs, names); diff != "" {
+			t.Errorf("TestBlockGenerator(%s): Sequences: -want/+got:\n%s", test.name, diff)
+		}
+		switch {
+		case test.wantGen && got.Generator == nil:
+			t.Errorf("TestBlockGenerator(%s): got Generator == nil, want Generator", test.name)
+		case !test.wantGen && got.Generator != nil:
+			t.Errorf("TestBlockGenerator(%s): got Generator, want Generator == nil", test.name)
+		case got.Generator != nil && (got.Generator.State != nil) != test.wantState:
+			t.Errorf("TestBlockGenerator(%s): got Generator.State == %v, want state kept == %v", test.name, got.Generator.State, test.wantState)
+		}
+	}
+}
+
 func TestBlock(t *testing.T) {
 	t.Parallel()
 
@@ -154,8 +518,10 @@ func TestBlock(t *testing.T) {
 	}
 
 	action := &workflow.Action{
+		Key:  id,
 		Name: "action1",
 		Req:  Req{Data: "Hello"},
+		Refs: []workflow.Ref{{Key: id, Path: "Data", Field: "Data"}},
 	}
 
 	sequence := &workflow.Sequence{
@@ -172,6 +538,9 @@ func TestBlock(t *testing.T) {
 		Descr:         "descr",
 		EntranceDelay: 1 * time.Second,
 		ExitDelay:     1 * time.Second,
+		Timeout:       1 * time.Minute,
+		Locks:         &workflow.Locks{Names: []string{"host/abc"}, Policy: workflow.LPFail},
+		Ramp:          &workflow.Ramp{Steps: []int{1, 2}, Successes: 3},
 		PreChecks: &workflow.Checks{
 			State: &workflow.State{},
 			Actions: []*workflow.Action{
@@ -193,8 +562,10 @@ func TestBlock(t *testing.T) {
 		Sequences: []*workflow.Sequence{
 			Sequence(ctx, sequence, WithKeepState(), WithKeepSecrets()),
 		},
-		Concurrency:       1,
-		ToleratedFailures: 1,
+		Concurrency:             1,
+		ToleratedFailures:       1,
+		ToleratedFailurePercent: 25.5,
+		ToleratedFailureSample:  4,
 		State: &workflow.State{
 			Status: workflow.Completed,
 		},
@@ -217,6 +588,9 @@ func TestBlock(t *testing.T) {
 				Descr:         "descr",
 				EntranceDelay: 1 * time.Second,
 				ExitDelay:     1 * time.Second,
+				Timeout:       1 * time.Minute,
+				Locks:         &workflow.Locks{Names: []string{"host/abc"}, Policy: workflow.LPFail},
+				Ramp:          &workflow.Ramp{Steps: []int{1, 2}, Successes: 3},
 				PreChecks: &workflow.Checks{
 					Actions: []*workflow.Action{
 						actionSecretRemoved,
@@ -235,8 +609,10 @@ func TestBlock(t *testing.T) {
 				Sequences: []*workflow.Sequence{
 					Sequence(ctx, sequence, WithKeepState()),
 				},
-				Concurrency:       1,
-				ToleratedFailures: 1,
+				Concurrency:             1,
+				ToleratedFailures:       1,
+				ToleratedFailurePercent: 25.5,
+				ToleratedFailureSample:  4,
 			},
 		},
 		{
@@ -255,6 +631,9 @@ func TestBlock(t *testing.T) {
 				Descr:         "descr",
 				EntranceDelay: 1 * time.Second,
 				ExitDelay:     1 * time.Second,
+				Timeout:       1 * time.Minute,
+				Locks:         &workflow.Locks{Names: []string{"host/abc"}, Policy: workflow.LPFail},
+				Ramp:          &workflow.Ramp{Steps: []int{1, 2}, Successes: 3},
 				PreChecks: &workflow.Checks{
 					State: &workflow.State{},
 					Actions: []*workflow.Action{
@@ -276,8 +655,10 @@ func TestBlock(t *testing.T) {
 				Sequences: []*workflow.Sequence{
 					Sequence(ctx, sequence, WithKeepState()),
 				},
-				Concurrency:       1,
-				ToleratedFailures: 1,
+				Concurrency:             1,
+				ToleratedFailures:       1,
+				ToleratedFailurePercent: 25.5,
+				ToleratedFailureSample:  4,
 				State: &workflow.State{
 					Status: workflow.Completed,
 				},
@@ -292,6 +673,9 @@ func TestBlock(t *testing.T) {
 				Descr:         "descr",
 				EntranceDelay: 1 * time.Second,
 				ExitDelay:     1 * time.Second,
+				Timeout:       1 * time.Minute,
+				Locks:         &workflow.Locks{Names: []string{"host/abc"}, Policy: workflow.LPFail},
+				Ramp:          &workflow.Ramp{Steps: []int{1, 2}, Successes: 3},
 				PreChecks: &workflow.Checks{
 					Actions: []*workflow.Action{
 						action,
@@ -310,8 +694,10 @@ func TestBlock(t *testing.T) {
 				Sequences: []*workflow.Sequence{
 					Sequence(ctx, sequence, WithKeepState(), WithKeepSecrets()),
 				},
-				Concurrency:       1,
-				ToleratedFailures: 1,
+				Concurrency:             1,
+				ToleratedFailures:       1,
+				ToleratedFailurePercent: 25.5,
+				ToleratedFailureSample:  4,
 			},
 		},
 	}
@@ -444,6 +830,11 @@ func TestSequence(t *testing.T) {
 				Req:  Req{Data: "Hello"},
 			},
 		},
+		Rollback: []*workflow.Action{
+			{
+				Name: "rollback1",
+			},
+		},
 		State: &workflow.State{
 			Status: workflow.Completed,
 		},
@@ -473,6 +864,11 @@ func TestSequence(t *testing.T) {
 						Req:  Req{Data: SecureStr},
 					},
 				},
+				Rollback: []*workflow.Action{
+					{
+						Name: "rollback1",
+					},
+				},
 			},
 		},
 		{
@@ -501,6 +897,11 @@ func TestSequence(t *testing.T) {
 						Req:  Req{"Hello"},
 					},
 				},
+				Rollback: []*workflow.Action{
+					{
+						Name: "rollback1",
+					},
+				},
 			},
 		},
 	}
@@ -543,8 +944,10 @@ func TestAction(t *testing.T) {
 		Req: Req{
 			Data: "hello",
 		},
-		Timeout: 10 * time.Second,
-		Retries: 2,
+		Timeout:     10 * time.Second,
+		Retries:     2,
+		RetryPolicy: &exponential.Policy{InitialInterval: time.Second, Multiplier: 2, MaxInterval: time.Minute},
+		MaxElapsed:  time.Hour,
 		Attempts: []*workflow.Attempt{
 			{Start: start},
 		},
@@ -576,8 +979,10 @@ func TestAction(t *testing.T) {
 				Req: Req{
 					Data: SecureStr,
 				},
-				Timeout: 10 * time.Second,
-				Retries: 2,
+				Timeout:     10 * time.Second,
+				Retries:     2,
+				RetryPolicy: &exponential.Policy{InitialInterval: time.Second, Multiplier: 2, MaxInterval: time.Minute},
+				MaxElapsed:  time.Hour,
 			},
 		},
 		{
@@ -599,8 +1004,10 @@ func TestAction(t *testing.T) {
 				Req: Req{
 					Data: SecureStr,
 				},
-				Timeout: 10 * time.Second,
-				Retries: 2,
+				Timeout:     10 * time.Second,
+				Retries:     2,
+				RetryPolicy: &exponential.Policy{InitialInterval: time.Second, Multiplier: 2, MaxInterval: time.Minute},
+				MaxElapsed:  time.Hour,
 				Attempts: []*workflow.Attempt{
 					{Start: start},
 				},
@@ -621,8 +1028,10 @@ func TestAction(t *testing.T) {
 				Req: Req{
 					Data: "hello",
 				},
-				Timeout: 10 * time.Second,
-				Retries: 2,
+				Timeout:     10 * time.Second,
+				Retries:     2,
+				RetryPolicy: &exponential.Policy{InitialInterval: time.Second, Multiplier: 2, MaxInterval: time.Minute},
+				MaxElapsed:  time.Hour,
 			},
 		},
 	}
@@ -730,8 +1139,10 @@ func TestCloneAttempts(t *testing.T) {
 						Message:   "not found",
 						Permanent: true,
 					},
-					Start: start,
-					End:   end,
+					Start:    start,
+					End:      end,
+					Progress: &workflow.Progress{Percent: 50, Msg: "working", Time: start},
+					Logs:     []workflow.LogRecord{{Time: start, Level: slog.LevelInfo, Msg: "working", Attrs: []workflow.LogAttr{{Key: "k", Value: "v"}}}},
 				},
 			},
 			want: []*workflow.Attempt{
@@ -754,8 +1165,10 @@ func TestCloneAttempts(t *testing.T) {
 						Message:   "not found",
 						Permanent: true,
 					},
-					Start: start,
-					End:   end,
+					Start:    start,
+					End:      end,
+					Progress: &workflow.Progress{Percent: 50, Msg: "working", Time: start},
+					Logs:     []workflow.LogRecord{{Time: start, Level: slog.LevelInfo, Msg: "working", Attrs: []workflow.LogAttr{{Key: "k", Value: "v"}}}},
 				},
 			},
 		},
@@ -7,6 +7,7 @@ import (
 	"io/fs"
 	"log"
 	"path/filepath"
+	"strings"
 	"time"
 	"unsafe"
 
@@ -45,9 +46,11 @@ func init() {
 					"banner":             banner,
 					"time":               timeOutput,
 					"statusColor":        statusColor,
+					"approvalColor":      approvalColor,
 					"mod":                mod,
 					"isZeroTime":         isZeroTime,
 					"jsonMarshal":        jsonMarshal,
+					"join":               strings.Join,
 				},
 			).Parse(string(tmplText))
 			if err != nil {
@@ -112,6 +115,9 @@ func calcCompleted[T supportedCalcs](v T) completed {
 		}
 		c.Total = len(x.Blocks)
 	case *workflow.Block:
+		if x.SubPlan != nil {
+			return calcCompleted(x.SubPlan)
+		}
 		for _, seq := range x.Sequences {
 			switch seq.State.Status {
 			case workflow.Running:
@@ -162,7 +168,9 @@ func calcCompleted[T supportedCalcs](v T) completed {
 	default:
 		panic("unsupported type")
 	}
-	c.Percent = (c.Completed * 100) / c.Total
+	if c.Total > 0 {
+		c.Percent = (c.Completed * 100) / c.Total
+	}
 	return c
 }
 
@@ -199,6 +207,19 @@ func statusColor(s workflow.Status) template.HTMLAttr {
 	}
 }
 
+func approvalColor(s workflow.ApprovalStatus) template.HTMLAttr {
+	switch s {
+	case workflow.ASNotRequested:
+		return template.HTMLAttr("gray")
+	case workflow.ASRejected, workflow.ASExpired:
+		return template.HTMLAttr("red")
+	case workflow.ASApproved:
+		return template.HTMLAttr("green")
+	default:
+		return template.HTMLAttr("blue")
+	}
+}
+
 func mod(a int) int {
 	return a % 2
 }
@@ -83,11 +83,23 @@ func Render(ctx context.Context, plan *workflow.Plan, options ...RenderOption) (
 		return nil, err
 	}
 
-	for item := range walk.Plan(ctx, plan) {
+	for item := range walk.Plan(ctx, plan, walk.WithSubPlans()) {
 		var b = bufferPool.Get()
 		defer bufferPool.Put(b)
 
 		switch item.Value.Type() {
+		case workflow.OTPlan:
+			// SubPlans are rendered beside the Plan, so that they share its sequences and actions directories.
+			sub := item.Plan()
+			if sub == plan {
+				continue
+			}
+			if err := embedded.Tmpls.ExecuteTemplate(b, "plan.tmpl", sub); err != nil {
+				return nil, err
+			}
+			if err := afero.WriteFile(fs, fmt.Sprintf("subplan_%s.html", sub.ID), b.Bytes(), 0644); err != nil {
+				return nil, err
+			}
 		case workflow.OTSequence:
 			seq := item.Sequence()
 			if err := embedded.Tmpls.ExecuteTemplate(b, "sequence.tmpl", seq); err != nil {
@@ -43,6 +43,34 @@ func Monitor(results <-chan coercion.Result[*workflow.Plan]) coercion.Result[*wo
 	return result
 }
 
+// MonitorEvents is a function that listens to the channel from Workstream.Events() and prints a line for each event.
+// Unlike Monitor, this does not need to read and diff the whole Plan.
+func MonitorEvents(events <-chan coercion.Result[workflow.Event]) coercion.Result[workflow.Event] {
+	var result coercion.Result[workflow.Event]
+	for result = range events {
+		if result.Err != nil {
+			panic(result.Err)
+		}
+		fmt.Println(eventLine(result.Data))
+	}
+	return result
+}
+
+func eventLine(ev workflow.Event) string {
+	buff := strings.Builder{}
+	buff.WriteString(fmt.Sprintf("%s %s(%s) %s -> %s", ev.Time.Format(time.RFC3339), ev.Type, ev.ID, ev.OldStatus, ev.NewStatus))
+	if ev.Type == workflow.OTAction && ev.Attempt > 0 {
+		buff.WriteString(fmt.Sprintf(" attempt %d", ev.Attempt))
+	}
+	if ev.Progress != nil {
+		buff.WriteString(" " + progressText(ev.Progress))
+	}
+	if ev.Err != nil {
+		color.New(color.FgRed).Fprintf(&buff, ": %s", ev.Err)
+	}
+	return buff.String()
+}
+
 func runningSummary(p *workflow.Plan) string {
 	if len(p.Blocks) == 0 {
 		return "no blocks defined"
@@ -130,11 +158,20 @@ func writeRunningActions(buff *strings.Builder, seq *workflow.Sequence) {
 	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
 	columnFmt := color.New(color.FgYellow).SprintfFunc()
 
-	tbl := table.New("Action Number", "Name", "Status").WithWriter(buff)
+	tbl := table.New("Action Number", "Name", "Status", "Progress").WithWriter(buff)
 	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
 
 	for i, action := range seq.Actions {
-		tbl.AddRow(i, action.Name, action.State.Status)
+		progress := ""
+		if a := action.FinalAttempt(); a != nil && a.Running() {
+			progress = progressText(a.Progress)
+		}
+		tbl.AddRow(i, action.Name, action.State.Status, progress)
 	}
 	tbl.Print()
 }
+
+// progressText formats the progress reported by a plugin.
+func progressText(p *workflow.Progress) string {
+	return fmt.Sprintf("%d%% %s (%s ago)", p.Percent, p.Msg, time.Since(p.Time).Round(time.Second))
+}
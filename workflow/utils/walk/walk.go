@@ -3,6 +3,7 @@ package walk
 
 import (
 	"context"
+	"slices"
 
 	"github.com/element-of-surprise/coercion/workflow"
 )
@@ -55,59 +56,86 @@ func (i Item) Action() *workflow.Action {
 	return i.Value.(*workflow.Action)
 }
 
+// Option is an option for Plan().
+type Option func(o walkOptions) walkOptions
+
+type walkOptions struct {
+	subPlans bool
+}
+
+// WithSubPlans descends into the SubPlan of each Block. A SubPlan is emitted after its Block's Generator,
+// where the Block's Sequences would be, and is followed by all of its objects. The Chain of an object in a
+// SubPlan starts with the top Plan and includes each Block and SubPlan that led to it.
+func WithSubPlans() Option {
+	return func(o walkOptions) walkOptions {
+		o.subPlans = true
+		return o
+	}
+}
+
 // Plan walks a *workflow.Plan for all objects in call order and emits the in the returned channel.
-// If the Context is canceled, the channel will be closed.
-func Plan(ctx context.Context, p *workflow.Plan) chan Item {
+// If the Context is canceled, the channel will be closed. SubPlans are not walked unless WithSubPlans()
+// is passed.
+func Plan(ctx context.Context, p *workflow.Plan, options ...Option) chan Item {
 	if p == nil {
 		ch := make(chan Item)
 		close(ch)
 		return ch
 	}
 
+	opts := walkOptions{}
+	for _, o := range options {
+		opts = o(opts)
+	}
+
 	ch := make(chan Item, 1)
 	go func() {
 		defer close(ch)
+		walkPlan(ctx, ch, nil, p, opts)
+	}()
+	return ch
+}
 
-		i := Item{Value: p}
-		if ok := emit(ctx, ch, i); !ok {
-			return
-		}
+func walkPlan(ctx context.Context, ch chan Item, chain []workflow.Object, p *workflow.Plan, opts walkOptions) (ok bool) {
+	i := Item{Chain: chain, Value: p}
+	if ok := emit(ctx, ch, i); !ok {
+		return false
+	}
 
-		chain := []workflow.Object{p}
-		if p.BypassChecks != nil {
-			if ok := walkChecks(ctx, ch, chain, p.BypassChecks); !ok {
-				return
-			}
+	chain = extend(chain, p)
+	if p.BypassChecks != nil {
+		if ok := walkChecks(ctx, ch, chain, p.BypassChecks); !ok {
+			return false
 		}
-		if p.PreChecks != nil {
-			if ok := walkChecks(ctx, ch, chain, p.PreChecks); !ok {
-				return
-			}
+	}
+	if p.PreChecks != nil {
+		if ok := walkChecks(ctx, ch, chain, p.PreChecks); !ok {
+			return false
 		}
-		if p.ContChecks != nil {
-			if ok := walkChecks(ctx, ch, chain, p.ContChecks); !ok {
-				return
-			}
+	}
+	if p.ContChecks != nil {
+		if ok := walkChecks(ctx, ch, chain, p.ContChecks); !ok {
+			return false
 		}
-		if p.Blocks != nil {
-			for _, block := range p.Blocks {
-				if ok := walkBlock(ctx, ch, chain, block); !ok {
-					return
-				}
+	}
+	if p.Blocks != nil {
+		for _, block := range p.Blocks {
+			if ok := walkBlock(ctx, ch, chain, block, opts); !ok {
+				return false
 			}
 		}
-		if p.PostChecks != nil {
-			if ok := walkChecks(ctx, ch, chain, p.PostChecks); !ok {
-				return
-			}
+	}
+	if p.PostChecks != nil {
+		if ok := walkChecks(ctx, ch, chain, p.PostChecks); !ok {
+			return false
 		}
-		if p.DeferredChecks != nil {
-			if ok := walkChecks(ctx, ch, chain, p.DeferredChecks); !ok {
-				return
-			}
+	}
+	if p.DeferredChecks != nil {
+		if ok := walkChecks(ctx, ch, chain, p.DeferredChecks); !ok {
+			return false
 		}
-	}()
-	return ch
+	}
+	return true
 }
 
 func walkChecks(ctx context.Context, ch chan Item, chain []workflow.Object, checks *workflow.Checks) (ok bool) {
@@ -116,7 +144,7 @@ func walkChecks(ctx context.Context, ch chan Item, chain []workflow.Object, chec
 		return false
 	}
 
-	chain = append(chain, checks)
+	chain = extend(chain, checks)
 	if checks.Actions != nil {
 		for _, action := range checks.Actions {
 			if ok := emit(ctx, ch, Item{Chain: chain, Value: action}); !ok {
@@ -127,13 +155,13 @@ func walkChecks(ctx context.Context, ch chan Item, chain []workflow.Object, chec
 	return true
 }
 
-func walkBlock(ctx context.Context, ch chan Item, chain []workflow.Object, block *workflow.Block) (ok bool) {
+func walkBlock(ctx context.Context, ch chan Item, chain []workflow.Object, block *workflow.Block, opts walkOptions) (ok bool) {
 	i := Item{Chain: chain, Value: block}
 	if ok := emit(ctx, ch, i); !ok {
 		return false
 	}
 
-	chain = append(chain, block)
+	chain = extend(chain, block)
 	if block.BypassChecks != nil {
 		if ok := walkChecks(ctx, ch, chain, block.BypassChecks); !ok {
 			return false
@@ -149,6 +177,16 @@ func walkBlock(ctx context.Context, ch chan Item, chain []workflow.Object, block
 			return false
 		}
 	}
+	if block.Generator != nil {
+		if ok := emit(ctx, ch, Item{Chain: chain, Value: block.Generator}); !ok {
+			return false
+		}
+	}
+	if block.SubPlan != nil && opts.subPlans {
+		if ok := walkPlan(ctx, ch, chain, block.SubPlan, opts); !ok {
+			return false
+		}
+	}
 
 	if block.Sequences != nil {
 		for _, sequence := range block.Sequences {
@@ -167,6 +205,11 @@ func walkBlock(ctx context.Context, ch chan Item, chain []workflow.Object, block
 			return false
 		}
 	}
+	for _, action := range block.Rollback {
+		if ok := emit(ctx, ch, Item{Chain: chain, Value: action}); !ok {
+			return false
+		}
+	}
 	return true
 }
 
@@ -176,7 +219,7 @@ func walkSequence(ctx context.Context, ch chan Item, chain []workflow.Object, se
 		return false
 	}
 
-	chain = append(chain, sequence)
+	chain = extend(chain, sequence)
 	if sequence.Actions != nil {
 		for _, action := range sequence.Actions {
 			if ok := emit(ctx, ch, Item{Chain: chain, Value: action}); !ok {
@@ -184,9 +227,20 @@ func walkSequence(ctx context.Context, ch chan Item, chain []workflow.Object, se
 			}
 		}
 	}
+	for _, action := range sequence.Rollback {
+		if ok := emit(ctx, ch, Item{Chain: chain, Value: action}); !ok {
+			return false
+		}
+	}
 	return true
 }
 
+// extend returns a new chain with o added to the end. The chain is always copied, as chains that share
+// an array would change the Chain of Items that have already been emitted.
+func extend(chain []workflow.Object, o workflow.Object) []workflow.Object {
+	return append(slices.Clip(chain), o)
+}
+
 // emit emits an Item to the channel unless the channel is blocke and the Context is canceled.
 // If the Context is canceled, emit returns false.
 func emit(ctx context.Context, ch chan Item, i Item) (ok bool) {
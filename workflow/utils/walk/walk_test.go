@@ -91,6 +91,8 @@ func TestPlan(t *testing.T) {
 						{Name: "plan_block_deferredcheck_action"},
 					},
 				},
+				Generator: &workflow.Action{Name: "plan_block_generator"},
+				Rollback:  []*workflow.Action{{Name: "plan_block_rollback"}},
 				Sequences: []*workflow.Sequence{
 					{
 						Name:  "plan_block_sequence",
@@ -101,6 +103,7 @@ func TestPlan(t *testing.T) {
 								Descr: "plan_block_action",
 							},
 						},
+						Rollback: []*workflow.Action{{Name: "plan_block_sequence_rollback"}},
 					},
 				},
 			},
@@ -128,12 +131,15 @@ func TestPlan(t *testing.T) {
 		{Chain: []workflow.Object{plan, plan.Blocks[0], plan.Blocks[0].PreChecks}, Value: plan.Blocks[0].PreChecks.Actions[0]},
 		{Chain: []workflow.Object{plan, plan.Blocks[0]}, Value: plan.Blocks[0].ContChecks},
 		{Chain: []workflow.Object{plan, plan.Blocks[0], plan.Blocks[0].ContChecks}, Value: plan.Blocks[0].ContChecks.Actions[0]},
+		{Chain: []workflow.Object{plan, plan.Blocks[0]}, Value: plan.Blocks[0].Generator},
 		{Chain: []workflow.Object{plan, plan.Blocks[0]}, Value: plan.Blocks[0].Sequences[0]},
 		{Chain: []workflow.Object{plan, plan.Blocks[0], plan.Blocks[0].Sequences[0]}, Value: plan.Blocks[0].Sequences[0].Actions[0]},
+		{Chain: []workflow.Object{plan, plan.Blocks[0], plan.Blocks[0].Sequences[0]}, Value: plan.Blocks[0].Sequences[0].Rollback[0]},
 		{Chain: []workflow.Object{plan, plan.Blocks[0]}, Value: plan.Blocks[0].PostChecks},
 		{Chain: []workflow.Object{plan, plan.Blocks[0], plan.Blocks[0].PostChecks}, Value: plan.Blocks[0].PostChecks.Actions[0]},
 		{Chain: []workflow.Object{plan, plan.Blocks[0]}, Value: plan.Blocks[0].DeferredChecks},
 		{Chain: []workflow.Object{plan, plan.Blocks[0], plan.Blocks[0].DeferredChecks}, Value: plan.Blocks[0].DeferredChecks.Actions[0]},
+		{Chain: []workflow.Object{plan, plan.Blocks[0]}, Value: plan.Blocks[0].Rollback[0]},
 		{Chain: []workflow.Object{plan}, Value: plan.PostChecks},
 		{Chain: []workflow.Object{plan, plan.PostChecks}, Value: plan.PostChecks.Actions[0]},
 		{Chain: []workflow.Object{plan}, Value: plan.DeferredChecks},
@@ -149,3 +155,68 @@ func TestPlan(t *testing.T) {
 		t.Errorf("TestPlan: -want, +got:\n%s", diff)
 	}
 }
+
+func TestPlanSubPlans(t *testing.T) {
+	subPlan := func(name string) *workflow.Plan {
+		return &workflow.Plan{
+			Name: name,
+			Blocks: []*workflow.Block{
+				{
+					Name:      name + "_block",
+					Sequences: []*workflow.Sequence{{Name: name + "_sequence"}},
+				},
+				{
+					Name:      name + "_block2",
+					Sequences: []*workflow.Sequence{{Name: name + "_sequence2"}},
+				},
+			},
+		}
+	}
+	plan := &workflow.Plan{
+		Name: "plan",
+		Blocks: []*workflow.Block{
+			{Name: "block0", SubPlan: subPlan("sub0")},
+			{Name: "block1", SubPlan: subPlan("sub1")},
+		},
+	}
+	b0, b1 := plan.Blocks[0], plan.Blocks[1]
+
+	pConfig := pretty.Config{
+		IncludeUnexported: false,
+		PrintStringers:    true,
+	}
+
+	got := []Item{}
+	for item := range Plan(context.Background(), plan) {
+		got = append(got, item)
+	}
+	want := []Item{
+		{Value: plan},
+		{Chain: []workflow.Object{plan}, Value: b0},
+		{Chain: []workflow.Object{plan}, Value: b1},
+	}
+	if diff := pConfig.Compare(want, got); diff != "" {
+		t.Errorf("TestPlanSubPlans(without WithSubPlans): -want, +got:\n%s", diff)
+	}
+
+	got = []Item{}
+	for item := range Plan(context.Background(), plan, WithSubPlans()) {
+		got = append(got, item)
+	}
+	want = []Item{{Value: plan}}
+	for _, b := range []*workflow.Block{b0, b1} {
+		s := b.SubPlan
+		want = append(
+			want,
+			Item{Chain: []workflow.Object{plan}, Value: b},
+			Item{Chain: []workflow.Object{plan, b}, Value: s},
+			Item{Chain: []workflow.Object{plan, b, s}, Value: s.Blocks[0]},
+			Item{Chain: []workflow.Object{plan, b, s, s.Blocks[0]}, Value: s.Blocks[0].Sequences[0]},
+			Item{Chain: []workflow.Object{plan, b, s}, Value: s.Blocks[1]},
+			Item{Chain: []workflow.Object{plan, b, s, s.Blocks[1]}, Value: s.Blocks[1].Sequences[0]},
+		)
+	}
+	if diff := pConfig.Compare(want, got); diff != "" {
+		t.Errorf("TestPlanSubPlans(WithSubPlans): -want, +got:\n%s", diff)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +16,7 @@ import (
 	"github.com/element-of-surprise/coercion/plugins/registry"
 
 	"github.com/google/uuid"
+	"github.com/gostdlib/base/retry/exponential"
 )
 
 //go:generate stringer -type=Status
@@ -61,6 +64,45 @@ const (
 	// FRExceedRecovery represents a failure reason that occurred because the last update for
 	// a workflow was too long ago to do a recovery.
 	FRExceedRecovery FailureReason = 600 // ExceedRecovery
+	// FRApproval represents a failure reason that occurred because an Approval was rejected
+	// or expired.
+	FRApproval FailureReason = 700 // Approval
+	// FRTimeout represents a failure reason that occurred because the Plan or one of its Blocks
+	// ran longer than its Timeout.
+	FRTimeout FailureReason = 800 // Timeout
+	// FRLocked represents a failure reason that occurred because a lock the Plan or one of its Blocks
+	// or Sequences needed was held by another object and its LockPolicy was LPFail.
+	FRLocked FailureReason = 900 // Locked
+)
+
+//go:generate stringer -type=ApprovalStatus
+
+// ApprovalStatus represents the status of an Approval.
+type ApprovalStatus int
+
+const (
+	// ASNotRequested represents an Approval that has not been requested yet.
+	ASNotRequested ApprovalStatus = 0 // NotRequested
+	// ASWaiting represents an Approval that the Plan is waiting on.
+	ASWaiting ApprovalStatus = 100 // Waiting
+	// ASApproved represents an Approval that was approved.
+	ASApproved ApprovalStatus = 200 // Approved
+	// ASRejected represents an Approval that was rejected.
+	ASRejected ApprovalStatus = 300 // Rejected
+	// ASExpired represents an Approval that was not decided before its Timeout.
+	ASExpired ApprovalStatus = 400 // Expired
+)
+
+//go:generate stringer -type=LockPolicy
+
+// LockPolicy is what an object does when a lock it needs is held by another object.
+type LockPolicy int
+
+const (
+	// LPWait waits until all the locks can be acquired. This is the default.
+	LPWait LockPolicy = 0 // Wait
+	// LPFail fails the object if any lock is held by another object.
+	LPFail LockPolicy = 100 // Fail
 )
 
 // State represents the internal state of a workflow object.
@@ -135,6 +177,12 @@ type Plan struct {
 	// GroupID is a unique identifier for a group of workflows. This is used to group
 	// workflows together for informational purposes. This is not required.
 	GroupID uuid.UUID
+	// ParentID is the ID of the Plan that this Plan retries. This is set by Workstream.Retry() and
+	// links a retry back to the Plan it was created from. This is not required.
+	ParentID uuid.UUID
+	// CallerID is the ID of the Plan that runs this Plan as the SubPlan of one of its Blocks. This is set
+	// on submission. A Plan with a CallerID can only be run by its caller. Should not be set by the user.
+	CallerID uuid.UUID
 	// Meta is any type of metadata that the user wants to store with the workflow.
 	// This is not used by the workflow engine. Optional.
 	Meta []byte
@@ -156,10 +204,24 @@ type Plan struct {
 	// Useful for logging and similar operations. Optional.
 	DeferredChecks *Checks
 
-	// Blocks is a list of blocks that are executed in sequence.
-	// If a block fails, the workflow will fail.
-	// Only one block can be executed at a time. Required.
+	// Blocks is a list of blocks that are executed in sequence, unless a Block has DependsOn set or
+	// BlockConcurrency is greater than 1. If a block fails, the workflow will fail. Required.
 	Blocks []*Block
+	// BlockConcurrency is the number of Blocks that can run at the same time. A Block starts once all the
+	// Blocks in its DependsOn have completed, so Blocks without DependsOn can run in parallel when this
+	// is greater than 1. This defaults to 1.
+	BlockConcurrency int
+
+	// Timeout is the maximum amount of time the Plan can run, measured from when it starts. When it
+	// is exceeded, running Actions are cancelled, no new Actions are started, unstarted objects are Stopped and the
+	// Plan fails with FRTimeout. DeferredChecks still run. This defaults to 0, which is no timeout.
+	Timeout time.Duration
+	// Locks are resources the Plan holds from when it starts until it ends. Optional.
+	Locks *Locks
+	// Priority orders Plans that are waiting to start because the Workstream is running its maximum number
+	// of Plans. Higher priorities start first, Plans with the same priority start in the order they were queued.
+	// This defaults to 0. Optional.
+	Priority int
 
 	// State is the internal state of the object. Should not be set by the user.
 	State *State
@@ -169,6 +231,164 @@ type Plan struct {
 	// Reason is the reason that the object failed.
 	// This will be set to FRUnknown if not in a failed state.
 	Reason FailureReason
+	// Paused is set when the Plan is paused and is waiting to be resumed. A paused Plan
+	// does not start new Blocks or Sequences. Should not be set by the user.
+	Paused bool
+	// Drained is set when the Plan was running in a Workstream that was closed with a drain. Running Actions
+	// finished and the Plan's state was written before the Workstream closed, so recovery resumes a drained
+	// Plan regardless of its last update. This is cleared when the Plan is recovered. Should not be set by the user.
+	Drained bool
+	// StartAt is the time the Plan is scheduled to start. This is set by Workstream.Schedule() and
+	// Workstream.SubmitRecurring(). Should not be set by the user.
+	StartAt time.Time
+	// Recurring is the cron spec of a recurring Plan. When a Plan with Recurring set starts, the next
+	// Plan is submitted and scheduled for the next time in the spec. This is set by Workstream.SubmitRecurring().
+	// Should not be set by the user.
+	Recurring string
+	// QueuedAt is the time the Plan was started and queued because the Workstream was running its maximum
+	// number of Plans. This is zero once the Plan leaves the queue. Should not be set by the user.
+	QueuedAt time.Time
+	// TraceID is the OpenTelemetry trace ID of the Plan's first run. When the Plan is recovered, the spans
+	// of the new run are linked to this trace. Should not be set by the user.
+	TraceID string
+	// Approval, if set, must be approved before the first Block is started. Optional.
+	Approval *Approval
+}
+
+// Approval is a requirement for a human to sign off before execution continues. An Approval on a Plan
+// must be approved before the first Block starts. An Approval on a Block must be approved before that
+// Block starts. If the Approval is rejected or expires, the Plan fails with FRApproval.
+type Approval struct {
+	// Timeout is the amount of time to wait for a decision. If no decision is made before the
+	// Timeout, the Approval expires. If 0, there is no timeout. Optional.
+	Timeout time.Duration
+
+	// Status is the status of the Approval. Should not be set by the user.
+	Status ApprovalStatus
+	// Approver is who approved or rejected the Approval. Should not be set by the user.
+	Approver string
+	// Comment is a comment the approver gave with their decision. Should not be set by the user.
+	Comment string
+	// Requested is the time the Plan started waiting on the Approval. Should not be set by the user.
+	Requested time.Time
+	// Decided is the time the Approval was approved, rejected or expired. Should not be set by the user.
+	Decided time.Time
+}
+
+// Locks are named resources, such as "cluster/east-1" or "host/abc", that an object holds while it runs.
+// No two running objects, in this or any other Plan that shares the same storage, can hold the same lock
+// at the same time. Locks are acquired all at once before the object starts and released when it ends.
+// An object cannot lock a name that one of its parents also locks.
+type Locks struct {
+	// Names are the names of the resources to lock. Required.
+	Names []string
+	// Policy is what to do when another object holds one of the locks. Defaults to LPWait.
+	Policy LockPolicy
+}
+
+// validate validates the Locks. A nil Locks is valid.
+func (l *Locks) validate() error {
+	if l == nil {
+		return nil
+	}
+	if len(l.Names) == 0 {
+		return fmt.Errorf("locks must have at least one name")
+	}
+	seen := make(map[string]bool, len(l.Names))
+	for _, n := range l.Names {
+		if strings.TrimSpace(n) == "" {
+			return fmt.Errorf("lock names cannot be empty")
+		}
+		if seen[n] {
+			return fmt.Errorf("lock name(%s) is listed more than once", n)
+		}
+		seen[n] = true
+	}
+	switch l.Policy {
+	case LPWait, LPFail:
+	default:
+		return fmt.Errorf("lock policy(%v) is not valid", l.Policy)
+	}
+	return nil
+}
+
+// lockNames returns the lock names in l. A nil Locks returns nil.
+func (l *Locks) lockNames() []string {
+	if l == nil {
+		return nil
+	}
+	return l.Names
+}
+
+// nestedLocks returns an error if a Block or Sequence locks a name that its Plan or Block also locks.
+// The same owner cannot hold a lock twice, so this would never be able to run.
+func (p *Plan) nestedLocks() error {
+	held := map[string]bool{}
+	for _, n := range p.Locks.lockNames() {
+		held[n] = true
+	}
+	for _, b := range p.Blocks {
+		if b == nil {
+			continue
+		}
+		for _, n := range b.Locks.lockNames() {
+			if held[n] {
+				return fmt.Errorf("block(%s) locks %q, which is already locked by its plan", b.Name, n)
+			}
+		}
+		for _, n := range b.SubPlan.allLockNames() {
+			if held[n] || slices.Contains(b.Locks.lockNames(), n) {
+				return fmt.Errorf("block(%s) has a sub-plan that locks %q, which is already locked by its plan or block", b.Name, n)
+			}
+		}
+		for _, s := range b.Sequences {
+			if s == nil {
+				continue
+			}
+			for _, n := range s.Locks.lockNames() {
+				if held[n] || slices.Contains(b.Locks.lockNames(), n) {
+					return fmt.Errorf("sequence(%s) locks %q, which is already locked by its plan or block", s.Name, n)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// allLockNames returns the names of all locks in the Plan, its Blocks, Sequences and SubPlans.
+// A nil Plan returns nil.
+func (p *Plan) allLockNames() []string {
+	if p == nil {
+		return nil
+	}
+	names := slices.Clone(p.Locks.lockNames())
+	for _, b := range p.Blocks {
+		if b == nil {
+			continue
+		}
+		names = append(names, b.Locks.lockNames()...)
+		for _, s := range b.Sequences {
+			if s != nil {
+				names = append(names, s.Locks.lockNames()...)
+			}
+		}
+		names = append(names, b.SubPlan.allLockNames()...)
+	}
+	return names
+}
+
+// validate validates the Approval. A nil Approval is valid.
+func (a *Approval) validate() error {
+	if a == nil {
+		return nil
+	}
+	if a.Timeout < 0 {
+		return fmt.Errorf("approval timeout cannot be negative")
+	}
+	if a.Status != ASNotRequested || a.Approver != "" || a.Comment != "" || !a.Requested.IsZero() || !a.Decided.IsZero() {
+		return fmt.Errorf("approval internal settings should not be set by the user")
+	}
+	return nil
 }
 
 // GetID returns the ID of the object.
@@ -208,6 +428,9 @@ func (p *Plan) Defaults() {
 		return
 	}
 	p.ID = NewV7()
+	if p.BlockConcurrency < 1 {
+		p.BlockConcurrency = 1
+	}
 	p.State = &State{
 		Status: NotStarted,
 	}
@@ -239,6 +462,48 @@ func (p *Plan) validate(ctx context.Context) ([]validator, error) {
 	if !p.SubmitTime.IsZero() {
 		return nil, fmt.Errorf("submit time should not be set by the user")
 	}
+	if p.Paused {
+		return nil, fmt.Errorf("paused should not be set by the user")
+	}
+	if p.Drained {
+		return nil, fmt.Errorf("drained should not be set by the user")
+	}
+	if !p.StartAt.IsZero() {
+		return nil, fmt.Errorf("start at should not be set by the user")
+	}
+	if p.Recurring != "" {
+		return nil, fmt.Errorf("recurring should not be set by the user")
+	}
+	if !p.QueuedAt.IsZero() {
+		return nil, fmt.Errorf("queued at should not be set by the user")
+	}
+	if p.TraceID != "" {
+		return nil, fmt.Errorf("trace id should not be set by the user")
+	}
+	if p.CallerID != uuid.Nil {
+		return nil, fmt.Errorf("caller id should not be set by the user")
+	}
+	if p.Timeout < 0 {
+		return nil, fmt.Errorf("timeout cannot be negative")
+	}
+	if p.BlockConcurrency < 0 {
+		return nil, fmt.Errorf("block concurrency cannot be negative")
+	}
+	if err := p.Approval.validate(); err != nil {
+		return nil, err
+	}
+	if err := p.Locks.validate(); err != nil {
+		return nil, err
+	}
+	if err := p.nestedLocks(); err != nil {
+		return nil, err
+	}
+	if err := p.validateDependsOn(); err != nil {
+		return nil, err
+	}
+	if err := p.validateRefs(); err != nil {
+		return nil, err
+	}
 
 	vals := []validator{p.BypassChecks, p.PreChecks, p.ContChecks, p.PostChecks, p.DeferredChecks}
 	for _, b := range p.Blocks {
@@ -348,8 +613,8 @@ func (c *Checks) validate(ctx context.Context) ([]validator, error) {
 }
 
 // Block represents a set of replated work. It contains a list of sequences that are executed with
-// a configurable amount of concurrency. If a block fails, the workflow will fail. Only one block
-// can be executed at a time.
+// a configurable amount of concurrency. If a block fails, the workflow will fail. Blocks run one at a
+// time unless the Plan's BlockConcurrency is greater than 1.
 type Block struct {
 	// ID is a unique identifier for the object. Should not be set by the user.
 	ID uuid.UUID
@@ -360,11 +625,20 @@ type Block struct {
 	Name string
 	// Descr is a description of the block. Required.
 	Descr string
+	// DependsOn are the Keys of Blocks in the same Plan that must complete before this block starts. If one
+	// of them fails, this block is not started. When any Block in a Plan has DependsOn, Blocks start as soon
+	// as their dependencies complete instead of in the order of Plan.Blocks. Optional.
+	DependsOn []uuid.UUID
 
 	// EntranceDelay is the amount of time to wait before the block starts. This defaults to 0.
 	EntranceDelay time.Duration
 	// ExitDelay is the amount of time to wait after the block has completed. This defaults to 0.
 	ExitDelay time.Duration
+	// Timeout is the maximum amount of time the block can run, including the EntranceDelay and ExitDelay.
+	// When it is exceeded, running Actions in the block are cancelled, no new Actions are started in the block,
+	// the block fails and the Plan fails with FRTimeout. This cannot be shorter than EntranceDelay and ExitDelay
+	// combined. This defaults to 0, which is no timeout.
+	Timeout time.Duration
 
 	// BypassChecks are actions that if they succeed will cause the block to be skipped.
 	// If any gate fails, the workflow will be executed. Optional.
@@ -383,14 +657,48 @@ type Block struct {
 	// Useful for logging and similar operations. Optional.
 	DeferredChecks *Checks
 
-	// Sequences is a list of sequences that are executed. Required..
+	// Sequences is a list of sequences that are executed. Required unless Generator or SubPlan is set.
 	Sequences []*Sequence
+	// Generator is an Action whose plugin generates Sequences when the block runs. This is useful when the
+	// targets of the block are only known at execution time. It runs after the PreChecks pass and its plugin's
+	// response must implement Generated. The Sequences it returns are added after Sequences and are run under
+	// the block's Concurrency and ToleratedFailures. Optional.
+	Generator *Action
+	// Rollback is a list of actions that are executed in order when the block fails after its Sequences
+	// have started. Before it runs, the Rollback of each Completed Sequence is run in the reverse order the
+	// Sequences completed in. The first failing Action stops all of these. A Rollback is not run when the
+	// block is Stopped. Optional.
+	Rollback []*Action
+	// SubPlan is a Plan that the block runs in place of Sequences. This allows a reusable Plan to be a step
+	// of a larger Plan. The SubPlan is submitted with the Plan and is stored as its own Plan, with its CallerID
+	// set to the Plan's ID. It runs after the block's PreChecks pass and the block fails if the SubPlan fails.
+	// A block with a SubPlan cannot have Sequences, a Generator or a Rollback. Keys must be unique across the
+	// Plan and its SubPlans. Optional.
+	SubPlan *Plan
 
 	// Concurrency is the number of sequences that are executed in parallel. This defaults to 1.
 	Concurrency int
+	// Ramp, if set, starts the block at a lower concurrency and steps it up to Concurrency as Sequences
+	// succeed. A failed Sequence returns the block to the first step. Optional.
+	Ramp *Ramp
 	// ToleratedFailures is the number of sequences that are allowed to fail before the block fails. This defaults to 0.
 	// If set to -1, all sequences are allowed to fail.
 	ToleratedFailures int
+	// ToleratedFailurePercent is the percentage, from 0 to 100, of the block's sequences that are allowed to fail
+	// before the block fails. Unlike ToleratedFailures, this scales with the number of sequences. Only one of
+	// ToleratedFailures and ToleratedFailurePercent can be set. Optional.
+	ToleratedFailurePercent float64
+	// ToleratedFailureSample, if set, evaluates ToleratedFailurePercent against the sequences started so far
+	// once at least this many have started, instead of against all of the block's sequences. This fails the
+	// block early when too many of its first sequences fail. Requires ToleratedFailurePercent. Optional.
+	ToleratedFailureSample int
+
+	// Approval, if set, must be approved before the block is started. This allows a human to sign off
+	// after the previous block has completed. Optional.
+	Approval *Approval
+	// Locks are resources the block holds while it runs. They are acquired after the Approval and before
+	// the EntranceDelay, and released after the ExitDelay. Optional.
+	Locks *Locks
 
 	// State represents settings that should not be set by the user, but users can query.
 	State *State
@@ -478,17 +786,81 @@ func (b *Block) validate(ctx context.Context) ([]validator, error) {
 		return nil, fmt.Errorf("internal settings should not be set by the user")
 	}
 
-	if len(b.Sequences) == 0 {
-		return nil, fmt.Errorf("at least one sequence is required")
+	switch {
+	case b.Timeout < 0:
+		return nil, fmt.Errorf("timeout cannot be negative")
+	case b.Timeout > 0 && b.Timeout < b.EntranceDelay+b.ExitDelay:
+		return nil, fmt.Errorf(
+			"timeout(%v) cannot be shorter than the entrance delay(%v) plus the exit delay(%v)",
+			b.Timeout, b.EntranceDelay, b.ExitDelay,
+		)
+	}
+
+	if err := b.Approval.validate(); err != nil {
+		return nil, err
+	}
+	if err := b.Locks.validate(); err != nil {
+		return nil, err
+	}
+	if err := b.Ramp.validate(max(b.Concurrency, 1)); err != nil {
+		return nil, err
+	}
+	switch {
+	case b.ToleratedFailurePercent < 0 || b.ToleratedFailurePercent > 100:
+		return nil, fmt.Errorf("tolerated failure percent(%v) must be between 0 and 100", b.ToleratedFailurePercent)
+	case b.ToleratedFailurePercent > 0 && b.ToleratedFailures != 0:
+		return nil, fmt.Errorf("only one of tolerated failures and tolerated failure percent can be set")
+	case b.ToleratedFailureSample < 0:
+		return nil, fmt.Errorf("tolerated failure sample cannot be negative")
+	case b.ToleratedFailureSample > 0 && b.ToleratedFailurePercent == 0:
+		return nil, fmt.Errorf("tolerated failure sample requires a tolerated failure percent")
+	}
+
+	if b.SubPlan != nil {
+		switch {
+		case len(b.Sequences) > 0:
+			return nil, fmt.Errorf("block(%s) cannot have sequences and a sub-plan", b.Name)
+		case b.Generator != nil:
+			return nil, fmt.Errorf("block(%s) cannot have a generator and a sub-plan", b.Name)
+		case len(b.Rollback) > 0:
+			return nil, fmt.Errorf("block(%s) cannot have a rollback and a sub-plan", b.Name)
+		}
+	} else if len(b.Sequences) == 0 && b.Generator == nil {
+		return nil, fmt.Errorf("at least one sequence, a generator or a sub-plan is required")
+	}
+	if err := b.Generator.validateGenerator(); err != nil {
+		return nil, err
 	}
 
 	vals := []validator{b.BypassChecks, b.PreChecks, b.ContChecks, b.PostChecks, b.DeferredChecks}
+	if b.SubPlan != nil {
+		vals = append(vals, b.SubPlan)
+	}
+	if b.Generator != nil {
+		vals = append(vals, b.Generator)
+	}
 	for _, seq := range b.Sequences {
 		vals = append(vals, seq)
 	}
+	for _, a := range b.Rollback {
+		vals = append(vals, a)
+	}
 	return vals, nil
 }
 
+// ExceedsToleratedFailures returns true if failed sequences are more than the block tolerates. started is the
+// number of the block's sequences that have been started, including those that have finished.
+func (b *Block) ExceedsToleratedFailures(failed, started int) bool {
+	if b.ToleratedFailurePercent == 0 {
+		return b.ToleratedFailures >= 0 && failed > b.ToleratedFailures
+	}
+	total := len(b.Sequences)
+	if b.ToleratedFailureSample > 0 && started >= b.ToleratedFailureSample {
+		total = started
+	}
+	return float64(failed) > b.ToleratedFailurePercent/100*float64(total)
+}
+
 // Sequence represents a set of Actions that are executed in sequence. Any error will cause the workflow to fail.
 type Sequence struct {
 	// ID is a unique identifier for the object. Should not be set by the user.
@@ -502,6 +874,18 @@ type Sequence struct {
 	Descr string
 	// Actions is a list of actions that are executed in sequence. Any error will cause the workflow to fail. Required.
 	Actions []*Action
+	// Timeout is the maximum amount of time the sequence can run. When it is exceeded, the running Action is
+	// cancelled, no new Actions are started and the sequence fails. This counts against the Block's ToleratedFailures like any other failure. If the block
+	// fails because of it, the Plan fails with FRTimeout. This defaults to 0, which is no timeout.
+	Timeout time.Duration
+	// Locks are resources the sequence holds while it runs. A sequence that fails to get its locks counts
+	// against the Block's ToleratedFailures. Optional.
+	Locks *Locks
+	// Rollback is a list of actions that are executed in order when the sequence fails after one of its
+	// Actions has started. It is also run if the sequence Completed and its Block later fails. The first
+	// failing Action stops the rollback, leaving the rest NotStarted. A Rollback is not run when the
+	// sequence is Stopped. Optional.
+	Rollback []*Action
 
 	// State represents settings that should not be set by the user, but users can query.
 	State *State
@@ -583,15 +967,24 @@ func (s *Sequence) validate(ctx context.Context) ([]validator, error) {
 	if s.State != nil {
 		return nil, fmt.Errorf("internal settings should not be set by the user")
 	}
+	if s.Timeout < 0 {
+		return nil, fmt.Errorf("timeout cannot be negative")
+	}
+	if err := s.Locks.validate(); err != nil {
+		return nil, err
+	}
 
 	if len(s.Actions) == 0 {
 		return nil, fmt.Errorf("at least one Action is required")
 	}
 
-	vals := make([]validator, 0, len(s.Actions))
+	vals := make([]validator, 0, len(s.Actions)+len(s.Rollback))
 	for _, a := range s.Actions {
 		vals = append(vals, a)
 	}
+	for _, a := range s.Rollback {
+		vals = append(vals, a)
+	}
 	return vals, nil
 }
 
@@ -607,6 +1000,82 @@ type Attempt struct {
 	Start time.Time
 	// End is the time the attempt ended.
 	End time.Time
+	// DryRun is true if the attempt was made during a dry run. Resp is what the plugin reported it would do
+	// if it implements plugins.DryRunner. Otherwise Resp is nil and the plugin would have been executed.
+	DryRun bool
+	// SlotWait is the time the attempt waited for a slot to execute a plugin that has a concurrency limit.
+	// This time is before Start. See plugins.ConcurrencyLimiter.
+	SlotWait time.Duration
+	// Progress is the last progress the plugin reported during the attempt. This is nil if the plugin
+	// did not report progress. See context.Progress().
+	Progress *Progress
+	// Logs are the log records the plugin emitted with context.Log() during the attempt.
+	Logs []LogRecord `json:",omitempty"`
+	// LogsDropped is the number of log records that were not kept in Logs because of the size limits.
+	LogsDropped int
+}
+
+// Running returns true if the Attempt has not finished. A running Attempt is only written to storage when
+// its plugin reports progress, so that a long running Action can be seen to be moving.
+func (a *Attempt) Running() bool {
+	return a.End.IsZero() && a.Progress != nil
+}
+
+// LogRecord is a log record that a plugin emitted during an Attempt. Nothing in LogRecord should be set by the user.
+type LogRecord struct {
+	// Time is when the record was emitted.
+	Time time.Time
+	// Level is the level of the record.
+	Level slog.Level
+	// Msg is the log message.
+	Msg string
+	// Attrs are the attributes of the record. Keys in a group are prefixed with the group name and a dot.
+	Attrs []LogAttr
+}
+
+// LogAttr is an attribute of a LogRecord.
+type LogAttr struct {
+	// Key is the key of the attribute.
+	Key string
+	// Value is the value of the attribute, formatted as a string.
+	Value string
+}
+
+// Progress is a heartbeat that a plugin reports while it executes. Nothing in Progress should be set by the user.
+type Progress struct {
+	// Percent is how much of the work is done, from 0 to 100.
+	Percent int
+	// Msg describes what the plugin is doing.
+	Msg string
+	// Time is when the progress was reported.
+	Time time.Time
+}
+
+// Event is emitted when an object in a running Plan changes Status, an Action makes an Attempt or
+// the plugin running an Action reports progress. Nothing in Event should be set by the user.
+type Event struct {
+	// PlanID is the ID of the Plan the object belongs to.
+	PlanID uuid.UUID
+	// Type is the type of the object.
+	Type ObjectType
+	// ID is the ID of the object.
+	ID uuid.UUID
+	// Key is the Key of the object. This is uuid.Nil for Plans and objects without a Key.
+	Key uuid.UUID
+	// OldStatus is the Status of the object before the event.
+	OldStatus Status
+	// NewStatus is the Status of the object after the event. This can be the same as OldStatus
+	// when an Action records a new Attempt.
+	NewStatus Status
+	// Attempt is the number of Attempts an Action has made. This is 0 for other objects.
+	Attempt int
+	// Err is the error of the last Attempt of an Action, if it failed.
+	Err *plugins.Error
+	// Progress is set when the event reports progress from the plugin running an Action's Attempt.
+	// NewStatus is the same as OldStatus and Attempt includes the running Attempt.
+	Progress *Progress
+	// Time is when the event occurred.
+	Time time.Time
 }
 
 // Action represents a single action that is executed by a plugin.
@@ -627,8 +1096,18 @@ type Action struct {
 	Timeout time.Duration
 	// Retries is the number of times to retry the Action if it fails. This defaults to 0.
 	Retries int
+	// RetryPolicy overrides the plugin's RetryPolicy() for this Action. This allows the same plugin to use
+	// fast retries in one Action and long waits in another. Optional.
+	RetryPolicy *exponential.Policy
+	// MaxElapsed is the maximum amount of time from the start of the Action to the start of its last attempt.
+	// Once another attempt would start after this, the Action fails. This defaults to 0, which has no limit.
+	MaxElapsed time.Duration
 	// Req is the request object that is passed to the plugin.
 	Req any
+	// Refs set fields in Req to values from the responses of earlier Actions just before the Action is executed.
+	// If there are Refs, the plugin validates Req after they are set instead of when the Plan is submitted.
+	// Refs are only supported on Actions in a Sequence. Optional.
+	Refs []Ref
 	// Attempts is the attempts of the action. This should not be set by the user.
 	Attempts []*Attempt
 	// State represents settings that should not be set by the user, but users can query.
@@ -741,6 +1220,14 @@ func (a *Action) validate(ctx context.Context) ([]validator, error) {
 	if a.Retries < 0 {
 		a.Retries = 0
 	}
+	if a.RetryPolicy != nil {
+		if err := registry.ValidatePolicy(*a.RetryPolicy); err != nil {
+			return nil, fmt.Errorf("retry policy is invalid: %w", err)
+		}
+	}
+	if a.MaxElapsed < 0 {
+		return nil, fmt.Errorf("max elapsed cannot be negative")
+	}
 
 	plug := a.register.Plugin(a.Plugin)
 
@@ -748,6 +1235,10 @@ func (a *Action) validate(ctx context.Context) ([]validator, error) {
 		return nil, fmt.Errorf("plugin %q not found", a.Plugin)
 	}
 
+	// Fields set by Refs are not known until the Action runs.
+	if len(a.Refs) > 0 {
+		return nil, nil
+	}
 	if err := plug.ValidateReq(a.Req); err != nil {
 		return nil, fmt.Errorf("plugin %q: %w", a.Plugin, err)
 	}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/element-of-surprise/coercion/plugins"
 	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/google/uuid"
 	"github.com/gostdlib/base/retry/exponential"
 
 	"github.com/kylelemons/godebug/pretty"
@@ -42,6 +44,12 @@ func TestPlanValidate(t *testing.T) {
 		expectVals = append(expectVals, v)
 	}
 
+	dagA, dagB := NewV7(), NewV7()
+	dagBlocks := []*Block{
+		{Name: "b", Key: dagB, DependsOn: []uuid.UUID{dagA}},
+		{Name: "a", Key: dagA},
+	}
+
 	tests := []struct {
 		name       string
 		plan       func() *Plan
@@ -71,6 +79,182 @@ func TestPlanValidate(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "Error: Approval Approver is set",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.Approval = &Approval{Approver: "someone"}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: StartAt is set",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.StartAt = time.Now()
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Recurring is set",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.Recurring = "@daily"
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Drained is set",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.Drained = true
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: TraceID is set",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.TraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: QueuedAt is set",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.QueuedAt = time.Now()
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Timeout is negative",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.Timeout = -1
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Locks has no names",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.Locks = &Locks{}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Block locks a name the Plan locks",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.Locks = &Locks{Names: []string{"cluster/east-1"}}
+				p.Blocks = []*Block{{Name: "block", Locks: &Locks{Names: []string{"cluster/east-1"}}}}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Sequence locks a name the Block locks",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.Blocks = []*Block{
+					{
+						Name:      "block",
+						Locks:     &Locks{Names: []string{"host/abc"}},
+						Sequences: []*Sequence{{Name: "seq", Locks: &Locks{Names: []string{"host/abc"}}}},
+					},
+				}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: CallerID is set",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.CallerID = NewV7()
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: SubPlan locks a name the Plan locks",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.Locks = &Locks{Names: []string{"cluster/east-1"}}
+				p.Blocks = []*Block{
+					{
+						Name: "block",
+						SubPlan: &Plan{
+							Name:   "sub",
+							Blocks: []*Block{{Name: "block", Locks: &Locks{Names: []string{"cluster/east-1"}}}},
+						},
+					},
+				}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: BlockConcurrency is negative",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.BlockConcurrency = -1
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: DependsOn a Key that is not a Block",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.Blocks = []*Block{{Name: "a", Key: NewV7(), DependsOn: []uuid.UUID{NewV7()}}}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: DependsOn itself",
+			plan: func() *Plan {
+				p := goodPlan()
+				key := NewV7()
+				p.Blocks = []*Block{{Name: "a", Key: key, DependsOn: []uuid.UUID{key}}}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: DependsOn has a cycle",
+			plan: func() *Plan {
+				p := goodPlan()
+				a, b, c := NewV7(), NewV7(), NewV7()
+				p.Blocks = []*Block{
+					{Name: "a", Key: a, DependsOn: []uuid.UUID{c}},
+					{Name: "b", Key: b, DependsOn: []uuid.UUID{a}},
+					{Name: "c", Key: c, DependsOn: []uuid.UUID{b}},
+				}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Success: DependsOn",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.BlockConcurrency = 2
+				p.Blocks = dagBlocks
+				return p
+			},
+			validators: append(append([]validator{}, expectVals[:5]...), dagBlocks[0], dagBlocks[1]),
+		},
 		{
 			name: "Error: Blocks is nil",
 			plan: func() *Plan {
@@ -547,6 +731,34 @@ func TestBlockValidate(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "Error: ToleratedFailurePercent is more than 100",
+			block: func() *Block {
+				b := goodBlock()
+				b.ToleratedFailurePercent = 101
+				return b
+			},
+			err: true,
+		},
+		{
+			name: "Error: ToleratedFailures and ToleratedFailurePercent are set",
+			block: func() *Block {
+				b := goodBlock()
+				b.ToleratedFailures = 1
+				b.ToleratedFailurePercent = 10
+				return b
+			},
+			err: true,
+		},
+		{
+			name: "Error: ToleratedFailureSample without ToleratedFailurePercent",
+			block: func() *Block {
+				b := goodBlock()
+				b.ToleratedFailureSample = 10
+				return b
+			},
+			err: true,
+		},
 		{
 			name: "Error: Sequences is empty",
 			block: func() *Block {
@@ -565,6 +777,156 @@ func TestBlockValidate(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "Error: Timeout is negative",
+			block: func() *Block {
+				b := goodBlock()
+				b.Timeout = -1
+				return b
+			},
+			err: true,
+		},
+		{
+			name: "Error: Timeout is shorter than EntranceDelay",
+			block: func() *Block {
+				b := goodBlock()
+				b.EntranceDelay = time.Minute
+				b.Timeout = time.Second
+				return b
+			},
+			err: true,
+		},
+		{
+			name: "Error: Timeout is shorter than ExitDelay",
+			block: func() *Block {
+				b := goodBlock()
+				b.ExitDelay = time.Minute
+				b.Timeout = time.Second
+				return b
+			},
+			err: true,
+		},
+		{
+			name: "Error: Timeout is shorter than EntranceDelay and ExitDelay combined",
+			block: func() *Block {
+				b := goodBlock()
+				b.EntranceDelay = 6 * time.Minute
+				b.ExitDelay = 6 * time.Minute
+				b.Timeout = 10 * time.Minute
+				return b
+			},
+			err: true,
+		},
+		{
+			name: "Error: Locks has an empty name",
+			block: func() *Block {
+				b := goodBlock()
+				b.Locks = &Locks{Names: []string{" "}}
+				return b
+			},
+			err: true,
+		},
+		{
+			name: "Error: Locks has a duplicate name",
+			block: func() *Block {
+				b := goodBlock()
+				b.Locks = &Locks{Names: []string{"host/abc", "host/abc"}}
+				return b
+			},
+			err: true,
+		},
+		{
+			name: "Error: Approval Timeout is negative",
+			block: func() *Block {
+				b := goodBlock()
+				b.Approval = &Approval{Timeout: -1}
+				return b
+			},
+			err: true,
+		},
+		{
+			name: "Error: Approval Status is set",
+			block: func() *Block {
+				b := goodBlock()
+				b.Approval = &Approval{Status: ASApproved}
+				return b
+			},
+			err: true,
+		},
+		{
+			name: "Error: Generator has Refs",
+			block: func() *Block {
+				b := goodBlock()
+				b.Generator = &Action{Refs: []Ref{{Key: NewV7(), Field: "Field"}}}
+				return b
+			},
+			err: true,
+		},
+		{
+			name: "Success: Generator without Sequences",
+			block: func() *Block {
+				b := goodBlock()
+				b.Sequences = nil
+				b.Generator = &Action{}
+				return b
+			},
+			vals: []validator{
+				goodBlock().BypassChecks,
+				goodBlock().PreChecks,
+				goodBlock().PostChecks,
+				goodBlock().ContChecks,
+				goodBlock().DeferredChecks,
+				&Action{},
+			},
+		},
+		{
+			name: "Error: SubPlan with Sequences",
+			block: func() *Block {
+				b := goodBlock()
+				b.SubPlan = &Plan{}
+				return b
+			},
+			err: true,
+		},
+		{
+			name: "Error: SubPlan with a Generator",
+			block: func() *Block {
+				b := goodBlock()
+				b.Sequences = nil
+				b.Generator = &Action{}
+				b.SubPlan = &Plan{}
+				return b
+			},
+			err: true,
+		},
+		{
+			name: "Error: SubPlan with a Rollback",
+			block: func() *Block {
+				b := goodBlock()
+				b.Sequences = nil
+				b.Rollback = []*Action{{}}
+				b.SubPlan = &Plan{}
+				return b
+			},
+			err: true,
+		},
+		{
+			name: "Success: SubPlan",
+			block: func() *Block {
+				b := goodBlock()
+				b.Sequences = nil
+				b.SubPlan = &Plan{Name: "sub"}
+				return b
+			},
+			vals: []validator{
+				goodBlock().BypassChecks,
+				goodBlock().PreChecks,
+				goodBlock().PostChecks,
+				goodBlock().ContChecks,
+				goodBlock().DeferredChecks,
+				&Plan{Name: "sub"},
+			},
+		},
 		{
 			name:    "Error: Duplicate Key",
 			block:   goodBlock,
@@ -689,6 +1051,24 @@ func TestSequenceValidate(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "Error: Locks has an invalid Policy",
+			sequence: func() *Sequence {
+				s := goodSequence()
+				s.Locks = &Locks{Names: []string{"host/abc"}, Policy: 1}
+				return s
+			},
+			err: true,
+		},
+		{
+			name: "Error: Timeout is negative",
+			sequence: func() *Sequence {
+				s := goodSequence()
+				s.Timeout = -1
+				return s
+			},
+			err: true,
+		},
 		{
 			name:     "Error: Duplicate Key",
 			sequence: goodSequence,
@@ -759,6 +1139,73 @@ func (v validatePlugin) ValidateReq(req any) error {
 	return nil
 }
 
+func TestExceedsToleratedFailures(t *testing.T) {
+	t.Parallel()
+
+	seqs := make([]*Sequence, 10)
+
+	tests := []struct {
+		name    string
+		block   *Block
+		failed  int
+		started int
+		want    bool
+	}{
+		{
+			name:    "ToleratedFailures not exceeded",
+			block:   &Block{ToleratedFailures: 2, Sequences: seqs},
+			failed:  2,
+			started: 2,
+		},
+		{
+			name:    "ToleratedFailures exceeded",
+			block:   &Block{ToleratedFailures: 2, Sequences: seqs},
+			failed:  3,
+			started: 3,
+			want:    true,
+		},
+		{
+			name:    "ToleratedFailures unlimited",
+			block:   &Block{ToleratedFailures: -1, Sequences: seqs},
+			failed:  10,
+			started: 10,
+		},
+		{
+			name:    "ToleratedFailurePercent of all Sequences not exceeded",
+			block:   &Block{ToleratedFailurePercent: 20, Sequences: seqs},
+			failed:  2,
+			started: 2,
+		},
+		{
+			name:    "ToleratedFailurePercent of all Sequences exceeded",
+			block:   &Block{ToleratedFailurePercent: 20, Sequences: seqs},
+			failed:  3,
+			started: 3,
+			want:    true,
+		},
+		{
+			name:    "ToleratedFailureSample not reached uses all Sequences",
+			block:   &Block{ToleratedFailurePercent: 20, ToleratedFailureSample: 5, Sequences: seqs},
+			failed:  2,
+			started: 4,
+		},
+		{
+			name:    "ToleratedFailureSample reached uses started",
+			block:   &Block{ToleratedFailurePercent: 20, ToleratedFailureSample: 5, Sequences: seqs},
+			failed:  2,
+			started: 5,
+			want:    true,
+		},
+	}
+
+	for _, test := range tests {
+		got := test.block.ExceedsToleratedFailures(test.failed, test.started)
+		if got != test.want {
+			t.Errorf("TestExceedsToleratedFailures(%s): got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
 func TestActionValidate(t *testing.T) {
 	t.Parallel()
 
@@ -844,6 +1291,24 @@ func TestActionValidate(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "Error: RetryPolicy is invalid",
+			action: func() *Action {
+				a := goodAction()
+				a.RetryPolicy = &exponential.Policy{InitialInterval: time.Second, Multiplier: 1, MaxInterval: time.Minute}
+				return a
+			},
+			err: true,
+		},
+		{
+			name: "Error: MaxElapsed is negative",
+			action: func() *Action {
+				a := goodAction()
+				a.MaxElapsed = -time.Second
+				return a
+			},
+			err: true,
+		},
 		{
 			name:    "Error: Duplicate Key",
 			action:  goodAction,
@@ -854,6 +1319,15 @@ func TestActionValidate(t *testing.T) {
 			name:   "Success",
 			action: goodAction,
 		},
+		{
+			name: "Success: RetryPolicy and MaxElapsed",
+			action: func() *Action {
+				a := goodAction()
+				a.RetryPolicy = &exponential.Policy{InitialInterval: time.Second, Multiplier: 2, MaxInterval: time.Minute}
+				a.MaxElapsed = time.Hour
+				return a
+			},
+		},
 	}
 
 	for _, test := range tests {